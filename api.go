@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/ochinchina/supervisord/faults"
+	"github.com/ochinchina/supervisord/types"
+)
+
+// ProcessAPI the JSON http interface to get the status of the programs and control them
+type ProcessAPI struct {
+	router     *mux.Router
+	supervisor *Supervisor
+}
+
+// NewProcessAPI create a ProcessAPI object
+func NewProcessAPI(supervisor *Supervisor) *ProcessAPI {
+	return &ProcessAPI{router: mux.NewRouter(), supervisor: supervisor}
+}
+
+// CreateHandler create the /api/ http handler
+func (api *ProcessAPI) CreateHandler() http.Handler {
+	api.router.HandleFunc("/api/processes", api.listProcesses).Methods("GET")
+	api.router.HandleFunc("/api/process/{name}", api.getProcess).Methods("GET")
+	api.router.HandleFunc("/api/process/{name}/start", api.startProcess).Methods("POST")
+	api.router.HandleFunc("/api/process/{name}/stop", api.stopProcess).Methods("POST")
+	api.router.HandleFunc("/api/process/{name}/restart", api.restartProcess).Methods("POST")
+	return api.router
+}
+
+func (api *ProcessAPI) listProcesses(w http.ResponseWriter, req *http.Request) {
+	reply := struct{ AllProcessInfo []types.ProcessInfo }{}
+	if err := api.supervisor.GetAllProcessInfo(req, nil, &reply); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeAPIResult(w, reply.AllProcessInfo)
+}
+
+func (api *ProcessAPI) getProcess(w http.ResponseWriter, req *http.Request) {
+	reply := struct{ ProcInfo types.ProcessInfo }{}
+	if err := api.supervisor.GetProcessInfo(req, &struct{ Name string }{mux.Vars(req)["name"]}, &reply); err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+	writeAPIResult(w, reply.ProcInfo)
+}
+
+func (api *ProcessAPI) startProcess(w http.ResponseWriter, req *http.Request) {
+	api.controlProcess(w, req, api.supervisor.StartProcess)
+}
+
+func (api *ProcessAPI) stopProcess(w http.ResponseWriter, req *http.Request) {
+	api.controlProcess(w, req, api.supervisor.StopProcess)
+}
+
+// restart the program by Supervisor.RestartProcess, which waits for the program to be stopped
+// before starting it again
+func (api *ProcessAPI) restartProcess(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	name := mux.Vars(req)["name"]
+	if api.supervisor.GetManager().Find(name) == nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("no process named %s", name))
+		return
+	}
+	reply := struct{ RPCTaskResults []RPCTaskResult }{}
+	if err := api.supervisor.RestartProcess(req, &StartProcessArgs{Name: name, Wait: true}, &reply); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, result := range reply.RPCTaskResults {
+		if result.Status != faults.Success {
+			writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("%s", result.Description))
+			return
+		}
+	}
+	api.getProcess(w, req)
+}
+
+// call the action on the program in the path and respond its process information
+func (api *ProcessAPI) controlProcess(w http.ResponseWriter, req *http.Request, action func(*http.Request, *StartProcessArgs, *struct{ Success bool }) error) {
+	defer req.Body.Close()
+	name := mux.Vars(req)["name"]
+	if api.supervisor.GetManager().Find(name) == nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("no process named %s", name))
+		return
+	}
+	reply := struct{ Success bool }{}
+	if err := action(req, &StartProcessArgs{Name: name, Wait: true}, &reply); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	api.getProcess(w, req)
+}
+
+func writeAPIResult(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
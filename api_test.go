@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ochinchina/supervisord/config"
+	"github.com/ochinchina/supervisord/process"
+	"github.com/ochinchina/supervisord/types"
+)
+
+func TestProcessAPI(t *testing.T) {
+	s := NewSupervisor("supervisord.conf")
+	entry := &config.Entry{ConfigDir: ".", Group: "web", Name: "program:api"}
+	s.GetManager().Add("api", process.NewProcess("supervisord", entry))
+	handler := NewProcessAPI(s).CreateHandler()
+
+	request := func(method string, path string, result interface{}) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		if err := json.NewDecoder(w.Body).Decode(result); err != nil {
+			t.Errorf("fail to decode the response of %s %s: %v", method, path, err)
+		}
+		return w.Code
+	}
+
+	var infos []types.ProcessInfo
+	if code := request("GET", "/api/processes", &infos); code != http.StatusOK || len(infos) != 1 || infos[0].Name != "api" || infos[0].Group != "web" {
+		t.Errorf("unexpected process list, status %d: %v", code, infos)
+	}
+
+	var info types.ProcessInfo
+	if code := request("POST", "/api/process/api/stop", &info); code != http.StatusOK || info.Name != "api" || info.State != int(process.Stopped) {
+		t.Errorf("unexpected process info after stop, status %d: %v", code, info)
+	}
+
+	var apiError map[string]string
+	if code := request("GET", "/api/process/missing", &apiError); code != http.StatusNotFound || apiError["error"] == "" {
+		t.Errorf("expect 404 with error for a missing process, status %d: %v", code, apiError)
+	}
+	if code := request("POST", "/api/process/missing/restart", &apiError); code != http.StatusNotFound {
+		t.Errorf("expect 404 to restart a missing process, status %d", code)
+	}
+}
+
+func TestProcessAPIRestart(t *testing.T) {
+	s := loadTestSupervisor(t, "[program:api]\ncommand=/bin/sleep 30\nstartsecs=0\n")
+	s.createPrograms(nil)
+	defer s.procMgr.StopAllProcesses()
+	proc := s.procMgr.Find("api")
+	proc.Start(true)
+	pid := proc.GetPid()
+	handler := NewProcessAPI(s).CreateHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/process/api/restart", nil))
+	var info types.ProcessInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("fail to decode the response: %v", err)
+	}
+	if w.Code != http.StatusOK || info.State != int(process.Running) || info.Pid == pid {
+		t.Errorf("expect the process is restarted with a new pid, status %d: %v", w.Code, info)
+	}
+}
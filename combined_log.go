@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+)
+
+// ProcessCombinedLogReadInfo the input argument to tail the stdout and stderr logs of a program
+// together. The two logs are read from their own offsets
+type ProcessCombinedLogReadInfo struct {
+	Name         string // the program name
+	StdoutOffset int    // the offset of the program stdout log
+	StderrOffset int    // the offset of the program stderr log
+	Length       int    // the length of log to read from each of stdout and stderr
+}
+
+// ProcessCombinedTailLog the output of tail the stdout and stderr logs of a program together.
+// Each line in LogData is prefixed with "stdout: " or "stderr: "
+type ProcessCombinedTailLog struct {
+	LogData      string
+	StdoutOffset int  // the offset of the stdout log to read next time
+	StderrOffset int  // the offset of the stderr log to read next time
+	Overflow     bool // true if the offset of any log is beyond its end
+	Binary       bool // true if the LogData is encoded with base64 because it is not valid UTF-8 text
+}
+
+// prefix each complete line of the log data read with length limit by the stream name. The
+// incomplete last line is left to the next read, unless the data is a single line longer than
+// the length limit.
+//
+// Return the marked lines and how many bytes of the data are consumed
+func markLogLines(stream string, data string, length int) (string, int) {
+	consumed := strings.LastIndexByte(data, '\n') + 1
+	if consumed == 0 && len(data) >= length {
+		consumed = len(data)
+	}
+	if consumed == 0 {
+		return "", 0
+	}
+	lines := strings.SplitAfter(data[:consumed], "\n")
+	var b strings.Builder
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		b.WriteString(stream)
+		b.WriteString(": ")
+		b.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), consumed
+}
@@ -2,14 +2,21 @@ package config
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"go.uber.org/zap"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ochinchina/go-ini"
 )
@@ -20,6 +27,14 @@ type Entry struct {
 	Group     string
 	Name      string
 	keyValues map[string]string
+	// the keys whose value is copied from the [program-default] section
+	defaultKeys map[string]bool
+	// the autostart set at runtime by SetAutostart: 0 if it is not set, 1 for true and -1 for false
+	autostartOverride int32
+	// the position of the program in the "programs" of its group section
+	groupOrder int
+	// the program name in the section header, shared by the numprocs processes of the program
+	sectionProgram string
 }
 
 // IsProgram return true if this is a program section
@@ -73,20 +88,97 @@ func (c *Entry) GetPrograms() []string {
 	return make([]string, 0)
 }
 
+// GetGroupStartOrder get the start order of the programs in the group from "group_start_order"
+func (c *Entry) GetGroupStartOrder() []string {
+	result := make([]string, 0)
+	if c.IsGroup() {
+		for _, p := range c.GetStringArray("group_start_order", ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
+
+// GetGroupOrder get the position of the program in the "programs" of its group section, the
+// programs not listed in a group section are at 0
+func (c *Entry) GetGroupOrder() int {
+	return c.groupOrder
+}
+
+// GetSectionProgramName get the program name in the [program:x] section header. It differs from
+// GetProgramName for the processes of a program with numprocs
+func (c *Entry) GetSectionProgramName() string {
+	if c.sectionProgram != "" {
+		return c.sectionProgram
+	}
+	return c.GetProgramName()
+}
+
 func (c *Entry) setGroup(group string) {
 	c.Group = group
 }
 
-// String dump the configuration as string
+// SetAutostart override the autostart of the program in memory. The configuration file is not
+// changed, so the override is lost when the configuration is reloaded
+func (c *Entry) SetAutostart(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&c.autostartOverride, 1)
+	} else {
+		atomic.StoreInt32(&c.autostartOverride, -1)
+	}
+}
+
+// GetAutostart get the autostart of the program, the value set by SetAutostart takes precedence
+// over the autostart item in the configuration
+func (c *Entry) GetAutostart() string {
+	switch atomic.LoadInt32(&c.autostartOverride) {
+	case 1:
+		return "true"
+	case -1:
+		return "false"
+	}
+	return c.GetString("autostart", "true")
+}
+
+// String dump the configuration as string, the items are sorted by key
 func (c *Entry) String() string {
+	keys := make([]string, 0, len(c.keyValues))
+	for k := range c.keyValues {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 	buf := bytes.NewBuffer(make([]byte, 0))
-	for k, v := range c.keyValues {
-		fmt.Fprintf(buf, "%s=%s\n", k, v)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s=%s\n", k, c.keyValues[k])
 	}
 	return buf.String()
 
 }
 
+// Hash return a digest of the configuration items of this entry. Two entries have the
+// same hash only if they have exactly the same items, so it is used to find the
+// programs changed by a reload. If keys are given, only these items are digested
+func (c *Entry) Hash(keys ...string) string {
+	if len(keys) <= 0 {
+		keys = make([]string, 0, len(c.keyValues))
+		for k := range c.keyValues {
+			keys = append(keys, k)
+		}
+	} else {
+		keys = append([]string(nil), keys...)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		if v, ok := c.keyValues[k]; ok {
+			fmt.Fprintf(h, "%s=%s\n", k, v)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Config memory reprentations of supervisor configuration file
 type Config struct {
 	configFile string
@@ -94,16 +186,23 @@ type Config struct {
 	entries map[string]*Entry
 
 	ProgramGroup *ProcessGroup
+	// the public key to verify the configuration files
+	pubKey ed25519.PublicKey
+	// the file to persist the runtime overrides of the programs, see overlay.go
+	overlayFile string
+	// process name => key => value loaded from the overlay file
+	overlay     map[string]map[string]string
+	overlayLock sync.Mutex
 }
 
 // NewEntry create a configuration entry
 func NewEntry(configDir string) *Entry {
-	return &Entry{configDir, "", "", make(map[string]string)}
+	return &Entry{ConfigDir: configDir, keyValues: make(map[string]string), defaultKeys: make(map[string]bool)}
 }
 
 // NewConfig create Config object
 func NewConfig(configFile string) *Config {
-	return &Config{configFile, make(map[string]*Entry), NewProcessGroup()}
+	return &Config{configFile: configFile, entries: make(map[string]*Entry), ProgramGroup: NewProcessGroup()}
 }
 
 //create a new entry or return the already-exist entry
@@ -118,19 +217,66 @@ func (c *Config) createEntry(name string, configDir string) *Entry {
 }
 
 //
-// Load load the configuration and return the loaded programs
+// Load load the configuration and return the loaded programs.
+// If the configuration file or one of the included files can't be read or verified, any resolved
+// process/group name is invalid, or the programs depend on each other in a cycle by depends_on, an
+// error is returned and the previous loaded configuration is kept
 func (c *Config) Load() ([]string, error) {
 	ini := ini.NewIni()
-	c.ProgramGroup = NewProcessGroup()
+	// the file defines each program
+	programFiles := make(map[string]string)
 	zap.S().Infow("load configuration from file", "file", c.configFile)
-	ini.LoadFile(c.configFile)
+	if err := c.loadIniFile(ini, c.configFile, programFiles); err != nil {
+		return nil, err
+	}
 
 	includeFiles := c.getIncludeFiles(ini)
 	for _, f := range includeFiles {
-		zap.S().Info("load configuration from file", "file", f)
-		ini.LoadFile(f)
+		zap.S().Infow("load configuration from file", "file", f)
+		if err := c.loadIniFile(ini, f, programFiles); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.checkProcessNames(ini); err != nil {
+		return nil, err
+	}
+	if err := c.checkDependsOnCycles(ini); err != nil {
+		return nil, err
 	}
-	return c.parse(ini), nil
+	// all the files are read, it is safe to replace the previous loaded configuration now
+	c.ProgramGroup = NewProcessGroup()
+	c.loadOverlay(ini)
+	loadedPrograms := c.parse(ini)
+	for _, problem := range c.Validate(false) {
+		zap.S().Warnw("invalid configuration", "problem", problem)
+	}
+	return loadedPrograms, nil
+}
+
+// read the configuration file and load it to the ini. Unlike ini.LoadFile(), an error
+// is returned if the file can't be read or its signature can't be verified, or it defines
+// a program already defined in another file. programFiles records the file of each program
+func (c *Config) loadIniFile(cfg *ini.Ini, fileName string, programFiles map[string]string) error {
+	content, err := readConfigFile(fileName)
+	if err != nil {
+		return fmt.Errorf("fail to read configuration file %s: %v", fileName, err)
+	}
+	if err = c.verifySignature(fileName, content); err != nil {
+		return err
+	}
+	fileIni := ini.NewIni()
+	fileIni.LoadBytes(content)
+	for _, section := range fileIni.Sections() {
+		if !strings.HasPrefix(section.Name, "program:") {
+			continue
+		}
+		if prevFile, ok := programFiles[section.Name]; ok && prevFile != fileName {
+			return fmt.Errorf("duplicate program %s is defined in both %s and %s", section.Name[len("program:"):], prevFile, fileName)
+		}
+		programFiles[section.Name] = fileName
+	}
+	cfg.LoadBytes(content)
+	return nil
 }
 
 func (c *Config) getIncludeFiles(cfg *ini.Ini) []string {
@@ -169,23 +315,26 @@ func (c *Config) getIncludeFiles(cfg *ini.Ini) []string {
 }
 
 func (c *Config) parse(cfg *ini.Ini) []string {
-	c.setProgramDefaultParams(cfg)
+	defaultKeys := c.setProgramDefaultParams(cfg)
 	c.parseGroup(cfg)
-	loadedPrograms := c.parseProgram(cfg)
+	loadedPrograms := c.parseProgram(cfg, defaultKeys)
 
 	//parse non-group,non-program and non-eventlistener sections
 	for _, section := range cfg.Sections() {
 		if !strings.HasPrefix(section.Name, "group:") && !strings.HasPrefix(section.Name, "program:") && !strings.HasPrefix(section.Name, "eventlistener:") {
 			entry := c.createEntry(section.Name, c.GetConfigFileDir())
 			c.entries[section.Name] = entry
-			entry.parse(section)
+			entry.parse(section, nil)
 		}
 	}
 	return loadedPrograms
 }
 
-// set the default parameteres of programs
-func (c *Config) setProgramDefaultParams(cfg *ini.Ini) {
+// set the default parameteres of programs.
+//
+// Return the keys set from the [program-default] section for each program section
+func (c *Config) setProgramDefaultParams(cfg *ini.Ini) map[string]map[string]bool {
+	defaultKeys := make(map[string]map[string]bool)
 	program_default_section, err := cfg.GetSection("program-default")
 	if err == nil {
 		for _, section := range cfg.Sections() {
@@ -195,15 +344,32 @@ func (c *Config) setProgramDefaultParams(cfg *ini.Ini) {
 			for _, key := range program_default_section.Keys() {
 				if !section.HasKey(key.Name()) {
 					section.Add(key.Name(), key.ValueWithDefault(""))
+					if _, ok := defaultKeys[section.Name]; !ok {
+						defaultKeys[section.Name] = make(map[string]bool)
+					}
+					defaultKeys[section.Name][key.Name()] = true
 				}
 			}
 
 		}
 	}
+	return defaultKeys
+}
+
+// GetConfigFile get the supervisor configuration file
+func (c *Config) GetConfigFile() string {
+	return c.configFile
 }
 
-// GetConfigFileDir get the directory of supervisor configuration file
+// GetConfigFileDir get the directory of supervisor configuration file. It is the current
+// directory if the configuration is read from a URL or stdin
 func (c *Config) GetConfigFileDir() string {
+	if IsRemoteConfigFile(c.configFile) {
+		if dir, err := os.Getwd(); err == nil {
+			return dir
+		}
+		return "."
+	}
 	return filepath.Dir(c.configFile)
 }
 
@@ -313,6 +479,33 @@ func (c *Entry) GetBool(key string, defValue bool) bool {
 	return defValue
 }
 
+// GetDependsOn get the programs this program depends on from "depends_on", a program with
+// numprocs stands for all its processes
+func (c *Entry) GetDependsOn() []string {
+	result := make([]string, 0)
+	for _, p := range c.GetStringArray("depends_on", ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// GetLabels get the labels of the program from "labels" in format "key1=value1,key2=value2"
+func (c *Entry) GetLabels() map[string]string {
+	result := make(map[string]string)
+	for _, label := range c.GetStringArray("labels", ",") {
+		pos := strings.Index(label, "=")
+		if pos == -1 {
+			continue
+		}
+		if key := strings.TrimSpace(label[0:pos]); key != "" {
+			result[key] = strings.TrimSpace(label[pos+1:])
+		}
+	}
+	return result
+}
+
 // HasParameter check if has parameter
 func (c *Entry) HasParameter(key string) bool {
 	_, ok := c.keyValues[key]
@@ -337,6 +530,24 @@ func (c *Entry) GetInt(key string, defValue int) int {
 	return defValue
 }
 
+// GetDuration get the value of the key as duration. The value is a duration string like
+// "500ms", "2s" or "1m", or an integer in seconds
+func (c *Entry) GetDuration(key string, defValue time.Duration) time.Duration {
+	value, ok := c.keyValues[key]
+	if !ok {
+		return defValue
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		zap.S().Warnw("invalid duration", "key", key, "value", value, "error", err)
+		return defValue
+	}
+	return d
+}
+
 func parseEnv(s string) *map[string]string {
 	result := make(map[string]string)
 	start := 0
@@ -379,23 +590,55 @@ func parseEnv(s string) *map[string]string {
 
 // GetEnv get the value of key as environment setting. An environment string example:
 //  environment = A="env 1",B="this is a test"
+//
+// The "%(file:path)s" is not replaced by the content of the file, so the secrets read from the files are never shown
 func (c *Entry) GetEnv(key string) []string {
+	result, _ := c.getEnv(key, false)
+	return result
+}
+
+// GetEnvWithFiles get the value of key as environment setting like GetEnv, and "%(file:path)s" is
+// replaced by the trimmed content of the file. It is called when the program is started, so the
+// secrets never sit in the configuration. An error is returned if any file can't be read
+func (c *Entry) GetEnvWithFiles(key string) ([]string, error) {
+	return c.getEnv(key, true)
+}
+
+func (c *Entry) getEnv(key string, readFiles bool) ([]string, error) {
 	value, ok := c.keyValues[key]
 	result := make([]string, 0)
 
 	if ok {
-		for k, v := range *parseEnv(value) {
-			tmp, err := NewStringExpression("program_name", c.GetProgramName(),
+		env := *parseEnv(value)
+		// sort the variables so the environment is same every time the program is started
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := env[k]
+			expr := NewStringExpression("program_name", c.GetProgramName(),
 				"process_num", c.GetString("process_num", "0"),
 				"group_name", c.GetGroupName(),
-				"here", c.ConfigDir).Eval(fmt.Sprintf("%s=%s", k, v))
+				"here", c.ConfigDir)
+			if readFiles {
+				expr.EnableFileSource()
+			}
+			tmp, err := expr.Eval(fmt.Sprintf("%s=%s", k, v))
 			if err == nil {
 				result = append(result, tmp)
+			} else if _, ok := err.(*fileSourceError); ok {
+				if readFiles {
+					return nil, fmt.Errorf("fail to set environment variable %s: %v", k, err)
+				}
+				// show the variable as it is configured
+				result = append(result, fmt.Sprintf("%s=%s", k, v))
 			}
 		}
 	}
 
-	return result
+	return result, nil
 }
 
 // GetString get the value of key as string
@@ -480,11 +723,49 @@ func (c *Entry) GetBytes(key string, defValue int) int {
 	return defValue
 }
 
-func (c *Entry) parse(section *ini.Section) {
+// GetOctal get the value of key as an octal integer like 022, defValue is returned if the value
+// is not a valid octal integer
+func (c *Entry) GetOctal(key string, defValue int) int {
+	v, ok := c.keyValues[key]
+	if ok {
+		i, err := strconv.ParseInt(v, 8, 32)
+		if err == nil && i >= 0 {
+			return int(i)
+		}
+	}
+	return defValue
+}
+
+// GetPercent get the value of key as a non-negative percentage, the % suffix is optional.
+//
+//	cpu_quota=50%
+//	cpu_quota=200
+//
+func (c *Entry) GetPercent(key string, defValue float64) float64 {
+	v, ok := c.keyValues[key]
+	if ok {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(v, "%")), 64)
+		if err == nil && percent >= 0 {
+			return percent
+		}
+	}
+	return defValue
+}
+
+// parse the items of the section, the items in overlay override the ones in the section
+func (c *Entry) parse(section *ini.Section, overlay map[string]string) {
 	c.Name = section.Name
+	// the entry is reused on reload, so the items removed from the section must not be kept
+	keyValues := make(map[string]string)
 	for _, key := range section.Keys() {
-		c.keyValues[key.Name()] = strings.TrimSpace(key.ValueWithDefault(""))
+		keyValues[key.Name()] = strings.TrimSpace(key.ValueWithDefault(""))
 	}
+	for key, value := range overlay {
+		keyValues[key] = value
+	}
+	c.keyValues = keyValues
+	// the autostart set at runtime does not survive the reload
+	atomic.StoreInt32(&c.autostartOverride, 0)
 }
 
 func (c *Config) parseGroup(cfg *ini.Ini) {
@@ -493,7 +774,7 @@ func (c *Config) parseGroup(cfg *ini.Ini) {
 	for _, section := range cfg.Sections() {
 		if strings.HasPrefix(section.Name, "group:") {
 			entry := c.createEntry(section.Name, c.GetConfigFileDir())
-			entry.parse(section)
+			entry.parse(section, nil)
 			groupName := entry.GetGroupName()
 			programs := entry.GetPrograms()
 			for _, program := range programs {
@@ -503,6 +784,18 @@ func (c *Config) parseGroup(cfg *ini.Ini) {
 	}
 }
 
+// get the position of the program in the "programs" of the group section, 0 if it is not listed
+func (c *Config) getGroupOrder(group string, programName string) int {
+	if entry, ok := c.entries["group:"+group]; ok {
+		for i, program := range entry.GetPrograms() {
+			if program == programName {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
 func (c *Config) isProgramOrEventListener(section *ini.Section) (bool, string) {
 	//check if it is a program or event listener section
 	isProgram := strings.HasPrefix(section.Name, "program:")
@@ -519,24 +812,20 @@ func (c *Config) isProgramOrEventListener(section *ini.Section) (bool, string) {
 // parse the sections starts with "program:" prefix.
 //
 // Return all the parsed program names in the ini
-func (c *Config) parseProgram(cfg *ini.Ini) []string {
+func (c *Config) parseProgram(cfg *ini.Ini, defaultKeys map[string]map[string]bool) []string {
 	loadedPrograms := make([]string, 0)
 	for _, section := range cfg.Sections() {
 		programOrEventListener, prefix := c.isProgramOrEventListener(section)
 
 		//if it is program or event listener
 		if programOrEventListener {
-			//get the number of processes
-			numProcs, err := section.GetInt("numprocs")
 			programName := section.Name[len(prefix):]
-			if err != nil {
-				numProcs = 1
-			}
+			processNums := getProcessNums(section)
 			procName, err := section.GetValue("process_name")
-			if numProcs > 1 {
+			if len(processNums) > 1 {
 				if err != nil || strings.Index(procName, "%(process_num)") == -1 {
 					zap.S().Errorw("no process_num in process name",
-						"numprocs", numProcs,
+						"numprocs", len(processNums),
 						"process_name", procName)
 				}
 			}
@@ -547,17 +836,8 @@ func (c *Config) parseProgram(cfg *ini.Ini) []string {
 
 			originalCmd := section.GetValueWithDefault("command", "")
 
-			for i := 1; i <= numProcs; i++ {
-				envs := NewStringExpression("program_name", programName,
-					"process_num", fmt.Sprintf("%d", i),
-					"group_name", c.ProgramGroup.GetGroup(programName, programName),
-					"here", c.GetConfigFileDir())
-				envValue, err := section.GetValue("environment")
-				if err == nil {
-					for k, v := range *parseEnv(envValue) {
-						envs.Add(fmt.Sprintf("ENV_%s", k), v)
-					}
-				}
+			for _, i := range processNums {
+				envs := c.newProcessExpression(section, programName, c.ProgramGroup.GetGroup(programName, programName), i)
 				cmd, err := envs.Eval(originalCmd)
 				if err != nil {
 					zap.S().Errorw("get envs failed",
@@ -576,13 +856,21 @@ func (c *Config) parseProgram(cfg *ini.Ini) []string {
 				}
 
 				section.Add("process_name", procName)
-				section.Add("numprocs_start", fmt.Sprintf("%d", (i-1)))
 				section.Add("process_num", fmt.Sprintf("%d", i))
 				entry := c.createEntry(procName, c.GetConfigFileDir())
-				entry.parse(section)
+				var overlay map[string]string
+				if prefix == "program:" {
+					overlay = c.getProgramOverlay(procName)
+				}
+				entry.parse(section, overlay)
+				entry.defaultKeys = defaultKeys[section.Name]
 				entry.Name = prefix + procName
 				group := c.ProgramGroup.GetGroup(programName, programName)
 				entry.Group = group
+				entry.groupOrder = c.getGroupOrder(group, programName)
+				if prefix == "program:" {
+					entry.sectionProgram = programName
+				}
 				loadedPrograms = append(loadedPrograms, procName)
 			}
 		}
@@ -590,6 +878,39 @@ func (c *Config) parseProgram(cfg *ini.Ini) []string {
 	return loadedPrograms
 }
 
+// get the process numbers of the numprocs processes of a program. The process numbers start from
+// numprocs_start if it is set, otherwise from 1
+func getProcessNums(section *ini.Section) []int {
+	numProcs, err := section.GetInt("numprocs")
+	if err != nil || numProcs < 1 {
+		numProcs = 1
+	}
+	start, err := section.GetInt("numprocs_start")
+	if err != nil {
+		start = 1
+	}
+	processNums := make([]int, numProcs)
+	for i := range processNums {
+		processNums[i] = start + i
+	}
+	return processNums
+}
+
+// create the expression to evaluate the command and process name of the processNum-th process of a program
+func (c *Config) newProcessExpression(section *ini.Section, programName string, group string, processNum int) *StringExpression {
+	envs := NewStringExpression("program_name", programName,
+		"process_num", fmt.Sprintf("%d", processNum),
+		"group_name", group,
+		"here", c.GetConfigFileDir())
+	envValue, err := section.GetValue("environment")
+	if err == nil {
+		for k, v := range *parseEnv(envValue) {
+			envs.Add(fmt.Sprintf("ENV_%s", k), v)
+		}
+	}
+	return envs
+}
+
 // String convert the configuration to string represents
 func (c *Config) String() string {
 	buf := bytes.NewBuffer(make([]byte, 0))
@@ -600,6 +921,24 @@ func (c *Config) String() string {
 	return buf.String()
 }
 
+// UpdateProgram set the configuration of a program to the entry loaded by another Config. The
+// existing entry is updated in place, so the process created from it uses the new configuration.
+// The entry is returned if the program is not in this configuration yet
+func (c *Config) UpdateProgram(entry *Entry) *Entry {
+	existing := c.GetProgram(entry.GetProgramName())
+	if existing == nil {
+		c.entries[entry.GetProgramName()] = entry
+		return entry
+	}
+	existing.ConfigDir = entry.ConfigDir
+	existing.Group = entry.Group
+	existing.Name = entry.Name
+	existing.keyValues = entry.keyValues
+	existing.defaultKeys = entry.defaultKeys
+	atomic.StoreInt32(&existing.autostartOverride, atomic.LoadInt32(&entry.autostartOverride))
+	return existing
+}
+
 // RemoveProgram remove a program entry by its name
 func (c *Config) RemoveProgram(programName string) {
 	delete(c.entries, programName)
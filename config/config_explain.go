@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the sources of a program setting
+const (
+	SourceProgram        = "program"         // set in the [program:x] section
+	SourceProgramDefault = "program-default" // copied from the [program-default] section
+	SourceBuiltin        = "built-in"        // not set, the built-in default value is used
+	SourceUnset          = "unset"           // not set and there is no built-in default value
+)
+
+// the built-in default values of the program settings
+var builtinDefaults = map[string]string{"autostart": "true",
+	"autorestart":             "unexpected",
+	"exitcodes":               "0,2",
+	"numprocs":                "1",
+	"priority":                "999",
+	"startsecs":               "1",
+	"startretries":            "3",
+	"restartpause":            "0",
+	"stopsignal":              "TERM",
+	"stopwaitsecs":            "10",
+	"stopasgroup":             "false",
+	"killasgroup":             "false",
+	"redirect_stderr":         "false",
+	"stdout_logfile_maxbytes": "50MB",
+	"stdout_logfile_backups":  "10",
+	"stderr_logfile_maxbytes": "50MB",
+	"stderr_logfile_backups":  "10",
+	"stdout_capture_maxbytes": "0",
+	"stderr_capture_maxbytes": "0",
+	"stdout_events_enabled":   "false",
+	"stderr_events_enabled":   "false"}
+
+// ExplainProgramSetting get the effective value of key for the program and where the value comes from.
+// The source is one of SourceProgram, SourceProgramDefault, SourceBuiltin and SourceUnset
+func (c *Config) ExplainProgramSetting(name string, key string) (value string, source string, err error) {
+	entry := c.GetProgram(name)
+	if entry == nil {
+		return "", "", fmt.Errorf("no program %s is found", name)
+	}
+	if _, ok := entry.keyValues[key]; ok {
+		if entry.defaultKeys[key] {
+			return entry.GetString(key, ""), SourceProgramDefault, nil
+		}
+		return entry.GetString(key, ""), SourceProgram, nil
+	}
+	if value, ok := builtinDefaults[key]; ok {
+		return value, SourceBuiltin, nil
+	}
+	return "", SourceUnset, nil
+}
+
+// GetEffectiveSettings get the effective settings of the program or event listener: the settings
+// in its section or copied from [program-default] with the %(...)s expressions expanded, and the
+// built-in default values of the settings not set. The environment is the expanded variables
+// separated by comma
+func (c *Entry) GetEffectiveSettings() map[string]string {
+	settings := make(map[string]string)
+	for key, value := range builtinDefaults {
+		settings[key] = value
+	}
+	for key := range c.keyValues {
+		if key == "environment" {
+			settings[key] = strings.Join(c.GetEnv(key), ",")
+		} else {
+			settings[key] = c.GetStringExpression(key, "")
+		}
+	}
+	return settings
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+)
+
+// the suffix of the detached signature file of a configuration file
+const signatureFileSuffix = ".sig"
+
+// LoadPublicKey load the ed25519 public key to verify the configuration files. The key file
+// can be a PEM encoded PKIX public key, the base64 encoded key or the raw 32 bytes key
+func LoadPublicKey(keyFile string) (ed25519.PublicKey, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read public key file %s: %v", keyFile, err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse public key file %s: %v", keyFile, err)
+		}
+		if pubKey, ok := key.(ed25519.PublicKey); ok {
+			return pubKey, nil
+		}
+		return nil, fmt.Errorf("public key in %s is not an ed25519 key", keyFile)
+	}
+	data = decodeKeyData(data)
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key in %s is not an ed25519 key", keyFile)
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// SetPublicKey set the public key to verify the configuration file and the included files.
+// Each file must have a detached signature in the file with ".sig" suffix if the key is set
+func (c *Config) SetPublicKey(pubKey ed25519.PublicKey) {
+	c.pubKey = pubKey
+}
+
+// GetPublicKey get the public key to verify the configuration files, nil if no key is set
+func (c *Config) GetPublicKey() ed25519.PublicKey {
+	return c.pubKey
+}
+
+// verify the content of configuration file with its detached signature file
+func (c *Config) verifySignature(fileName string, content []byte) error {
+	if c.pubKey == nil {
+		return nil
+	}
+	sigFile := fileName + signatureFileSuffix
+	sig, err := readConfigFile(sigFile)
+	if err != nil {
+		return fmt.Errorf("fail to read signature file %s: %v", sigFile, err)
+	}
+	if !ed25519.Verify(c.pubKey, content, decodeKeyData(sig)) {
+		return fmt.Errorf("signature of configuration file %s does not match", fileName)
+	}
+	return nil
+}
+
+// decode the base64 encoded key or signature, the data is returned as is if it is not base64 encoded
+func decodeKeyData(data []byte) []byte {
+	s := string(bytes.TrimSpace(data))
+	if decoded, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return decoded
+	}
+	return data
+}
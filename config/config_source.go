@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the configuration is read from stdin if the configuration file is "-"
+const stdinConfigFile = "-"
+
+// the timeout to fetch the configuration from a URL
+var configFetchTimeout = 30 * time.Second
+
+// stdin can be read only once, so the configuration read from it is kept for reload
+var stdinConfig struct {
+	once    sync.Once
+	content []byte
+	err     error
+}
+
+// IsRemoteConfigFile check if the configuration file is not a local file but a http(s) URL or "-"
+// for stdin
+func IsRemoteConfigFile(configFile string) bool {
+	return isConfigURL(configFile) || configFile == stdinConfigFile
+}
+
+func isConfigURL(configFile string) bool {
+	return strings.HasPrefix(configFile, "http://") || strings.HasPrefix(configFile, "https://")
+}
+
+// read the configuration file. The configuration is fetched if the file is a http(s) URL, and it is
+// read from stdin if the file is "-". The URL is fetched again on each call, so a reload picks up the
+// remote changes, but stdin is read only the first time
+func readConfigFile(configFile string) ([]byte, error) {
+	if isConfigURL(configFile) {
+		return fetchConfigURL(configFile)
+	}
+	if configFile == stdinConfigFile {
+		stdinConfig.once.Do(func() {
+			stdinConfig.content, stdinConfig.err = ioutil.ReadAll(os.Stdin)
+		})
+		return stdinConfig.content, stdinConfig.err
+	}
+	return ioutil.ReadFile(configFile)
+}
+
+func fetchConfigURL(url string) ([]byte, error) {
+	client := &http.Client{Timeout: configFetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected http status %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadConfigFromURL(t *testing.T) {
+	command := "/bin/ls"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/supervisord.conf" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "[program:test]\ncommand=%s\n", command)
+	}))
+	defer server.Close()
+
+	config := NewConfig(server.URL + "/supervisord.conf")
+	if _, err := config.Load(); err != nil {
+		t.Fatalf("Fail to load the configuration from URL: %v", err)
+	}
+	if config.GetProgram("test").GetString("command", "") != "/bin/ls" {
+		t.Error("Unexpected command of the program loaded from URL")
+	}
+
+	// the URL is fetched again on reload
+	command = "/bin/cat"
+	if _, err := config.Load(); err != nil || config.GetProgram("test").GetString("command", "") != "/bin/cat" {
+		t.Errorf("The changed configuration is not fetched on reload, error: %v", err)
+	}
+
+	if _, err := NewConfig(server.URL + "/missing.conf").Load(); err == nil {
+		t.Error("Load should fail if the URL responds with an error")
+	}
+	server.Close()
+	if _, err := NewConfig(server.URL + "/supervisord.conf").Load(); err == nil {
+		t.Error("Load should fail if the URL is unreachable")
+	}
+}
@@ -1,12 +1,15 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 func createTmpFile() (string, error) {
@@ -110,6 +113,24 @@ func TestGetBytesFromConfig(t *testing.T) {
 
 }
 
+func TestGetDurationFromConfig(t *testing.T) {
+	config, _ := parse([]byte("[program:test]\nA=3\nB=500ms\nC=2m\nD=test"))
+	entry := config.GetProgram("test")
+
+	if entry.GetDuration("A", 0) != 3*time.Second || entry.GetDuration("B", 0) != 500*time.Millisecond || entry.GetDuration("C", 0) != 2*time.Minute || entry.GetDuration("D", time.Second) != time.Second || entry.GetDuration("E", time.Hour) != time.Hour {
+		t.Error("Fail to get duration")
+	}
+}
+
+func TestGetPercentFromConfig(t *testing.T) {
+	config, _ := parse([]byte("[program:test]\nA=50%\nB=150\nC=-10%\nD=test"))
+	entry := config.GetProgram("test")
+
+	if entry.GetPercent("A", 0) != 50 || entry.GetPercent("B", 0) != 150 || entry.GetPercent("C", -1) != -1 || entry.GetPercent("D", -1) != -1 || entry.GetPercent("E", 0) != 0 {
+		t.Error("Fail to get percent")
+	}
+}
+
 func TestGetUnitHttpServer(t *testing.T) {
 	config, _ := parse([]byte("[program:test]\nA=1024\nB=2KB\nC=3MB\nD=4GB\nE=test\n[unix_http_server]\n"))
 
@@ -182,6 +203,28 @@ func TestConfigWithInclude(t *testing.T) {
 
 }
 
+func TestLoadRejectDuplicateProgramInIncludes(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tmp")
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "supervisord.conf"), []byte("[include]\nfiles=conf.d/*.conf"), os.ModePerm)
+	os.Mkdir(filepath.Join(dir, "conf.d"), os.ModePerm)
+	ioutil.WriteFile(filepath.Join(dir, "conf.d", "a.conf"), []byte("[program:web]\ncommand=ls\n"), os.ModePerm)
+	config := NewConfig(filepath.Join(dir, "supervisord.conf"))
+	if _, err := config.Load(); err != nil || config.GetProgram("web") == nil {
+		t.Fatalf("Fail to load the included program, error: %v", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "conf.d", "b.conf"), []byte("[program:web]\ncommand=pwd\n"), os.ModePerm)
+	_, err := config.Load()
+	if err == nil || !strings.Contains(err.Error(), "a.conf") || !strings.Contains(err.Error(), "b.conf") {
+		t.Errorf("Load should fail with both files of the duplicate program, error: %v", err)
+	}
+	if config.GetProgram("web").GetString("command", "") != "ls" {
+		t.Error("The previous loaded configuration is not kept")
+	}
+}
+
 func TestDefaultParams(t *testing.T) {
 	s := "[program:test]\nautorestart=true\ntest=1\n[program-default]\ncommand=/usr/bin/ls\nrestart=true\nautorestart=false"
 	config, _ := parse([]byte(s))
@@ -201,3 +244,273 @@ func TestDefaultParams(t *testing.T) {
 	}
 
 }
+
+func TestValidateProgramConfig(t *testing.T) {
+	problems := ValidateProgramConfig("[program:test]\ncommand=/bin/ls\nstartsecs=5\nstopsignal=TERM KILL\n", ".")
+	if len(problems) != 0 {
+		t.Errorf("Expect no problem but get %v", problems)
+	}
+
+	problems = ValidateProgramConfig("[program:test]\nstartsecs=abc\nstopsignal=FOO\nautorestart=yes\ndirectory=/not/exist/dir\n", ".")
+	if len(problems) != 5 {
+		t.Errorf("Expect 5 problems but get %v", problems)
+	}
+
+	problems = ValidateProgramConfig("[program:test]\ncommand=/bin/ls\nstartretries=infinite\n[program:test2]\ncommand=/bin/ls\nstartretries=-1\n[program:test3]\ncommand=/bin/ls\nstartretries=forever\n", ".")
+	if len(problems) != 1 || !strings.Contains(problems[0], "startretries=forever") {
+		t.Errorf("Expect only the problem of startretries=forever but get %v", problems)
+	}
+
+	problems = ValidateProgramConfig("[program:test]\ncommand=/bin/ls\nautostart=host:web*\n[program:test2]\ncommand=/bin/ls\nautostart=host:[web\n[program:test3]\ncommand=/bin/ls\nautostart=web*\n", ".")
+	if len(problems) != 2 || !strings.Contains(problems[0]+problems[1], "invalid host pattern") {
+		t.Errorf("Expect the problems of the invalid autostart but get %v", problems)
+	}
+
+	problems = ValidateProgramConfig("[supervisord]\nlogfile=test.log\n", ".")
+	if len(problems) != 1 {
+		t.Errorf("Expect a problem for missing program but get %v", problems)
+	}
+}
+
+func TestLoadKeepPreviousConfigWhenFileMissing(t *testing.T) {
+	fileName, err := saveToTmpFile([]byte("[program:test]\ncommand=/bin/ls"))
+	if err != nil {
+		t.Fatal("Fail to create the configuration file")
+	}
+	config := NewConfig(fileName)
+	if _, err := config.Load(); err != nil {
+		t.Fatal("Fail to load the configuration file")
+	}
+	os.Remove(fileName)
+
+	if _, err := config.Load(); err == nil {
+		t.Error("Load should fail if the configuration file is missing")
+	}
+	if config.GetProgram("test") == nil || config.ProgramGroup.GetGroup("test", "") != "test" {
+		t.Error("The previous loaded configuration should be kept")
+	}
+}
+
+func TestLoadRejectInvalidProcessName(t *testing.T) {
+	_, err := parse([]byte("[supervisord]\nmax_process_name_length=10\n[program:test]\ncommand=/bin/ls\nnumprocs=2\nprocess_name=%(program_name)s_long_%(process_num)d\n"))
+	if err == nil {
+		t.Error("Load should fail if the process name is too long")
+	}
+
+	_, err = parse([]byte("[program:test]\ncommand=/bin/ls\nprocess_name=a/%(program_name)s\nstdout_logfile=/tmp/%(program_name)s.log\n"))
+	if err == nil {
+		t.Error("Load should fail if the process name used in logfile includes a path separator")
+	}
+
+	_, err = parse([]byte("[program-default]\nstdout_logfile=/tmp/%(program_name)s.log\n[program:test]\ncommand=/bin/ls\nprocess_name=a/%(program_name)s\n"))
+	if err == nil {
+		t.Error("Load should fail if the process name used in the logfile of program-default includes a path separator")
+	}
+
+	config, err := parse([]byte("[program:test]\ncommand=/bin/ls\nprocess_name=a/%(program_name)s\n"))
+	if err != nil || config.GetProgram("a/test") == nil {
+		t.Errorf("The process name is not used in logfile and should be accepted, error: %v", err)
+	}
+}
+
+func TestNumprocsProcessNames(t *testing.T) {
+	config, err := parse([]byte("[program:worker]\ncommand=/bin/worker --id %(process_num)d\nnumprocs=3\nnumprocs_start=8\nprocess_name=%(program_name)s_%(process_num)02d\n"))
+	if err != nil {
+		t.Fatalf("Fail to load the numprocs program: %v", err)
+	}
+	for _, num := range []string{"08", "09", "10"} {
+		entry := config.GetProgram("worker_" + num)
+		if entry == nil {
+			t.Errorf("No process worker_%s is created", num)
+			continue
+		}
+		if cmd := entry.GetString("command", ""); cmd != "/bin/worker --id "+strings.TrimPrefix(num, "0") {
+			t.Errorf("Unexpected command %s of worker_%s", cmd, num)
+		}
+	}
+	if len(config.GetPrograms()) != 3 {
+		t.Errorf("Expect 3 processes but get %d", len(config.GetPrograms()))
+	}
+
+	if _, err = parse([]byte("[program:worker]\ncommand=/bin/worker\nnumprocs=2\n")); err == nil {
+		t.Error("Load should fail if the processes have the same name")
+	}
+}
+
+func TestLoadRejectDependsOnCycle(t *testing.T) {
+	_, err := parse([]byte("[program:a]\ncommand=/bin/ls\ndepends_on=b\n[program:b]\ncommand=/bin/ls\ndepends_on=c, d\n[program:c]\ncommand=/bin/ls\ndepends_on=a\n[program:d]\ncommand=/bin/ls\n"))
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("Load should fail with the cycle of depends_on, error: %v", err)
+	}
+
+	_, err = parse([]byte("[program:a]\ncommand=/bin/ls\ndepends_on=a\n"))
+	if err == nil {
+		t.Error("Load should fail if a program depends on itself")
+	}
+
+	_, err = parse([]byte("[program:web]\ncommand=/bin/ls\nnumprocs=2\nprocess_name=%(program_name)s_%(process_num)d\ndepends_on=proxy\n[program:proxy]\ncommand=/bin/ls\ndepends_on=web\n"))
+	if err == nil || !strings.Contains(err.Error(), "proxy -> web_1 -> proxy") {
+		t.Errorf("Load should fail with the cycle through the processes of a numprocs program, error: %v", err)
+	}
+
+	config, err := parse([]byte("[program:a]\ncommand=/bin/ls\ndepends_on=b, c\n[program:b]\ncommand=/bin/ls\ndepends_on=c\n[program:c]\ncommand=/bin/ls\n"))
+	if err != nil || config.GetProgram("a") == nil {
+		t.Errorf("The depends_on without cycle should be accepted, error: %v", err)
+	}
+}
+
+func TestExplainProgramSetting(t *testing.T) {
+	s := "[program:test]\ncommand=/usr/bin/ls\nautorestart=true\n[program-default]\nstopwaitsecs=30\nautorestart=false"
+	config, _ := parse([]byte(s))
+	expected := [][]string{{"autorestart", "true", SourceProgram},
+		{"stopwaitsecs", "30", SourceProgramDefault},
+		{"startsecs", "1", SourceBuiltin},
+		{"not_exist", "", SourceUnset}}
+	for _, e := range expected {
+		value, source, err := config.ExplainProgramSetting("test", e[0])
+		if err != nil || value != e[1] || source != e[2] {
+			t.Errorf("Expect %s=%s from %s but get %s from %s, error: %v", e[0], e[1], e[2], value, source, err)
+		}
+	}
+	if _, _, err := config.ExplainProgramSetting("not_exist", "command"); err == nil {
+		t.Error("Fail to report the missing program")
+	}
+}
+
+func TestGetEffectiveSettings(t *testing.T) {
+	s := "[program:test]\ncommand=/bin/echo %(program_name)s\nenvironment=A=\"1\",B=\"%(program_name)s\"\n[program-default]\nstopwaitsecs=30"
+	config, _ := parse([]byte(s))
+	settings := config.GetProgram("test").GetEffectiveSettings()
+	expected := map[string]string{"command": "/bin/echo test",
+		"environment":  "A=1,B=test",
+		"stopwaitsecs": "30",
+		"startsecs":    "1"}
+	for key, value := range expected {
+		if settings[key] != value {
+			t.Errorf("Expect %s=%s but get %s", key, value, settings[key])
+		}
+	}
+}
+
+func TestLoadVerifySignature(t *testing.T) {
+	content := []byte("[program:test]\ncommand=/bin/ls")
+	fileName, err := saveToTmpFile(content)
+	if err != nil {
+		t.Fatal("Fail to create the configuration file")
+	}
+	defer os.Remove(fileName)
+	defer os.Remove(fileName + ".sig")
+	pubKey, privKey, _ := ed25519.GenerateKey(nil)
+	config := NewConfig(fileName)
+	config.SetPublicKey(pubKey)
+
+	if _, err := config.Load(); err == nil {
+		t.Error("Load should fail if the signature file is missing")
+	}
+	ioutil.WriteFile(fileName+".sig", ed25519.Sign(privKey, []byte("[program:evil]\ncommand=/bin/ls")), os.ModePerm)
+	if _, err := config.Load(); err == nil {
+		t.Error("Load should fail if the signature does not match")
+	}
+	ioutil.WriteFile(fileName+".sig", ed25519.Sign(privKey, content), os.ModePerm)
+	if _, err := config.Load(); err != nil || config.GetProgram("test") == nil {
+		t.Errorf("Fail to load the configuration with valid signature, error: %v", err)
+	}
+}
+
+func TestEntryHashChangedByReload(t *testing.T) {
+	fileName, err := saveToTmpFile([]byte("[program:test]\ncommand=/bin/ls\nstartsecs=3\n[program:other]\ncommand=/bin/ls"))
+	if err != nil {
+		t.Fatal("Fail to create the configuration file")
+	}
+	defer os.Remove(fileName)
+	config := NewConfig(fileName)
+	if _, err := config.Load(); err != nil {
+		t.Fatalf("Fail to load configuration: %v", err)
+	}
+	testHash := config.GetProgram("test").Hash()
+	otherHash := config.GetProgram("other").Hash()
+	testCommandHash := config.GetProgram("test").Hash("command", "directory")
+
+	ioutil.WriteFile(fileName, []byte("[program:test]\ncommand=/bin/ls\n[program:other]\ncommand=/bin/ls"), os.ModePerm)
+	if _, err := config.Load(); err != nil {
+		t.Fatalf("Fail to reload configuration: %v", err)
+	}
+	if config.GetProgram("test").HasParameter("startsecs") {
+		t.Error("The removed startsecs is kept after reload")
+	}
+	if config.GetProgram("test").Hash() == testHash {
+		t.Error("The hash of changed program is not changed")
+	}
+	if config.GetProgram("other").Hash() != otherHash {
+		t.Error("The hash of unchanged program is changed")
+	}
+	if config.GetProgram("test").Hash("command", "directory") != testCommandHash {
+		t.Error("The hash of unchanged items is changed")
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	fileName, err := saveToTmpFile([]byte("# the secrets\nA=1\nexport B = two words\n\nC=\nexported=3\nD=4"))
+	if err != nil {
+		t.Fatalf("Fail to save the env file: %v", err)
+	}
+	defer os.Remove(fileName)
+
+	env, err := LoadEnvFile(fileName)
+	expected := []string{"A=1", "B=two words", "exported=3", "D=4"}
+	if err != nil || strings.Join(env, ";") != strings.Join(expected, ";") {
+		t.Errorf("Expect the env %v but got %v, error: %v", expected, env, err)
+	}
+	if _, err = LoadEnvFile(fileName + ".missing"); err == nil {
+		t.Error("Fail to report the missing env file")
+	}
+}
+
+func TestSetAutostartUntilReload(t *testing.T) {
+	fileName, err := saveToTmpFile([]byte("[program:test]\ncommand=/bin/true\nautostart=host:web-*\n"))
+	if err != nil {
+		t.Fatalf("fail to save the configuration: %v", err)
+	}
+	defer os.Remove(fileName)
+	config := NewConfig(fileName)
+	if _, err = config.Load(); err != nil {
+		t.Fatalf("fail to load the configuration: %v", err)
+	}
+	entry := config.GetProgram("test")
+	entry.SetAutostart(false)
+	if entry.GetAutostart() != "false" {
+		t.Errorf("expect the autostart set at runtime but got %s", entry.GetAutostart())
+	}
+	entry.SetAutostart(true)
+	if entry.GetAutostart() != "true" {
+		t.Errorf("expect the autostart set at runtime but got %s", entry.GetAutostart())
+	}
+	if _, err = config.Load(); err != nil {
+		t.Fatalf("fail to reload the configuration: %v", err)
+	}
+	if entry != config.GetProgram("test") || entry.GetAutostart() != "host:web-*" {
+		t.Errorf("expect the configured autostart after reload but got %s", entry.GetAutostart())
+	}
+}
+
+func TestGetEnvWithFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisord-secret-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "db")
+	ioutil.WriteFile(secretFile, []byte(" s3cret \n"), 0600)
+	config, _ := parse([]byte("[program:test]\nenvironment=A=a,DB_PASSWORD=%(file:" + secretFile + ")s\n[program:missing]\nenvironment=DB_PASSWORD=%(file:" + dir + "/missing)s\n"))
+
+	entry := config.GetProgram("test")
+	if envs, err := entry.GetEnvWithFiles("environment"); err != nil || len(envs) != 2 || envs[1] != "DB_PASSWORD=s3cret" {
+		t.Errorf("expect the secret read from the file but got %v, error: %v", envs, err)
+	}
+	if envs := entry.GetEnv("environment"); len(envs) != 2 || envs[1] != "DB_PASSWORD=%(file:"+secretFile+")s" {
+		t.Errorf("expect the secret is not read without the file source but got %v", envs)
+	}
+	if _, err := config.GetProgram("missing").GetEnvWithFiles("environment"); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD") {
+		t.Errorf("expect an error for the missing file but got %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ochinchina/go-ini"
+	"github.com/ochinchina/supervisord/logger"
+	"github.com/ochinchina/supervisord/signals"
+)
+
+// check if the signal name or number can be used in "stopsignal" and "stopkillsignal"
+func isValidSignal(sig string) bool {
+	_, err := signals.ToSignal(sig)
+	return err == nil
+}
+
+// the keys whose value must be an integer
+var intKeys = []string{"numprocs", "numprocs_start", "priority", "startsecs", "stopwaitsecs", "restartpause", "flap_max", "flap_window_secs", "flap_cooldown_secs", "startdelay", "max_lifetime_secs"}
+
+// the keys whose value must be a bytes setting like 1024, 10KB, 50MB or 1GB
+var bytesKeys = []string{"stdout_logfile_maxbytes", "stderr_logfile_maxbytes", "stdout_capture_maxbytes", "stderr_capture_maxbytes", "memory_limit"}
+
+// the keys whose value must be a boolean
+var boolKeys = []string{"redirect_stderr", "stopasgroup", "killasgroup", "stdout_events_enabled", "stderr_events_enabled", "restart_when_binary_changed", "logfile_compress", "redeliver_failed_events", "inherit_env"}
+
+// the default max length of the process and group names
+const defaultMaxProcessNameLength = 128
+
+// the logfile keys which may use the process/group name in the path
+var logfileKeys = []string{"stdout_logfile", "stderr_logfile"}
+
+// Validate check all the program and event listener entries and return the found problems.
+// If checkDirectory is true, the "directory" of program must exist
+func (c *Config) Validate(checkDirectory bool) []string {
+	result := make([]string, 0)
+	for _, entry := range c.GetPrograms() {
+		result = append(result, entry.validate(checkDirectory)...)
+	}
+	for _, entry := range c.GetEventListeners() {
+		result = append(result, entry.validate(checkDirectory)...)
+	}
+	return result
+}
+
+// ValidateProgramConfig parse the ini fragment in an isolated Config object and validate the
+// program sections in it. The relative paths are resolved against configDir
+func ValidateProgramConfig(content string, configDir string) []string {
+	c := NewConfig(filepath.Join(configDir, "supervisord.conf"))
+	ini := ini.NewIni()
+	ini.LoadString(content)
+	if len(c.parse(ini)) <= 0 {
+		return []string{"no program section is found"}
+	}
+	return c.Validate(true)
+}
+
+// validate check the settings of a program or event listener
+func (c *Entry) validate(checkDirectory bool) []string {
+	result := make([]string, 0)
+	addError := func(format string, args ...interface{}) {
+		result = append(result, fmt.Sprintf("[%s] %s", c.Name, fmt.Sprintf(format, args...)))
+	}
+
+	if strings.TrimSpace(c.GetString("command", "")) == "" {
+		addError("command is missing")
+	}
+
+	for _, key := range intKeys {
+		if value, ok := c.keyValues[key]; ok {
+			if _, err := strconv.Atoi(value); err != nil {
+				addError("%s=%s is not a valid integer", key, value)
+			}
+		}
+	}
+
+	for _, key := range bytesKeys {
+		if value, ok := c.keyValues[key]; ok {
+			if c.GetBytes(key, -1) == -1 {
+				addError("%s=%s is not a valid bytes setting", key, value)
+			}
+		}
+	}
+
+	for _, key := range boolKeys {
+		if value, ok := c.keyValues[key]; ok {
+			if _, err := strconv.ParseBool(value); err != nil {
+				addError("%s=%s is not a valid boolean", key, value)
+			}
+		}
+	}
+
+	if value, ok := c.keyValues["startretries"]; ok && !strings.EqualFold(strings.TrimSpace(value), "infinite") {
+		if _, err := strconv.Atoi(value); err != nil {
+			addError("startretries=%s should be an integer, -1 or infinite", value)
+		}
+	}
+
+	if value, ok := c.keyValues["umask"]; ok && (c.GetOctal("umask", -1) < 0 || c.GetOctal("umask", -1) > 0777) {
+		addError("umask=%s is not a valid octal umask like 022", value)
+	}
+
+	if value, ok := c.keyValues["cpu_quota"]; ok && c.GetPercent("cpu_quota", -1) < 0 {
+		addError("cpu_quota=%s is not a valid percentage", value)
+	}
+
+	if value, ok := c.keyValues["autostart"]; ok {
+		if pattern := strings.TrimSpace(value); strings.HasPrefix(pattern, "host:") {
+			if _, err := path.Match(strings.TrimSpace(pattern[len("host:"):]), ""); err != nil {
+				addError("autostart=%s has an invalid host pattern: %v", value, err)
+			}
+		} else if value != "true" && value != "false" {
+			addError("autostart=%s should be true, false or host:pattern", value)
+		}
+	}
+
+	if value, ok := c.keyValues["autorestart"]; ok && value != "true" && value != "false" && value != "unexpected" {
+		addError("autorestart=%s should be one of true, false or unexpected", value)
+	}
+
+	if value, ok := c.keyValues["exitcodes"]; ok {
+		for _, code := range strings.Split(value, ",") {
+			if _, err := strconv.Atoi(strings.TrimSpace(code)); err != nil {
+				addError("exitcodes=%s includes invalid exit code %s", value, code)
+			}
+		}
+	}
+
+	if value, ok := c.keyValues["logfile_rotate"]; ok && !logger.IsValidRotatePeriod(value) {
+		addError("logfile_rotate=%s should be daily or hourly", value)
+	}
+
+	if value, ok := c.keyValues["syslog_facility"]; ok && !logger.IsValidSyslogFacility(value) {
+		addError("syslog_facility=%s is not a valid syslog facility", value)
+	}
+
+	for _, sig := range strings.Fields(c.GetString("stopsignal", "")) {
+		if !isValidSignal(sig) {
+			addError("stopsignal %s is not a valid signal", sig)
+		}
+	}
+
+	if value, ok := c.keyValues["stopkillsignal"]; ok && !isValidSignal(value) {
+		addError("stopkillsignal %s is not a valid signal", value)
+	}
+
+	if checkDirectory {
+		// the directory starting with ~ is expanded to the user home directory and checked when
+		// the program is started
+		if dir := c.GetStringExpression("directory", ""); dir != "" && !strings.HasPrefix(dir, "~") {
+			if fileInfo, err := os.Stat(dir); err != nil || !fileInfo.IsDir() {
+				addError("directory %s does not exist", dir)
+			}
+		}
+	}
+	return result
+}
+
+// check the resolved process names and the group names. An error is returned if any name is
+// longer than the max_process_name_length in [supervisord] section, if the name is used in a
+// logfile template but contains characters invalid for a file name, or if the numprocs processes
+// of a program have the same name
+func (c *Config) checkProcessNames(cfg *ini.Ini) error {
+	maxLength := defaultMaxProcessNameLength
+	if section, err := cfg.GetSection("supervisord"); err == nil {
+		if n, err := section.GetInt("max_process_name_length"); err == nil && n > 0 {
+			maxLength = n
+		}
+	}
+	programDefaultSection, _ := cfg.GetSection("program-default")
+
+	groups := NewProcessGroup()
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name, "group:") {
+			continue
+		}
+		groupName := section.Name[len("group:"):]
+		if err := checkName(section.Name, "group", groupName, maxLength, false); err != nil {
+			return err
+		}
+		for _, program := range strings.Split(section.GetValueWithDefault("programs", ""), ",") {
+			groups.Add(groupName, strings.TrimSpace(program))
+		}
+	}
+
+	for _, section := range cfg.Sections() {
+		programOrEventListener, prefix := c.isProgramOrEventListener(section)
+		if !programOrEventListener {
+			continue
+		}
+		programName := section.Name[len(prefix):]
+		group := groups.GetGroup(programName, programName)
+		nameInPath, groupInPath := false, false
+		for _, key := range logfileKeys {
+			logfile := section.GetValueWithDefault(key, "")
+			if prefix == "program:" && programDefaultSection != nil && !section.HasKey(key) {
+				logfile = programDefaultSection.GetValueWithDefault(key, "")
+			}
+			nameInPath = nameInPath || strings.Contains(logfile, "%(program_name)")
+			groupInPath = groupInPath || strings.Contains(logfile, "%(group_name)")
+		}
+		if err := checkName(section.Name, "group", group, maxLength, groupInPath); err != nil {
+			return err
+		}
+		procNames := make(map[string]bool)
+		for _, procName := range c.getProcessNames(section, programName, group) {
+			if err := checkName(section.Name, "process", procName, maxLength, nameInPath); err != nil {
+				return err
+			}
+			if procNames[procName] {
+				return fmt.Errorf("the process name %s of [%s] is used by more than one process, include %%(process_num) in the process_name if numprocs is greater than 1", procName, section.Name)
+			}
+			procNames[procName] = true
+		}
+	}
+	return nil
+}
+
+// get the resolved process names of a program or event listener section. The names which
+// can't be resolved are skipped, the error is reported when parsing the program
+func (c *Config) getProcessNames(section *ini.Section, programName string, group string) []string {
+	procNameTemplate := section.GetValueWithDefault("process_name", programName)
+	result := make([]string, 0)
+	for _, i := range getProcessNums(section) {
+		if procName, err := c.newProcessExpression(section, programName, group, i).Eval(procNameTemplate); err == nil {
+			result = append(result, procName)
+		}
+	}
+	return result
+}
+
+// check the programs do not depend on each other in a cycle by depends_on, otherwise none of
+// them can be started
+func (c *Config) checkDependsOnCycles(cfg *ini.Ini) error {
+	groups := NewProcessGroup()
+	for _, section := range cfg.Sections() {
+		if strings.HasPrefix(section.Name, "group:") {
+			for _, program := range strings.Split(section.GetValueWithDefault("programs", ""), ",") {
+				groups.Add(section.Name[len("group:"):], strings.TrimSpace(program))
+			}
+		}
+	}
+
+	// the processes of each program, a program with numprocs has more than one
+	programProcs := make(map[string][]string)
+	for _, section := range cfg.Sections() {
+		if strings.HasPrefix(section.Name, "program:") {
+			programName := section.Name[len("program:"):]
+			programProcs[programName] = c.getProcessNames(section, programName, groups.GetGroup(programName, programName))
+		}
+	}
+
+	dependsOn := make(map[string][]string)
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name, "program:") {
+			continue
+		}
+		programName := section.Name[len("program:"):]
+		deps := make([]string, 0)
+		for _, dep := range strings.Split(section.GetValueWithDefault("depends_on", ""), ",") {
+			if dep = strings.TrimSpace(dep); dep == "" {
+				continue
+			} else if procNames, ok := programProcs[dep]; ok {
+				deps = append(deps, procNames...)
+			} else {
+				deps = append(deps, dep)
+			}
+		}
+		for _, procName := range programProcs[programName] {
+			dependsOn[procName] = deps
+		}
+	}
+
+	if cycle := findDependsOnCycle(dependsOn); len(cycle) > 0 {
+		return fmt.Errorf("the programs depend on each other in a cycle by depends_on: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// find a cycle in the depends_on graph, the returned cycle starts and ends with the same
+// program. An empty slice is returned if there is no cycle
+func findDependsOnCycle(dependsOn map[string][]string) []string {
+	names := make([]string, 0, len(dependsOn))
+	for name := range dependsOn {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// the programs all whose dependencies are checked
+	checked := make(map[string]bool)
+	// the position of the programs in current checking path
+	pathIndex := make(map[string]int)
+	path := make([]string, 0)
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		if index, ok := pathIndex[name]; ok {
+			return append(append([]string{}, path[index:]...), name)
+		}
+		if checked[name] {
+			return nil
+		}
+		pathIndex[name] = len(path)
+		path = append(path, name)
+		for _, dep := range dependsOn[name] {
+			if cycle := visit(dep); len(cycle) > 0 {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		delete(pathIndex, name)
+		checked[name] = true
+		return nil
+	}
+	for _, name := range names {
+		if cycle := visit(name); len(cycle) > 0 {
+			return cycle
+		}
+	}
+	return []string{}
+}
+
+// check the length of name and if it can be used as part of a file path
+func checkName(sectionName string, kind string, name string, maxLength int, inPath bool) error {
+	if len(name) > maxLength {
+		return fmt.Errorf("[%s] the %s name %s is %d characters long, exceeds the max_process_name_length %d", sectionName, kind, name, len(name), maxLength)
+	}
+	if inPath && (name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00")) {
+		return fmt.Errorf("[%s] the %s name %q is used in the logfile but contains characters invalid for a file name", sectionName, kind, name)
+	}
+	return nil
+}
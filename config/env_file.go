@@ -0,0 +1,50 @@
+package config
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// LoadEnvFile read the environment variables from the file and return them in "key=value"
+// format in the order of the file. In the file, each line is a "key=value", optionally
+// exported with "export", and the lines starting with '#' are comments. The variables with
+// empty key or value are ignored
+func LoadEnvFile(fileName string) ([]string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	result := make([]string, 0)
+	reader := bufio.NewReader(f)
+	for {
+		//for each line
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		//if line starts with '#', it is a comment line, ignore it
+		line = strings.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			//if environment variable is exported with "export"
+			if strings.HasPrefix(line, "export") && len(line) > len("export") && unicode.IsSpace(rune(line[len("export")])) {
+				line = strings.TrimSpace(line[len("export"):])
+			}
+			//split the environment variable with "="
+			if pos := strings.Index(line, "="); pos != -1 {
+				k := strings.TrimSpace(line[0:pos])
+				v := strings.TrimSpace(line[pos+1:])
+				if len(k) > 0 && len(v) > 0 {
+					result = append(result, k+"="+v)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return result, nil
+}
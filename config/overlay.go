@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ochinchina/go-ini"
+	"go.uber.org/zap"
+)
+
+// The overlay file keeps the program items changed at runtime, like the autostart disabled by
+// the setProcessAutostart RPC, in JSON format: process name => item => value. It is set by
+// "overlay_file" in the [supervisord] section, and its items override the program sections
+// when the configuration is loaded, so the runtime changes survive the reload and restart.
+// Only the items in overlayKeys can be overridden, the overlay file is not verified by the
+// signature of the configuration, so it must never change the command, user or environment
+
+// the program items which can be overridden by the overlay file
+var overlayKeys = map[string]bool{"autostart": true}
+
+// load the overlay file set in the [supervisord] section. The overlay is ignored if it can't be
+// read or parsed, so a corrupt overlay never fails the loading of the configuration
+func (c *Config) loadOverlay(cfg *ini.Ini) {
+	file := ""
+	if section, err := cfg.GetSection("supervisord"); err == nil {
+		env := NewStringExpression("here", c.GetConfigFileDir())
+		if f, err := env.Eval(section.GetValueWithDefault("overlay_file", "")); err == nil {
+			file = strings.TrimSpace(f)
+		}
+	}
+	overlay := make(map[string]map[string]string)
+	if file != "" {
+		if content, err := ioutil.ReadFile(file); err == nil {
+			if err = json.Unmarshal(content, &overlay); err != nil {
+				zap.S().Errorw("fail to parse the overlay file, ignore it", "file", file, "error", err)
+				overlay = make(map[string]map[string]string)
+			}
+			dropDisallowedOverlayKeys(file, overlay)
+		} else if !os.IsNotExist(err) {
+			zap.S().Errorw("fail to read the overlay file, ignore it", "file", file, "error", err)
+		}
+	}
+	c.overlayLock.Lock()
+	defer c.overlayLock.Unlock()
+	c.overlayFile = file
+	c.overlay = overlay
+}
+
+// remove the items which can't be overridden by the overlay file from the overlay
+func dropDisallowedOverlayKeys(file string, overlay map[string]map[string]string) {
+	for procName, items := range overlay {
+		for key := range items {
+			if !overlayKeys[key] {
+				zap.S().Warnw("ignore the item not allowed in the overlay file", "file", file, "program", procName, "key", key)
+				delete(items, key)
+			}
+		}
+	}
+}
+
+// get the items of the process in the overlay
+func (c *Config) getProgramOverlay(procName string) map[string]string {
+	c.overlayLock.Lock()
+	defer c.overlayLock.Unlock()
+	return c.overlay[procName]
+}
+
+// GetOverlayFile get the overlay file, empty if "overlay_file" is not set
+func (c *Config) GetOverlayFile() string {
+	c.overlayLock.Lock()
+	defer c.overlayLock.Unlock()
+	return c.overlayFile
+}
+
+// SetOverlayValue save the item of the process to the overlay file, so it overrides the item in
+// the configuration after the configuration is reloaded. The loaded configuration is not changed
+func (c *Config) SetOverlayValue(procName string, key string, value string) error {
+	c.overlayLock.Lock()
+	defer c.overlayLock.Unlock()
+	if c.overlayFile == "" {
+		return errors.New("overlay_file is not set")
+	}
+	if !overlayKeys[key] {
+		return fmt.Errorf("%s can't be overridden by the overlay file", key)
+	}
+	overlay := make(map[string]map[string]string)
+	for name, items := range c.overlay {
+		overlay[name] = items
+	}
+	items := make(map[string]string)
+	for k, v := range overlay[procName] {
+		items[k] = v
+	}
+	items[key] = value
+	overlay[procName] = items
+	content, err := json.MarshalIndent(overlay, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temporary file and rename it, so the file is never half written
+	tmpFile := c.overlayFile + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, content, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpFile, c.overlayFile); err != nil {
+		return err
+	}
+	c.overlay = overlay
+	return nil
+}
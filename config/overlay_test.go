@@ -0,0 +1,87 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisord-overlay-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "supervisord.conf")
+	ioutil.WriteFile(configFile, []byte("[supervisord]\noverlay_file=%(here)s/overlay.json\n[program:test]\ncommand=/bin/true\nautostart=true\n"), 0644)
+
+	config := NewConfig(configFile)
+	if _, err = config.Load(); err != nil {
+		t.Fatalf("fail to load the configuration without the overlay file: %v", err)
+	}
+	if config.GetOverlayFile() != filepath.Join(dir, "overlay.json") {
+		t.Fatalf("unexpected overlay file %s", config.GetOverlayFile())
+	}
+	if err = config.SetOverlayValue("test", "autostart", "false"); err != nil {
+		t.Fatalf("fail to save the overlay: %v", err)
+	}
+	if config.GetProgram("test").GetString("autostart", "") != "true" {
+		t.Error("expect the loaded configuration is not changed by the overlay until reload")
+	}
+
+	if _, err = config.Load(); err != nil {
+		t.Fatalf("fail to reload the configuration: %v", err)
+	}
+	if value := config.GetProgram("test").GetString("autostart", ""); value != "false" {
+		t.Errorf("expect the overlay overrides the configuration after reload but got %s", value)
+	}
+	config = NewConfig(configFile)
+	if _, err = config.Load(); err != nil || config.GetProgram("test").GetString("autostart", "") != "false" {
+		t.Errorf("expect the overlay is loaded after restart, error: %v", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "overlay.json"), []byte("{corrupt"), 0644)
+	if _, err = config.Load(); err != nil {
+		t.Fatalf("expect the corrupt overlay is ignored but got %v", err)
+	}
+	if value := config.GetProgram("test").GetString("autostart", ""); value != "true" {
+		t.Errorf("expect the configuration without the corrupt overlay but got %s", value)
+	}
+}
+
+func TestSetOverlayValueWithoutOverlayFile(t *testing.T) {
+	config, err := parse([]byte("[program:test]\ncommand=/bin/true\n"))
+	if err != nil {
+		t.Fatalf("fail to load the configuration: %v", err)
+	}
+	if err = config.SetOverlayValue("test", "autostart", "false"); err == nil {
+		t.Error("expect an error if overlay_file is not set")
+	}
+}
+
+func TestOverlayAllowedKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisord-overlay-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "supervisord.conf")
+	ioutil.WriteFile(configFile, []byte("[supervisord]\noverlay_file=%(here)s/overlay.json\n[program:test]\ncommand=/bin/true\nuser=nobody\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "overlay.json"), []byte(`{"test": {"command": "/bin/sh -c id", "user": "root", "autostart": "false"}}`), 0644)
+
+	config := NewConfig(configFile)
+	if _, err = config.Load(); err != nil {
+		t.Fatalf("fail to load the configuration: %v", err)
+	}
+	entry := config.GetProgram("test")
+	if entry.GetString("command", "") != "/bin/true" || entry.GetString("user", "") != "nobody" {
+		t.Errorf("expect the command and user are not overridden but got %s, %s", entry.GetString("command", ""), entry.GetString("user", ""))
+	}
+	if entry.GetString("autostart", "") != "false" {
+		t.Error("expect the autostart is overridden by the overlay")
+	}
+	if err = config.SetOverlayValue("test", "command", "/bin/false"); err == nil {
+		t.Error("expect an error to save an item not allowed in the overlay")
+	}
+}
@@ -94,10 +94,22 @@ func (p *ProcessSorter) sortDepends() []string {
 	}
 
 	for len(finishedPrograms) < len(progsWithDependsInfo) {
+		progress := false
 		for progName := range p.dependsOnGraph {
 			if _, ok := finishedPrograms[progName]; !ok && p.inFinishedPrograms(progName, finishedPrograms) {
 				finishedPrograms[progName] = progName
 				progsStartOrder = append(progsStartOrder, progName)
+				progress = true
+			}
+		}
+		// the left programs depend on each other in a cycle, it is rejected when loading the
+		// configuration. Append them in any order instead of looping forever
+		if !progress {
+			for progName := range p.dependsOnGraph {
+				if _, ok := finishedPrograms[progName]; !ok {
+					finishedPrograms[progName] = progName
+					progsStartOrder = append(progsStartOrder, progName)
+				}
 			}
 		}
 	}
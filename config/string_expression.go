@@ -1,15 +1,33 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
+// the prefix of the variable replaced by the content of a file, like "%(file:/run/secrets/db)s"
+const fileSourcePrefix = "file:"
+
 // StringExpression replace the python String like "%(var)s" to string
 type StringExpression struct {
 	env map[string]string // the environment variable used to replace the var in the python expression
+	// if the "%(file:path)s" is replaced by the content of the file
+	fileSource bool
+}
+
+// fileSourceError the error to replace "%(file:path)s" in the expression
+type fileSourceError struct {
+	path string
+	err  error
+}
+
+func (e *fileSourceError) Error() string {
+	return fmt.Sprintf("fail to read %%(%s%s)s: %v", fileSourcePrefix, e.path, e.err)
 }
 
 // NewStringExpression create a new StringExpression with the environment variables
@@ -40,6 +58,28 @@ func (se *StringExpression) Add(key string, value string) *StringExpression {
 	return se
 }
 
+// EnableFileSource replace "%(file:path)s" by the trimmed content of the file when the expression
+// is evaluated. A relative path is relative to the "here" variable if it is set
+func (se *StringExpression) EnableFileSource() *StringExpression {
+	se.fileSource = true
+	return se
+}
+
+// read the trimmed content of the file for "%(file:path)s"
+func (se *StringExpression) readFileSource(path string) (string, error) {
+	if !se.fileSource {
+		return "", &fileSourceError{path: path, err: errors.New("the file is not read in this setting")}
+	}
+	if here, ok := se.env["here"]; ok && !filepath.IsAbs(path) {
+		path = filepath.Join(here, path)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", &fileSourceError{path: path, err: err}
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
 // Eval evaluate the expression include "%(var)s"  and return the string after replacing the var
 func (se *StringExpression) Eval(s string) (string, error) {
 	for {
@@ -69,6 +109,13 @@ func (se *StringExpression) Eval(s string) (string, error) {
 			varName := s[start+2 : end]
 
 			varValue, ok := se.env[varName]
+			if strings.HasPrefix(varName, fileSourcePrefix) {
+				value, err := se.readFileSource(varName[len(fileSourcePrefix):])
+				if err != nil {
+					return "", err
+				}
+				varValue, ok = value, true
+			}
 
 			if !ok {
 				return "", fmt.Errorf("fail to find the environment variable %s", varName)
@@ -1,6 +1,10 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -15,3 +19,27 @@ func TestEval(t *testing.T) {
 		t.Error("fail to replace the environment")
 	}
 }
+
+func TestEvalFileSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisord-secret-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "db"), []byte("s3cret\n"), 0600)
+
+	se := NewStringExpression("here", dir)
+	if _, err = se.Eval("DB_PASSWORD=%(file:db)s"); err == nil {
+		t.Error("expect the file is not read if the file source is not enabled")
+	}
+	se.EnableFileSource()
+	if r, err := se.Eval("DB_PASSWORD=%(file:db)s"); err != nil || r != "DB_PASSWORD=s3cret" {
+		t.Errorf("expect the trimmed content of the file relative to here but got %s, error: %v", r, err)
+	}
+	if r, err := se.Eval("DB_PASSWORD=%(file:" + filepath.Join(dir, "db") + ")s"); err != nil || r != "DB_PASSWORD=s3cret" {
+		t.Errorf("expect the content of the absolute file but got %s, error: %v", r, err)
+	}
+	if _, err = se.Eval("DB_PASSWORD=%(file:missing)s"); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expect an error naming the missing file but got %v", err)
+	}
+}
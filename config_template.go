@@ -7,8 +7,8 @@ import (
 
 var configTemplate = `[unix_http_server]
 file=/tmp/supervisord.sock
-#chmod=not support
-#chown=not support
+#chmod=0700
+#chown=nobody:nogroup
 username=test1
 password={SHA}82ab876d1387bfafe46cc1c8a2ef074eae50cb1d
 
@@ -63,7 +63,7 @@ stderr_capture_maxbytes=0
 stderr_events_enabled=false
 environment=KEY="val",KEY2="val2"
 directory=/tmp
-#umask=not support
+#umask=022
 serverurl=AUTO
 
 [include]
@@ -85,8 +85,8 @@ autorestart=true
 exitcodes=0,2
 stopsignal=TERM
 stopwaitsecs=10
-#stopasgroup=not support
-#killasgroup=not support
+#stopasgroup=false
+#killasgroup=false
 user=user1
 redirect_stderr=false
 stdout_logfile=AUTO
@@ -101,7 +101,7 @@ stderr_capture_maxbytes=0
 stderr_events_enabled=false
 environment=KEY="val",KEY2="val2"
 directory=/tmp
-#umask=not support
+#umask=022
 serverurl=AUTO
 buffer_size=10240
 events=PROCESS_STATE
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// execControlCommand execute a control command line like "start web", "stop worker" or "restart all".
+// The command accepts one or more program names, "group:*" and "all" are also supported
+func (s *Supervisor) execControlCommand(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid control command: %s", line)
+	}
+	action := strings.ToLower(fields[0])
+	if action != "start" && action != "stop" && action != "restart" {
+		return fmt.Errorf("unknown control command: %s", fields[0])
+	}
+	zap.S().Infow("execute control command", "command", action, "programs", fields[1:])
+	for _, name := range fields[1:] {
+		if err := s.execControlAction(action, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Supervisor) execControlAction(action string, name string) error {
+	if name == "all" {
+		var reply struct{ RPCTaskResults []RPCTaskResult }
+		if action == "stop" || action == "restart" {
+			s.StopAllProcesses(nil, &struct {
+				Wait bool `default:"true"`
+			}{Wait: true}, &reply)
+		}
+		if action == "start" || action == "restart" {
+			s.StartAllProcesses(nil, &struct {
+				Wait bool `default:"true"`
+			}{Wait: true}, &reply)
+		}
+		return nil
+	}
+	procs := s.procMgr.FindMatch(name)
+	if len(procs) <= 0 {
+		return fmt.Errorf("fail to find process %s", name)
+	}
+	for _, proc := range procs {
+		if action == "stop" || action == "restart" {
+			proc.Stop(true)
+		}
+		if action == "start" || action == "restart" {
+			proc.Start(true)
+		}
+	}
+	return nil
+}
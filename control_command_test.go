@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ochinchina/supervisord/process"
+)
+
+func TestExecControlCommand(t *testing.T) {
+	s := loadTestSupervisor(t, "[program:test]\ncommand=/bin/sleep 10\nautostart=false\nstartsecs=0\n")
+	s.createPrograms(nil)
+	proc := s.procMgr.Find("test")
+	defer proc.Stop(true)
+
+	for _, line := range []string{"start", "reload test", "start unknown"} {
+		if err := s.execControlCommand(line); err == nil {
+			t.Errorf("expect an error for the control command %q", line)
+		}
+	}
+	if err := s.execControlCommand("START test"); err != nil || proc.GetState() != process.Running {
+		t.Errorf("expect the program is started by the control command but got %v, %v", proc.GetState(), err)
+	}
+	if err := s.execControlCommand("stop  test "); err != nil || proc.GetState() == process.Running {
+		t.Errorf("expect the program is stopped by the control command but got %v, %v", proc.GetState(), err)
+	}
+	if err := s.execControlCommand("stop test unknown"); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("expect an error for the unknown program but got %v", err)
+	}
+}
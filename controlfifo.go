@@ -0,0 +1,97 @@
+// +build !windows
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/ochinchina/supervisord/config"
+	"go.uber.org/zap"
+)
+
+// controlFifo read the control commands from the control_fifo until it is closed
+type controlFifo struct {
+	file string
+	f    *os.File
+	// closed when the reader goroutine exits
+	done chan struct{}
+}
+
+// start a goroutine to read the control commands from the control_fifo
+// configured in the [supervisord] section. The reader of the fifo removed
+// from the configuration is stopped. The reloadLock must be held
+func (s *Supervisor) startControlFifo() {
+	fifo := ""
+	if supervisordConf, ok := s.config.GetSupervisord(); ok {
+		env := config.NewStringExpression("here", s.config.GetConfigFileDir())
+		var err error
+		if fifo, err = env.Eval(supervisordConf.GetString("control_fifo", "")); err != nil {
+			zap.S().Errorw("invalid control_fifo", "error", err)
+		}
+	}
+	if s.controlFifo != nil && s.controlFifo.file == fifo {
+		return
+	}
+	s.controlFifo.close()
+	s.controlFifo = nil
+	if fifo == "" {
+		return
+	}
+	if err := createFifo(fifo); err != nil {
+		zap.S().Errorw("fail to create the control fifo", "file", fifo, "error", err)
+		return
+	}
+	// opened for reading and writing, so opening it doesn't wait for a writer and the reading
+	// doesn't end when all the writers close it
+	f, err := os.OpenFile(fifo, os.O_RDWR, 0)
+	if err != nil {
+		zap.S().Errorw("fail to open the control fifo", "file", fifo, "error", err)
+		return
+	}
+	zap.S().Infow("read control commands from fifo", "file", fifo)
+	s.controlFifo = &controlFifo{file: fifo, f: f, done: make(chan struct{})}
+	go s.readControlFifo(s.controlFifo)
+}
+
+// create the fifo if it does not exist
+func createFifo(fifo string) error {
+	fileInfo, err := os.Stat(fifo)
+	if err == nil {
+		if fileInfo.Mode()&os.ModeNamedPipe == 0 {
+			return fmt.Errorf("%s exists but is not a fifo", fifo)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	return syscall.Mkfifo(fifo, 0600)
+}
+
+// read the control commands line by line until the fifo is closed
+func (s *Supervisor) readControlFifo(fifo *controlFifo) {
+	defer close(fifo.done)
+	scanner := bufio.NewScanner(fifo.f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := s.execControlCommand(line); err != nil {
+			zap.S().Errorw("fail to execute control command", "command", line, "error", err)
+		}
+	}
+}
+
+// stop reading the fifo and wait for the reader goroutine to exit. Nothing is done if fifo is nil
+func (fifo *controlFifo) close() {
+	if fifo == nil {
+		return
+	}
+	fifo.f.Close()
+	<-fifo.done
+}
@@ -0,0 +1,48 @@
+// +build !windows
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ochinchina/supervisord/process"
+)
+
+func TestControlFifoAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "control-fifo")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	content := "[supervisord]\ncontrol_fifo=" + filepath.Join(dir, "control") + "\n[program:test]\ncommand=/bin/sleep 10\nautostart=false\nstartsecs=0\n"
+
+	prev := loadTestSupervisor(t, content)
+	prev.startControlFifo()
+	// the previous supervisor stops reading the fifo when it is restarted
+	prev.restartOnce.Do(func() { close(prev.restarting) })
+	prev.WaitForExit()
+	if prev.controlFifo != nil {
+		t.Error("expect the control fifo is closed after restart")
+	}
+	s := loadTestSupervisor(t, content)
+	s.createPrograms(nil)
+	s.startControlFifo()
+	defer s.controlFifo.close()
+	proc := s.procMgr.Find("test")
+	proc.Start(true)
+	defer proc.Stop(true)
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "control"), []byte("stop test\n"), 0600); err != nil {
+		t.Fatalf("fail to write the control fifo: %v", err)
+	}
+	for i := 0; i < 100 && proc.GetState() == process.Running; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if proc.GetState() == process.Running {
+		t.Error("expect the program of the current supervisor is stopped by the control command")
+	}
+}
@@ -0,0 +1,23 @@
+// +build windows
+
+package main
+
+import (
+	"go.uber.org/zap"
+)
+
+// controlFifo the control fifo is not supported on windows
+type controlFifo struct {
+}
+
+// the control fifo is not supported on windows
+func (s *Supervisor) startControlFifo() {
+	supervisordConf, ok := s.config.GetSupervisord()
+	if ok && supervisordConf.GetString("control_fifo", "") != "" {
+		zap.S().Warn("control_fifo is not supported on windows")
+	}
+}
+
+// nothing to close because no control fifo is read on windows
+func (fifo *controlFifo) close() {
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"encoding/base64"
 	"fmt"
 	"github.com/jessevdk/go-flags"
-	"github.com/ochinchina/supervisord/config"
 	"github.com/ochinchina/supervisord/types"
 	"github.com/ochinchina/supervisord/xmlrpcclient"
+	"math"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 // CtlCommand the entry of ctl command
@@ -55,6 +57,11 @@ type SignalCommand struct {
 type LogtailCommand struct {
 }
 
+// TailCommand print the end of the stdout/stderr log of program through the RPC interface
+type TailCommand struct {
+	Follow bool `short:"f" long:"follow" description:"keep printing the log written later"`
+}
+
 // CmdCheckWrapperCommand A wrapper can be use to check whether
 // number of parameters is valid or not
 type CmdCheckWrapperCommand struct {
@@ -77,55 +84,24 @@ var reloadCommand = CmdCheckWrapperCommand{&ReloadCommand{}, 0, ""}
 var pidCommand = CmdCheckWrapperCommand{&PidCommand{}, 1, "pid <program>"}
 var signalCommand = CmdCheckWrapperCommand{&SignalCommand{}, 2, "signal <signal_name> <program>[...]"}
 var logtailCommand = CmdCheckWrapperCommand{&LogtailCommand{}, 1, "logtail <program>"}
+var tailCommand TailCommand
+
+// the bytes of the log printed by the tail command, like supervisorctl
+const tailLength = 1600
+
+// the interval the tail command reads the log written later with -f
+const tailFollowInterval = time.Second
 
 func (x *CtlCommand) getServerURL() string {
-	options.Configuration, _ = findSupervisordConf()
-
-	if x.ServerURL != "" {
-		return x.ServerURL
-	} else if _, err := os.Stat(options.Configuration); err == nil {
-		config := config.NewConfig(options.Configuration)
-		config.Load()
-		if entry, ok := config.GetSupervisorctl(); ok {
-			serverurl := entry.GetString("serverurl", "")
-			if serverurl != "" {
-				return serverurl
-			}
-		}
-	}
-	return "http://localhost:9001"
+	return getCtlServerURL(x.ServerURL, loadCtlConfig())
 }
 
 func (x *CtlCommand) getUser() string {
-	options.Configuration, _ = findSupervisordConf()
-
-	if x.User != "" {
-		return x.User
-	} else if _, err := os.Stat(options.Configuration); err == nil {
-		config := config.NewConfig(options.Configuration)
-		config.Load()
-		if entry, ok := config.GetSupervisorctl(); ok {
-			user := entry.GetString("username", "")
-			return user
-		}
-	}
-	return ""
+	return getCtlCredential(x.User, x.ServerURL, loadCtlConfig(), "username")
 }
 
 func (x *CtlCommand) getPassword() string {
-	options.Configuration, _ = findSupervisordConf()
-
-	if x.Password != "" {
-		return x.Password
-	} else if _, err := os.Stat(options.Configuration); err == nil {
-		config := config.NewConfig(options.Configuration)
-		config.Load()
-		if entry, ok := config.GetSupervisorctl(); ok {
-			password := entry.GetString("password", "")
-			return password
-		}
-	}
-	return ""
+	return getCtlCredential(x.Password, x.ServerURL, loadCtlConfig(), "password")
 }
 
 func (x *CtlCommand) createRPCClient() *xmlrpcclient.XMLRPCClient {
@@ -262,6 +238,21 @@ func (x *CtlCommand) reload(rpcc *xmlrpcclient.XMLRPCClient) {
 		if len(reply.RemovedGroup) > 0 {
 			fmt.Printf("Removed Groups: %s\n", strings.Join(reply.RemovedGroup, ","))
 		}
+		if len(reply.AddedPrograms) > 0 {
+			fmt.Printf("Added Programs: %s\n", strings.Join(reply.AddedPrograms, ","))
+		}
+		if len(reply.RemovedPrograms) > 0 {
+			fmt.Printf("Removed Programs: %s\n", strings.Join(reply.RemovedPrograms, ","))
+		}
+		if len(reply.RestartedPrograms) > 0 {
+			fmt.Printf("Restarted Programs: %s\n", strings.Join(reply.RestartedPrograms, ","))
+		}
+		if len(reply.PreservedPrograms) > 0 {
+			fmt.Printf("Preserved Programs: %s\n", strings.Join(reply.PreservedPrograms, ","))
+		}
+		for _, warning := range reply.Warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
 	} else {
 		os.Exit(1)
 	}
@@ -316,22 +307,55 @@ func (x *CtlCommand) showGroupName() bool {
 	return val == "yes" || val == "true" || val == "y" || val == "t" || val == "1"
 }
 
+// show the process information, the quarantined processes are shown separately after the others
 func (x *CtlCommand) showProcessInfo(reply *xmlrpcclient.AllProcessInfoReply, processesMap map[string]bool) {
+	quarantined := make([]types.ProcessInfo, 0)
 	for _, pinfo := range reply.Value {
-		description := pinfo.Description
-		if strings.ToLower(description) == "<string></string>" {
-			description = ""
+		if strings.EqualFold(pinfo.Statename, "QUARANTINED") {
+			quarantined = append(quarantined, pinfo)
+		} else {
+			x.showOneProcessInfo(pinfo, processesMap)
 		}
+	}
+	shown := false
+	for _, pinfo := range quarantined {
 		if x.inProcessMap(&pinfo, processesMap) {
-			processName := pinfo.GetFullName()
-			if !x.showGroupName() {
-				processName = pinfo.Name
+			if !shown {
+				fmt.Printf("\nQuarantined programs, start them explicitly to release:\n")
+				shown = true
 			}
-			fmt.Printf("%s%-33s%-10s%s%s\n", x.getANSIColor(pinfo.Statename), processName, pinfo.Statename, description, "\x1b[0m")
+			x.showOneProcessInfo(pinfo, processesMap)
 		}
 	}
 }
 
+func (x *CtlCommand) showOneProcessInfo(pinfo types.ProcessInfo, processesMap map[string]bool) {
+	description := pinfo.Description
+	if strings.ToLower(description) == "<string></string>" {
+		description = ""
+	}
+	lastError := strings.TrimSpace(pinfo.LastError)
+	if strings.ToLower(lastError) == "<string></string>" {
+		lastError = ""
+	}
+	if lastError != "" && pinfo.Statename != "RUNNING" {
+		description = fmt.Sprintf("%s last error: %s", description, lastError)
+	}
+	if pinfo.HealthCheck != "" {
+		description = fmt.Sprintf("%s health check: %s", description, pinfo.HealthCheck)
+	}
+	if pinfo.FlapCount > 0 {
+		description = fmt.Sprintf("%s flaps: %d", description, pinfo.FlapCount)
+	}
+	if x.inProcessMap(&pinfo, processesMap) {
+		processName := pinfo.GetFullName()
+		if !x.showGroupName() {
+			processName = pinfo.Name
+		}
+		fmt.Printf("%s%-33s%-10s%s%s\n", x.getANSIColor(pinfo.Statename), processName, pinfo.Statename, description, "\x1b[0m")
+	}
+}
+
 func (x *CtlCommand) inProcessMap(procInfo *types.ProcessInfo, processesMap map[string]bool) bool {
 	if len(processesMap) <= 0 {
 		return true
@@ -358,7 +382,7 @@ func (x *CtlCommand) getANSIColor(statename string) string {
 	if statename == "RUNNING" {
 		// green
 		return "\x1b[0;32m"
-	} else if statename == "BACKOFF" || statename == "FATAL" {
+	} else if statename == "BACKOFF" || statename == "FATAL" || statename == "QUARANTINED" || statename == "COOLDOWN" {
 		// red
 		return "\x1b[0;31m"
 	} else {
@@ -454,7 +478,61 @@ func (lc *LogtailCommand) tailLog(program string, dev string) error {
 			os.Stderr.Write(buf[0:n])
 		}
 	}
-	return nil
+}
+
+// Execute print the last bytes of the stdout or stderr log of a program, and the log written
+// later if -f is set
+func (tc *TailCommand) Execute(args []string) error {
+	if len(args) < 1 || len(args) > 2 || (len(args) == 2 && args[1] != "stdout" && args[1] != "stderr") {
+		err := fmt.Errorf("Invalid arguments.\nUsage: supervisord ctl tail [-f] <program> [stdout|stderr]")
+		fmt.Printf("%v\n", err)
+		return err
+	}
+	program, stream := args[0], "stdout"
+	if len(args) == 2 {
+		stream = args[1]
+	}
+	rpcc := ctlCommand.createRPCClient()
+	// the offset beyond the end of the log is replied with the log size
+	reply, err := rpcc.TailProcessLog(program, stream, math.MaxInt32, 0)
+	if err != nil {
+		fmt.Printf("Fail to tail the %s of %s: %v\n", stream, program, err)
+		return err
+	}
+	offset := reply.Offset - tailLength
+	if offset < 0 {
+		offset = 0
+	}
+	for {
+		reply, err = rpcc.TailProcessLog(program, stream, offset, 65536)
+		if err != nil {
+			fmt.Printf("Fail to tail the %s of %s: %v\n", stream, program, err)
+			return err
+		}
+		if reply.Offset < offset {
+			// the log is rotated, read the new log from the beginning
+			offset = 0
+			continue
+		}
+		tc.printLog(reply)
+		if reply.Offset > offset {
+			offset = reply.Offset
+			continue
+		}
+		if !tc.Follow {
+			return nil
+		}
+		time.Sleep(tailFollowInterval)
+	}
+}
+
+// print the log data, which may be encoded with base64
+func (tc *TailCommand) printLog(reply xmlrpcclient.TailLogReply) {
+	if !reply.Binary {
+		os.Stdout.WriteString(reply.LogData)
+	} else if data, err := base64.StdEncoding.DecodeString(reply.LogData); err == nil {
+		os.Stdout.Write(data)
+	}
 }
 
 // Execute check if the number of arguments is ok
@@ -504,6 +582,10 @@ func init() {
 		"get the pid of specified program",
 		"get the pid of specified program",
 		&pidCommand)
+	ctlCmd.AddCommand("tail",
+		"print the end of the log of the program",
+		"print the last bytes of the stdout (default) or stderr log of the program, keep printing the log written later with -f",
+		&tailCommand)
 	ctlCmd.AddCommand("logtail",
 		"get the standard output&standard error of the program",
 		"get the standard output&standard error of the program",
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ochinchina/supervisord/config"
+)
+
+// the serverurl of [supervisorctl] to connect to the http server set in the same configuration
+const autoServerURL = "AUTO"
+
+// the serverurl used if it is not set and no http server is found in the configuration
+const defaultServerURL = "http://localhost:9001"
+
+// check if the serverurl is AUTO, so the http server is discovered from the configuration
+func isAutoServerURL(serverurl string) bool {
+	return serverurl == "" || strings.EqualFold(serverurl, autoServerURL)
+}
+
+// the configuration read by the ctl command, it is loaded once
+var ctlConfig *config.Config
+var ctlConfigOnce sync.Once
+
+// load the configuration found like supervisord does, nil if it can't be found
+func loadCtlConfig() *config.Config {
+	ctlConfigOnce.Do(func() {
+		options.Configuration, _ = findSupervisordConf()
+		if _, err := os.Stat(options.Configuration); err == nil {
+			ctlConfig = config.NewConfig(options.Configuration)
+			ctlConfig.Load()
+		}
+	})
+	return ctlConfig
+}
+
+// discover the url of the http server in [inet_http_server] or [unix_http_server] of the
+// configuration, the tcp http server is preferred. The server section is also returned to get
+// its credentials
+func discoverHTTPServer(cfg *config.Config) (string, *config.Entry, bool) {
+	if entry, ok := cfg.GetInetHTTPServer(); ok {
+		if host, port, err := parseInetListenAddr(entry.GetString("port", "")); err == nil {
+			if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+				host = "localhost"
+			}
+			scheme := "http"
+			if getHTTPTLSFiles(entry).CertFile != "" {
+				scheme = "https"
+			}
+			return scheme + "://" + net.JoinHostPort(host, port), entry, true
+		}
+	}
+	if entry, ok := cfg.GetUnixHTTPServer(); ok {
+		env := config.NewStringExpression("here", cfg.GetConfigFileDir())
+		if sockFile, err := env.Eval(entry.GetString("file", "/tmp/supervisord.sock")); err == nil {
+			return "unix://" + sockFile, entry, true
+		}
+	}
+	return "", nil, false
+}
+
+// get the serverurl from the command line, [supervisorctl] or the http server discovered from the
+// configuration if it is AUTO or not set
+func getCtlServerURL(cmdLineURL string, cfg *config.Config) string {
+	if !isAutoServerURL(cmdLineURL) {
+		return cmdLineURL
+	}
+	if cfg == nil {
+		return defaultServerURL
+	}
+	if entry, ok := cfg.GetSupervisorctl(); ok && !isAutoServerURL(entry.GetString("serverurl", "")) {
+		return entry.GetString("serverurl", "")
+	}
+	if serverurl, _, ok := discoverHTTPServer(cfg); ok {
+		return serverurl
+	}
+	return defaultServerURL
+}
+
+// get the username or password from the command line or [supervisorctl]. If the serverurl is
+// discovered from the configuration, the credentials of the discovered http server are used
+// if [supervisorctl] does not set them
+func getCtlCredential(cmdLineValue string, cmdLineURL string, cfg *config.Config, key string) string {
+	if cmdLineValue != "" || cfg == nil {
+		return cmdLineValue
+	}
+	serverurl := ""
+	if entry, ok := cfg.GetSupervisorctl(); ok {
+		if value := entry.GetString(key, ""); value != "" {
+			return value
+		}
+		serverurl = entry.GetString("serverurl", "")
+	}
+	if isAutoServerURL(cmdLineURL) && isAutoServerURL(serverurl) {
+		if _, entry, ok := discoverHTTPServer(cfg); ok {
+			return entry.GetString(key, "")
+		}
+	}
+	return ""
+}
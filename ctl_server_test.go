@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGetCtlServerURL(t *testing.T) {
+	tests := []struct {
+		content   string
+		serverurl string
+		user      string
+	}{
+		{"[inet_http_server]\nport=:9002\nusername=admin\npassword=secret\n", "http://localhost:9002", "admin"},
+		{"[inet_http_server]\nport=[::1]:9002\n[supervisorctl]\nserverurl=AUTO\nusername=ops\n", "http://[::1]:9002", "ops"},
+		{"[unix_http_server]\nfile=/tmp/supervisord-test.sock\nusername=admin\n[supervisorctl]\nserverurl=auto\n", "unix:///tmp/supervisord-test.sock", "admin"},
+		{"[inet_http_server]\nport=127.0.0.1:9002\nusername=admin\n[supervisorctl]\nserverurl=http://127.0.0.1:9003\n", "http://127.0.0.1:9003", ""},
+		{"[program:test]\ncommand=/bin/true\n", defaultServerURL, ""},
+	}
+	for _, test := range tests {
+		cfg := loadTestSupervisor(t, test.content).GetConfig()
+		if serverurl := getCtlServerURL("", cfg); serverurl != test.serverurl {
+			t.Errorf("expect the serverurl %s but got %s from %q", test.serverurl, serverurl, test.content)
+		}
+		if user := getCtlCredential("", "", cfg, "username"); user != test.user {
+			t.Errorf("expect the user %q but got %q from %q", test.user, user, test.content)
+		}
+	}
+
+	cfg := loadTestSupervisor(t, tests[0].content).GetConfig()
+	if serverurl := getCtlServerURL("http://remote:9001", cfg); serverurl != "http://remote:9001" {
+		t.Errorf("expect the serverurl in the command line but got %s", serverurl)
+	}
+	if user := getCtlCredential("", "http://remote:9001", cfg, "username"); user != "" {
+		t.Errorf("expect the credentials of the discovered server are not sent to the serverurl in the command line but got %q", user)
+	}
+}
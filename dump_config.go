@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/ochinchina/supervisord/config"
+	"github.com/ochinchina/supervisord/process"
+)
+
+// print the effective configuration of the programs and event listeners without starting
+// anything. The configuration is loaded with the includes, the [program-default] section and
+// the built-in default values applied, and the %(...)s expressions expanded
+//
+// Return the exit code: 0 if the configuration is loaded, otherwise 1
+func dumpConfiguration() int {
+	loadEnvFile()
+	s, err := createSupervisor()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fail to load the public key: %v\n", err)
+		return 1
+	}
+	if _, err = s.GetConfig().Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "fail to load the configuration %s: %v\n", s.GetConfig().GetConfigFile(), err)
+		return 1
+	}
+	entries := append(s.GetConfig().GetPrograms(), s.GetConfig().GetEventListeners()...)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	for _, entry := range entries {
+		writeEffectiveSettings(os.Stdout, entry)
+	}
+	return 0
+}
+
+// write the effective settings of the entry as an ini section with sorted keys
+func writeEffectiveSettings(w io.Writer, entry *config.Entry) {
+	settings := entry.GetEffectiveSettings()
+	if dir, ok := settings["directory"]; ok {
+		if expandDir, err := process.PathExpand(dir); err == nil {
+			settings["directory"] = expandDir
+		}
+	}
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "[%s]\n", entry.Name)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s=%s\n", key, settings[key])
+	}
+	fmt.Fprintln(w)
+}
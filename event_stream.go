@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ochinchina/supervisord/events"
+	"github.com/ochinchina/supervisord/types"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// the number of state changes buffered for a client, the client which can't keep up is disconnected
+const eventStreamBufferSize = 256
+
+// EventStream push the state changes of the processes to the WebSocket clients, so a dashboard
+// needn't poll the process information
+type EventStream struct {
+	supervisor *Supervisor
+}
+
+// EventStreamSnapshot the first message sent to the client with the information of all the processes
+type EventStreamSnapshot struct {
+	Type      string              `json:"type"`
+	Processes []types.ProcessInfo `json:"processes"`
+}
+
+// EventStreamStateChange the message sent to the client when a process changes its state
+type EventStreamStateChange struct {
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Group     string `json:"group"`
+	FromState string `json:"from_state"`
+	State     string `json:"state"`
+	Time      int    `json:"time"`
+}
+
+// NewEventStream create an EventStream object
+func NewEventStream(supervisor *Supervisor) *EventStream {
+	return &EventStream{supervisor: supervisor}
+}
+
+// CreateHandler create the /ws/events http handler
+func (es *EventStream) CreateHandler() http.Handler {
+	// the clients are authenticated like the other http interfaces, so the Origin is not checked
+	return websocket.Server{Handler: es.serve}
+}
+
+// convert the state in the event like RUNNING to the state name in the process information like Running
+func toStateName(state string) string {
+	if state == "" {
+		return state
+	}
+	return state[:1] + strings.ToLower(state[1:])
+}
+
+// send the snapshot of all the processes and then each state change until the client disconnects
+func (es *EventStream) serve(ws *websocket.Conn) {
+	defer ws.Close()
+	changes := make(chan *events.ProcessStateEvent, eventStreamBufferSize)
+	overflow := make(chan struct{})
+	var overflowOnce sync.Once
+	// subscribe before taking the snapshot, so no state change is missed
+	unsubscribe := events.Subscribe([]string{"PROCESS_STATE"}, func(event events.Event) {
+		stateEvent, ok := event.(*events.ProcessStateEvent)
+		if !ok {
+			return
+		}
+		select {
+		case changes <- stateEvent:
+		default:
+			overflowOnce.Do(func() { close(overflow) })
+		}
+	})
+	defer unsubscribe()
+
+	snapshot := struct{ AllProcessInfo []types.ProcessInfo }{}
+	es.supervisor.GetAllProcessInfo(ws.Request(), nil, &snapshot)
+	if err := websocket.JSON.Send(ws, EventStreamSnapshot{Type: "snapshot", Processes: snapshot.AllProcessInfo}); err != nil {
+		return
+	}
+
+	// the messages from the client are discarded, the read fails after the client disconnects
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		var message string
+		for websocket.Message.Receive(ws, &message) == nil {
+		}
+	}()
+
+	for {
+		select {
+		case event := <-changes:
+			change := EventStreamStateChange{Type: "state",
+				Name:      event.GetProcessName(),
+				Group:     event.GetGroupName(),
+				FromState: event.GetFromState(),
+				State:     toStateName(event.GetState()),
+				Time:      int(time.Now().Unix())}
+			if proc := es.supervisor.GetManager().Find(change.Name); proc != nil {
+				change.Group = proc.GetGroup()
+			}
+			if err := websocket.JSON.Send(ws, change); err != nil {
+				return
+			}
+		case <-overflow:
+			zap.S().Warnw("the event stream client can't keep up with the state changes, disconnect it", "client", ws.Request().RemoteAddr)
+			return
+		case <-disconnected:
+			return
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ochinchina/supervisord/events"
+	"golang.org/x/net/websocket"
+)
+
+func TestEventStream(t *testing.T) {
+	s := loadTestSupervisor(t, "[program:test]\ncommand=/bin/true\n")
+	s.createPrograms(nil)
+	server := httptest.NewServer(NewEventStream(s).CreateHandler())
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/events"
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatalf("fail to connect to the event stream: %v", err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	snapshot := EventStreamSnapshot{}
+	if err = websocket.JSON.Receive(ws, &snapshot); err != nil {
+		t.Fatalf("fail to receive the snapshot: %v", err)
+	}
+	if snapshot.Type != "snapshot" || len(snapshot.Processes) != 1 || snapshot.Processes[0].Name != "test" {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+
+	events.EmitEvent(events.CreateProcessRunningEvent("test", "", "Starting", 123))
+	change := EventStreamStateChange{}
+	if err = websocket.JSON.Receive(ws, &change); err != nil {
+		t.Fatalf("fail to receive the state change: %v", err)
+	}
+	if change.Type != "state" || change.Name != "test" || change.Group != "test" || change.FromState != "Starting" || change.State != "Running" {
+		t.Errorf("unexpected state change %+v", change)
+	}
+}
@@ -54,6 +54,8 @@ type EventListenerManager struct {
 	namedListeners map[string]*EventListener
 	//mapping between the event name and the event listeners
 	eventListeners map[string]map[*EventListener]bool
+	//number of registered listeners, checked before emitting any event
+	listenerCount int32
 }
 
 // EventPoolSerial manage the event serial generation
@@ -88,6 +90,8 @@ type EventListener struct {
 	stdin      *bufio.Reader
 	stdout     io.Writer
 	bufferSize int
+	// deliver the event rejected by the listener with FAIL result again
+	redeliverFailedEvents bool
 }
 
 // NewEventListener create a NewEventListener object
@@ -97,16 +101,32 @@ func NewEventListener(pool string,
 	stdout io.Writer,
 	bufferSize int) *EventListener {
 	evtListener := &EventListener{pool: pool,
-		server:     server,
-		cond:       sync.NewCond(new(sync.Mutex)),
-		events:     list.New(),
-		stdin:      bufio.NewReader(stdin),
-		stdout:     stdout,
-		bufferSize: bufferSize}
+		server:                server,
+		cond:                  sync.NewCond(new(sync.Mutex)),
+		events:                list.New(),
+		stdin:                 bufio.NewReader(stdin),
+		stdout:                stdout,
+		bufferSize:            bufferSize,
+		redeliverFailedEvents: true}
 	evtListener.start()
 	return evtListener
 }
 
+// SetRedeliverFailedEvents set if the event rejected by the listener with a FAIL result (or an
+// unknown result) is delivered again after the listener is READY, or it is discarded. The
+// rejected events are delivered again by default
+func (el *EventListener) SetRedeliverFailedEvents(redeliver bool) {
+	el.cond.L.Lock()
+	defer el.cond.L.Unlock()
+	el.redeliverFailedEvents = redeliver
+}
+
+func (el *EventListener) isRedeliverFailedEvents() bool {
+	el.cond.L.Lock()
+	defer el.cond.L.Unlock()
+	return el.redeliverFailedEvents
+}
+
 func (el *EventListener) getFirstEvent() ([]byte, bool) {
 	el.cond.L.Lock()
 
@@ -158,12 +178,18 @@ func (el *EventListener) start() {
 						zap.S().Infow("succeed to send the event", "eventListener", el.pool)
 						el.removeFirstEvent()
 						break
-					} else if result == "FAIL" {
-						zap.S().Warnw("fail to send the event", "eventListener", el.pool)
-						break
+					}
+					if result != "FAIL" {
+						zap.S().Warnw("unknown result from listener, take it as FAIL", "eventListener", el.pool, "result", result)
+					}
+					header := string(b[:bytes.IndexByte(b, '\n')])
+					if el.isRedeliverFailedEvents() {
+						zap.S().Warnw("the event is rejected by the listener, deliver it again", "eventListener", el.pool, "event", header)
 					} else {
-						zap.S().Warnw("unknown result from listener", "eventListener", el.pool, "result", result)
+						zap.S().Warnw("the event is rejected by the listener, discard it", "eventListener", el.pool, "event", header)
+						el.removeFirstEvent()
 					}
+					break
 				}
 			}
 		}
@@ -254,6 +280,7 @@ var eventTypeDerives = map[string][]string{
 	"PROCESS_STATE_EXITED":             {"EVENT", "PROCESS_STATE"},
 	"PROCESS_STATE_STOPPED":            {"EVENT", "PROCESS_STATE"},
 	"PROCESS_STATE_FATAL":              {"EVENT", "PROCESS_STATE"},
+	"PROCESS_STATE_QUARANTINED":        {"EVENT", "PROCESS_STATE"},
 	"PROCESS_STATE_UNKNOWN":            {"EVENT", "PROCESS_STATE"},
 	"REMOTE_COMMUNICATION":             {"EVENT"},
 	"PROCESS_LOG_STDOUT":               {"EVENT", "PROCESS_LOG"},
@@ -266,13 +293,24 @@ var eventTypeDerives = map[string][]string{
 	"TICK_60":                          {"EVENT", "TICK"},
 	"TICK_3600":                        {"EVENT", "TICK"},
 	"PROCESS_GROUP_ADDED":              {"EVENT", "PROCESS_GROUP"},
-	"PROCESS_GROUP_REMOVED":            {"EVENT", "PROCESS_GROUP"}}
+	"PROCESS_GROUP_REMOVED":            {"EVENT", "PROCESS_GROUP"},
+	"MEMORY_LIMIT_EXCEEDED":            {"EVENT", "MEMORY_LIMIT"},
+	"MEMORY_LIMIT_PROCESS_SHED":        {"EVENT", "MEMORY_LIMIT"},
+	"LOG_BACKUP_PRUNED":                {"EVENT"},
+	"PROCESS_FATAL":                    {"EVENT"}}
 var eventSerial uint64
 var eventListenerManager = NewEventListenerManager()
 var eventPoolSerial = NewEventPoolSerial()
-
-func init() {
-	startTickTimer()
+var tickTimerOnce sync.Once
+
+// start the TICK timer only when the first listener subscribes to TICK events
+func startTickTimerOnDemand(events map[string]bool) {
+	for event := range events {
+		if strings.HasPrefix(event, "TICK_") {
+			tickTimerOnce.Do(startTickTimer)
+			return
+		}
+	}
 }
 
 func startTickTimer() {
@@ -315,6 +353,20 @@ func (em *EventListenerManager) registerEventListener(eventListenerName string,
 	listener *EventListener) {
 
 	em.namedListeners[eventListenerName] = listener
+	allEvents := getFinalEvents(events)
+	for event := range allEvents {
+		zap.S().Infow("register event listener", "eventListener", eventListenerName, "event", event)
+		if _, ok := em.eventListeners[event]; !ok {
+			em.eventListeners[event] = make(map[*EventListener]bool)
+		}
+		em.eventListeners[event][listener] = true
+	}
+	atomic.StoreInt32(&em.listenerCount, int32(len(em.namedListeners)))
+	startTickTimerOnDemand(allEvents)
+}
+
+// get the final events of the events, an abstract event like PROCESS_STATE is replaced by all its derived events
+func getFinalEvents(events []string) map[string]bool {
 	allEvents := make(map[string]bool)
 	for _, event := range events {
 		for k, values := range eventTypeDerives {
@@ -329,13 +381,7 @@ func (em *EventListenerManager) registerEventListener(eventListenerName string,
 			}
 		}
 	}
-	for event := range allEvents {
-		zap.S().Infow("register event listener", "eventListener", eventListenerName, "event", event)
-		if _, ok := em.eventListeners[event]; !ok {
-			em.eventListeners[event] = make(map[*EventListener]bool)
-		}
-		em.eventListeners[event][listener] = true
-	}
+	return allEvents
 }
 
 // RegisterEventListener register the event listener to accept the emitted events
@@ -356,6 +402,7 @@ func (em *EventListenerManager) unregisterEventListener(eventListenerName string
 
 			delete(listeners, listener)
 		}
+		atomic.StoreInt32(&em.listenerCount, int32(len(em.namedListeners)))
 		return listener
 	}
 	return nil
@@ -366,8 +413,16 @@ func UnregisterEventListener(eventListenerName string) *EventListener {
 	return eventListenerManager.unregisterEventListener(eventListenerName)
 }
 
+// HasListeners return true if at least one event listener is registered
+func (em *EventListenerManager) HasListeners() bool {
+	return atomic.LoadInt32(&em.listenerCount) > 0
+}
+
 // EmitEvent emit an event to all the listeners managed by this manager
 func (em *EventListenerManager) EmitEvent(event Event) {
+	if !em.HasListeners() {
+		return
+	}
 	listeners, ok := em.eventListeners[event.GetType()]
 	if ok {
 		zap.S().Infow("process event", "event", event.GetType())
@@ -398,6 +453,89 @@ func (r *RemoteCommunicationEvent) GetBody() string {
 	return fmt.Sprintf("type:%s\n%s", r.typ, r.data)
 }
 
+// MemoryLimitEvent the event emitted when the total memory of the programs exceeds the limit
+// or a program is stopped to reduce the total memory
+type MemoryLimitEvent struct {
+	BaseEvent
+	processName string
+	groupName   string
+	rss         int64
+	totalRSS    int64
+	limit       int64
+}
+
+// CreateMemoryLimitExceededEvent create the event emitted when the total memory of the programs exceeds the limit
+func CreateMemoryLimitExceededEvent(totalRSS int64, limit int64) *MemoryLimitEvent {
+	r := &MemoryLimitEvent{totalRSS: totalRSS, limit: limit}
+	r.eventType = "MEMORY_LIMIT_EXCEEDED"
+	r.serial = nextEventSerial()
+	return r
+}
+
+// CreateMemoryLimitProcessShedEvent create the event emitted when a program using rss bytes is
+// stopped because the total memory of the programs exceeds the limit
+func CreateMemoryLimitProcessShedEvent(process string, group string, rss int64, totalRSS int64, limit int64) *MemoryLimitEvent {
+	r := &MemoryLimitEvent{processName: process, groupName: group, rss: rss, totalRSS: totalRSS, limit: limit}
+	r.eventType = "MEMORY_LIMIT_PROCESS_SHED"
+	r.serial = nextEventSerial()
+	return r
+}
+
+// GetBody get the body of memory limit event
+func (m *MemoryLimitEvent) GetBody() string {
+	if m.processName == "" {
+		return fmt.Sprintf("total_rss:%d limit:%d", m.totalRSS, m.limit)
+	}
+	return fmt.Sprintf("processname:%s groupname:%s rss:%d total_rss:%d limit:%d", m.processName, m.groupName, m.rss, m.totalRSS, m.limit)
+}
+
+// LogBackupPrunedEvent the event emitted when a log backup file is removed because the total size
+// of the log files exceeds the limit
+type LogBackupPrunedEvent struct {
+	BaseEvent
+	file  string
+	size  int64
+	total int64
+	limit int64
+}
+
+// CreateLogBackupPrunedEvent create the event emitted when the log backup file of size bytes is
+// removed, the total is the size of all the log files after it is removed
+func CreateLogBackupPrunedEvent(file string, size int64, total int64, limit int64) *LogBackupPrunedEvent {
+	r := &LogBackupPrunedEvent{file: file, size: size, total: total, limit: limit}
+	r.eventType = "LOG_BACKUP_PRUNED"
+	r.serial = nextEventSerial()
+	return r
+}
+
+// GetBody get the body of the log backup pruned event
+func (l *LogBackupPrunedEvent) GetBody() string {
+	return fmt.Sprintf("file:%s size:%d total:%d limit:%d", l.file, l.size, l.total, l.limit)
+}
+
+// ProcessFatalEvent the event emitted when a program gives up retrying and enters the fatal state
+type ProcessFatalEvent struct {
+	BaseEvent
+	processName string
+	groupName   string
+	exitStatus  int
+	tries       int
+}
+
+// NewProcessFatalEvent create the event emitted when a program enters the fatal state after it is
+// started tries times, the exitStatus is the exit status of its last run or -1 if unknown
+func NewProcessFatalEvent(process string, group string, exitStatus int, tries int) *ProcessFatalEvent {
+	r := &ProcessFatalEvent{processName: process, groupName: group, exitStatus: exitStatus, tries: tries}
+	r.eventType = "PROCESS_FATAL"
+	r.serial = nextEventSerial()
+	return r
+}
+
+// GetBody get the body of the process fatal event
+func (pf *ProcessFatalEvent) GetBody() string {
+	return fmt.Sprintf("processname:%s groupname:%s exitstatus:%d tries:%d", pf.processName, pf.groupName, pf.exitStatus, pf.tries)
+}
+
 // ProcCommEvent process communication event definition
 type ProcCommEvent struct {
 	BaseEvent
@@ -425,9 +563,60 @@ func (p *ProcCommEvent) GetBody() string {
 	return fmt.Sprintf("processname:%s groupname:%s pid:%d\n%s", p.processName, p.groupName, p.pid, p.data)
 }
 
-// EmitEvent emit an event to default event listener manager
+// EmitEvent emit an event to default event listener manager and the subscribers
 func EmitEvent(event Event) {
 	eventListenerManager.EmitEvent(event)
+	notifySubscribers(event)
+}
+
+// subscriber receives the events in supervisord itself, unlike the event listener programs
+type subscriber struct {
+	events  map[string]bool
+	handler func(event Event)
+}
+
+var (
+	subscribersLock sync.RWMutex
+	subscribers     = make(map[*subscriber]bool)
+	subscriberCount int32
+)
+
+// Subscribe call handler for each emitted event in events (like PROCESS_STATE), until the returned
+// unsubscribe function is called. The handler is called while the event is emitted, so it must not block
+func Subscribe(events []string, handler func(event Event)) (unsubscribe func()) {
+	sub := &subscriber{events: getFinalEvents(events), handler: handler}
+	subscribersLock.Lock()
+	subscribers[sub] = true
+	atomic.StoreInt32(&subscriberCount, int32(len(subscribers)))
+	subscribersLock.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			subscribersLock.Lock()
+			delete(subscribers, sub)
+			atomic.StoreInt32(&subscriberCount, int32(len(subscribers)))
+			subscribersLock.Unlock()
+		})
+	}
+}
+
+func notifySubscribers(event Event) {
+	if atomic.LoadInt32(&subscriberCount) == 0 {
+		return
+	}
+	subscribersLock.RLock()
+	defer subscribersLock.RUnlock()
+	for sub := range subscribers {
+		if sub.events[event.GetType()] {
+			sub.handler(event)
+		}
+	}
+}
+
+// HasListeners return true if any event listener is registered to the default event listener manager.
+// Callers can use it to skip building events nobody will receive
+func HasListeners() bool {
+	return eventListenerManager.HasListeners()
 }
 
 // TickEvent the tick event definition
@@ -558,6 +747,26 @@ type ProcessStateEvent struct {
 	pid         int
 }
 
+// GetProcessName get the name of the process whose state is changed
+func (pse *ProcessStateEvent) GetProcessName() string {
+	return pse.processName
+}
+
+// GetGroupName get the group of the process whose state is changed
+func (pse *ProcessStateEvent) GetGroupName() string {
+	return pse.groupName
+}
+
+// GetFromState get the state of the process before the change
+func (pse *ProcessStateEvent) GetFromState() string {
+	return pse.fromState
+}
+
+// GetState get the state of the process after the change, like RUNNING for PROCESS_STATE_RUNNING
+func (pse *ProcessStateEvent) GetState() string {
+	return strings.TrimPrefix(pse.GetType(), "PROCESS_STATE_")
+}
+
 // CreateProcessStartingEvent create a process starting event
 func CreateProcessStartingEvent(process string,
 	group string,
@@ -670,6 +879,23 @@ func CreateProcessFatalEvent(process string,
 	return r
 }
 
+// CreateProcessQuarantinedEvent create process quarantined event, the tries is how many
+// times the process entered the fatal state
+func CreateProcessQuarantinedEvent(process string,
+	group string,
+	fromState string,
+	tries int) *ProcessStateEvent {
+	r := &ProcessStateEvent{processName: process,
+		groupName: group,
+		fromState: fromState,
+		tries:     tries,
+		expected:  -1,
+		pid:       0}
+	r.eventType = "PROCESS_STATE_QUARANTINED"
+	r.serial = nextEventSerial()
+	return r
+}
+
 // CreateProcessUnknownEvent create process state unknown event
 func CreateProcessUnknownEvent(process string,
 	group string,
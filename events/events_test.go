@@ -84,6 +84,31 @@ func TestEventListener(t *testing.T) {
 	eventListenerManager.unregisterEventListener("pool-1")
 }
 
+func TestEventListenerDiscardFailedEvent(t *testing.T) {
+	r1, w1 := io.Pipe()
+	r2, w2 := io.Pipe()
+	reader := bufio.NewReader(r1)
+
+	listener := NewEventListener("pool-3", "supervisor", r2, w1, 10)
+	listener.SetRedeliverFailedEvents(false)
+	listener.HandleEvent(NewRemoteCommunicationEvent("type-1", "rejected event"))
+	listener.HandleEvent(NewRemoteCommunicationEvent("type-1", "next event"))
+	w2.Write([]byte("READY\n"))
+	if _, body := readEvent(reader); body != "type:type-1\nrejected event" {
+		t.Errorf("unexpected event body %q", body)
+	}
+	w2.Write([]byte("RESULT 4\nFAIL"))
+	w2.Write([]byte("READY\n"))
+	if _, body := readEvent(reader); body != "type:type-1\nnext event" {
+		t.Errorf("expect the rejected event is discarded but got %q", body)
+	}
+	w2.Write([]byte("RESULT 2\nOK"))
+	w2.Close()
+	r2.Close()
+	r1.Close()
+	w1.Close()
+}
+
 func TestProcCommEventCapture(t *testing.T) {
 	r1, w1 := io.Pipe()
 	r2, w2 := io.Pipe()
@@ -192,6 +217,26 @@ func TestProcessFatalEvent(t *testing.T) {
 	}
 }
 
+func TestNewProcessFatalEvent(t *testing.T) {
+	event := NewProcessFatalEvent("proc-1", "group-1", 2, 4)
+	if event.GetType() != "PROCESS_FATAL" {
+		t.Error("Fail to creating the process fatal event")
+	}
+	if event.GetBody() != "processname:proc-1 groupname:group-1 exitstatus:2 tries:4" {
+		t.Error("Fail to encode the process fatal event")
+	}
+}
+
+func TestProcessQuarantinedEvent(t *testing.T) {
+	event := CreateProcessQuarantinedEvent("proc-1", "group-1", "FATAL", 3)
+	if event.GetType() != "PROCESS_STATE_QUARANTINED" {
+		t.Error("Fail to creating the process quarantined event")
+	}
+	if event.GetBody() != "processname:proc-1 groupname:group-1 from_state:FATAL tries:3" {
+		t.Error("Fail to encode the process quarantined event")
+	}
+}
+
 func TestProcessUnknownEvent(t *testing.T) {
 	event := CreateProcessUnknownEvent("proc-1", "group-1", "BACKOFF")
 	if event.GetType() != "PROCESS_STATE_UNKNOWN" {
@@ -201,3 +246,64 @@ func TestProcessUnknownEvent(t *testing.T) {
 		t.Error("Fail to encode the process unknown event")
 	}
 }
+
+func TestMemoryLimitEvent(t *testing.T) {
+	event := CreateMemoryLimitExceededEvent(2048, 1024)
+	if event.GetType() != "MEMORY_LIMIT_EXCEEDED" || event.GetBody() != "total_rss:2048 limit:1024" {
+		t.Error("Fail to encode the memory limit exceeded event")
+	}
+	event = CreateMemoryLimitProcessShedEvent("proc-1", "group-1", 512, 2048, 1024)
+	if event.GetType() != "MEMORY_LIMIT_PROCESS_SHED" || event.GetBody() != "processname:proc-1 groupname:group-1 rss:512 total_rss:2048 limit:1024" {
+		t.Error("Fail to encode the memory limit process shed event")
+	}
+}
+
+func TestLogBackupPrunedEvent(t *testing.T) {
+	event := CreateLogBackupPrunedEvent("/var/log/web.log.3", 512, 1536, 2048)
+	if event.GetType() != "LOG_BACKUP_PRUNED" || event.GetBody() != "file:/var/log/web.log.3 size:512 total:1536 limit:2048" {
+		t.Error("Fail to encode the log backup pruned event")
+	}
+}
+
+func TestEmitEventWithoutListeners(t *testing.T) {
+	em := NewEventListenerManager()
+	if em.HasListeners() {
+		t.Error("A new event listener manager should not have listeners")
+	}
+	em.EmitEvent(NewRemoteCommunicationEvent("type-1", "nobody listens"))
+
+	r, w := io.Pipe()
+	listener := NewEventListener("pool-2", "supervisor", r, w, 10)
+	em.registerEventListener("pool-2", []string{"REMOTE_COMMUNICATION"}, listener)
+	if !em.HasListeners() {
+		t.Error("Fail to count the registered event listener")
+	}
+	em.unregisterEventListener("pool-2")
+	if em.HasListeners() {
+		t.Error("Fail to count the unregistered event listener")
+	}
+	w.Close()
+	r.Close()
+}
+
+func TestSubscribe(t *testing.T) {
+	received := make([]Event, 0)
+	unsubscribe := Subscribe([]string{"PROCESS_STATE"}, func(event Event) {
+		received = append(received, event)
+	})
+	EmitEvent(CreateProcessRunningEvent("test", "test", "STARTING", 123))
+	EmitEvent(NewRemoteCommunicationEvent("test", "data"))
+	unsubscribe()
+	EmitEvent(CreateProcessStoppedEvent("test", "test", "RUNNING", 123))
+
+	if len(received) != 1 || received[0].GetType() != "PROCESS_STATE_RUNNING" {
+		t.Fatalf("expect only the state event before unsubscribe but got %v", received)
+	}
+	stateEvent := received[0].(*ProcessStateEvent)
+	if stateEvent.GetProcessName() != "test" || stateEvent.GetFromState() != "STARTING" || stateEvent.GetState() != "RUNNING" {
+		t.Errorf("unexpected state event %+v", stateEvent)
+	}
+	if subscriberCount != 0 {
+		t.Errorf("expect no subscriber after unsubscribe but got %d", subscriberCount)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// parse the port setting of [inet_http_server] like :9001, 127.0.0.1:9001, [::1]:9001 or
+// localhost:9001 to the host and the port. The empty host means all the interfaces
+func parseInetListenAddr(listenAddr string) (string, string, error) {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid address %q of inet_http_server, it must be like :9001, 127.0.0.1:9001, [::1]:9001 or localhost:9001: %v", listenAddr, err)
+	}
+	if _, err = net.LookupPort("tcp", port); err != nil {
+		return "", "", fmt.Errorf("invalid port %q in the address %q of inet_http_server: %v", port, listenAddr, err)
+	}
+	return host, port, nil
+}
+
+// lookupHost resolve the hostname to its addresses, it is replaced in the tests so they don't
+// depend on the DNS
+var lookupHost = net.LookupHost
+
+// resolve the port setting of [inet_http_server] to the addresses to listen on. The hostname is
+// resolved to all its addresses, so the http server is served on each of them
+func resolveInetListenAddrs(listenAddr string) ([]string, error) {
+	host, port, err := parseInetListenAddr(listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return []string{net.JoinHostPort(host, port)}, nil
+	}
+	ips, err := lookupHost(host)
+	if err != nil {
+		return nil, fmt.Errorf("fail to resolve the host %s of inet_http_server: %v", host, err)
+	}
+	addrs := make([]string, 0)
+	resolved := make(map[string]bool)
+	for _, ip := range ips {
+		if !resolved[ip] {
+			resolved[ip] = true
+			addrs = append(addrs, net.JoinHostPort(ip, port))
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseInetListenAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		host string
+		port string
+	}{
+		{":9001", "", "9001"},
+		{"127.0.0.1:9001", "127.0.0.1", "9001"},
+		{"[::1]:9001", "::1", "9001"},
+		{"[::]:9001", "::", "9001"},
+		{"localhost:9001", "localhost", "9001"},
+	}
+	for _, test := range tests {
+		host, port, err := parseInetListenAddr(test.addr)
+		if err != nil || host != test.host || port != test.port {
+			t.Errorf("expect %s is parsed to host %q and port %q but got %q, %q, error: %v", test.addr, test.host, test.port, host, port, err)
+		}
+	}
+
+	for _, addr := range []string{"::1:9001", "127.0.0.1", "[::1]:abc", "localhost:99999"} {
+		if _, _, err := parseInetListenAddr(addr); err == nil || !strings.Contains(err.Error(), "inet_http_server") {
+			t.Errorf("expect an error of the malformed address %s but got %v", addr, err)
+		}
+	}
+}
+
+func TestResolveInetListenAddrs(t *testing.T) {
+	if addrs, err := resolveInetListenAddrs("[::1]:9001"); err != nil || strings.Join(addrs, ",") != "[::1]:9001" {
+		t.Errorf("expect to listen on [::1]:9001 but got %v, error: %v", addrs, err)
+	}
+	addrs, err := resolveInetListenAddrs("localhost:9001")
+	if err != nil {
+		t.Skipf("localhost can't be resolved: %v", err)
+	}
+	for _, addr := range addrs {
+		if !strings.HasSuffix(addr, ":9001") || strings.Contains(addr, "localhost") {
+			t.Errorf("expect localhost is resolved to the addresses with port 9001 but got %v", addrs)
+		}
+	}
+}
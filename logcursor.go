@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/ochinchina/supervisord/config"
+	"go.uber.org/zap"
+)
+
+// logCursors the log offsets of the named consumers for each program. The cursors are
+// saved to the file so the consumers can resume reading after they are restarted
+type logCursors struct {
+	lock sync.Mutex
+	// the file to save the cursors, the cursors are only kept in memory if it is empty
+	file string
+	// consumer name => program => offset
+	cursors map[string]map[string]int
+}
+
+func newLogCursors() *logCursors {
+	return &logCursors{cursors: make(map[string]map[string]int)}
+}
+
+// set the file to save the cursors and load the saved cursors from it
+func (lc *logCursors) setFile(file string) {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	if file == lc.file {
+		return
+	}
+	lc.file = file
+	if file == "" {
+		return
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			zap.S().Errorw("fail to read the log cursor file", "file", file, "error", err)
+		}
+		return
+	}
+	cursors := make(map[string]map[string]int)
+	if err = json.Unmarshal(content, &cursors); err != nil {
+		zap.S().Errorw("fail to parse the log cursor file", "file", file, "error", err)
+		return
+	}
+	lc.cursors = cursors
+}
+
+func (lc *logCursors) get(name string, program string) int {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	return lc.cursors[name][program]
+}
+
+// set the offset of the cursor and save all the cursors to the file
+func (lc *logCursors) set(name string, program string, offset int) error {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	if _, ok := lc.cursors[name]; !ok {
+		lc.cursors[name] = make(map[string]int)
+	}
+	lc.cursors[name][program] = offset
+	if lc.file == "" {
+		return nil
+	}
+	content, err := json.Marshal(lc.cursors)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file and rename it, so the file is never half written
+	tmpFile := lc.file + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, lc.file)
+}
+
+// set the log cursor file from the "log_cursor_file" in [supervisord] section
+func (s *Supervisor) setLogCursorFile() {
+	file := ""
+	if supervisordConf, ok := s.config.GetSupervisord(); ok {
+		env := config.NewStringExpression("here", s.config.GetConfigFileDir())
+		if f, err := env.Eval(supervisordConf.GetString("log_cursor_file", "")); err == nil {
+			file = f
+		}
+	}
+	s.logCursors.setFile(file)
+}
+
+// LogCursorArgs the arguments to get or advance a log cursor
+type LogCursorArgs struct {
+	Name    string // the name of the log consumer
+	Program string // the program whose log is read
+	Offset  int    // the new offset of the cursor, only used by AdvanceLogCursor
+}
+
+// GetLogCursor get the log offset the consumer has read to for the program, 0 if the
+// consumer never advances its cursor
+func (s *Supervisor) GetLogCursor(r *http.Request, args *LogCursorArgs, reply *struct{ Offset int }) error {
+	if s.procMgr.Find(args.Program) == nil {
+		return fmt.Errorf("fail to find process %s", args.Program)
+	}
+	reply.Offset = s.logCursors.get(args.Name, args.Program)
+	return nil
+}
+
+// AdvanceLogCursor save the log offset the consumer has read to for the program
+func (s *Supervisor) AdvanceLogCursor(r *http.Request, args *LogCursorArgs, reply *struct{ Success bool }) error {
+	if s.procMgr.Find(args.Program) == nil {
+		return fmt.Errorf("fail to find process %s", args.Program)
+	}
+	if args.Name == "" || args.Offset < 0 {
+		return fmt.Errorf("invalid log cursor name %s or offset %d", args.Name, args.Offset)
+	}
+	if err := s.logCursors.set(args.Name, args.Program, args.Offset); err != nil {
+		zap.S().Errorw("fail to save the log cursor", "name", args.Name, "program", args.Program, "error", err)
+		return err
+	}
+	reply.Success = true
+	return nil
+}
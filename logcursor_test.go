@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogCursorsSavedToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cursor")
+	if err != nil {
+		t.Fatal("fail to create temp dir")
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cursors.json")
+
+	cursors := newLogCursors()
+	cursors.setFile(file)
+	if err := cursors.set("shipper", "web", 1024); err != nil {
+		t.Fatalf("fail to save the cursor: %v", err)
+	}
+
+	restored := newLogCursors()
+	restored.setFile(file)
+	if restored.get("shipper", "web") != 1024 {
+		t.Errorf("expect the cursor 1024 but got %d", restored.get("shipper", "web"))
+	}
+	if restored.get("other", "web") != 0 {
+		t.Error("the unknown cursor should be 0")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ochinchina/supervisord/logger"
+	"github.com/ochinchina/supervisord/process"
+	"go.uber.org/zap"
+)
+
+// ProcessLogExportArgs the arguments to export the program logs
+type ProcessLogExportArgs struct {
+	Names          []string // the programs, "group:*" and "group:program" are also supported
+	IncludeBackups bool     // true if the backup log files are also exported
+}
+
+// ExportProcessLogs write a tar.gz of the stdout/stderr log files of selected programs to writer.
+// The log files are streamed to the writer one by one, so the archive is never buffered in memory.
+//
+// An error is returned before anything is written if any program can't be found
+func (s *Supervisor) ExportProcessLogs(args *ProcessLogExportArgs, writer io.Writer) error {
+	procs := make([]*process.Process, 0)
+	for _, name := range args.Names {
+		found := s.procMgr.FindMatch(name)
+		if len(found) <= 0 {
+			return fmt.Errorf("fail to find process %s", name)
+		}
+		procs = append(procs, found...)
+	}
+
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	exported := make(map[string]bool)
+	for _, proc := range procs {
+		for _, logInfo := range []logger.LogInfo{proc.GetStdoutLogInfo(), proc.GetStderrLogInfo()} {
+			for _, file := range getLogFilesToExport(logInfo, args.IncludeBackups) {
+				if exported[file] {
+					continue
+				}
+				exported[file] = true
+				if err := addFileToTar(tarWriter, file, filepath.Join(proc.GetName(), filepath.Base(file))); err != nil {
+					zap.S().Errorw("fail to export log file", "program", proc.GetName(), "file", file, "error", err)
+					return err
+				}
+			}
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// get the log file and its backup files if the log is written to file
+func getLogFilesToExport(logInfo logger.LogInfo, includeBackups bool) []string {
+	result := make([]string, 0)
+	if logInfo.Type != "file" || !logInfo.Exists {
+		return result
+	}
+	result = append(result, logInfo.File)
+	if includeBackups {
+		result = append(result, logger.GetBackupFiles(logInfo.File, logInfo.Backups)...)
+	}
+	return result
+}
+
+// add the content of file to the tar with name. Only the content present when the
+// file is opened is added because the log file may be still written by the program
+func addFileToTar(tarWriter *tar.Writer, file string, name string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(fileInfo, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err = tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tarWriter, f, fileInfo.Size())
+	return err
+}
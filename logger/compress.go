@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// the suffix of the compressed backup files
+const compressedSuffix = ".gz"
+
+// SetCompress gzip each backup file in background after the log file is rotated, the compressed
+// backup file is named with the .gz suffix. The incomplete compressed backup files left by a
+// previous run are removed, and the backup files not compressed yet are compressed again
+func (l *FileLogger) SetCompress(compress bool) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	l.compress = compress
+	if !compress {
+		return
+	}
+	for _, backup := range GetBackupFiles(l.name, l.backups) {
+		if strings.HasSuffix(backup, compressedSuffix) {
+			continue
+		}
+		// the backup file is removed only after it is compressed completely, so the compressed
+		// file is incomplete if the backup file still exists
+		os.Remove(backup + compressedSuffix)
+		l.compressBackup(backup)
+	}
+}
+
+// compress the backup file in background if the compression is enabled
+func (l *FileLogger) compressBackup(backup string) {
+	if !l.compress {
+		return
+	}
+	l.compressing.Add(1)
+	go func() {
+		defer l.compressing.Done()
+		if err := compressFile(backup); err != nil && !os.IsNotExist(err) {
+			zap.S().Errorw("fail to compress the log backup file", "file", backup, "error", err)
+		}
+	}()
+}
+
+// gzip the file to file.gz with the same modification time and remove the file
+func compressFile(file string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	fileInfo, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dest := file + compressedSuffix
+	err = writeCompressedFile(dest, src, fileInfo.Mode())
+	if err != nil {
+		os.Remove(dest)
+		return err
+	}
+	// keep the modification time so the backup files rotated by time are still sorted by it
+	os.Chtimes(dest, fileInfo.ModTime(), fileInfo.ModTime())
+	return os.Remove(file)
+}
+
+func writeCompressedFile(dest string, src io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gzipWriter := gzip.NewWriter(f)
+	if _, err = io.Copy(gzipWriter, src); err != nil {
+		return err
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// JSONLineLogger wrap each line of the program stdout/stderr to a JSON object and
+// write it to the underline logger, one JSON object per line
+type JSONLineLogger struct {
+	underlineLogger Logger
+	program         string
+	group           string
+	stream          string
+	lock            sync.Mutex
+	partial         []byte
+	keepUnderline   bool
+}
+
+// the JSON object written for each line
+type jsonLogLine struct {
+	Program   string `json:"program"`
+	Group     string `json:"group"`
+	Stream    string `json:"stream"`
+	Timestamp string `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+// NewJSONLineLogger create a JSONLineLogger object, the stream is "stdout" or "stderr"
+func NewJSONLineLogger(underlineLogger Logger, program string, group string, stream string) *JSONLineLogger {
+	return &JSONLineLogger{underlineLogger: underlineLogger,
+		program: program,
+		group:   group,
+		stream:  stream}
+}
+
+// SetKeepUnderline set if the underline logger is kept open when this logger is closed. It is
+// used when the underline logger is shared with another logger which closes it
+func (l *JSONLineLogger) SetKeepUnderline(keep bool) {
+	l.keepUnderline = keep
+}
+
+// Write write the complete lines in p as JSON objects. The incomplete last line
+// is kept until the rest of it is written or the logger is closed
+func (l *JSONLineLogger) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	data := append(l.partial, p...)
+	l.partial = nil
+	for {
+		index := bytes.IndexByte(data, '\n')
+		if index < 0 {
+			break
+		}
+		if err := l.writeLine(data[0:index]); err != nil {
+			return 0, err
+		}
+		data = data[index+1:]
+	}
+	if len(data) > 0 {
+		l.partial = append([]byte(nil), data...)
+	}
+	return len(p), nil
+}
+
+func (l *JSONLineLogger) writeLine(line []byte) error {
+	b, err := json.Marshal(jsonLogLine{Program: l.program,
+		Group:     l.group,
+		Stream:    l.stream,
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Message:   string(bytes.TrimSuffix(line, []byte("\r")))})
+	if err != nil {
+		return err
+	}
+	_, err = l.underlineLogger.Write(append(b, '\n'))
+	return err
+}
+
+// Close write the incomplete last line and close the underline logger
+func (l *JSONLineLogger) Close() error {
+	l.lock.Lock()
+	if len(l.partial) > 0 {
+		l.writeLine(l.partial)
+		l.partial = nil
+	}
+	l.lock.Unlock()
+	if l.keepUnderline {
+		return nil
+	}
+	return l.underlineLogger.Close()
+}
+
+// SetPid set the pid of the program
+func (l *JSONLineLogger) SetPid(pid int) {
+	l.underlineLogger.SetPid(pid)
+}
+
+// ReadLog read the log
+func (l *JSONLineLogger) ReadLog(offset int64, length int64) (string, error) {
+	return l.underlineLogger.ReadLog(offset, length)
+}
+
+// ReadTailLog tail the log
+func (l *JSONLineLogger) ReadTailLog(offset int64, length int64) (string, int64, bool, error) {
+	return l.underlineLogger.ReadTailLog(offset, length)
+}
+
+// ClearCurLogFile clear the current log file
+func (l *JSONLineLogger) ClearCurLogFile() error {
+	return l.underlineLogger.ClearCurLogFile()
+}
+
+// ClearAllLogFile clear all the log files
+func (l *JSONLineLogger) ClearAllLogFile() error {
+	return l.underlineLogger.ClearAllLogFile()
+}
@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/ochinchina/supervisord/events"
@@ -9,6 +11,8 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 //Logger the log interface to log program stdout/stderr logs to file
@@ -24,6 +28,8 @@ type Logger interface {
 // LogEventEmitter the interface to emit log events
 type LogEventEmitter interface {
 	emitLogEvent(data string)
+	// flushLogEvent emit the buffered incomplete line, called when the log is closed
+	flushLogEvent()
 }
 
 // FileLogger log program stdout/stderr to file
@@ -35,6 +41,13 @@ type FileLogger struct {
 	file            *os.File
 	logEventEmitter LogEventEmitter
 	locker          sync.Locker
+	// rotate the log file daily or hourly besides by size, empty to rotate by size only
+	rotatePeriod string
+	// the start of the period current log file is written in if it is rotated by time
+	filePeriod time.Time
+	// gzip the backup files in background
+	compress    bool
+	compressing sync.WaitGroup
 }
 
 // SysLogger log program stdout/stderr to syslog
@@ -82,6 +95,24 @@ func (l *FileLogger) SetPid(pid int) {
 	//NOTHING TO DO
 }
 
+// SetRotatePeriod rotate the log file at the beginning of each period besides by size, the period
+// is daily or hourly. The log file is rotated to a backup file named with the start of the period,
+// like name.2021-03-01 or name.2021-03-01-13, and at most backups of them are kept. The log file
+// is rotated by size only if the period is not valid
+func (l *FileLogger) SetRotatePeriod(period string) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	if _, ok := rotatePeriodLayouts[period]; !ok {
+		period = ""
+	}
+	l.rotatePeriod = period
+	l.filePeriod = getPeriodStart(time.Now(), period)
+	// the existing log file may be written in a previous period
+	if fileInfo, err := os.Stat(l.name); err == nil {
+		l.filePeriod = getPeriodStart(fileInfo.ModTime(), period)
+	}
+}
+
 // open the file and truncate the file if trunc is true
 func (l *FileLogger) openFile(trunc bool) error {
 	if l.file != nil {
@@ -99,19 +130,62 @@ func (l *FileLogger) openFile(trunc bool) error {
 	if err != nil {
 		fmt.Printf("Fail to open log file --%s-- with error %v\n", l.name, err)
 	}
+	// the log file is opened after it is created, rotated or cleared, so count its backup files again
+	logUsage.register(l.name, l.backups)
 	return err
 }
 
 func (l *FileLogger) backupFiles() {
+	// the backup files are renamed, so wait for the backup files in compressing
+	l.compressing.Wait()
+	if l.rotatePeriod != "" {
+		l.backupDatedFiles()
+		return
+	}
 	for i := l.backups - 1; i > 0; i-- {
 		src := fmt.Sprintf("%s.%d", l.name, i)
 		dest := fmt.Sprintf("%s.%d", l.name, i+1)
-		if _, err := os.Stat(src); err == nil {
-			os.Rename(src, dest)
+		// a backup file may be compressed or not, the uncompressed one is moved last so it
+		// replaces an incomplete compressed one
+		for _, suffix := range []string{compressedSuffix, ""} {
+			if _, err := os.Stat(src + suffix); err == nil {
+				os.Remove(dest)
+				os.Remove(dest + compressedSuffix)
+				os.Rename(src+suffix, dest+suffix)
+			}
 		}
 	}
 	dest := fmt.Sprintf("%s.1", l.name)
+	os.Remove(dest + compressedSuffix)
 	os.Rename(l.name, dest)
+	l.compressBackup(dest)
+}
+
+// rename the log file to a backup file named with the start of its period and remove the
+// oldest backup files if there are more than l.backups
+func (l *FileLogger) backupDatedFiles() {
+	backup := getDatedBackupFile(l.name, l.rotatePeriod, l.filePeriod)
+	os.Rename(l.name, backup)
+	backups := getDatedBackupFiles(l.name)
+	for i := l.backups; i >= 0 && i < len(backups); i++ {
+		os.Remove(backups[i])
+	}
+	l.compressBackup(backup)
+}
+
+// rotate the log file if it is written in a previous period
+func (l *FileLogger) rotateByTime() {
+	if l.rotatePeriod == "" {
+		return
+	}
+	period := getPeriodStart(time.Now(), l.rotatePeriod)
+	if !period.Equal(l.filePeriod) {
+		l.closeFile()
+		l.backupFiles()
+		l.openFile(true)
+		l.fileSize = 0
+		l.filePeriod = period
+	}
 }
 
 // ClearCurLogFile clear the current log file contents
@@ -127,16 +201,24 @@ func (l *FileLogger) ClearAllLogFile() error {
 	l.locker.Lock()
 	defer l.locker.Unlock()
 
+	l.compressing.Wait()
 	for i := l.backups; i > 0; i-- {
-		logFile := fmt.Sprintf("%s.%d", l.name, i)
-		_, err := os.Stat(logFile)
-		if err == nil {
-			err = os.Remove(logFile)
-			if err != nil {
-				return faults.NewFault(faults.Failed, err.Error())
+		for _, suffix := range []string{"", compressedSuffix} {
+			logFile := fmt.Sprintf("%s.%d%s", l.name, i, suffix)
+			_, err := os.Stat(logFile)
+			if err == nil {
+				err = os.Remove(logFile)
+				if err != nil {
+					return faults.NewFault(faults.Failed, err.Error())
+				}
 			}
 		}
 	}
+	for _, logFile := range getDatedBackupFiles(l.name) {
+		if err := os.Remove(logFile); err != nil {
+			return faults.NewFault(faults.Failed, err.Error())
+		}
+	}
 	err := l.openFile(true)
 	if err != nil {
 		return faults.NewFault(faults.Failed, err.Error())
@@ -144,7 +226,8 @@ func (l *FileLogger) ClearAllLogFile() error {
 	return nil
 }
 
-// ReadLog read the log from current logfile
+// ReadLog read the log from current logfile. The uncompressed backup files rotated by time are
+// read before the current logfile, as if they were one file
 func (l *FileLogger) ReadLog(offset int64, length int64) (string, error) {
 	if offset < 0 && length != 0 {
 		return "", faults.NewFault(faults.BadArguments, "BAD_ARGUMENTS")
@@ -155,20 +238,15 @@ func (l *FileLogger) ReadLog(offset int64, length int64) (string, error) {
 
 	l.locker.Lock()
 	defer l.locker.Unlock()
-	f, err := os.Open(l.name)
+	f, err := openLogSpan(l.name)
 
 	if err != nil {
 		return "", faults.NewFault(faults.Failed, "FAILED")
 	}
 	defer f.Close()
 
-	//check the length of file
-	statInfo, err := f.Stat()
-	if err != nil {
-		return "", faults.NewFault(faults.Failed, "FAILED")
-	}
-
-	fileLen := statInfo.Size()
+	//check the length of the log
+	fileLen := f.size
 
 	if offset < 0 { //offset < 0 && length == 0
 		offset = fileLen + offset
@@ -203,7 +281,8 @@ func (l *FileLogger) ReadLog(offset int64, length int64) (string, error) {
 	return string(b[:n]), nil
 }
 
-// ReadTailLog tail the log of current log file
+// ReadTailLog tail the log of current log file. Like ReadLog, the uncompressed backup files
+// rotated by time are read before the current log file, so the tail continues across the rotation
 func (l *FileLogger) ReadTailLog(offset int64, length int64) (string, int64, bool, error) {
 	if offset < 0 {
 		return "", offset, false, fmt.Errorf("offset should not be less than 0")
@@ -215,20 +294,15 @@ func (l *FileLogger) ReadTailLog(offset int64, length int64) (string, int64, boo
 	defer l.locker.Unlock()
 
 	//open the file
-	f, err := os.Open(l.name)
+	f, err := openLogSpan(l.name)
 	if err != nil {
 		return "", 0, false, err
 	}
 
 	defer f.Close()
 
-	//get the length of file
-	statInfo, err := f.Stat()
-	if err != nil {
-		return "", 0, false, err
-	}
-
-	fileLen := statInfo.Size()
+	//get the length of the log
+	fileLen := f.size
 
 	//check if offset exceeds the length of file
 	if offset >= fileLen {
@@ -254,6 +328,7 @@ func (l *FileLogger) Write(p []byte) (int, error) {
 	l.locker.Lock()
 	defer l.locker.Unlock()
 
+	l.rotateByTime()
 	n, err := l.file.Write(p)
 
 	if err != nil {
@@ -269,16 +344,25 @@ func (l *FileLogger) Write(p []byte) (int, error) {
 			return n, errStat
 		}
 	}
+	logUsage.written(l.name, l.fileSize)
 	if l.fileSize >= l.maxSize {
-		l.Close()
+		l.closeFile()
 		l.backupFiles()
 		l.openFile(true)
 	}
 	return n, err
 }
 
-// Close close the file logger
+// Close close the file logger. The log file and its backup files left on the disk are still
+// counted in the total size of the log files, and the backup files can be pruned
 func (l *FileLogger) Close() error {
+	l.logEventEmitter.flushLogEvent()
+	logUsage.close(l.name)
+	return l.closeFile()
+}
+
+// close the current log file, it is reopened when rotating the log
+func (l *FileLogger) closeFile() error {
 	if l.file != nil {
 		err := l.file.Close()
 		l.file = nil
@@ -298,6 +382,7 @@ func (sl *SysLogger) Write(b []byte) (int, error) {
 
 // Close close the logger
 func (sl *SysLogger) Close() error {
+	sl.logEventEmitter.flushLogEvent()
 	if sl.logWriter == nil {
 		return errors.New("not connect to syslog server")
 	}
@@ -322,6 +407,7 @@ func (l *NullLogger) Write(p []byte) (int, error) {
 
 // Close close the logger
 func (l *NullLogger) Close() error {
+	l.logEventEmitter.flushLogEvent()
 	return nil
 }
 
@@ -409,21 +495,91 @@ type StdLogger struct {
 	NullLogger
 	logEventEmitter LogEventEmitter
 	writer          io.Writer
+	// the prefix of each line written, the lines are written only when they are complete if it is set
+	linePrefix string
+	lock       sync.Mutex
+	partial    []byte
 }
 
+// the lock of the stdout and stderr of supervisord, so the lines written by the programs
+// concurrently are not interleaved
+var stdWriteLock sync.Mutex
+
 // NewStdoutLogger create a StdLogger object
 func NewStdoutLogger(logEventEmitter LogEventEmitter) *StdLogger {
 	return &StdLogger{logEventEmitter: logEventEmitter,
 		writer: os.Stdout}
 }
 
+// SetLinePrefix set the prefix (like the program name) of each line written. The incomplete
+// last line is kept until the rest of it is written or the logger is closed
+func (l *StdLogger) SetLinePrefix(prefix string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.linePrefix = prefix
+}
+
 // Write output the log to stdout/stderr
 func (l *StdLogger) Write(p []byte) (int, error) {
-	n, err := l.writer.Write(p)
-	if err != nil {
-		l.logEventEmitter.emitLogEvent(string(p))
+	data := l.prefixCompleteLines(p)
+	if len(data) > 0 {
+		stdWriteLock.Lock()
+		_, err := l.writer.Write(data)
+		stdWriteLock.Unlock()
+		if err != nil {
+			return 0, err
+		}
 	}
-	return n, err
+	l.logEventEmitter.emitLogEvent(string(p))
+	return len(p), nil
+}
+
+// get the complete lines in p with the line prefix. The incomplete last line is kept unless it
+// is longer than maxLogEventLineBytes. p is returned as is if the line prefix is not set
+func (l *StdLogger) prefixCompleteLines(p []byte) []byte {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.linePrefix == "" {
+		return p
+	}
+	data := append(l.partial, p...)
+	l.partial = nil
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if end == 0 && len(data) < maxLogEventLineBytes {
+		l.partial = data
+		return nil
+	}
+	if end == 0 {
+		data = append(data, '\n')
+		end = len(data)
+	}
+	if end < len(data) {
+		l.partial = append([]byte(nil), data[end:]...)
+	}
+	var b bytes.Buffer
+	for _, line := range bytes.SplitAfter(data[:end], []byte("\n")) {
+		if len(line) > 0 {
+			b.WriteString(l.linePrefix)
+			b.Write(line)
+		}
+	}
+	return b.Bytes()
+}
+
+// Close write the incomplete last line
+func (l *StdLogger) Close() error {
+	l.logEventEmitter.flushLogEvent()
+	l.lock.Lock()
+	partial := l.partial
+	l.partial = nil
+	l.lock.Unlock()
+	if len(partial) > 0 {
+		stdWriteLock.Lock()
+		defer stdWriteLock.Unlock()
+		_, err := l.writer.Write(append([]byte(l.linePrefix), append(partial, '\n')...))
+		return err
+	}
+	return nil
 }
 
 // NewStderrLogger create a stderr logger
@@ -437,6 +593,7 @@ type LogCaptureLogger struct {
 	underlineLogger        Logger
 	procCommEventCapWriter io.Writer
 	procCommEventCapture   *events.ProcCommEventCapture
+	captureEnabled         func() bool
 }
 
 // NewLogCaptureLogger create a new LogCaptureLogger object
@@ -461,9 +618,17 @@ func (l *LogCaptureLogger) SetPid(pid int) {
 	l.procCommEventCapture.SetPid(pid)
 }
 
+// SetCaptureSwitch set the function to check if the capture is enabled. The log
+// is always written to the underline logger even if the capture is disabled
+func (l *LogCaptureLogger) SetCaptureSwitch(enabled func() bool) {
+	l.captureEnabled = enabled
+}
+
 // Write write the log to capture
 func (l *LogCaptureLogger) Write(p []byte) (int, error) {
-	l.procCommEventCapWriter.Write(p)
+	if l.captureEnabled == nil || l.captureEnabled() {
+		l.procCommEventCapWriter.Write(p)
+	}
 	return l.underlineLogger.Write(p)
 }
 
@@ -505,12 +670,46 @@ func NewNullLogEventEmitter() *NullLogEventEmitter {
 func (ne *NullLogEventEmitter) emitLogEvent(data string) {
 }
 
-// StdLogEventEmitter emit the Stdout/Stderr LogEvent
+// flushLogEvent nothing is buffered
+func (ne *NullLogEventEmitter) flushLogEvent() {
+}
+
+// SwitchableLogEventEmitter emit the log event only if it is enabled
+type SwitchableLogEventEmitter struct {
+	emitter LogEventEmitter
+	enabled func() bool
+}
+
+// NewSwitchableLogEventEmitter create a LogEventEmitter which emits the log event by emitter
+// only if enabled returns true
+func NewSwitchableLogEventEmitter(emitter LogEventEmitter, enabled func() bool) *SwitchableLogEventEmitter {
+	return &SwitchableLogEventEmitter{emitter: emitter, enabled: enabled}
+}
+
+// emitLogEvent emit the log if it is enabled
+func (se *SwitchableLogEventEmitter) emitLogEvent(data string) {
+	if se.enabled() {
+		se.emitter.emitLogEvent(data)
+	}
+}
+
+// flushLogEvent flush the buffered log of the emitter
+func (se *SwitchableLogEventEmitter) flushLogEvent() {
+	se.emitter.flushLogEvent()
+}
+
+// the max length of a log line in the log event, the longer line is emitted in pieces
+const maxLogEventLineBytes = 64 * 1024
+
+// StdLogEventEmitter emit the Stdout/Stderr LogEvent for each complete line of the log
 type StdLogEventEmitter struct {
 	Type        string
 	processName string
 	groupName   string
 	pidFunc     func() int
+	lock        sync.Mutex
+	// the last incomplete line which is emitted after the rest of it is written
+	partialLine []byte
 }
 
 // NewStdoutLogEventEmitter create a new StdLogEventEmitter object
@@ -529,13 +728,64 @@ func NewStderrLogEventEmitter(processName string, groupName string, procPidFunc
 		pidFunc:     procPidFunc}
 }
 
-// emitLogEvent emit stdout/stderr log with data
+// emitLogEvent emit a stdout/stderr log event for each complete line in data. Nothing is buffered
+// if no event listener is registered
 func (se *StdLogEventEmitter) emitLogEvent(data string) {
+	if !events.HasListeners() {
+		se.lock.Lock()
+		se.partialLine = nil
+		se.lock.Unlock()
+		return
+	}
+	for _, line := range se.completeLines(data) {
+		se.emitLine(line)
+	}
+}
+
+// flushLogEvent emit the incomplete last line, so the output of an exited program without the
+// trailing newline is not lost
+func (se *StdLogEventEmitter) flushLogEvent() {
+	se.lock.Lock()
+	partialLine := se.partialLine
+	se.partialLine = nil
+	se.lock.Unlock()
+	if len(partialLine) > 0 && events.HasListeners() {
+		se.emitLine(string(partialLine))
+	}
+}
+
+func (se *StdLogEventEmitter) emitLine(line string) {
 	if se.Type == "stdout" {
-		events.EmitEvent(events.CreateProcessLogStdoutEvent(se.processName, se.groupName, se.pidFunc(), data))
+		events.EmitEvent(events.CreateProcessLogStdoutEvent(se.processName, se.groupName, se.pidFunc(), line))
 	} else {
-		events.EmitEvent(events.CreateProcessLogStderrEvent(se.processName, se.groupName, se.pidFunc(), data))
+		events.EmitEvent(events.CreateProcessLogStderrEvent(se.processName, se.groupName, se.pidFunc(), line))
+	}
+}
+
+// append data to the incomplete line and return the complete lines with the line endings. The
+// incomplete line longer than maxLogEventLineBytes is returned as well
+func (se *StdLogEventEmitter) completeLines(data string) []string {
+	se.lock.Lock()
+	defer se.lock.Unlock()
+	lines := make([]string, 0)
+	buf := append(se.partialLine, data...)
+	for {
+		index := bytes.IndexByte(buf, '\n')
+		if index < 0 {
+			break
+		}
+		lines = append(lines, string(buf[:index+1]))
+		buf = buf[index+1:]
 	}
+	for len(buf) >= maxLogEventLineBytes {
+		lines = append(lines, string(buf[:maxLogEventLineBytes]))
+		buf = buf[maxLogEventLineBytes:]
+	}
+	se.partialLine = nil
+	if len(buf) > 0 {
+		se.partialLine = append([]byte(nil), buf...)
+	}
+	return lines
 }
 
 // BackgroundWriteCloser write data in background
@@ -543,13 +793,16 @@ type BackgroundWriteCloser struct {
 	io.WriteCloser
 	logChannel  chan []byte
 	writeCloser io.WriteCloser
+	// closed after all the data in logChannel is written
+	done chan struct{}
 }
 
 // NewBackgroundWriteCloser create a new BackgroundWriteCloser object
 func NewBackgroundWriteCloser(writeCloser io.WriteCloser) *BackgroundWriteCloser {
 	channel := make(chan []byte)
 	bw := &BackgroundWriteCloser{logChannel: channel,
-		writeCloser: writeCloser}
+		writeCloser: writeCloser,
+		done:        make(chan struct{})}
 
 	bw.start()
 	return bw
@@ -557,6 +810,7 @@ func NewBackgroundWriteCloser(writeCloser io.WriteCloser) *BackgroundWriteCloser
 
 func (bw *BackgroundWriteCloser) start() {
 	go func() {
+		defer close(bw.done)
 		for {
 			b, ok := <-bw.logChannel
 			if !ok {
@@ -573,12 +827,55 @@ func (bw *BackgroundWriteCloser) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-// Close close the background data channel
+// Close close the background data channel and close the writer after the pending data is written
 func (bw *BackgroundWriteCloser) Close() error {
 	close(bw.logChannel)
+	<-bw.done
 	return bw.writeCloser.Close()
 }
 
+// NonBlockingLogger write the log to another logger in background. The log is dropped if the
+// other logger can't keep up, so a failed or slow sink never blocks the program output
+type NonBlockingLogger struct {
+	Logger
+	logChannel chan []byte
+	closeOnce  sync.Once
+}
+
+// the number of log writes buffered by NonBlockingLogger
+const nonBlockingLoggerBufferSize = 1024
+
+// NewNonBlockingLogger create a NonBlockingLogger writing the log to logger
+func NewNonBlockingLogger(logger Logger) *NonBlockingLogger {
+	nl := &NonBlockingLogger{Logger: logger, logChannel: make(chan []byte, nonBlockingLoggerBufferSize)}
+	go func() {
+		for b := range nl.logChannel {
+			nl.Logger.Write(b)
+		}
+		nl.Logger.Close()
+	}()
+	return nl
+}
+
+// Write queue the log data without waiting for it being written
+func (nl *NonBlockingLogger) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	select {
+	case nl.logChannel <- b:
+	default:
+	}
+	return len(p), nil
+}
+
+// Close stop writing the log after the queued log data is written
+func (nl *NonBlockingLogger) Close() error {
+	nl.closeOnce.Do(func() {
+		close(nl.logChannel)
+	})
+	return nil
+}
+
 // NewCompositeLogger create a new CompositeLogger object
 func NewCompositeLogger(loggers []Logger) *CompositeLogger {
 	return &CompositeLogger{loggers: loggers}
@@ -665,53 +962,161 @@ func (cl *CompositeLogger) ClearAllLogFile() error {
 
 // NewLogger create a logger for a program with parameters
 //
-func NewLogger(programName string, logFile string, locker sync.Locker, maxBytes int64, backups int, logEventEmitter LogEventEmitter) Logger {
+// The logFile can be a list of sinks separated by comma or semicolon, the log is written to
+// all of them. The log is read from the first file sink if present, otherwise from the first
+// sink. The other sinks are written in background, so a failed or blocked sink does not
+// block or fail the others. The log files are rotated by maxBytes, and also by rotatePeriod
+// (daily or hourly) if it is not empty. The rotated backup files are gzipped if compress is true.
+// The syslog messages are tagged with syslogTag and sent with syslogFacility (like user or local0),
+// the default facility is used if it is empty. Each line written to /dev/stdout or /dev/stderr
+// is prefixed with stdLinePrefix if it is not empty
+func NewLogger(syslogTag string, syslogFacility string, stdLinePrefix string, logFile string, locker sync.Locker, maxBytes int64, backups int, rotatePeriod string, compress bool, logEventEmitter LogEventEmitter) Logger {
 	files := splitLogFile(logFile)
-	loggers := make([]Logger, 0)
+	primary := primaryLogFileIndex(files)
+	loggers := []Logger{createLogger(syslogTag, syslogFacility, stdLinePrefix, files[primary], locker, maxBytes, backups, rotatePeriod, compress, logEventEmitter)}
 	for i, f := range files {
-		var lr Logger
-		if i == 0 {
-			lr = createLogger(programName, f, locker, maxBytes, backups, logEventEmitter)
-		} else {
-			lr = createLogger(programName, f, NewNullLocker(), maxBytes, backups, NewNullLogEventEmitter())
+		if i != primary {
+			lr := createLogger(syslogTag, syslogFacility, stdLinePrefix, f, NewNullLocker(), maxBytes, backups, rotatePeriod, compress, NewNullLogEventEmitter())
+			loggers = append(loggers, NewNonBlockingLogger(lr))
 		}
-		loggers = append(loggers, lr)
+	}
+	if len(loggers) > 1 && !isLogFile(files[primary]) {
+		loggers[0] = NewNonBlockingLogger(loggers[0])
 	}
 	return NewCompositeLogger(loggers)
 }
 
 func splitLogFile(logFile string) []string {
-	files := strings.Split(logFile, ",")
+	files := strings.Split(strings.Replace(logFile, ";", ",", -1), ",")
 	for i, f := range files {
 		files[i] = strings.TrimSpace(f)
 	}
 	return files
 }
 
-func createLogger(programName string, logFile string, locker sync.Locker, maxBytes int64, backups int, logEventEmitter LogEventEmitter) Logger {
-	if logFile == "/dev/stdout" {
-		return NewStdoutLogger(logEventEmitter)
+// get the index of the sink the log is read from: the first file sink if present, otherwise the first sink
+func primaryLogFileIndex(files []string) int {
+	for i, f := range files {
+		if isLogFile(f) {
+			return i
+		}
 	}
-	if logFile == "/dev/stderr" {
-		return NewStderrLogger(logEventEmitter)
+	return 0
+}
+
+// check if the log sink is a file, not /dev/stdout, /dev/stderr, /dev/null or syslog
+func isLogFile(f string) bool {
+	return len(f) > 0 && f != "/dev/stdout" && f != "/dev/stderr" && f != "/dev/null" && !strings.HasPrefix(f, "syslog")
+}
+
+func createLogger(syslogTag string, syslogFacility string, stdLinePrefix string, logFile string, locker sync.Locker, maxBytes int64, backups int, rotatePeriod string, compress bool, logEventEmitter LogEventEmitter) Logger {
+	if logFile == "/dev/stdout" || logFile == "/dev/stderr" {
+		stdLogger := NewStdoutLogger(logEventEmitter)
+		if logFile == "/dev/stderr" {
+			stdLogger = NewStderrLogger(logEventEmitter)
+		}
+		stdLogger.SetLinePrefix(stdLinePrefix)
+		return stdLogger
 	}
 	if logFile == "/dev/null" {
 		return NewNullLogger(logEventEmitter)
 	}
 
 	if logFile == "syslog" {
-		return NewSysLogger(programName, logEventEmitter)
+		return NewSysLogger(syslogTag, syslogFacility, logEventEmitter)
 	}
 	if strings.HasPrefix(logFile, "syslog") {
 		fields := strings.Split(logFile, "@")
 		fields[0] = strings.TrimSpace(fields[0])
 		fields[1] = strings.TrimSpace(fields[1])
 		if len(fields) == 2 && fields[0] == "syslog" {
-			return NewRemoteSysLogger(programName, fields[1], logEventEmitter)
+			return NewRemoteSysLogger(syslogTag, fields[1], syslogFacility, logEventEmitter)
 		}
 	}
 	if len(logFile) > 0 {
-		return NewFileLogger(logFile, maxBytes, backups, logEventEmitter, locker)
+		fileLogger := NewFileLogger(logFile, maxBytes, backups, logEventEmitter, locker)
+		if rotatePeriod != "" {
+			fileLogger.SetRotatePeriod(rotatePeriod)
+		}
+		if compress {
+			fileLogger.SetCompress(true)
+		}
+		return fileLogger
 	}
 	return NewNullLogger(logEventEmitter)
 }
+
+// LogInfo the metadata of a program log
+type LogInfo struct {
+	Type         string `xml:"type" json:"type"`                   // file, syslog, stdout, stderr or none
+	File         string `xml:"file" json:"file"`                   // the log file if the type is file
+	Exists       bool   `xml:"exists" json:"exists"`               // true if the log file is created
+	Size         int    `xml:"size" json:"size"`                   // the size of current log file
+	LastModified int    `xml:"last_modified" json:"last_modified"` // the last modified time of current log file in seconds
+	Backups      int    `xml:"backups" json:"backups"`             // the number of existing backup files
+}
+
+// GetLogInfo get the metadata of log without reading the log content. The log type is decided by
+// logFile in the same way as NewLogger(). If more than one sink is configured, only the sink
+// the log is read from is examined
+func GetLogInfo(logFile string, backups int) LogInfo {
+	files := splitLogFile(logFile)
+	f := files[primaryLogFileIndex(files)]
+	switch {
+	case f == "/dev/stdout":
+		return LogInfo{Type: "stdout"}
+	case f == "/dev/stderr":
+		return LogInfo{Type: "stderr"}
+	case f == "/dev/null" || len(f) <= 0:
+		return LogInfo{Type: "none"}
+	case strings.HasPrefix(f, "syslog"):
+		return LogInfo{Type: "syslog"}
+	}
+	info := LogInfo{Type: "file", File: f}
+	if fileInfo, err := os.Stat(f); err == nil {
+		info.Exists = true
+		info.Size = int(fileInfo.Size())
+		info.LastModified = int(fileInfo.ModTime().Unix())
+	}
+	info.Backups = len(GetBackupFiles(f, backups))
+	return info
+}
+
+// GetLogFiles get the files from the logFile setting. The /dev/stdout, /dev/stderr, /dev/null
+// and syslog targets are not included
+func GetLogFiles(logFile string) []string {
+	result := make([]string, 0)
+	for _, f := range splitLogFile(logFile) {
+		if isLogFile(f) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// EncodeLogData encode the log data with base64 if it is not valid UTF-8 or it includes characters
+// not allowed in XML, so the log data can be sent safely in the XML-RPC/JSON response.
+//
+// Return the (encoded) log data and true if the log data is encoded with base64
+func EncodeLogData(data string) (string, bool) {
+	if isPrintableText(data) {
+		return data, false
+	}
+	return base64.StdEncoding.EncodeToString([]byte(data)), true
+}
+
+// check if the data is valid UTF-8 and all the characters are allowed in XML
+func isPrintableText(data string) bool {
+	if !utf8.ValidString(data) {
+		return false
+	}
+	for _, r := range data {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+		if r == 0xFFFE || r == 0xFFFF {
+			return false
+		}
+	}
+	return true
+}
@@ -1,12 +1,28 @@
 package logger
 
 import (
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/ochinchina/supervisord/events"
 )
 
 func TestWriteSingleLog(t *testing.T) {
-	logger := NewFileLogger("test.log", int64(50), 2, NewNullLogEventEmitter(), NewNullLocker())
+	dir, err := ioutil.TempDir("", "log-single")
+	if err != nil {
+		t.Fatal("Fail to create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	logger := NewFileLogger(filepath.Join(dir, "test.log"), int64(50), 2, NewNullLogEventEmitter(), NewNullLocker())
 	for i := 0; i < 10; i++ {
 		logger.Write([]byte(fmt.Sprintf("this is a test %d\n", i)))
 	}
@@ -14,7 +30,7 @@ func TestWriteSingleLog(t *testing.T) {
 }
 
 func TestSplitLogFile(t *testing.T) {
-	files := splitLogFile(" test1.log, /dev/stdout, test2.log ")
+	files := splitLogFile(" test1.log, /dev/stdout; test2.log ")
 	if len(files) != 3 {
 		t.Error("Fail to split log file")
 	}
@@ -29,3 +45,466 @@ func TestSplitLogFile(t *testing.T) {
 	}
 
 }
+
+func TestGetLogInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-info")
+	if err != nil {
+		t.Fatal("Fail to create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "test.log")
+	info := GetLogInfo(logFile, 2)
+	if info.Type != "file" || info.Exists {
+		t.Error("The log file should not exist")
+	}
+
+	logger := NewFileLogger(logFile, int64(50), 2, NewNullLogEventEmitter(), NewNullLocker())
+	for i := 0; i < 10; i++ {
+		logger.Write([]byte(fmt.Sprintf("this is a test %d\n", i)))
+	}
+	logger.Close()
+
+	info = GetLogInfo(logFile, 2)
+	if !info.Exists || info.Size <= 0 || info.LastModified <= 0 || info.Backups != 2 {
+		t.Errorf("Fail to get the log file info: %v", info)
+	}
+	if GetLogInfo("/dev/stdout, "+logFile, 2).File != logFile {
+		t.Error("Fail to get the log info of the file sink")
+	}
+	if GetLogInfo("/dev/stdout, syslog", 2).Type != "stdout" {
+		t.Error("Fail to get the log type of stdout")
+	}
+	if GetLogInfo("syslog", 2).Type != "syslog" {
+		t.Error("Fail to get the log type of syslog")
+	}
+}
+
+func TestGetLogFiles(t *testing.T) {
+	files := GetLogFiles("test1.log, /dev/stdout, syslog @udp:localhost, /dev/null, test2.log")
+	if len(files) != 2 || files[0] != "test1.log" || files[1] != "test2.log" {
+		t.Errorf("Fail to get the log files: %v", files)
+	}
+}
+
+func TestEncodeLogData(t *testing.T) {
+	if data, binary := EncodeLogData("hello\tworld\n"); binary || data != "hello\tworld\n" {
+		t.Error("The text log should not be encoded")
+	}
+	if data, binary := EncodeLogData("hello\x00\xff"); !binary || data != "aGVsbG8A/w==" {
+		t.Errorf("The binary log should be encoded with base64, but got %s", data)
+	}
+}
+
+type blockingLogger struct {
+	NullLogger
+	block chan struct{}
+}
+
+func (l *blockingLogger) Write(p []byte) (int, error) {
+	<-l.block
+	return len(p), nil
+}
+
+func TestNonBlockingLoggerNotBlocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-sinks")
+	if err != nil {
+		t.Fatal("Fail to create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "test.log")
+	fileLogger := NewFileLogger(logFile, int64(1024*1024), 0, NewNullLogEventEmitter(), NewNullLocker())
+	blocked := &blockingLogger{block: make(chan struct{})}
+	defer close(blocked.block)
+	logger := NewCompositeLogger([]Logger{fileLogger, NewNonBlockingLogger(blocked)})
+	for i := 0; i < 2*nonBlockingLoggerBufferSize; i++ {
+		if _, err := logger.Write([]byte("this is a test\n")); err != nil {
+			t.Fatalf("Fail to write log: %v", err)
+		}
+	}
+	if data, err := logger.ReadLog(0, 15); err != nil || data != "this is a test\n" {
+		t.Errorf("Fail to read the log from file sink: %s, %v", data, err)
+	}
+	fileLogger.Close()
+}
+
+func TestJSONLineLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-json")
+	if err != nil {
+		t.Fatal("Fail to create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "test.log")
+	fileLogger := NewFileLogger(logFile, int64(200), 1, NewNullLogEventEmitter(), NewNullLocker())
+	logger := NewJSONLineLogger(fileLogger, "test", "group", "stderr")
+	logger.Write([]byte("first line\nsecond "))
+	logger.Write([]byte("line \"quoted\"\nlast"))
+	logger.Close()
+
+	data, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Fail to read the log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	// the JSON lines are rotated by maxSize as the text lines
+	backup, err := ioutil.ReadFile(logFile + ".1")
+	if err != nil {
+		t.Fatalf("Fail to read the rotated log file: %v", err)
+	}
+	lines = append(strings.Split(strings.TrimSuffix(string(backup), "\n"), "\n"), lines...)
+	expected := []string{"first line", "second line \"quoted\"", "last"}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expect %d JSON lines but got %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		var entry map[string]string
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Fail to decode the JSON line %s: %v", line, err)
+		}
+		if entry["program"] != "test" || entry["group"] != "group" || entry["stream"] != "stderr" || entry["message"] != expected[i] {
+			t.Errorf("Unexpected JSON line %s", line)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, entry["timestamp"]); err != nil {
+			t.Errorf("Fail to parse the timestamp of %s: %v", line, err)
+		}
+	}
+}
+
+func TestRotateLogFileDaily(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-daily")
+	if err != nil {
+		t.Fatal("Fail to create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "test.log")
+	ioutil.WriteFile(logFile, []byte("yesterday\n"), 0644)
+	yesterday := time.Now().AddDate(0, 0, -1)
+	os.Chtimes(logFile, yesterday, yesterday)
+
+	logger := NewFileLogger(logFile, int64(30), 2, NewNullLogEventEmitter(), NewNullLocker())
+	logger.SetRotatePeriod(LogRotateDaily)
+	// the log file of yesterday is rotated before writing today's log
+	logger.Write([]byte("today\n"))
+	if data, err := logger.ReadLog(0, 0); err != nil || data != "yesterday\ntoday\n" {
+		t.Errorf("Fail to read the log after it is rotated: %q, %v", data, err)
+	}
+	yesterdayBackup := logFile + "." + yesterday.Format("2006-01-02")
+	if data, err := ioutil.ReadFile(yesterdayBackup); err != nil || string(data) != "yesterday\n" {
+		t.Errorf("Fail to rotate the log of yesterday: %q, %v", data, err)
+	}
+
+	// the log is still rotated by size in the same day
+	for i := 0; i < 3; i++ {
+		logger.Write([]byte("this is a long line of today\n"))
+	}
+	logger.Close()
+	today := logFile + "." + time.Now().Format("2006-01-02")
+	backups := GetBackupFiles(logFile, 2)
+	if len(backups) != 2 || backups[0] != today+".1" || backups[1] != today {
+		t.Errorf("Expect the 2 newest backups of today but got %v", backups)
+	}
+	if GetLogInfo(logFile, 2).Backups != 2 {
+		t.Errorf("Fail to count the backups rotated by time")
+	}
+
+	if err := logger.ClearAllLogFile(); err != nil || len(GetBackupFiles(logFile, 2)) != 0 {
+		t.Errorf("Fail to clear the backups rotated by time, error: %v", err)
+	}
+	logger.Close()
+}
+
+func TestReadLogAcrossRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-daily")
+	if err != nil {
+		t.Fatal("Fail to create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "test.log")
+	ioutil.WriteFile(logFile, []byte("yesterday\n"), 0644)
+	yesterday := time.Now().AddDate(0, 0, -1)
+	os.Chtimes(logFile, yesterday, yesterday)
+
+	logger := NewFileLogger(logFile, int64(1024), 2, NewNullLogEventEmitter(), NewNullLocker())
+	defer logger.Close()
+	logger.SetRotatePeriod(LogRotateDaily)
+	data, offset, overflow, err := logger.ReadTailLog(0, 100)
+	if err != nil || data != "yesterday\n" || offset != 10 || overflow {
+		t.Errorf("Fail to tail the log before it is rotated: %q, %d, %v, %v", data, offset, overflow, err)
+	}
+
+	// the log of yesterday is rotated to the dated backup file before writing today's log
+	logger.Write([]byte("today\n"))
+	data, offset, overflow, err = logger.ReadTailLog(offset, 100)
+	if err != nil || data != "today\n" || offset != 16 || overflow {
+		t.Errorf("Fail to continue tailing the log after it is rotated: %q, %d, %v, %v", data, offset, overflow, err)
+	}
+	if data, err := logger.ReadLog(5, 8); err != nil || data != "rday\ntod" {
+		t.Errorf("Fail to read the log across the rotation boundary: %q, %v", data, err)
+	}
+	if data, err := logger.ReadLog(-8, 0); err != nil || data != "y\ntoday\n" {
+		t.Errorf("Fail to read the end of the log across the rotation boundary: %q, %v", data, err)
+	}
+}
+
+func TestCompressBackupFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-compress")
+	if err != nil {
+		t.Fatal("Fail to create temp directory")
+	}
+	defer os.RemoveAll(dir)
+
+	logFile := filepath.Join(dir, "test.log")
+	// an incomplete compressed backup left by the previous run
+	ioutil.WriteFile(logFile+".1", []byte("previous\n"), 0644)
+	ioutil.WriteFile(logFile+".1.gz", []byte("incomplete"), 0644)
+
+	logger := NewFileLogger(logFile, int64(30), 2, NewNullLogEventEmitter(), NewNullLocker())
+	logger.SetCompress(true)
+	for i := 0; i < 4; i++ {
+		logger.Write([]byte(fmt.Sprintf("this is a long line %d of the log\n", i)))
+	}
+	logger.Close()
+	logger.compressing.Wait()
+
+	backups := GetBackupFiles(logFile, 2)
+	if len(backups) != 2 || backups[0] != logFile+".1.gz" || backups[1] != logFile+".2.gz" {
+		t.Fatalf("Expect 2 compressed backups but got %v", backups)
+	}
+	for i, expected := range []string{"this is a long line 3 of the log\n", "this is a long line 2 of the log\n"} {
+		f, _ := os.Open(backups[i])
+		reader, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("Fail to read the compressed backup %s: %v", backups[i], err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		f.Close()
+		if err != nil || string(data) != expected {
+			t.Errorf("Expect %q in %s but got %q, %v", expected, backups[i], data, err)
+		}
+	}
+	if _, err := os.Stat(logFile + ".1"); err == nil {
+		t.Error("The uncompressed backup is not removed")
+	}
+
+	if err := logger.ClearAllLogFile(); err != nil || len(GetBackupFiles(logFile, 2)) != 0 {
+		t.Errorf("Fail to clear the compressed backups, error: %v", err)
+	}
+	logger.Close()
+}
+
+func TestLogEventEmitterCompleteLines(t *testing.T) {
+	emitter := NewStdoutLogEventEmitter("test", "test", func() int { return 0 })
+	if lines := emitter.completeLines("first line\nsecond"); len(lines) != 1 || lines[0] != "first line\n" {
+		t.Errorf("expect only the complete line but got %q", lines)
+	}
+	if lines := emitter.completeLines(" line\nthird line\n"); len(lines) != 2 || lines[0] != "second line\n" || lines[1] != "third line\n" {
+		t.Errorf("expect the incomplete line is completed but got %q", lines)
+	}
+
+	long := strings.Repeat("x", maxLogEventLineBytes+10)
+	if lines := emitter.completeLines(long); len(lines) != 1 || len(lines[0]) != maxLogEventLineBytes {
+		t.Errorf("expect the long line is emitted in pieces but got %d lines", len(lines))
+	}
+	if lines := emitter.completeLines("\n"); len(lines) != 1 || lines[0] != strings.Repeat("x", 10)+"\n" {
+		t.Errorf("expect the rest of the long line but got %q", lines)
+	}
+}
+
+func TestLogEventEmitterFlushOnClose(t *testing.T) {
+	emitter := NewStdoutLogEventEmitter("test", "test", func() int { return 0 })
+	logger := NewNullLogger(emitter)
+	emitter.completeLines("complete\nlast words")
+	logger.Close()
+	if emitter.partialLine != nil {
+		t.Errorf("expect the incomplete line is flushed on close but %q is left", emitter.partialLine)
+	}
+}
+
+type slowWriteCloser struct {
+	lock   sync.Mutex
+	data   strings.Builder
+	closed bool
+}
+
+func (w *slowWriteCloser) Write(p []byte) (int, error) {
+	time.Sleep(10 * time.Millisecond)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	return w.data.Write(p)
+}
+
+func (w *slowWriteCloser) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.closed = true
+	return nil
+}
+
+func TestBackgroundWriteCloserDrainOnClose(t *testing.T) {
+	w := &slowWriteCloser{}
+	bw := NewBackgroundWriteCloser(w)
+	bw.Write([]byte("first\n"))
+	bw.Write([]byte("last"))
+	bw.Close()
+	if w.data.String() != "first\nlast" {
+		t.Errorf("expect all the data is written before close but got %q", w.data.String())
+	}
+}
+
+func TestStdLoggerLinePrefix(t *testing.T) {
+	var output strings.Builder
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		stdLogger := &StdLogger{logEventEmitter: NewNullLogEventEmitter(), writer: &output}
+		stdLogger.SetLinePrefix(name + " | ")
+		wg.Add(1)
+		go func(stdLogger *StdLogger, name string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				// each line is written in pieces
+				stdLogger.Write([]byte(name + "-"))
+				stdLogger.Write([]byte(fmt.Sprintf("%d\n%s-", i, name)))
+				stdLogger.Write([]byte("x\n"))
+			}
+			stdLogger.Write([]byte("end"))
+			stdLogger.Close()
+		}(stdLogger, name)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 402 {
+		t.Fatalf("expect 402 lines but got %d", len(lines))
+	}
+	for _, line := range lines {
+		name := line[0:1]
+		if !strings.HasPrefix(line, name+" | "+name+"-") && line != name+" | end" {
+			t.Errorf("the line %q is interleaved", line)
+		}
+	}
+}
+
+// wait until the oldest backup files are removed in background
+func waitLogUsagePruned(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		logUsage.lock.Lock()
+		pruned := !logUsage.pruning && logUsage.total <= logUsage.limit
+		logUsage.lock.Unlock()
+		if pruned {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("The log backup files are not pruned")
+}
+
+func TestTotalLogBytesLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-usage")
+	if err != nil {
+		t.Fatal("Fail to create temp directory")
+	}
+	defer os.RemoveAll(dir)
+	// don't count the log files of the other tests
+	prevUsage := logUsage
+	logUsage = newLogDiskUsage()
+	defer func() { logUsage = prevUsage }()
+
+	var prunedLock sync.Mutex
+	pruned := make([]string, 0)
+	unsubscribe := events.Subscribe([]string{"LOG_BACKUP_PRUNED"}, func(event events.Event) {
+		prunedLock.Lock()
+		defer prunedLock.Unlock()
+		pruned = append(pruned, event.GetBody())
+	})
+	defer unsubscribe()
+
+	now := time.Now()
+	backup := func(name string, age time.Duration) string {
+		file := filepath.Join(dir, name)
+		ioutil.WriteFile(file, []byte(strings.Repeat("x", 100)), 0644)
+		os.Chtimes(file, now.Add(-age), now.Add(-age))
+		return file
+	}
+	webBackup1 := backup("web.log.1", time.Hour)
+	webBackup2 := backup("web.log.2", 3*time.Hour)
+	workerBackup1 := backup("worker.log.1", 2*time.Hour)
+	web := NewFileLogger(filepath.Join(dir, "web.log"), int64(1024), 2, NewNullLogEventEmitter(), NewNullLocker())
+	defer web.Close()
+	worker := NewFileLogger(filepath.Join(dir, "worker.log"), int64(1024), 2, NewNullLogEventEmitter(), NewNullLocker())
+	defer worker.Close()
+
+	// the oldest backup of all the programs is removed first
+	SetTotalLogBytesLimit(250)
+	defer SetTotalLogBytesLimit(0)
+	waitLogUsagePruned(t)
+	if isFileExist(webBackup2) || !isFileExist(webBackup1) || !isFileExist(workerBackup1) {
+		t.Errorf("Expect only %s is removed", webBackup2)
+	}
+
+	// the limit is exceeded again by writing the log
+	worker.Write([]byte(strings.Repeat("y", 59) + "\n"))
+	waitLogUsagePruned(t)
+	if isFileExist(workerBackup1) || !isFileExist(webBackup1) {
+		t.Errorf("Expect %s is removed after the log is written", workerBackup1)
+	}
+
+	prunedLock.Lock()
+	defer prunedLock.Unlock()
+	expected := []string{fmt.Sprintf("file:%s size:100 total:200 limit:250", webBackup2),
+		fmt.Sprintf("file:%s size:100 total:160 limit:250", workerBackup1)}
+	if strings.Join(pruned, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expect the log backup pruned events %v but got %v", expected, pruned)
+	}
+}
+
+func TestTotalLogBytesOfClosedLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-usage")
+	if err != nil {
+		t.Fatal("Fail to create temp directory")
+	}
+	defer os.RemoveAll(dir)
+	prevUsage := logUsage
+	logUsage = newLogDiskUsage()
+	defer func() { logUsage = prevUsage }()
+
+	webBackup := filepath.Join(dir, "web.log.1")
+	ioutil.WriteFile(webBackup, []byte(strings.Repeat("x", 100)), 0644)
+	web := NewFileLogger(filepath.Join(dir, "web.log"), int64(1024), 2, NewNullLogEventEmitter(), NewNullLocker())
+	worker := NewFileLogger(filepath.Join(dir, "worker.log"), int64(1024), 2, NewNullLogEventEmitter(), NewNullLocker())
+	defer worker.Close()
+	SetTotalLogBytesLimit(200)
+	defer SetTotalLogBytesLimit(0)
+	web.Write([]byte(strings.Repeat("y", 49) + "\n"))
+	web.Close()
+
+	// the backup of the closed logger is still on the disk, so it is pruned when the limit is exceeded
+	worker.Write([]byte(strings.Repeat("z", 59) + "\n"))
+	waitLogUsagePruned(t)
+	if isFileExist(webBackup) {
+		t.Errorf("Expect the backup %s of the closed logger is pruned", webBackup)
+	}
+	logUsage.lock.Lock()
+	if logUsage.total != 110 {
+		t.Errorf("Expect the log file of the closed logger is still accounted but got %d", logUsage.total)
+	}
+	logUsage.lock.Unlock()
+
+	// the closed log file is forgotten after all its files are removed
+	os.Remove(filepath.Join(dir, "web.log"))
+	SetTotalLogBytesLimit(200)
+	logUsage.lock.Lock()
+	defer logUsage.lock.Unlock()
+	if _, ok := logUsage.backups[filepath.Join(dir, "web.log")]; ok {
+		t.Error("Expect the closed log file without any file on the disk is not accounted")
+	}
+	if logUsage.total != 60 {
+		t.Errorf("Expect only the size of the open log file is accounted but got %d", logUsage.total)
+	}
+}
@@ -10,9 +10,18 @@ import (
 	"strings"
 )
 
-// NewSysLogger create a local syslog
-func NewSysLogger(name string, logEventEmitter LogEventEmitter) *SysLogger {
-	writer, err := syslog.New(syslog.LOG_DEBUG, name)
+// get the syslog priority of the facility name with debug severity, defaultFacility is used if
+// the facility is empty or unknown
+func getSysLogPriority(facility string, defaultFacility syslog.Priority) syslog.Priority {
+	if code, ok := syslogFacilities[facility]; ok {
+		return syslog.Priority(code<<3) | syslog.LOG_DEBUG
+	}
+	return defaultFacility | syslog.LOG_DEBUG
+}
+
+// NewSysLogger create a local syslog, the messages are tagged with name
+func NewSysLogger(name string, facility string, logEventEmitter LogEventEmitter) *SysLogger {
+	writer, err := syslog.New(getSysLogPriority(facility, syslog.LOG_KERN), name)
 	logger := &SysLogger{logEventEmitter: logEventEmitter}
 	if err == nil {
 		logger.logWriter = writer
@@ -123,21 +132,22 @@ func parseSysLogConfig(config string) (protocol string, host string, port int, e
 }
 
 // NewRemoteSysLogger create a network syslog
-func NewRemoteSysLogger(name string, config string, logEventEmitter LogEventEmitter) *SysLogger {
+func NewRemoteSysLogger(name string, config string, facility string, logEventEmitter LogEventEmitter) *SysLogger {
 	if len(config) <= 0 {
-		return NewSysLogger(name, logEventEmitter)
+		return NewSysLogger(name, facility, logEventEmitter)
 	}
 
 	protocol, host, port, err := parseSysLogConfig(config)
 	if err != nil {
-		return NewSysLogger(name, logEventEmitter)
+		return NewSysLogger(name, facility, logEventEmitter)
 	}
-	writer, err := syslog.Dial(protocol, fmt.Sprintf("%s:%d", host, port), syslog.LOG_LOCAL7|syslog.LOG_DEBUG, name)
+	priority := getSysLogPriority(facility, syslog.LOG_LOCAL7)
+	writer, err := syslog.Dial(protocol, fmt.Sprintf("%s:%d", host, port), priority, name)
 	logger := &SysLogger{logEventEmitter: logEventEmitter}
 	if writer != nil && err == nil {
 		logger.logWriter = writer
 	} else {
-		logger.logWriter = NewBackendSysLogWriter(protocol, fmt.Sprintf("%s:%d", host, port), syslog.LOG_LOCAL7|syslog.LOG_DEBUG, name)
+		logger.logWriter = NewBackendSysLogWriter(protocol, fmt.Sprintf("%s:%d", host, port), priority, name)
 	}
 	return logger
 
@@ -0,0 +1,23 @@
+// +build !windows,!nacl,!plan9
+
+package logger
+
+import (
+	"log/syslog"
+	"testing"
+)
+
+func TestGetSysLogPriority(t *testing.T) {
+	if p := getSysLogPriority("local3", syslog.LOG_KERN); p != syslog.LOG_LOCAL3|syslog.LOG_DEBUG {
+		t.Errorf("expect the priority of local3 but got %v", p)
+	}
+	if p := getSysLogPriority("daemon", syslog.LOG_KERN); p != syslog.LOG_DAEMON|syslog.LOG_DEBUG {
+		t.Errorf("expect the priority of daemon but got %v", p)
+	}
+	if p := getSysLogPriority("", syslog.LOG_LOCAL7); p != syslog.LOG_LOCAL7|syslog.LOG_DEBUG {
+		t.Errorf("expect the default facility but got %v", p)
+	}
+	if IsValidSyslogFacility("local8") || !IsValidSyslogFacility("user") {
+		t.Error("fail to validate the syslog facility")
+	}
+}
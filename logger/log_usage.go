@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ochinchina/supervisord/events"
+	"go.uber.org/zap"
+)
+
+// logDiskUsage account the disk usage of all the log files and their backup files, and remove
+// the oldest backup files if the total size exceeds the limit. The size of a log file is updated
+// when it is written and the size of its backup files when it is rotated, so the backup files are
+// listed only when the log files are opened or rotated, or the oldest backup files are removed.
+// The files of a closed log file are still on the disk, so they are accounted and pruned until
+// none of them is left
+type logDiskUsage struct {
+	lock sync.Mutex
+	// the limit of the total size, no limit if it is 0
+	limit int64
+	// the log file => the number of its backup files
+	backups map[string]int
+	// the log file => the size of the log file
+	fileSizes map[string]int64
+	// the log file => the total size of its backup files
+	backupSizes map[string]int64
+	// the log files whose logger is closed
+	closed  map[string]bool
+	total   int64
+	pruning bool
+}
+
+var logUsage = newLogDiskUsage()
+
+func newLogDiskUsage() *logDiskUsage {
+	return &logDiskUsage{backups: make(map[string]int),
+		fileSizes:   make(map[string]int64),
+		backupSizes: make(map[string]int64),
+		closed:      make(map[string]bool)}
+}
+
+// SetTotalLogBytesLimit set the limit of the total size of all the log files and their backup
+// files. If it is exceeded, the oldest backup files are removed until the total size is under the
+// limit. The log files being written are never removed. 0 for no limit
+func SetTotalLogBytesLimit(limit int64) {
+	logUsage.setLimit(limit)
+}
+
+func (u *logDiskUsage) setLimit(limit int64) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	atomic.StoreInt64(&u.limit, limit)
+	for name := range u.backups {
+		u.updateFileSizes(name)
+	}
+	u.pruneIfExceeded()
+}
+
+// register the log file and get the size of its backup files when it is opened
+func (u *logDiskUsage) register(name string, backups int) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.backups[name] = backups
+	delete(u.closed, name)
+	u.updateFileSizes(name)
+	u.pruneIfExceeded()
+}
+
+// mark the log file closed when its logger is closed for good. Its files are accounted until
+// they are all removed from the disk, then the log file is forgotten
+func (u *logDiskUsage) close(name string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if _, ok := u.backups[name]; ok {
+		u.closed[name] = true
+		u.updateFileSizes(name)
+	}
+}
+
+// update the size of the log file after it is written
+func (u *logDiskUsage) written(name string, fileSize int64) {
+	if atomic.LoadInt64(&u.limit) <= 0 {
+		return
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.total += fileSize - u.fileSizes[name]
+	u.fileSizes[name] = fileSize
+	u.pruneIfExceeded()
+}
+
+// get the size of the log file and its backup files from the disk, the lock must be held
+func (u *logDiskUsage) updateFileSizes(name string) {
+	fileSize := int64(0)
+	fileExists := false
+	if fileInfo, err := os.Stat(name); err == nil {
+		fileSize = fileInfo.Size()
+		fileExists = true
+	}
+	backupSize := int64(0)
+	backupFiles := GetBackupFiles(name, u.backups[name])
+	for _, backup := range backupFiles {
+		if fileInfo, err := os.Stat(backup); err == nil {
+			backupSize += fileInfo.Size()
+		}
+	}
+	u.total += fileSize + backupSize - u.fileSizes[name] - u.backupSizes[name]
+	if u.closed[name] && !fileExists && len(backupFiles) == 0 {
+		delete(u.backups, name)
+		delete(u.fileSizes, name)
+		delete(u.backupSizes, name)
+		delete(u.closed, name)
+		return
+	}
+	u.fileSizes[name] = fileSize
+	u.backupSizes[name] = backupSize
+}
+
+// remove the oldest backup files in background if the total size exceeds the limit, the lock must be held
+func (u *logDiskUsage) pruneIfExceeded() {
+	if u.limit <= 0 || u.total <= u.limit || u.pruning {
+		return
+	}
+	u.pruning = true
+	backups := make(map[string]int)
+	for name, n := range u.backups {
+		backups[name] = n
+	}
+	go u.prune(backups, u.limit)
+}
+
+type backupFileInfo struct {
+	name     string
+	fileInfo os.FileInfo
+}
+
+// remove the oldest backup files of all the log files until the total size is under the limit
+func (u *logDiskUsage) prune(backups map[string]int, limit int64) {
+	total := int64(0)
+	backupFiles := make([]backupFileInfo, 0)
+	for name, n := range backups {
+		if fileInfo, err := os.Stat(name); err == nil {
+			total += fileInfo.Size()
+		}
+		for _, backup := range GetBackupFiles(name, n) {
+			if fileInfo, err := os.Stat(backup); err == nil {
+				total += fileInfo.Size()
+				backupFiles = append(backupFiles, backupFileInfo{name: backup, fileInfo: fileInfo})
+			}
+		}
+	}
+	sort.SliceStable(backupFiles, func(i, j int) bool {
+		return backupFiles[i].fileInfo.ModTime().Before(backupFiles[j].fileInfo.ModTime())
+	})
+	for i := 0; i < len(backupFiles) && total > limit; i++ {
+		if err := os.Remove(backupFiles[i].name); err != nil {
+			zap.S().Errorw("fail to remove the log backup file", "file", backupFiles[i].name, "error", err)
+			continue
+		}
+		total -= backupFiles[i].fileInfo.Size()
+		zap.S().Warnw("remove the oldest log backup file because the total size of the log files exceeds total_log_bytes_limit",
+			"file", backupFiles[i].name, "size", backupFiles[i].fileInfo.Size(), "total", total, "limit", limit)
+		events.EmitEvent(events.CreateLogBackupPrunedEvent(backupFiles[i].name, backupFiles[i].fileInfo.Size(), total, limit))
+	}
+	if total > limit {
+		zap.S().Warnw("the total size of the log files exceeds total_log_bytes_limit, but no backup file can be removed", "total", total, "limit", limit)
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	for name := range u.backups {
+		u.updateFileSizes(name)
+	}
+	u.pruning = false
+}
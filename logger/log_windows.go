@@ -2,10 +2,31 @@
 
 package logger
 
-func NewSysLogger(name string, logEventEmitter LogEventEmitter) *SysLogger {
-	return &SysLogger{logEventEmitter: logEventEmitter, logWriter: nil}
+import (
+	"io"
+	"io/ioutil"
+
+	"go.uber.org/zap"
+)
+
+// discard the log written to it
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// NewSysLogger create a syslog which discards the log because syslog is not supported on
+// this platform
+func NewSysLogger(name string, facility string, logEventEmitter LogEventEmitter) *SysLogger {
+	zap.S().Warnw("syslog is not supported on this platform, the log is discarded", "tag", name)
+	return &SysLogger{logEventEmitter: logEventEmitter, logWriter: nopWriteCloser{ioutil.Discard}}
 }
 
-func NewRemoteSysLogger(name string, config string, logEventEmitter LogEventEmitter) *SysLogger {
-	return NewSysLogger(name, logEventEmitter)
+// NewRemoteSysLogger create a syslog which discards the log because syslog is not supported on
+// this platform
+func NewRemoteSysLogger(name string, config string, facility string, logEventEmitter LogEventEmitter) *SysLogger {
+	return NewSysLogger(name, facility, logEventEmitter)
 }
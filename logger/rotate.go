@@ -0,0 +1,185 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// LogRotateDaily rotate the log file at midnight
+	LogRotateDaily = "daily"
+	// LogRotateHourly rotate the log file at the beginning of each hour
+	LogRotateHourly = "hourly"
+)
+
+// the time layout of the backup file suffix for each rotate period
+var rotatePeriodLayouts = map[string]string{LogRotateDaily: "2006-01-02",
+	LogRotateHourly: "2006-01-02-15"}
+
+// the suffix of the backup files rotated by time, like ".2021-03-01", ".2021-03-01-13" or
+// ".2021-03-01.1" if the log is rotated more than once in the period, with ".gz" if it is compressed
+var datedBackupSuffix = regexp.MustCompile(`^\.\d{4}-\d{2}-\d{2}(-\d{2})?(\.\d+)?(\.gz)?$`)
+
+// IsValidRotatePeriod check if the period is empty (rotate by size only), daily or hourly
+func IsValidRotatePeriod(period string) bool {
+	_, ok := rotatePeriodLayouts[period]
+	return ok || period == ""
+}
+
+// get the start of the period t is in. The zero time is returned if period is not daily or hourly
+func getPeriodStart(t time.Time, period string) time.Time {
+	year, month, day := t.Date()
+	switch period {
+	case LogRotateDaily:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	case LogRotateHourly:
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// get a backup file name of the log file written in the period starting at periodStart.
+// A sequence number is appended if the log file is rotated more than once in the period
+func getDatedBackupFile(name string, period string, periodStart time.Time) string {
+	backup := fmt.Sprintf("%s.%s", name, periodStart.Format(rotatePeriodLayouts[period]))
+	for i := 1; ; i++ {
+		if !isFileExist(backup) && !isFileExist(backup+compressedSuffix) {
+			return backup
+		}
+		backup = fmt.Sprintf("%s.%s.%d", name, periodStart.Format(rotatePeriodLayouts[period]), i)
+	}
+}
+
+// get the backup files of the log file rotated by time, the newest one first
+func getDatedBackupFiles(name string) []string {
+	fileInfos, _ := ioutil.ReadDir(filepath.Dir(name))
+	base := filepath.Base(name)
+	backups := make([]os.FileInfo, 0)
+	for _, fileInfo := range fileInfos {
+		if strings.HasPrefix(fileInfo.Name(), base) && datedBackupSuffix.MatchString(fileInfo.Name()[len(base):]) {
+			backups = append(backups, fileInfo)
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].ModTime().Equal(backups[j].ModTime()) {
+			return backups[i].Name() > backups[j].Name()
+		}
+		return backups[i].ModTime().After(backups[j].ModTime())
+	})
+	result := make([]string, 0)
+	for _, b := range backups {
+		result = append(result, filepath.Join(filepath.Dir(name), b.Name()))
+	}
+	return result
+}
+
+// logSpan read the uncompressed backup files rotated by time and the log file as one log, so
+// the offsets in the log are kept when the log file is rotated by time
+type logSpan struct {
+	files []*os.File
+	sizes []int64
+	// the total size of the files
+	size int64
+}
+
+// open the uncompressed backup files of the log file rotated by time, the oldest one first, and
+// the log file. The backup files which can't be opened are skipped
+func openLogSpan(name string) (*logSpan, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	span := &logSpan{}
+	backups := getDatedBackupFiles(name)
+	for i := len(backups) - 1; i >= 0; i-- {
+		if strings.HasSuffix(backups[i], compressedSuffix) {
+			continue
+		}
+		if backup, err := os.Open(backups[i]); err == nil {
+			span.add(backup)
+		}
+	}
+	if err = span.add(f); err != nil {
+		span.Close()
+		return nil, err
+	}
+	return span, nil
+}
+
+func (s *logSpan) add(f *os.File) error {
+	statInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.files = append(s.files, f)
+	s.sizes = append(s.sizes, statInfo.Size())
+	s.size += statInfo.Size()
+	return nil
+}
+
+// ReadAt read len(b) bytes from offset of the log, the read may span more than one file
+func (s *logSpan) ReadAt(b []byte, offset int64) (int, error) {
+	n := 0
+	for i, f := range s.files {
+		if n >= len(b) {
+			break
+		}
+		if offset >= s.sizes[i] {
+			offset -= s.sizes[i]
+			continue
+		}
+		length := s.sizes[i] - offset
+		if length > int64(len(b)-n) {
+			length = int64(len(b) - n)
+		}
+		m, err := f.ReadAt(b[n:n+int(length)], offset)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		offset = 0
+	}
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close close all the files of the log
+func (s *logSpan) Close() error {
+	for _, f := range s.files {
+		f.Close()
+	}
+	return nil
+}
+
+// GetBackupFiles get the existing backup files of the log file: the files rotated by size
+// (name.1 to name.<backups>) and the files rotated by time (like name.2021-03-01), the newest one
+// first in each kind. A backup file has the .gz suffix if it is compressed
+func GetBackupFiles(name string, backups int) []string {
+	result := make([]string, 0)
+	for i := 1; i <= backups; i++ {
+		f := fmt.Sprintf("%s.%d", name, i)
+		if !isFileExist(f) {
+			f += compressedSuffix
+		}
+		if !isFileExist(f) {
+			break
+		}
+		result = append(result, f)
+	}
+	return append(result, getDatedBackupFiles(name)...)
+}
+
+func isFileExist(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,30 @@
+package logger
+
+// the syslog facility codes by name, as defined in RFC 5424
+var syslogFacilities = map[string]int{"kern": 0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23}
+
+// IsValidSyslogFacility check if the facility is empty (the default facility) or a syslog
+// facility name like user, daemon or local0 to local7
+func IsValidSyslogFacility(facility string) bool {
+	_, ok := syslogFacilities[facility]
+	return ok || facility == ""
+}
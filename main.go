@@ -1,16 +1,17 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"github.com/jessevdk/go-flags"
+	"github.com/ochinchina/supervisord/config"
 	"go.uber.org/zap"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"syscall"
-	"unicode"
+	"time"
 )
 
 // Options the command line options
@@ -18,6 +19,10 @@ type Options struct {
 	Configuration string `short:"c" long:"configuration" description:"the configuration file"`
 	Daemon        bool   `short:"d" long:"daemon" description:"run as daemon"`
 	EnvFile       string `long:"env-file" description:"the environment file"`
+	ConfigPubkey  string `long:"config-pubkey" description:"the ed25519 public key to verify the configuration files with their .sig signature files"`
+	Validate      bool   `short:"t" long:"validate" description:"validate the configuration and exit without starting anything"`
+	Pidfile       string `long:"pidfile" description:"the pidfile overriding the pidfile in [supervisord] section"`
+	DumpConfig    bool   `long:"dump-config" description:"print the effective configuration of the programs and exit without starting anything"`
 }
 
 func init() {
@@ -25,14 +30,62 @@ func init() {
 	zap.ReplaceGlobals(l)
 }
 
-func initSignals(s *Supervisor) {
-	sigs := make(chan os.Signal, 1)
+// the repeated SIGINT/SIGTERM received in this window after the first one escalate the shutdown
+const shutdownEscalationWindow = 30 * time.Second
+
+// the supervisor of the current restart iteration, the signals are handled by it
+var currentSupervisor atomic.Value
+
+func getCurrentSupervisor() *Supervisor {
+	return currentSupervisor.Load().(*Supervisor)
+}
+
+// initSignals handle the SIGINT/SIGTERM:
+// - first signal: stop all the processes gracefully and exit
+// - second signal in the shutdown window: kill the remaining processes with SIGKILL
+// - third signal in the shutdown window: exit immediately
+//
+// and reload the configuration like the reloadConfig RPC on SIGHUP. The signals are handled by the
+// current supervisor, so it is called only once before the supervisor is restarted
+func initSignals() {
+	reloadSigs := make(chan os.Signal, 1)
+	signal.Notify(reloadSigs, syscall.SIGHUP)
+	go func() {
+		for range reloadSigs {
+			zap.S().Infow("receive a signal to reload the configuration", "signal", syscall.SIGHUP)
+			if _, err := getCurrentSupervisor().reloadConfig(); err != nil {
+				zap.S().Errorw("fail to reload the configuration", "error", err)
+			}
+		}
+	}()
+
+	sigs := make(chan os.Signal, 3)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
-		sig := <-sigs
-		zap.S().Infow("receive a signal to stop all process & exit", "signal", sig)
-		s.procMgr.StopAllProcesses()
-		os.Exit(-1)
+		sigCount := 0
+		var firstSigTime time.Time
+		for sig := range sigs {
+			if sigCount == 0 || time.Since(firstSigTime) > shutdownEscalationWindow {
+				sigCount = 0
+				firstSigTime = time.Now()
+			}
+			sigCount++
+			switch sigCount {
+			case 1:
+				zap.S().Infow("receive a signal to stop all process & exit", "signal", sig)
+				s := getCurrentSupervisor()
+				go func() {
+					s.procMgr.StopAllProcesses()
+					os.Exit(-1)
+				}()
+			case 2:
+				zap.S().Warnw("receive the signal again, kill all the processes", "signal", sig)
+				go getCurrentSupervisor().procMgr.KillAllProcesses()
+			default:
+				zap.S().Warnw("receive the signal again, exit immediately", "signal", sig)
+				os.Exit(-1)
+			}
+		}
 	}()
 
 }
@@ -44,39 +97,14 @@ func loadEnvFile() {
 	if len(options.EnvFile) <= 0 {
 		return
 	}
-	//try to open the environment file
-	f, err := os.Open(options.EnvFile)
+	env, err := config.LoadEnvFile(options.EnvFile)
 	if err != nil {
-		zap.S().Error("Fail to open environment file", "file", options.EnvFile)
+		zap.S().Errorw("Fail to open environment file", "file", options.EnvFile, "error", err)
 		return
 	}
-	defer f.Close()
-	reader := bufio.NewReader(f)
-	for {
-		//for each line
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		//if line starts with '#', it is a comment line, ignore it
-		line = strings.TrimSpace(line)
-		if len(line) > 0 && line[0] == '#' {
-			continue
-		}
-		//if environment variable is exported with "export"
-		if strings.HasPrefix(line, "export") && len(line) > len("export") && unicode.IsSpace(rune(line[len("export")])) {
-			line = strings.TrimSpace(line[len("export"):])
-		}
-		//split the environment variable with "="
-		pos := strings.Index(line, "=")
-		if pos != -1 {
-			k := strings.TrimSpace(line[0:pos])
-			v := strings.TrimSpace(line[pos+1:])
-			//if key and value are not empty, put it into the environment
-			if len(k) > 0 && len(v) > 0 {
-				os.Setenv(k, v)
-			}
-		}
+	for _, kv := range env {
+		pos := strings.Index(kv, "=")
+		os.Setenv(kv[0:pos], kv[pos+1:])
 	}
 }
 
@@ -89,6 +117,9 @@ func loadEnvFile() {
 // 5. ../etc/supervisord.conf (Relative to the executable)
 // 6. ../supervisord.conf (Relative to the executable)
 func findSupervisordConf() (string, error) {
+	if config.IsRemoteConfigFile(options.Configuration) {
+		return options.Configuration, nil
+	}
 	possibleSupervisordConf := []string{options.Configuration,
 		"./supervisord.conf",
 		"./etc/supervisord.conf",
@@ -110,17 +141,41 @@ func findSupervisordConf() (string, error) {
 	return "", fmt.Errorf("fail to find supervisord.conf")
 }
 
+// create the supervisor with the configuration file and public key in the command line options
+func createSupervisor() (*Supervisor, error) {
+	if len(options.Configuration) <= 0 {
+		options.Configuration, _ = findSupervisordConf()
+	}
+	s := NewSupervisor(options.Configuration)
+	s.SetPidfile(options.Pidfile)
+	if len(options.ConfigPubkey) > 0 {
+		pubKey, err := config.LoadPublicKey(options.ConfigPubkey)
+		if err != nil {
+			return nil, err
+		}
+		s.GetConfig().SetPublicKey(pubKey)
+	}
+	return s, nil
+}
+
 func runServer() {
 	// infinite loop for handling Restart ('reload' command)
 	loadEnvFile()
+	signalsInitialized := false
 	for true {
-		if len(options.Configuration) <= 0 {
-			options.Configuration, _ = findSupervisordConf()
+		s, err := createSupervisor()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fail to create the supervisor: %v\n", err)
+			os.Exit(1)
+		}
+		currentSupervisor.Store(s)
+		if !signalsInitialized {
+			initSignals()
+			signalsInitialized = true
 		}
-		s := NewSupervisor(options.Configuration)
-		initSignals(s)
-		if _, _, _, sErr := s.Reload(); sErr != nil {
-			panic(sErr)
+		if _, sErr := s.Reload(); sErr != nil {
+			fmt.Fprintf(os.Stderr, "fail to load the configuration %s: %v\n", s.GetConfig().GetConfigFile(), sErr)
+			os.Exit(1)
 		}
 		s.WaitForExit()
 	}
@@ -137,7 +192,11 @@ func main() {
 				fmt.Fprintln(os.Stdout, err)
 				os.Exit(0)
 			case flags.ErrCommandRequired:
-				if options.Daemon {
+				if options.Validate {
+					os.Exit(validateConfiguration())
+				} else if options.DumpConfig {
+					os.Exit(dumpConfiguration())
+				} else if options.Daemon {
 					Deamonize(runServer)
 				} else {
 					runServer()
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/ochinchina/supervisord/process"
+)
+
+// Metrics export the metrics of the programs in the Prometheus text format through http interface
+type Metrics struct {
+	router     *mux.Router
+	supervisor *Supervisor
+}
+
+// NewMetrics create a Metrics object
+func NewMetrics(supervisor *Supervisor) *Metrics {
+	return &Metrics{router: mux.NewRouter(), supervisor: supervisor}
+}
+
+// CreateHandler create the /metrics http handler
+func (m *Metrics) CreateHandler() http.Handler {
+	m.router.HandleFunc("/metrics", m.getMetrics).Methods("GET")
+	return m.router
+}
+
+// check if the /metrics is enabled by metrics_enabled of [inet_http_server]
+func (s *Supervisor) isMetricsEnabled() bool {
+	httpServerConfig, ok := s.config.GetInetHTTPServer()
+	return ok && httpServerConfig.GetBool("metrics_enabled", false)
+}
+
+// a metric of one program
+type processMetric struct {
+	name   string
+	help   string
+	typ    string
+	labels string
+	value  func(proc *process.Process) float64
+}
+
+var processMetrics = []processMetric{
+	{name: "supervisord_process_state", help: "The state code of the program.", typ: "gauge",
+		value: func(proc *process.Process) float64 { return float64(proc.GetState()) }},
+	{name: "supervisord_process_restarts_total", help: "How many times the program is restarted.", typ: "counter",
+		value: func(proc *process.Process) float64 { return float64(proc.GetRestarts()) }},
+	{name: "supervisord_process_uptime_seconds", help: "How long the program has been running.", typ: "gauge",
+		value: func(proc *process.Process) float64 { return proc.GetUptime().Seconds() }},
+	{name: "supervisord_process_exit_status", help: "The exit status of the last run of the program, -1 if unknown.", typ: "gauge",
+		value: func(proc *process.Process) float64 { return float64(proc.GetLastExitStatus()) }},
+	{name: "supervisord_process_output_bytes_total", help: "The bytes written by the program.", typ: "counter", labels: `stream="stdout"`,
+		value: func(proc *process.Process) float64 { return float64(proc.GetStdoutStats().TotalBytes) }},
+	{name: "supervisord_process_output_bytes_total", labels: `stream="stderr"`,
+		value: func(proc *process.Process) float64 { return float64(proc.GetStderrStats().TotalBytes) }},
+	{name: "supervisord_process_output_lines_total", help: "The lines written by the program.", typ: "counter", labels: `stream="stdout"`,
+		value: func(proc *process.Process) float64 { return float64(proc.GetStdoutStats().TotalLines) }},
+	{name: "supervisord_process_output_lines_total", labels: `stream="stderr"`,
+		value: func(proc *process.Process) float64 { return float64(proc.GetStderrStats().TotalLines) }},
+}
+
+func (m *Metrics) getMetrics(w http.ResponseWriter, req *http.Request) {
+	procs := make([]*process.Process, 0)
+	m.supervisor.GetManager().ForEachProcess(func(proc *process.Process) {
+		procs = append(procs, proc)
+	})
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].GetName() < procs[j].GetName()
+	})
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(w, procs)
+}
+
+// write the metrics of the programs in the Prometheus text format
+func writeMetrics(w io.Writer, procs []*process.Process) {
+	fmt.Fprintf(w, "# HELP supervisord_processes The number of programs managed by supervisord.\n")
+	fmt.Fprintf(w, "# TYPE supervisord_processes gauge\n")
+	fmt.Fprintf(w, "supervisord_processes %d\n", len(procs))
+	for _, metric := range processMetrics {
+		if metric.help != "" {
+			fmt.Fprintf(w, "# HELP %s %s\n", metric.name, metric.help)
+			fmt.Fprintf(w, "# TYPE %s %s\n", metric.name, metric.typ)
+		}
+		for _, proc := range procs {
+			labels := fmt.Sprintf(`name="%s",group="%s"`, escapeLabelValue(proc.GetName()), escapeLabelValue(proc.GetGroup()))
+			if metric.labels != "" {
+				labels = labels + "," + metric.labels
+			}
+			fmt.Fprintf(w, "%s{%s} %v\n", metric.name, labels, metric.value(proc))
+		}
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
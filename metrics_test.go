@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ochinchina/supervisord/config"
+	"github.com/ochinchina/supervisord/process"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	entry := &config.Entry{ConfigDir: ".", Group: "web", Name: "program:api"}
+	procs := []*process.Process{process.NewProcess("supervisord", entry)}
+	buf := bytes.NewBuffer(nil)
+	writeMetrics(buf, procs)
+	metrics := buf.String()
+
+	for _, expected := range []string{
+		"supervisord_processes 1\n",
+		"# TYPE supervisord_process_restarts_total counter\n",
+		`supervisord_process_state{name="api",group="web"} 0` + "\n",
+		`supervisord_process_exit_status{name="api",group="web"} -1` + "\n",
+		`supervisord_process_output_lines_total{name="api",group="web",stream="stderr"} 0` + "\n",
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("expect %q in the metrics:\n%s", expected, metrics)
+		}
+	}
+	if strings.Count(metrics, "# TYPE supervisord_process_output_bytes_total") != 1 {
+		t.Errorf("expect the type of a metric is written one time:\n%s", metrics)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if escaped := escapeLabelValue("a\"b\\c\nd"); escaped != `a\"b\\c\nd` {
+		t.Errorf("fail to escape the label value: %s", escaped)
+	}
+}
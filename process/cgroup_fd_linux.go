@@ -0,0 +1,18 @@
+// +build linux,go1.20
+
+package process
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// let the command be started in the cgroup opened as fd by clone3 with CLONE_INTO_CGROUP
+func setCgroupFD(cmd *exec.Cmd, fd int) bool {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = fd
+	return true
+}
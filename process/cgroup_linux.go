@@ -0,0 +1,160 @@
+// +build linux
+
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// the mount point of the cgroup v2 hierarchy
+var cgroupRoot = "/sys/fs/cgroup"
+
+// the period in microseconds of the cpu.max setting for cpu_quota
+const cgroupCPUPeriod = 100000
+
+// the magic number of the cgroup v2 file system
+const cgroup2SuperMagic = 0x63677270
+
+// start the command in the cgroup of the program with the memory_limit and cpu_quota, so the
+// processes forked by the program can't escape the limits before it is placed in the cgroup. If
+// the kernel can't start a process in a cgroup, the program is placed in the cgroup right after
+// it is started. The command is started without cgroup if both are not set and the cgroup was
+// never created. The lock must be held
+func (p *Process) startWithResourceLimits(cmd *exec.Cmd, umask int) error {
+	dir := p.prepareCgroup()
+	if dir != "" && canStartInCgroup(dir) {
+		if f, err := os.Open(dir); err == nil {
+			defer f.Close()
+			if setCgroupFD(cmd, int(f.Fd())) {
+				return startCommand(cmd, umask)
+			}
+		}
+	}
+	err := startCommand(cmd, umask)
+	if err == nil && dir != "" {
+		placeInCgroup(p.GetName(), dir, cmd.Process.Pid)
+	}
+	return err
+}
+
+// place the process, like the daemon tracked by the pidfile, in the cgroup of the program if it
+// is created. The lock must be held
+func (p *Process) placeInCgroup(pid int) {
+	if p.cgroupDir != "" {
+		placeInCgroup(p.GetName(), p.cgroupDir, pid)
+	}
+}
+
+func placeInCgroup(program string, dir string, pid int) {
+	if err := writeCgroupFile(dir, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+		zap.S().Errorw("fail to place the program in its cgroup", "program", program, "cgroup", dir, "error", err)
+	}
+}
+
+// create the cgroup of the program with the memory_limit and cpu_quota and return its directory.
+// Empty is returned if both are not set and the cgroup was never created, or the cgroup can't be
+// created. The lock must be held
+func (p *Process) prepareCgroup() string {
+	memoryLimit := p.config.GetBytes("memory_limit", 0)
+	cpuQuota := p.config.GetPercent("cpu_quota", 0)
+	if memoryLimit <= 0 && cpuQuota <= 0 && p.cgroupDir == "" {
+		return ""
+	}
+	dir := filepath.Join(cgroupRoot, "supervisord-"+p.supervisorID, p.GetName())
+	if err := createCgroup(dir, memoryLimit, cpuQuota); err != nil {
+		zap.S().Errorw("fail to set the resource limits of the program", "program", p.GetName(), "cgroup", dir, "error", err)
+		return ""
+	}
+	p.cgroupDir = dir
+	return dir
+}
+
+// check if a process can be started in the cgroup dir, which must be a cgroup v2 directory and
+// the kernel must support clone3 with CLONE_INTO_CGROUP since Linux 5.7
+func canStartInCgroup(dir string) bool {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil || int64(fs.Type) != cgroup2SuperMagic {
+		return false
+	}
+	return isKernelAtLeast(5, 7)
+}
+
+// check if the version of the running kernel is at least major.minor
+func isKernelAtLeast(major int, minor int) bool {
+	var uname syscall.Utsname
+	if err := syscall.Uname(&uname); err != nil {
+		return false
+	}
+	release := make([]byte, 0, len(uname.Release))
+	for _, c := range uname.Release {
+		if c == 0 {
+			break
+		}
+		release = append(release, byte(c))
+	}
+	var kernelMajor, kernelMinor int
+	if _, err := fmt.Sscanf(string(release), "%d.%d", &kernelMajor, &kernelMinor); err != nil {
+		return false
+	}
+	return kernelMajor > major || (kernelMajor == major && kernelMinor >= minor)
+}
+
+// create the cgroup dir if it does not exist and write its memory.max and cpu.max. The limit
+// <= 0 is written as max, so the limit removed from the configuration is lifted
+func createCgroup(dir string, memoryLimit int, cpuQuota float64) error {
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// the controllers must be enabled in all the ancestors of the cgroup to set its limits
+	for _, controller := range []string{"memory", "cpu"} {
+		for _, ancestor := range []string{filepath.Dir(parent), parent} {
+			if err := writeCgroupFile(ancestor, "cgroup.subtree_control", "+"+controller); err != nil {
+				return err
+			}
+		}
+	}
+
+	memoryMax := "max"
+	if memoryLimit > 0 {
+		memoryMax = strconv.Itoa(memoryLimit)
+	}
+	if err := writeCgroupFile(dir, "memory.max", memoryMax); err != nil {
+		return err
+	}
+	cpuMax := fmt.Sprintf("max %d", cgroupCPUPeriod)
+	if cpuQuota > 0 {
+		cpuMax = fmt.Sprintf("%d %d", int64(cpuQuota*cgroupCPUPeriod/100), cgroupCPUPeriod)
+	}
+	return writeCgroupFile(dir, "cpu.max", cpuMax)
+}
+
+func writeCgroupFile(dir string, name string, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// RemoveCgroup remove the cgroup created for the memory_limit and cpu_quota of the program. It
+// should be called after the program is stopped and removed
+func (p *Process) RemoveCgroup() {
+	p.lock.Lock()
+	dir := p.cgroupDir
+	p.cgroupDir = ""
+	p.lock.Unlock()
+	if dir == "" {
+		return
+	}
+	if err := os.Remove(dir); err != nil {
+		zap.S().Warnw("fail to remove the cgroup of the program", "program", p.GetName(), "cgroup", dir, "error", err)
+		return
+	}
+	// the parent is removed with the cgroup of the last program in it
+	os.Remove(filepath.Dir(dir))
+}
@@ -0,0 +1,73 @@
+// +build linux
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestApplyResourceLimits(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatalf("fail to create the cgroup root: %v", err)
+	}
+	defer os.RemoveAll(root)
+	defer func(prev string) { cgroupRoot = prev }(cgroupRoot)
+	cgroupRoot = root
+
+	readCgroupFile := func(name string) string {
+		b, _ := ioutil.ReadFile(filepath.Join(root, "supervisord-supervisord", "test", name))
+		return string(b)
+	}
+
+	start := func(proc *Process) *exec.Cmd {
+		cmd := exec.Command("/bin/true")
+		if err := proc.startWithResourceLimits(cmd, -1); err != nil {
+			t.Fatalf("fail to start the command: %v", err)
+		}
+		cmd.Wait()
+		return cmd
+	}
+
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\n"))
+	if cmd := start(proc); proc.cgroupDir != "" || cmd.SysProcAttr != nil {
+		t.Errorf("expect no cgroup without the limits but get %s", proc.cgroupDir)
+	}
+
+	// the temporary cgroup root is not a cgroup v2 file system, so the program is placed in the
+	// cgroup after it is started
+	proc = NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\nmemory_limit=64MB\ncpu_quota=50%\n"))
+	cmd := start(proc)
+	if readCgroupFile("memory.max") != "67108864" || readCgroupFile("cpu.max") != "50000 100000" || readCgroupFile("cgroup.procs") != strconv.Itoa(cmd.Process.Pid) {
+		t.Errorf("unexpected cgroup settings memory.max=%q cpu.max=%q cgroup.procs=%q", readCgroupFile("memory.max"), readCgroupFile("cpu.max"), readCgroupFile("cgroup.procs"))
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(root, "supervisord-supervisord", "cgroup.subtree_control")); string(b) != "+cpu" {
+		t.Errorf("expect the controllers are enabled in the parent cgroup but get %q", string(b))
+	}
+
+	// the limits removed from the configuration are lifted on the next start
+	proc.config = loadTestProgram(t, "[program:test]\ncommand=/bin/true\n")
+	start(proc)
+	if readCgroupFile("memory.max") != "max" || readCgroupFile("cpu.max") != "max 100000" {
+		t.Errorf("expect the limits are lifted but get memory.max=%q cpu.max=%q", readCgroupFile("memory.max"), readCgroupFile("cpu.max"))
+	}
+}
+
+func TestCanStartInCgroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if canStartInCgroup(dir) {
+		t.Error("expect a process can't be started in a directory not in the cgroup v2 file system")
+	}
+	if !isKernelAtLeast(2, 6) || isKernelAtLeast(1000, 0) {
+		t.Error("fail to compare the kernel version")
+	}
+}
@@ -0,0 +1,13 @@
+// +build linux,!go1.20
+
+package process
+
+import (
+	"os/exec"
+)
+
+// the go before 1.20 can't start a command in a cgroup, so the program is placed in its cgroup
+// by writing its pid to cgroup.procs after it is started
+func setCgroupFD(cmd *exec.Cmd, fd int) bool {
+	return false
+}
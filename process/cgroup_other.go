@@ -0,0 +1,26 @@
+// +build !linux
+
+package process
+
+import (
+	"os/exec"
+	"runtime"
+
+	"go.uber.org/zap"
+)
+
+// the memory_limit and cpu_quota are applied by cgroups, which are only supported on linux
+func (p *Process) startWithResourceLimits(cmd *exec.Cmd, umask int) error {
+	if p.config.GetBytes("memory_limit", 0) > 0 || p.config.GetPercent("cpu_quota", 0) > 0 {
+		zap.S().Warnw("memory_limit and cpu_quota are not supported, the program runs without the limits", "program", p.GetName(), "os", runtime.GOOS)
+	}
+	return startCommand(cmd, umask)
+}
+
+// nothing to do because no cgroup is created on this platform
+func (p *Process) placeInCgroup(pid int) {
+}
+
+// RemoveCgroup nothing to remove because no cgroup is created on this platform
+func (p *Process) RemoveCgroup() {
+}
@@ -0,0 +1,50 @@
+package process
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ochinchina/supervisord/config"
+)
+
+// CheckProgram check if the program can be started without starting it: its command can be
+// parsed, its directory exists, the command binary exists and is executable, and its user
+// resolves. All the problems found are returned
+func CheckProgram(entry *config.Entry) []string {
+	problems := make([]string, 0)
+	name := entry.Name
+	args, err := parseCommand(entry.GetStringExpression("command", ""))
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("%s: fail to parse the command: %v", name, err))
+	} else if len(args) == 0 {
+		problems = append(problems, fmt.Sprintf("%s: the command is empty", name))
+	}
+	dir, err := getDirectory(entry)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+	} else if len(args) > 0 {
+		if err := checkExecutable(args[0], dir); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if userName := entry.GetString("user", ""); userName != "" {
+		if _, _, err := LookupUser(userName); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: user %s does not resolve: %v", name, userName, err))
+		}
+	}
+	return problems
+}
+
+// check if the command binary exists and is executable. The binary without path separator is
+// searched in PATH, and the relative path is relative to the directory the program runs in
+func checkExecutable(binary string, dir string) error {
+	if strings.ContainsAny(binary, `/\`) && !filepath.IsAbs(binary) && dir != "" {
+		binary = filepath.Join(dir, binary)
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return fmt.Errorf("the command %s is not an executable file: %v", binary, err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package process
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckProgram(t *testing.T) {
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/sh -c true\n")
+	if problems := CheckProgram(entry); len(problems) != 0 {
+		t.Errorf("expect no problem but got %v", problems)
+	}
+
+	entry = loadTestProgram(t, "[program:test]\ncommand=/no/such/binary\nuser=no-such-user\n")
+	problems := CheckProgram(entry)
+	if len(problems) != 2 || !strings.Contains(problems[0], "/no/such/binary") || !strings.Contains(problems[1], "no-such-user") {
+		t.Errorf("expect the missing binary and user are reported but got %v", problems)
+	}
+}
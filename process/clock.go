@@ -0,0 +1,37 @@
+package process
+
+import (
+	"time"
+)
+
+// clock the time source of the process package
+//
+// The durations like startsecs, backoff and uptime are measured with monotonic(), so they are
+// not affected if the wall clock jumps (NTP step, manual change). now() is only used for the
+// timestamps displayed to the user
+type clock interface {
+	// get the wall clock time
+	now() time.Time
+	// get the elapsed time by the monotonic clock since a fixed point
+	monotonic() time.Duration
+}
+
+// systemClock read the wall clock and the monotonic clock of the system
+type systemClock struct {
+	base time.Time
+}
+
+func newSystemClock() *systemClock {
+	return &systemClock{base: time.Now()}
+}
+
+func (c *systemClock) now() time.Time {
+	return time.Now()
+}
+
+// time.Since uses the monotonic clock reading of base
+func (c *systemClock) monotonic() time.Duration {
+	return time.Since(c.base)
+}
+
+var processClock clock = newSystemClock()
@@ -0,0 +1,64 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ochinchina/supervisord/config"
+)
+
+// fakeClock a clock whose wall clock can jump without changing the monotonic clock
+type fakeClock struct {
+	wall time.Time
+	mono time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.wall
+}
+
+func (c *fakeClock) monotonic() time.Duration {
+	return c.mono
+}
+
+// advance both the wall clock and the monotonic clock
+func (c *fakeClock) advance(d time.Duration) {
+	c.wall = c.wall.Add(d)
+	c.mono += d
+}
+
+// replace processClock with the fake clock until the test finishes. The previous clock is
+// restored instead of a new system clock, so the monotonic times recorded before are kept.
+// processClock is shared by all the processes, so the tests using it must not run in parallel
+func useFakeClock(t *testing.T, fake *fakeClock) {
+	prevClock := processClock
+	processClock = fake
+	t.Cleanup(func() { processClock = prevClock })
+}
+
+func TestBackoffNotAffectedByClockJump(t *testing.T) {
+	fake := &fakeClock{wall: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), mono: time.Hour}
+	useFakeClock(t, fake)
+
+	entry := &config.Entry{ConfigDir: ".", Group: "test", Name: "program:test"}
+	proc := NewProcess("supervisord", entry)
+	proc.startTime = fake.now()
+	proc.startMonotonic = fake.monotonic()
+	proc.backoff(10 * time.Second)
+
+	fake.advance(4 * time.Second)
+	// the wall clock jumps backward one hour
+	fake.wall = fake.wall.Add(-time.Hour)
+
+	if remaining := proc.GetNextRetryTime().Sub(fake.now()); remaining != 6*time.Second {
+		t.Errorf("expect the next retry in 6s but got %v", remaining)
+	}
+	if uptime := proc.getUptime(); uptime != 4*time.Second {
+		t.Errorf("expect the uptime 4s but got %v", uptime)
+	}
+
+	fake.advance(6 * time.Second)
+	if !proc.GetNextRetryTime().Equal(fake.now()) {
+		t.Errorf("expect the retry is due but it is at %v", proc.GetNextRetryTime())
+	}
+}
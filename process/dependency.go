@@ -0,0 +1,77 @@
+package process
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the time a program may take besides its startsecs to reach Running, for example to spawn it
+const dependencyStartGrace = 2 * time.Second
+
+// wait for the programs proc depends on and start it. If any of them does not reach Running
+// in time, proc is not started and the reason is shown in its description
+func startAfterDependencies(proc *Process, deps []*Process) {
+	for _, dep := range deps {
+		if err := waitDependency(dep); err != nil {
+			zap.S().Warnw("the program is not started because its dependency is not ready", "program", proc.GetName(), "dependency", dep.GetName(), "error", err)
+			proc.setDependencyError(err.Error())
+			return
+		}
+	}
+	proc.setWaitingDependencies(false)
+	proc.Start(false)
+}
+
+// wait for the dependency to be ready. The dependency should reach Running in its startsecs
+// after it is started; if it has ready_regex, it should be ready in maxDependencyReadyWait after
+// it is Running. The time the dependency waits for its own dependencies is not counted
+func waitDependency(dep *Process) error {
+	startWait := time.Duration(dep.getStartSeconds())*time.Second + dependencyStartGrace
+	deadline := time.Now().Add(startWait)
+	var readyDeadline time.Time
+	for !dep.IsReady() {
+		now := time.Now()
+		waiting, depError := dep.getDependencyStatus()
+		if depError != "" {
+			return fmt.Errorf("dependency %s is not started", dep.GetName())
+		} else if dep.GetState() == Fatal {
+			return fmt.Errorf("dependency %s is FATAL", dep.GetName())
+		} else if waiting {
+			deadline = now.Add(startWait)
+		} else if dep.GetState() == Running {
+			if readyDeadline.IsZero() {
+				readyDeadline = now.Add(maxDependencyReadyWait)
+			} else if now.After(readyDeadline) {
+				return fmt.Errorf("dependency %s is not ready in %v", dep.GetName(), maxDependencyReadyWait)
+			}
+		} else if now.After(deadline) {
+			return fmt.Errorf("dependency %s is not RUNNING in %v", dep.GetName(), startWait)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
+
+func (p *Process) setWaitingDependencies(waiting bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.waitingDependencies = waiting
+	p.dependencyError = ""
+}
+
+func (p *Process) setDependencyError(reason string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.waitingDependencies = false
+	p.dependencyError = reason
+}
+
+// get if the program is waiting for its dependencies to start it, and why it is not started
+// if any of its dependencies is not ready
+func (p *Process) getDependencyStatus() (waiting bool, dependencyError string) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.waitingDependencies, p.dependencyError
+}
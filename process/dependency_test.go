@@ -0,0 +1,24 @@
+package process
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNotStartedIfDependencyNotRunning(t *testing.T) {
+	dep := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\nstartsecs=0\nautostart=false\n"))
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\n"))
+
+	proc.setWaitingDependencies(true)
+	startAfterDependencies(proc, []*Process{dep})
+
+	if proc.GetState() != Stopped {
+		t.Errorf("expect the program is not started but it is %v", proc.GetState())
+	}
+	if desc := proc.GetDescription(); !strings.HasPrefix(desc, "not started, dependency test is not RUNNING") {
+		t.Errorf("unexpected description %q", desc)
+	}
+	if waiting, _ := proc.getDependencyStatus(); waiting {
+		t.Error("expect the program does not wait for its dependencies any more")
+	}
+}
@@ -0,0 +1,73 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the timeout to post the fatal state of a program to the fatal webhook
+const fatalWebhookTimeout = 10 * time.Second
+
+// the JSON body posted to the fatal webhook
+type fatalWebhookBody struct {
+	Program    string `json:"program"`
+	Group      string `json:"group"`
+	ExitStatus int    `json:"exit_status"`
+	Retries    int    `json:"retries"`
+}
+
+// SetFatalWebhook set the url to post a JSON body to when a program enters Fatal state, the
+// empty url disables it
+func (pm *Manager) SetFatalWebhook(url string) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	pm.fatalWebhookURL = url
+}
+
+// notify the fatal webhook that the program entered Fatal state after retries starts. It is
+// called in a separate goroutine, so a slow webhook does not block the program
+func (pm *Manager) notifyFatal(proc *Process, exitStatus int, retries int) {
+	pm.lock.Lock()
+	url := pm.fatalWebhookURL
+	pm.lock.Unlock()
+	if url == "" {
+		return
+	}
+	body, _ := json.Marshal(fatalWebhookBody{Program: proc.GetName(),
+		Group:      proc.GetGroup(),
+		ExitStatus: exitStatus,
+		Retries:    retries})
+	client := &http.Client{Timeout: fatalWebhookTimeout}
+	if err := postFatalWebhook(client, url, body); err != nil {
+		zap.S().Errorw("fail to post the fatal state to the webhook", "program", proc.GetName(), "url", url, "error", err)
+		return
+	}
+	zap.S().Infow("post the fatal state to the webhook", "program", proc.GetName(), "url", url)
+}
+
+func postFatalWebhook(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// set the callback which is called in a separate goroutine when the process enters Fatal state
+func (p *Process) setFatalCallback(callback func(p *Process, exitStatus int, retries int)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.fatalCallback = callback
+}
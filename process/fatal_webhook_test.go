@@ -0,0 +1,40 @@
+package process
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ochinchina/supervisord/config"
+)
+
+func TestNotifyFatalWebhook(t *testing.T) {
+	bodies := make(chan fatalWebhookBody, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body fatalWebhookBody
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s with content type %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	pm := NewManager()
+	entry := &config.Entry{ConfigDir: ".", Group: "web", Name: "program:api"}
+	proc := pm.CreateProcess("supervisord", entry)
+
+	// no request is sent if the webhook is not set
+	pm.notifyFatal(proc, 1, 3)
+	pm.SetFatalWebhook(server.URL)
+	pm.notifyFatal(proc, 2, 4)
+
+	body := <-bodies
+	if body.Program != "api" || body.Group != "web" || body.ExitStatus != 2 || body.Retries != 4 {
+		t.Errorf("unexpected webhook body %+v", body)
+	}
+	if len(bodies) != 0 {
+		t.Error("expect only one webhook request")
+	}
+}
@@ -0,0 +1,70 @@
+package process
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// check if the flap detection is enabled by flap_max
+func (p *Process) isFlapDetectionEnabled() bool {
+	return p.config.GetInt("flap_max", 0) > 0
+}
+
+// get the restarts in the sliding window flap_window_secs, the lock must be held
+func (p *Process) getRecentRestarts() []time.Duration {
+	window := time.Duration(p.config.GetInt("flap_window_secs", 600)) * time.Second
+	since := processClock.monotonic() - window
+	for len(p.restartMonotonics) > 0 && p.restartMonotonics[0] < since {
+		p.restartMonotonics = p.restartMonotonics[1:]
+	}
+	return p.restartMonotonics
+}
+
+// record the program is restarted and check if it flaps: it is restarted more than flap_max times
+// in flap_window_secs. The flapping program is in Cooldown state for flap_cooldown_secs (defaults
+// to 300) before it is restarted.
+//
+// Return false if the program is stopped in the cooldown, so it should not be restarted
+func (p *Process) waitFlapCooldown() bool {
+	if !p.isFlapDetectionEnabled() {
+		return true
+	}
+	cooldown := time.Duration(p.config.GetInt("flap_cooldown_secs", 300)) * time.Second
+	p.lock.Lock()
+	p.restartMonotonics = append(p.getRecentRestarts(), processClock.monotonic())
+	restarts := len(p.restartMonotonics)
+	if stopByUser := p.stopByUser; restarts <= p.config.GetInt("flap_max", 0) || stopByUser {
+		p.lock.Unlock()
+		return !stopByUser
+	}
+	zap.S().Warnw(fmt.Sprintf("the program restarts too often, restart it after %v", cooldown), "program", p.GetName(), "restarts", restarts)
+	p.nextRetryMonotonic = processClock.monotonic() + cooldown
+	p.changeStateTo(Cooldown)
+	abortCooldown := p.abortBackoff
+	p.lock.Unlock()
+
+	sleepUntilAborted(cooldown, abortCooldown)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stopByUser {
+		if p.state == Cooldown {
+			p.changeStateTo(Stopped)
+		}
+		return false
+	}
+	return true
+}
+
+// GetFlapCount get how many times the program is restarted in the sliding window flap_window_secs,
+// it is 0 if the flap detection is not enabled by flap_max
+func (p *Process) GetFlapCount() int {
+	if !p.isFlapDetectionEnabled() {
+		return 0
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.getRecentRestarts())
+}
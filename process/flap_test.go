@@ -0,0 +1,49 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlappingProgramCoolsDown(t *testing.T) {
+	fake := &fakeClock{wall: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), mono: time.Hour}
+	useFakeClock(t, fake)
+
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/true\nflap_max=2\nflap_window_secs=60\nflap_cooldown_secs=60\n")
+	proc := NewProcess("supervisord", entry)
+	proc.abortBackoff = make(chan struct{})
+
+	for _, elapsed := range []time.Duration{0, 10 * time.Second, 55 * time.Second} {
+		fake.advance(elapsed)
+		if !proc.waitFlapCooldown() || proc.GetState() == Cooldown {
+			t.Fatalf("expect the program is restarted without cooldown")
+		}
+	}
+	// the first restart slides out of the window
+	if count := proc.GetFlapCount(); count != 2 {
+		t.Errorf("expect 2 restarts in the window but got %d", count)
+	}
+
+	fake.advance(5 * time.Second)
+	result := make(chan bool)
+	go func() {
+		result <- proc.waitFlapCooldown()
+	}()
+	for i := 0; i < 100 && proc.GetState() != Cooldown; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if proc.GetState() != Cooldown || proc.GetFlapCount() != 3 || proc.GetNextRetryTime() != fake.now().Add(60*time.Second) {
+		t.Fatalf("expect the program cools down after 3 restarts, state %v, flap count %d", proc.GetState(), proc.GetFlapCount())
+	}
+
+	// stop the program in the cooldown
+	proc.Stop(false)
+	select {
+	case restart := <-result:
+		if restart || proc.GetState() != Stopped {
+			t.Errorf("expect the program stopped in the cooldown is not restarted, state %v", proc.GetState())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cooldown is not aborted by stopping the program")
+	}
+}
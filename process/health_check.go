@@ -0,0 +1,86 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the status of the health check by healthcheck_url
+const (
+	HealthCheckPassing = "passing"
+	HealthCheckFailing = "failing"
+)
+
+// check if the health of the program is checked by healthcheck_url before it enters Running state
+func (p *Process) hasHealthCheck() bool {
+	return p.config.GetStringExpression("healthcheck_url", "") != ""
+}
+
+// wait for the program to pass the health check after startsecs elapses. The healthcheck_url is
+// requested every healthcheck_interval until it responds 2xx. If healthcheck_retries checks fail,
+// the program is stopped by the stop signals like Stop(), but not as stopped by user, so its start
+// fails.
+//
+// Return true if the program is healthy or the health check is not configured
+func (p *Process) waitHealthy(programExited *int32) bool {
+	url := p.config.GetStringExpression("healthcheck_url", "")
+	if url == "" {
+		return true
+	}
+	interval := p.config.GetDuration("healthcheck_interval", time.Second)
+	timeout := p.config.GetDuration("healthcheck_timeout", 2*time.Second)
+	retries := p.config.GetInt("healthcheck_retries", 3)
+	client := &http.Client{Timeout: timeout}
+	for failures := 0; atomic.LoadInt32(programExited) == 0; {
+		err := checkHealth(client, url)
+		if err == nil {
+			zap.S().Infow("the program passes the health check", "program", p.GetName(), "url", url)
+			p.setHealthStatus(HealthCheckPassing)
+			return true
+		}
+		failures++
+		p.setHealthStatus(fmt.Sprintf("%s: %v", HealthCheckFailing, err))
+		if failures >= retries {
+			zap.S().Warnw("the program fails the health check, stop it", "program", p.GetName(), "url", url, "failures", failures, "error", err)
+			p.sendStopSignals()
+			return false
+		}
+		zap.S().Infow("the program fails the health check, check it again later", "program", p.GetName(), "url", url, "error", err)
+		time.Sleep(interval)
+	}
+	return false
+}
+
+// request the url, the program is healthy if 2xx is responded
+func checkHealth(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *Process) setHealthStatus(status string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.healthStatus = status
+}
+
+// GetHealthStatus get the result of the last health check of the program, "passing" or
+// "failing: <reason>". It is empty if the healthcheck_url is not set or not checked yet
+func (p *Process) GetHealthStatus() string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.healthStatus
+}
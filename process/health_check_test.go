@@ -0,0 +1,46 @@
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckFailureStopsWithStopSignal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "supervisord-health-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "terminated")
+
+	entry := loadTestProgram(t, fmt.Sprintf("[program:test]\ncommand=/bin/sh -c \"trap 'touch %s; exit 0' TERM; while true; do sleep 0.1; done\"\n"+
+		"startsecs=1\nstartretries=0\nautorestart=false\nstopsignal=TERM\nhealthcheck_url=%s\nhealthcheck_retries=1\n", marker, server.URL))
+	proc := NewProcess("supervisord", entry)
+	defer proc.Stop(true)
+	proc.Start(true)
+
+	for i := 0; i < 50 && !isFileExist(marker); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !isFileExist(marker) {
+		t.Error("expect the program failing the health check is stopped by its stopsignal")
+	}
+	if proc.GetState() == Running {
+		t.Error("expect the program failing the health check is not running")
+	}
+}
+
+func isFileExist(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,80 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// the prefix of the autostart setting which autostarts the program only on the matching hosts
+const autostartHostPrefix = "host:"
+
+// EvalAutostart evaluate the autostart setting. It is true or false, or host:pattern which is true
+// if the host name matches the glob pattern like host:web*
+func EvalAutostart(value string) (bool, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, autostartHostPrefix) {
+		return value == "true", nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false, err
+	}
+	return path.Match(strings.TrimSpace(value[len(autostartHostPrefix):]), hostname)
+}
+
+// evalHostPredicates evaluate the comma separated host predicates, true if all of them match.
+// The supported predicates:
+//  hostname=name, hostname=~regex - match the host name
+//  env:NAME=value, env:NAME=~regex - match the environment variable
+func evalHostPredicates(expr string) (bool, error) {
+	for _, predicate := range strings.Split(expr, ",") {
+		predicate = strings.TrimSpace(predicate)
+		if predicate == "" {
+			continue
+		}
+		matched, err := evalHostPredicate(predicate)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func evalHostPredicate(predicate string) (bool, error) {
+	pos := strings.Index(predicate, "=")
+	if pos == -1 {
+		return false, fmt.Errorf("invalid predicate %s", predicate)
+	}
+	name := strings.TrimSpace(predicate[0:pos])
+	expected := predicate[pos+1:]
+	isRegex := strings.HasPrefix(expected, "~")
+	if isRegex {
+		expected = expected[1:]
+	}
+	expected = strings.TrimSpace(expected)
+
+	value := ""
+	if name == "hostname" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return false, err
+		}
+		value = hostname
+	} else if strings.HasPrefix(name, "env:") {
+		value = os.Getenv(name[len("env:"):])
+	} else {
+		return false, fmt.Errorf("unknown predicate %s", predicate)
+	}
+	if !isRegex {
+		return value == expected, nil
+	}
+	// the whole value should match the regex
+	re, err := regexp.Compile("^(?:" + expected + ")$")
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(value), nil
+}
@@ -0,0 +1,59 @@
+package process
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEvalHostPredicates(t *testing.T) {
+	hostname, _ := os.Hostname()
+	os.Setenv("SUPERVISORD_TEST_ROLE", "worker")
+	defer os.Unsetenv("SUPERVISORD_TEST_ROLE")
+
+	expected := map[string]bool{"hostname=" + hostname: true,
+		"hostname=~.*":                                true,
+		"hostname=~not-" + hostname:                   false,
+		"env:SUPERVISORD_TEST_ROLE=worker":            true,
+		"env:SUPERVISORD_TEST_ROLE=~work.*":           true,
+		"env:SUPERVISORD_TEST_ROLE=web":               false,
+		"hostname=~.*, env:SUPERVISORD_TEST_ROLE=web": false}
+	for expr, result := range expected {
+		matched, err := evalHostPredicates(expr)
+		if err != nil || matched != result {
+			t.Errorf("expect %v for %s but got %v, error: %v", result, expr, matched, err)
+		}
+	}
+	if _, err := evalHostPredicates("role=worker"); err == nil {
+		t.Error("fail to report the unknown predicate")
+	}
+}
+
+func TestEvalAutostart(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil || len(hostname) < 2 {
+		t.Skipf("the host name %q is not available: %v", hostname, err)
+	}
+	expected := map[string]bool{"true": true,
+		"false":                         false,
+		"host:" + hostname:              true,
+		"host:" + hostname[:1] + "*":    true,
+		"host: *":                       true,
+		"host:not-" + hostname + "*":    false,
+		"host:" + hostname[:1] + "?":    len(hostname) == 2,
+		"host:[^" + hostname[:1] + "]*": false}
+	for value, result := range expected {
+		autostart, err := EvalAutostart(value)
+		if err != nil || autostart != result {
+			t.Errorf("expect %v for autostart=%s but got %v, error: %v", result, value, autostart, err)
+		}
+	}
+	if _, err := EvalAutostart("host:[web"); err == nil {
+		t.Error("fail to report the invalid host pattern")
+	}
+
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/true\nautostart=host:not-"+hostname+"\n")
+	proc := NewProcess("supervisord", entry)
+	if proc.IsAutoStart() || !proc.IsAutostartSkipped() {
+		t.Error("expect the program is not autostarted on the host not matching autostart")
+	}
+}
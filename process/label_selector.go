@@ -0,0 +1,98 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+)
+
+// labelRequirement a requirement on one label of the program
+type labelRequirement struct {
+	key      string
+	value    string
+	operator string // "=", "!=", "exists" or "!exists"
+}
+
+// LabelSelector select the programs by their labels. All the requirements must be met
+type LabelSelector struct {
+	requirements []labelRequirement
+}
+
+// ParseLabelSelector parse the comma separated label requirements, a requirement can be:
+//
+//	key=value   the label key has the value
+//	key!=value  the label key does not have the value
+//	key         the label key exists
+//	!key        the label key does not exist
+func ParseLabelSelector(selector string) (*LabelSelector, error) {
+	result := &LabelSelector{requirements: make([]labelRequirement, 0)}
+	for _, item := range strings.Split(selector, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var r labelRequirement
+		if pos := strings.Index(item, "!="); pos != -1 {
+			r = labelRequirement{key: item[0:pos], value: item[pos+2:], operator: "!="}
+		} else if pos := strings.Index(item, "="); pos != -1 {
+			r = labelRequirement{key: item[0:pos], value: item[pos+1:], operator: "="}
+		} else if strings.HasPrefix(item, "!") {
+			r = labelRequirement{key: item[1:], operator: "!exists"}
+		} else {
+			r = labelRequirement{key: item, operator: "exists"}
+		}
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if r.key == "" {
+			return nil, fmt.Errorf("invalid label requirement %s", item)
+		}
+		result.requirements = append(result.requirements, r)
+	}
+	if len(result.requirements) <= 0 {
+		return nil, fmt.Errorf("empty label selector")
+	}
+	return result, nil
+}
+
+// Matches check if the labels meet all the requirements of the selector
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s.requirements {
+		value, ok := labels[r.key]
+		switch r.operator {
+		case "=":
+			if !ok || value != r.value {
+				return false
+			}
+		case "!=":
+			if ok && value == r.value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GetLabels get the labels of the program
+func (p *Process) GetLabels() map[string]string {
+	return p.config.GetLabels()
+}
+
+// FindBySelector find the programs whose labels match the selector
+func (pm *Manager) FindBySelector(selector *LabelSelector) []*Process {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	result := make([]*Process, 0)
+	for _, proc := range pm.getAllProcess() {
+		if selector.Matches(proc.GetLabels()) {
+			result = append(result, proc)
+		}
+	}
+	return result
+}
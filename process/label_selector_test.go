@@ -0,0 +1,36 @@
+package process
+
+import (
+	"testing"
+)
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"tier": "critical", "team": "payments"}
+	tests := []struct {
+		selector string
+		matched  bool
+	}{
+		{"tier=critical,team=payments", true},
+		{"tier=critical, team=search", false},
+		{"team!=search", true},
+		{"team!=payments", false},
+		{"tier", true},
+		{"!tier", false},
+		{"!region", true},
+	}
+	for _, test := range tests {
+		selector, err := ParseLabelSelector(test.selector)
+		if err != nil {
+			t.Errorf("fail to parse selector %s: %v", test.selector, err)
+			continue
+		}
+		if selector.Matches(labels) != test.matched {
+			t.Errorf("expect selector %s matched=%v", test.selector, test.matched)
+		}
+	}
+	for _, invalid := range []string{"", " , ", "=critical"} {
+		if _, err := ParseLabelSelector(invalid); err == nil {
+			t.Errorf("expect error for invalid selector %q", invalid)
+		}
+	}
+}
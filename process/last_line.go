@@ -0,0 +1,62 @@
+package process
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// the max bytes of an incomplete line kept by lastLineRecorder
+const maxPartialLineBytes = 4096
+
+// lastLineRecorder an io.Writer records the last non-empty line written to it
+type lastLineRecorder struct {
+	lock     sync.Mutex
+	partial  []byte
+	lastLine string
+}
+
+func newLastLineRecorder() *lastLineRecorder {
+	return &lastLineRecorder{partial: make([]byte, 0)}
+}
+
+// Write record the last non-empty complete line in the data
+func (r *lastLineRecorder) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	data := p
+	for {
+		index := bytes.IndexByte(data, '\n')
+		if index == -1 {
+			break
+		}
+		r.appendPartial(data[0:index])
+		if line := strings.TrimSpace(string(r.partial)); line != "" {
+			r.lastLine = line
+		}
+		r.partial = r.partial[:0]
+		data = data[index+1:]
+	}
+	r.appendPartial(data)
+	return len(p), nil
+}
+
+// the incomplete line longer than maxPartialLineBytes is truncated
+func (r *lastLineRecorder) appendPartial(data []byte) {
+	n := maxPartialLineBytes - len(r.partial)
+	if n > len(data) {
+		n = len(data)
+	}
+	r.partial = append(r.partial, data[0:n]...)
+}
+
+// LastLine get the last non-empty line. The incomplete line is also returned
+// because the program may exit without writing the line end
+func (r *lastLineRecorder) LastLine() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if line := strings.TrimSpace(string(r.partial)); line != "" {
+		return line
+	}
+	return r.lastLine
+}
@@ -0,0 +1,18 @@
+package process
+
+import (
+	"testing"
+)
+
+func TestLastLineRecorder(t *testing.T) {
+	recorder := newLastLineRecorder()
+	recorder.Write([]byte("starting\nlisten on"))
+	recorder.Write([]byte(" port 80\n\n  \n"))
+	if recorder.LastLine() != "listen on port 80" {
+		t.Errorf("expect the last non-empty line but got %s", recorder.LastLine())
+	}
+	recorder.Write([]byte("bind: address already in use"))
+	if recorder.LastLine() != "bind: address already in use" {
+		t.Errorf("expect the incomplete line but got %s", recorder.LastLine())
+	}
+}
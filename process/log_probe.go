@@ -0,0 +1,228 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// logScanner match the program output lines against a pattern and count the matched lines
+type logScanner struct {
+	regex *regexp.Regexp
+	// onMatch is called one time when the matched lines reach threshold
+	threshold     int
+	onMatch       func(line string)
+	lock          sync.Mutex
+	matches       int
+	lastMatchTime time.Time
+}
+
+func newLogScanner(pattern string, threshold int, onMatch func(line string)) (*logScanner, error) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &logScanner{regex: regex, threshold: threshold, onMatch: onMatch}, nil
+}
+
+// scan count the line if it matches the pattern
+func (s *logScanner) scan(line string) {
+	if !s.regex.MatchString(line) {
+		return
+	}
+	s.lock.Lock()
+	s.matches++
+	s.lastMatchTime = time.Now()
+	reached := s.matches == s.threshold
+	s.lock.Unlock()
+	if reached && s.onMatch != nil {
+		s.onMatch(line)
+	}
+}
+
+// getMatches get how many lines match the pattern
+func (s *logScanner) getMatches() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.matches
+}
+
+// getLastMatchTime get when the last line matching the pattern is scanned
+func (s *logScanner) getLastMatchTime() time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lastMatchTime
+}
+
+// lineScanWriter an io.Writer splits the data written to it into lines and passes
+// every complete non-empty line to the scanners
+type lineScanWriter struct {
+	lock     sync.Mutex
+	partial  []byte
+	scanners []*logScanner
+}
+
+func newLineScanWriter(scanners ...*logScanner) *lineScanWriter {
+	return &lineScanWriter{partial: make([]byte, 0), scanners: scanners}
+}
+
+// Write scan the complete lines in the data, the incomplete line is kept until its line end is written
+func (w *lineScanWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	data := p
+	for {
+		index := bytes.IndexByte(data, '\n')
+		if index == -1 {
+			break
+		}
+		w.appendPartial(data[0:index])
+		if line := strings.TrimSpace(string(w.partial)); line != "" {
+			for _, s := range w.scanners {
+				s.scan(line)
+			}
+		}
+		w.partial = w.partial[:0]
+		data = data[index+1:]
+	}
+	w.appendPartial(data)
+	return len(p), nil
+}
+
+// the incomplete line longer than maxPartialLineBytes is truncated
+func (w *lineScanWriter) appendPartial(data []byte) {
+	n := maxPartialLineBytes - len(w.partial)
+	if n > len(data) {
+		n = len(data)
+	}
+	w.partial = append(w.partial, data[0:n]...)
+}
+
+// create the scanners of ready_regex, alive_regex and dead_regex for a new started program
+func (p *Process) createLogScanners() []*logScanner {
+	atomic.StoreInt32(&p.ready, 0)
+	p.readyScanner, p.aliveScanner, p.deadScanner = nil, nil, nil
+	scanners := make([]*logScanner, 0)
+	if pattern := p.config.GetString("ready_regex", ""); pattern != "" {
+		s, err := newLogScanner(pattern, 1, func(line string) {
+			zap.S().Infow("the program is ready", "program", p.GetName(), "line", line)
+			atomic.StoreInt32(&p.ready, 1)
+		})
+		if err != nil {
+			zap.S().Errorw("invalid ready_regex", "program", p.GetName(), "error", err)
+		} else {
+			p.readyScanner = s
+			scanners = append(scanners, s)
+		}
+	}
+	if pattern := p.config.GetString("alive_regex", ""); pattern != "" {
+		s, err := newLogScanner(pattern, 1, nil)
+		if err != nil {
+			zap.S().Errorw("invalid alive_regex", "program", p.GetName(), "error", err)
+		} else {
+			p.aliveScanner = s
+			scanners = append(scanners, s)
+		}
+	}
+	if pattern := p.config.GetString("dead_regex", ""); pattern != "" {
+		s, err := newLogScanner(pattern, p.config.GetInt("dead_threshold", 1), func(line string) {
+			go p.restartUnhealthy(fmt.Sprintf("dead_regex matches: %s", line))
+		})
+		if err != nil {
+			zap.S().Errorw("invalid dead_regex", "program", p.GetName(), "error", err)
+		} else {
+			p.deadScanner = s
+			scanners = append(scanners, s)
+		}
+	}
+	return scanners
+}
+
+// restart the program if the program in Running state is not alive
+func (p *Process) restartUnhealthy(reason string) {
+	if p.GetState() != Running {
+		return
+	}
+	zap.S().Warnw("the program is not alive, restart it", "program", p.GetName(), "reason", reason)
+	p.Stop(true)
+	p.waitStartLoopExit(10 * time.Second)
+	p.Start(false)
+}
+
+// start to watch if a line matching alive_regex is written at least every alive_timeout seconds,
+// the program is restarted if not. The watch is stopped when the program exits. The process lock
+// must be held, like stopLivenessWatch, so the watch is never started and stopped at the same time
+func (p *Process) startLivenessWatch() {
+	p.livenessDone = nil
+	if p.aliveScanner == nil {
+		return
+	}
+	timeout := time.Duration(p.config.GetInt("alive_timeout", 60)) * time.Second
+	done := make(chan struct{})
+	p.livenessDone = done
+	scanner := p.aliveScanner
+	watchTime := time.Now()
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			lastAliveTime := scanner.getLastMatchTime()
+			if lastAliveTime.Before(watchTime) {
+				lastAliveTime = watchTime
+			}
+			if time.Since(lastAliveTime) > timeout && p.GetState() == Running {
+				p.restartUnhealthy(fmt.Sprintf("no line matches alive_regex in %v", timeout))
+				return
+			}
+		}
+	}()
+}
+
+// stop the liveness watch started by startLivenessWatch, the process lock must be held
+func (p *Process) stopLivenessWatch() {
+	if p.livenessDone != nil {
+		close(p.livenessDone)
+		p.livenessDone = nil
+	}
+}
+
+// HasReadinessProbe check if the readiness of the program is decided by ready_regex
+func (p *Process) HasReadinessProbe() bool {
+	return p.config.GetString("ready_regex", "") != ""
+}
+
+// IsReady check if the program is ready. If ready_regex is set, the program is ready after it
+// writes a line matching ready_regex, otherwise it is ready in Running state
+func (p *Process) IsReady() bool {
+	if p.GetState() != Running {
+		return false
+	}
+	return !p.HasReadinessProbe() || atomic.LoadInt32(&p.ready) == 1
+}
+
+// GetLogProbeMatches get how many output lines of current started program match alive_regex and dead_regex
+func (p *Process) GetLogProbeMatches() (aliveMatches int, deadMatches int) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.aliveScanner != nil {
+		aliveMatches = p.aliveScanner.getMatches()
+	}
+	if p.deadScanner != nil {
+		deadMatches = p.deadScanner.getMatches()
+	}
+	return aliveMatches, deadMatches
+}
@@ -0,0 +1,44 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineScanWriter(t *testing.T) {
+	matchedLines := make([]string, 0)
+	scanner, err := newLogScanner("deadlock detected", 2, func(line string) {
+		matchedLines = append(matchedLines, line)
+	})
+	if err != nil {
+		t.Fatalf("fail to create the scanner: %v", err)
+	}
+	writer := newLineScanWriter(scanner)
+	writer.Write([]byte("starting\nERROR: dead"))
+	if scanner.getMatches() != 0 {
+		t.Error("the incomplete line should not be scanned")
+	}
+	writer.Write([]byte("lock detected\nok\nERROR: deadlock detected again\nERROR: deadlock detected\n"))
+	if scanner.getMatches() != 3 {
+		t.Errorf("expect 3 matched lines but got %d", scanner.getMatches())
+	}
+	if len(matchedLines) != 1 || matchedLines[0] != "ERROR: deadlock detected again" {
+		t.Errorf("expect the callback is called one time when the threshold is reached but got %v", matchedLines)
+	}
+	if scanner.getLastMatchTime().IsZero() {
+		t.Error("fail to record the last match time")
+	}
+}
+
+func TestLivenessWatchRestartProgram(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sh -c \"echo alive; sleep 30\"\nstartsecs=0\nalive_regex=alive\nalive_timeout=1\n"))
+	proc.Start(true)
+	pid := proc.GetPid()
+	for i := 0; i < 50 && proc.GetPid() == pid; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	proc.Stop(true)
+	if proc.GetPid() == pid {
+		t.Error("expect the program is restarted if no line matches alive_regex in alive_timeout")
+	}
+}
@@ -0,0 +1,62 @@
+package process
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// get the maximum time the program runs before it is restarted by max_lifetime_secs, 0 if the
+// program is never restarted for its lifetime
+func (p *Process) getMaxLifetime() time.Duration {
+	return time.Duration(p.config.GetInt("max_lifetime_secs", 0)) * time.Second
+}
+
+// start to watch the lifetime of the started program, the program is stopped gracefully and
+// started again after it runs for max_lifetime_secs. The restart is scheduled, so it is not
+// counted in the flap detection and the start retries. The watch is stopped when the program exits
+func (p *Process) startLifetimeWatch() {
+	p.lifetimeDone = nil
+	p.scheduledRestartTime = time.Time{}
+	lifetime := p.getMaxLifetime()
+	if lifetime <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	p.lifetimeDone = done
+	p.scheduledRestartTime = p.startTime.Add(lifetime)
+	go func() {
+		timer := time.NewTimer(lifetime)
+		defer timer.Stop()
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+		if p.GetState() != Running {
+			return
+		}
+		zap.S().Infow(fmt.Sprintf("the program runs longer than %v, restart it", lifetime), "program", p.GetName())
+		p.Stop(true)
+		p.waitStartLoopExit(10 * time.Second)
+		p.Start(false)
+	}()
+}
+
+// stop the lifetime watch started by startLifetimeWatch
+func (p *Process) stopLifetimeWatch() {
+	if p.lifetimeDone != nil {
+		close(p.lifetimeDone)
+		p.lifetimeDone = nil
+	}
+	p.scheduledRestartTime = time.Time{}
+}
+
+// GetScheduledRestartTime get when the running program is restarted by max_lifetime_secs, the
+// zero time if no restart is scheduled
+func (p *Process) GetScheduledRestartTime() time.Time {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.scheduledRestartTime
+}
@@ -0,0 +1,116 @@
+package process
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ochinchina/supervisord/events"
+	"go.uber.org/zap"
+)
+
+// the interval to sample the memory of the programs if the total memory limit is set
+const memorySampleInterval = 5 * time.Second
+
+const (
+	// MemoryActionWarn log and emit an event if the total memory limit is exceeded
+	MemoryActionWarn = "warn"
+	// MemoryActionShed stop the programs until the total memory is under the limit
+	MemoryActionShed = "shed"
+)
+
+// GetRSS get the resident set size in bytes of the running program
+func (p *Process) GetRSS() (int64, error) {
+	pid := p.GetPid()
+	if pid <= 0 {
+		return 0, fmt.Errorf("program %s is not running", p.GetName())
+	}
+	return getProcessRSS(pid)
+}
+
+// SetTotalMemoryLimit set the limit in bytes of the total memory of all the running programs and
+// the action taken if the limit is exceeded. The memory is sampled periodically after a limit is
+// set, the limit <= 0 disables the check
+func (pm *Manager) SetTotalMemoryLimit(limit int64, action string) {
+	pm.lock.Lock()
+	pm.totalMemoryLimit = limit
+	pm.totalMemoryAction = action
+	pm.lock.Unlock()
+	if limit > 0 {
+		pm.memorySamplerOnce.Do(func() {
+			go func() {
+				for {
+					time.Sleep(memorySampleInterval)
+					pm.checkTotalMemory()
+				}
+			}()
+		})
+	}
+}
+
+// the memory of a running program
+type processRSS struct {
+	proc *Process
+	rss  int64
+}
+
+// get the memory of all the running programs
+func (pm *Manager) getProcessRSS() []processRSS {
+	pm.lock.Lock()
+	procs := pm.getAllProcess()
+	pm.lock.Unlock()
+	result := make([]processRSS, 0)
+	for _, proc := range procs {
+		if rss, err := proc.GetRSS(); err == nil {
+			result = append(result, processRSS{proc: proc, rss: rss})
+		}
+	}
+	return result
+}
+
+// GetTotalRSS get the total resident set size in bytes of all the running programs
+func (pm *Manager) GetTotalRSS() int64 {
+	total := int64(0)
+	for _, r := range pm.getProcessRSS() {
+		total += r.rss
+	}
+	return total
+}
+
+// check the total memory of the programs against the limit. If the action is "shed", the programs
+// with the highest priority value (started last) are stopped first until the total memory is under
+// the limit
+func (pm *Manager) checkTotalMemory() {
+	pm.lock.Lock()
+	limit := pm.totalMemoryLimit
+	action := pm.totalMemoryAction
+	pm.lock.Unlock()
+	if limit <= 0 {
+		return
+	}
+	procRSS := pm.getProcessRSS()
+	total := int64(0)
+	for _, r := range procRSS {
+		total += r.rss
+	}
+	if total <= limit {
+		return
+	}
+	zap.S().Warnw("the total memory of the programs exceeds the limit", "totalRSS", total, "limit", limit, "action", action)
+	events.EmitEvent(events.CreateMemoryLimitExceededEvent(total, limit))
+	if action != MemoryActionShed {
+		return
+	}
+	sort.SliceStable(procRSS, func(i, j int) bool {
+		return procRSS[i].proc.GetPriority() > procRSS[j].proc.GetPriority()
+	})
+	for _, r := range procRSS {
+		if total <= limit {
+			break
+		}
+		zap.S().Warnw("stop the program to reduce the total memory", "program", r.proc.GetName(), "rss", r.rss, "totalRSS", total, "limit", limit)
+		events.EmitEvent(events.CreateMemoryLimitProcessShedEvent(r.proc.GetName(), r.proc.GetGroup(), r.rss, total, limit))
+		r.proc.Stop(true)
+		total -= r.rss
+	}
+}
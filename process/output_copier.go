@@ -0,0 +1,60 @@
+package process
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// outputCopier copy the program output from a pipe or pty to the log
+type outputCopier struct {
+	reader   *os.File      // the end read by supervisord
+	childEnd *os.File      // the end written by the program, closed after the program is started
+	writer   io.Writer     // the log to write the output to
+	done     chan struct{} // closed after all the output is copied
+}
+
+// create a pipe to copy the program output to writer. The pipe write end is returned for
+// the program. If the pipe can't be created, the writer itself is returned
+func (p *Process) pipeOutput(writer io.Writer) io.Writer {
+	reader, childEnd, err := os.Pipe()
+	if err != nil {
+		return writer
+	}
+	p.outputCopiers = append(p.outputCopiers, &outputCopier{reader: reader, childEnd: childEnd, writer: writer})
+	return childEnd
+}
+
+// start to copy the output after the program is started
+func (p *Process) startOutputCopiers(startErr error) {
+	for _, copier := range p.outputCopiers {
+		copier.childEnd.Close()
+		if startErr != nil {
+			copier.reader.Close()
+			continue
+		}
+		copier.done = make(chan struct{})
+		go func(copier *outputCopier) {
+			// the read fails after all the processes close the write end
+			io.Copy(copier.writer, copier.reader)
+			close(copier.done)
+		}(copier)
+	}
+	if startErr != nil {
+		p.outputCopiers = nil
+	}
+}
+
+// drain the output after the program exits. The child processes of the program may still
+// hold the write end, so the copy is aborted after the exit_drain_timeout seconds
+func (p *Process) waitOutputCopiers() {
+	deadline := time.Now().Add(time.Duration(p.config.GetInt("exit_drain_timeout", 1)) * time.Second)
+	for _, copier := range p.outputCopiers {
+		select {
+		case <-copier.done:
+		case <-time.After(time.Until(deadline)):
+		}
+		copier.reader.Close()
+	}
+	p.outputCopiers = nil
+}
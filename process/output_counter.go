@@ -0,0 +1,66 @@
+package process
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+)
+
+// OutputStats the bytes and lines written by the program to stdout or stderr
+type OutputStats struct {
+	Bytes      int64 // written since the process is started
+	Lines      int64 // written since the process is started
+	TotalBytes int64 // written by all the runs of the process
+	TotalLines int64 // written by all the runs of the process
+}
+
+// outputCounter count the bytes and lines written by the program
+type outputCounter struct {
+	bytes      int64
+	lines      int64
+	totalBytes int64
+	totalLines int64
+}
+
+// reset the counters of current run, the cumulative counters are kept
+func (c *outputCounter) reset() {
+	atomic.StoreInt64(&c.bytes, 0)
+	atomic.StoreInt64(&c.lines, 0)
+}
+
+func (c *outputCounter) add(data []byte) {
+	n := int64(len(data))
+	lines := int64(bytes.Count(data, []byte{'\n'}))
+	atomic.AddInt64(&c.bytes, n)
+	atomic.AddInt64(&c.lines, lines)
+	atomic.AddInt64(&c.totalBytes, n)
+	atomic.AddInt64(&c.totalLines, lines)
+}
+
+func (c *outputCounter) stats() OutputStats {
+	if c == nil {
+		return OutputStats{}
+	}
+	return OutputStats{Bytes: atomic.LoadInt64(&c.bytes),
+		Lines:      atomic.LoadInt64(&c.lines),
+		TotalBytes: atomic.LoadInt64(&c.totalBytes),
+		TotalLines: atomic.LoadInt64(&c.totalLines)}
+}
+
+// wrap the writer to count the data written to it
+func (c *outputCounter) wrap(writer io.Writer) io.Writer {
+	return &countingWriter{writer: writer, counter: c}
+}
+
+type countingWriter struct {
+	writer  io.Writer
+	counter *outputCounter
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if n > 0 {
+		w.counter.add(p[0:n])
+	}
+	return n, err
+}
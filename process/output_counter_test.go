@@ -0,0 +1,22 @@
+package process
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestOutputCounter(t *testing.T) {
+	counter := &outputCounter{}
+	writer := counter.wrap(ioutil.Discard)
+	writer.Write([]byte("line1\nline2\npartial"))
+	counter.reset()
+	writer.Write([]byte(" line3\n"))
+
+	stats := counter.stats()
+	if stats.Bytes != 7 || stats.Lines != 1 {
+		t.Errorf("expect 7 bytes and 1 line since reset but got %d bytes and %d lines", stats.Bytes, stats.Lines)
+	}
+	if stats.TotalBytes != 26 || stats.TotalLines != 3 {
+		t.Errorf("expect 26 bytes and 3 lines in total but got %d bytes and %d lines", stats.TotalBytes, stats.TotalLines)
+	}
+}
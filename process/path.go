@@ -1,8 +1,12 @@
 package process
 
 import (
+	"fmt"
+	"os"
 	"os/user"
 	"path/filepath"
+
+	"github.com/ochinchina/supervisord/config"
 )
 
 func pathSplit(path string) []string {
@@ -46,3 +50,21 @@ func PathExpand(path string) (string, error) {
 	}
 	return path, nil
 }
+
+// get the directory the program runs in: the environment variables in the directory setting are
+// expanded and the ~ is replaced with the user home directory. An error is returned if the
+// directory does not exist, and an empty string is returned if the directory is not set
+func getDirectory(entry *config.Entry) (string, error) {
+	dir := entry.GetStringExpression("directory", "")
+	if dir == "" {
+		return "", nil
+	}
+	expandDir, err := PathExpand(dir)
+	if err != nil {
+		return "", fmt.Errorf("fail to expand the directory %s: %v", dir, err)
+	}
+	if fileInfo, err := os.Stat(expandDir); err != nil || !fileInfo.IsDir() {
+		return "", fmt.Errorf("the directory %s does not exist", expandDir)
+	}
+	return expandDir, nil
+}
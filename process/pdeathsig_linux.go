@@ -10,3 +10,8 @@ func setDeathsig(sysProcAttr *syscall.SysProcAttr) {
 	sysProcAttr.Setpgid = true
 	sysProcAttr.Pdeathsig = syscall.SIGKILL
 }
+
+// check if a new process group is created for the process started with sysProcAttr
+func createsProcessGroup(sysProcAttr *syscall.SysProcAttr) bool {
+	return sysProcAttr != nil && (sysProcAttr.Setpgid || sysProcAttr.Setsid)
+}
@@ -8,3 +8,9 @@ import (
 
 func setDeathsig(_ *syscall.SysProcAttr) {
 }
+
+// check if a new process group is created for the process started with sysProcAttr.
+// The whole process tree is always killed on windows, so the process group is not needed
+func createsProcessGroup(_ *syscall.SysProcAttr) bool {
+	return true
+}
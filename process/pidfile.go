@@ -0,0 +1,100 @@
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// get the process tracked by supervisord: the daemon read from the pidfile if
+// the program is tracked by pidfile, otherwise the started program
+func (p *Process) getProcess() *os.Process {
+	if p.daemon != nil {
+		return p.daemon
+	}
+	return p.cmd.Process
+}
+
+// track the daemon whose PID is written to the pidfile instead of the started program, which
+// is expected to exit after it forks the daemon. Wait at most pidfile_timeout seconds for the
+// started program to exit and the pidfile to be written by an alive daemon.
+//
+// The lock must be held and it is released while waiting
+func (p *Process) trackPidfile(pidfile string) error {
+	cmd := p.cmd
+	timeout := time.Duration(p.config.GetInt("pidfile_timeout", 10)) * time.Second
+	deadline := time.Now().Add(timeout)
+	startTime := p.startTime
+	p.lock.Unlock()
+	defer p.lock.Lock()
+
+	launcherExited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(launcherExited)
+	}()
+	select {
+	case <-launcherExited:
+	case <-time.After(time.Until(deadline)):
+		// the program may write its own PID to the pidfile without forking
+	}
+	for {
+		pid, err := readPidfile(pidfile, startTime)
+		if err == nil {
+			daemon, findErr := os.FindProcess(pid)
+			if findErr == nil && isProcessAlive(daemon) {
+				zap.S().Infow("track the daemon from pidfile", "program", p.GetName(), "pidfile", pidfile, "pid", pid)
+				p.lock.Lock()
+				p.daemon = daemon
+				p.lock.Unlock()
+				return nil
+			}
+			err = fmt.Errorf("the process %d in pidfile %s is not alive", pid, pidfile)
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			<-launcherExited
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// read the PID from the pidfile. The pidfile not updated after startTime is
+// ignored because it may be left by the previous run
+func readPidfile(pidfile string, startTime time.Time) (int, error) {
+	fileInfo, err := os.Stat(pidfile)
+	if err != nil {
+		return 0, err
+	}
+	if fileInfo.ModTime().Before(startTime.Truncate(time.Second)) {
+		return 0, fmt.Errorf("the pidfile %s is not written after the program is started", pidfile)
+	}
+	b, err := ioutil.ReadFile(pidfile)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid PID in pidfile %s", pidfile)
+	}
+	return pid, nil
+}
+
+// wait for the daemon tracked by pidfile to exit
+func (p *Process) waitDaemonExit() {
+	for {
+		p.lock.RLock()
+		running := p.isRunning()
+		p.lock.RUnlock()
+		if !running {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
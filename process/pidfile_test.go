@@ -0,0 +1,126 @@
+// +build !windows
+
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func createPidfileDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "supervisord-pidfile-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	return dir
+}
+
+// call trackPidfile for the started launcher like run() does
+func trackTestPidfile(proc *Process, launcher *exec.Cmd, pidfile string) error {
+	proc.lock.Lock()
+	defer proc.lock.Unlock()
+	proc.cmd = launcher
+	proc.startTime = time.Now()
+	if err := launcher.Start(); err != nil {
+		return err
+	}
+	return proc.trackPidfile(pidfile)
+}
+
+func TestTrackPidfileOfForkingLauncher(t *testing.T) {
+	dir := createPidfileDir(t)
+	defer os.RemoveAll(dir)
+	pidfile := filepath.Join(dir, "test.pid")
+	entry := loadTestProgram(t, fmt.Sprintf("[program:test]\ncommand=/bin/sh -c \"sleep 30 >/dev/null 2>&1 & echo $! > %s\"\npidfile=%s\nstartsecs=1\nautorestart=false\n", pidfile, pidfile))
+	proc := NewProcess("supervisord", entry)
+	proc.Start(true)
+	if proc.GetState() != Running {
+		t.Fatalf("fail to start the program, it is %v", proc.GetState())
+	}
+	b, _ := ioutil.ReadFile(pidfile)
+	daemonPid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	defer syscall.Kill(daemonPid, syscall.SIGKILL)
+	if proc.GetPid() != daemonPid {
+		t.Errorf("expect the daemon %d is tracked but got %d", daemonPid, proc.GetPid())
+	}
+	if proc.cmd.ProcessState == nil {
+		t.Error("expect the launcher has exited")
+	}
+
+	// the exit of the daemon is detected although it is not a child of supervisord
+	syscall.Kill(daemonPid, syscall.SIGKILL)
+	for i := 0; i < 50 && proc.GetState() == Running; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if proc.GetState() != Exited {
+		t.Errorf("expect the program exits with the daemon but it is %v", proc.GetState())
+	}
+}
+
+func TestTrackPidfileIgnoreStalePidfile(t *testing.T) {
+	dir := createPidfileDir(t)
+	defer os.RemoveAll(dir)
+	pidfile := filepath.Join(dir, "test.pid")
+	// the pidfile left by the previous run refers to an alive process
+	ioutil.WriteFile(pidfile, []byte(strconv.Itoa(os.Getpid())), 0644)
+	stale := time.Now().Add(-time.Hour)
+	os.Chtimes(pidfile, stale, stale)
+
+	if _, err := readPidfile(pidfile, time.Now()); err == nil {
+		t.Error("expect an error to read the pidfile not written after the start time")
+	}
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\npidfile_timeout=1\n"))
+	if err := trackTestPidfile(proc, exec.Command("/bin/true"), pidfile); err == nil {
+		t.Error("expect an error if only a stale pidfile exists")
+	}
+	if proc.daemon != nil {
+		t.Errorf("expect the process %d in the stale pidfile is not tracked", proc.daemon.Pid)
+	}
+}
+
+func TestTrackPidfileWithDeadPid(t *testing.T) {
+	dir := createPidfileDir(t)
+	defer os.RemoveAll(dir)
+	pidfile := filepath.Join(dir, "test.pid")
+	dead := exec.Command("/bin/true")
+	if err := dead.Run(); err != nil {
+		t.Fatalf("fail to run the command: %v", err)
+	}
+
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\npidfile_timeout=1\n"))
+	launcher := exec.Command("/bin/sh", "-c", fmt.Sprintf("echo %d > %s", dead.Process.Pid, pidfile))
+	err := trackTestPidfile(proc, launcher, pidfile)
+	if err == nil || !strings.Contains(err.Error(), "is not alive") {
+		t.Errorf("expect an error for the dead process in the pidfile but got %v", err)
+	}
+	if proc.daemon != nil {
+		t.Error("expect the dead process is not tracked")
+	}
+}
+
+func TestTrackPidfileTimeout(t *testing.T) {
+	dir := createPidfileDir(t)
+	defer os.RemoveAll(dir)
+	pidfile := filepath.Join(dir, "test.pid")
+
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\npidfile_timeout=1\n"))
+	launcher := exec.Command("/bin/sleep", "30")
+	begin := time.Now()
+	if err := trackTestPidfile(proc, launcher, pidfile); err == nil {
+		t.Error("expect an error if the pidfile is not written in pidfile_timeout")
+	}
+	if elapsed := time.Since(begin); elapsed > 3*time.Second {
+		t.Errorf("expect to give up after pidfile_timeout but waited %v", elapsed)
+	}
+	if launcher.ProcessState == nil {
+		t.Error("expect the launcher is killed after pidfile_timeout")
+	}
+}
@@ -49,6 +49,13 @@ const (
 	// Fatal the Fatal state
 	Fatal = 200
 
+	// Quarantined the program went to Fatal state too many times, it is not started
+	// again until it is started explicitly
+	Quarantined = 300
+
+	// Cooldown the program restarts too often, it is not restarted until flap_cooldown_secs elapses
+	Cooldown = 400
+
 	// Unknown the unknown state
 	Unknown = 1000
 )
@@ -77,6 +84,10 @@ func (p State) String() string {
 		return "Exited"
 	case Fatal:
 		return "Fatal"
+	case Quarantined:
+		return "Quarantined"
+	case Cooldown:
+		return "Cooldown"
 	default:
 		return "Unknown"
 	}
@@ -89,31 +100,108 @@ type Process struct {
 	cmd          *exec.Cmd
 	startTime    time.Time
 	stopTime     time.Time
-	state        State
+	// the monotonic clock reading when the process is started, used to compute the uptime
+	startMonotonic time.Duration
+	state          State
 	//true if process is starting
 	inStart bool
 	//true if the process is stopped by user
 	stopByUser bool
 	retryTimes *int32
-	lock       sync.RWMutex
-	stdin      io.WriteCloser
-	StdoutLog  logger.Logger
-	StderrLog  logger.Logger
+	// the monotonic clock reading of next start retry if the process is in Backoff or Cooldown state
+	nextRetryMonotonic time.Duration
+	// the monotonic clock readings when the program is restarted, for the flap detection
+	restartMonotonics []time.Duration
+	// the recent state transitions, at most stateHistorySize
+	stateHistory     []StateTransition
+	stateHistorySize int
+	// how many times the process enters Running state
+	runningTimes int32
+	// how many times the program is spawned since supervisord started, never reset
+	spawnTimes int32
+	// the exit status of the last run, -1 if it is unknown
+	lastExitStatus int
+	// how many times the process enters Fatal state since it was running last time
+	fatalTimes int
+	// closed to abort the pause between the start retries
+	abortBackoff chan struct{}
+	// the pause before the next start retry if backoff_initial is set, it is doubled after each
+	// failed attempt and reset when the process enters Running state
+	backoffDelay time.Duration
+	// called in a new goroutine when the process enters Running state again
+	restartedCallback func(p *Process)
+	// called when the process enters Fatal state
+	fatalCallback func(p *Process, exitStatus int, retries int)
+	// true if a new process group is created when the process is started, so the signal
+	// can be sent to the process and its children
+	processGroupCreated bool
+	// record the last stderr line if capture_fatal_reason is true
+	stderrLastLine *lastLineRecorder
+	// the last stderr line when the process exited unexpectedly
+	lastError string
+	// count the output of the program
+	stdoutCounter *outputCounter
+	stderrCounter *outputCounter
+	// true if the program is not autostarted because autostart or autostart_if does not match
+	autostartSkipped bool
+	// true if the autostarted program waits for the programs it depends on to start it
+	waitingDependencies bool
+	// why the autostarted program is not started if its dependency is not ready in time
+	dependencyError string
+	// true if the program waits for startdelay before it is started
+	inStartDelay bool
+	// why the program failed to start if it is in Fatal state
+	spawnError string
+	// the signal sent to the program because it did not exit in stopwaitsecs after it was
+	// stopped, empty if it exited by the stop signals
+	killSignal string
+	// scan the program output for ready_regex, alive_regex and dead_regex
+	readyScanner *logScanner
+	aliveScanner *logScanner
+	deadScanner  *logScanner
+	// 1 if the program writes a line matching ready_regex after it is started
+	ready int32
+	// the result of the last health check by healthcheck_url
+	healthStatus string
+	// the daemon read from the pidfile if the program is tracked by pidfile
+	daemon *os.Process
+	// the cgroup the program is placed in for memory_limit and cpu_quota, empty if not created
+	cgroupDir string
+	// closed to stop the liveness watch when the program exits, guarded by lock
+	livenessDone chan struct{}
+	// closed to stop the lifetime watch when the program exits
+	lifetimeDone chan struct{}
+	// when the program is restarted by max_lifetime_secs, zero if not scheduled
+	scheduledRestartTime time.Time
+	// copy the program output from the pipes or pty to the logs
+	outputCopiers []*outputCopier
+	// 1 if the stdout/stderr log events (or process communication capture) are enabled
+	stdoutEventsEnabled int32
+	stderrEventsEnabled int32
+	lock                sync.RWMutex
+	stdin               io.WriteCloser
+	StdoutLog           logger.Logger
+	StderrLog           logger.Logger
 }
 
 // NewProcess create a new Process
 func NewProcess(supervisorID string, config *config.Entry) *Process {
 	proc := &Process{supervisorID: supervisorID,
-		config:     config,
-		cmd:        nil,
-		startTime:  time.Unix(0, 0),
-		stopTime:   time.Unix(0, 0),
-		state:      Stopped,
-		inStart:    false,
-		stopByUser: false,
-		retryTimes: new(int32)}
+		config:           config,
+		cmd:              nil,
+		startTime:        time.Unix(0, 0),
+		stopTime:         time.Unix(0, 0),
+		state:            Stopped,
+		inStart:          false,
+		stopByUser:       false,
+		retryTimes:       new(int32),
+		stateHistorySize: DefaultStateHistorySize,
+		stdoutCounter:    &outputCounter{},
+		stderrCounter:    &outputCounter{}}
 	proc.config = config
 	proc.cmd = nil
+	proc.lastExitStatus = -1
+	proc.ResetLogEventsEnabled()
 	proc.addToCron()
 	return proc
 }
@@ -126,7 +214,9 @@ func (p *Process) addToCron() {
 		zap.S().Infow("try to create cron program with cron expression", "expression", s, "program", p.GetName())
 		scheduler.AddFunc(s, func() {
 			zap.S().Infow("start cron program", "program", p.GetName())
-			if !p.isRunning() {
+			if p.IsQuarantined() {
+				zap.S().Infow("Don't start the quarantined cron program", "program", p.GetName())
+			} else if !p.isRunning() {
 				p.Start(false)
 			}
 		})
@@ -138,55 +228,127 @@ func (p *Process) addToCron() {
 // Args:
 //  wait - true, wait the program started or failed
 func (p *Process) Start(wait bool) {
+	started := p.start()
+	if wait {
+		<-started
+	}
+}
+
+// StartWithTimeout start the process and wait at most timeout for the program to be started or
+// failed. If it is still starting after timeout, an error with its state is returned and the
+// program is not stopped
+func (p *Process) StartWithTimeout(timeout time.Duration) error {
+	select {
+	case <-p.start():
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("the program %s is not started in %v, it is in %s state: %s", p.GetName(), timeout, p.GetState(), p.GetDescription())
+	}
+}
+
+// start the process in background and return a channel which receives a value after the first
+// attempt to start the program is finished, it is closed if the program is already started
+func (p *Process) start() <-chan struct{} {
 	zap.S().Infow("try to start program", "program", p.GetName())
+	started := make(chan struct{}, 1)
 	p.lock.Lock()
+	// the operator starts the program explicitly, so retry it without the accumulated backoff
+	p.backoffDelay = 0
 	if p.inStart {
-		zap.S().Infow("Don't start program again, program is already started", "program", p.GetName())
+		if p.state == Backoff || p.state == Cooldown {
+			zap.S().Infow("retry the program in Backoff or Cooldown state immediately", "program", p.GetName())
+			p.retryNow()
+		} else if p.inStartDelay {
+			zap.S().Infow("Don't start program again, program is waiting for startdelay", "program", p.GetName())
+		} else {
+			zap.S().Infow("Don't start program again, program is already started", "program", p.GetName())
+		}
 		p.lock.Unlock()
-		return
+		close(started)
+		return started
 	}
 
 	p.inStart = true
 	p.stopByUser = false
-	p.lock.Unlock()
-
-	var runCond *sync.Cond
-	if wait {
-		runCond = sync.NewCond(&sync.Mutex{})
-		runCond.L.Lock()
+	p.autostartSkipped = false
+	p.dependencyError = ""
+	p.killSignal = ""
+	p.spawnError = ""
+	p.abortBackoff = make(chan struct{})
+	if p.state == Quarantined {
+		zap.S().Infow("the program is released from quarantine", "program", p.GetName())
+		p.fatalTimes = 0
 	}
+	p.lock.Unlock()
 
 	go func() {
+		defer func() {
+			p.lock.Lock()
+			p.inStart = false
+			p.lock.Unlock()
+		}()
+		if !p.waitStartDelay() {
+			zap.S().Infow("Stopped by user in the start delay, don't start it", "program", p.GetName())
+			started <- struct{}{}
+			return
+		}
 
 		for {
 			p.run(func() {
-				if wait {
-					runCond.L.Lock()
-					runCond.Signal()
-					runCond.L.Unlock()
+				select {
+				case started <- struct{}{}:
+				default:
 				}
 			})
 			//avoid print too many logs if fail to start program too quickly
-			if time.Now().Unix()-p.startTime.Unix() < 2 {
-				time.Sleep(5 * time.Second)
+			if p.getUptime() < 2*time.Second {
+				p.lock.RLock()
+				abortBackoff, stopByUser := p.abortBackoff, p.stopByUser
+				p.lock.RUnlock()
+				if !stopByUser {
+					sleepUntilAborted(5*time.Second, abortBackoff)
+				}
 			}
 			if p.stopByUser {
 				zap.S().Infow("Stopped by user, don't start it again", "program", p.GetName())
 				break
 			}
+			if p.IsQuarantined() {
+				zap.S().Infow("Don't start the quarantined program again", "program", p.GetName())
+				break
+			}
 			if !p.isAutoRestart() {
-				zap.S().Infow("Don't start the stopped program because its autorestart flag is false", "program", p.GetName())
+				zap.S().Infow("Don't start the stopped program because its autorestart flag is false or it exited with an expected exit code", "program", p.GetName(), "exitcode", p.GetExitstatus())
+				break
+			}
+			if !p.waitFlapCooldown() {
+				zap.S().Infow("Stopped by user in cooldown, don't start it again", "program", p.GetName())
 				break
 			}
 		}
-		p.lock.Lock()
-		p.inStart = false
-		p.lock.Unlock()
 	}()
 
-	if wait {
-		runCond.Wait()
-		runCond.L.Unlock()
+	return started
+}
+
+// Restart stop the program and wait for it to be stopped, then start it again
+func (p *Process) Restart(wait bool) {
+	p.Stop(true)
+	p.waitStartLoopExit(10 * time.Second)
+	p.Start(wait)
+}
+
+// wait at most timeout for the start loop created by previous Start() call to exit
+func (p *Process) waitStartLoopExit(timeout time.Duration) {
+	endTime := time.Now().Add(timeout)
+	for time.Now().Before(endTime) {
+		p.lock.RLock()
+		inStart := p.inStart
+		p.lock.RUnlock()
+		if !inStart {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
 }
 
@@ -206,25 +368,74 @@ func (p *Process) GetGroup() string {
 	return p.config.Group
 }
 
-// GetDescription get the process status description
+// GetDescription get the process status description like python supervisord: the pid and uptime
+// of the running program, the stop time and the exit reason of the stopped program, and why the
+// program failed to start in Fatal state
 func (p *Process) GetDescription() string {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
-	if p.state == Running {
-		seconds := int(time.Now().Sub(p.startTime).Seconds())
+	switch p.state {
+	case Running:
+		seconds := int(p.getUptime().Seconds())
 		minutes := seconds / 60
 		hours := minutes / 60
 		days := hours / 24
 		if days > 0 {
-			return fmt.Sprintf("pid %d, uptime %d days, %d:%02d:%02d", p.cmd.Process.Pid, days, hours%24, minutes%60, seconds%60)
+			return fmt.Sprintf("pid %d, uptime %d days, %d:%02d:%02d", p.getProcess().Pid, days, hours%24, minutes%60, seconds%60)
+		}
+		return fmt.Sprintf("pid %d, uptime %d:%02d:%02d", p.getProcess().Pid, hours%24, minutes%60, seconds%60)
+	case Quarantined:
+		return fmt.Sprintf("quarantined after %d fatal failures, start it explicitly to release", p.fatalTimes)
+	case Cooldown:
+		remaining := (p.nextRetryMonotonic - processClock.monotonic()).Round(time.Second)
+		return fmt.Sprintf("restarted too often, cooling down for %v", remaining)
+	case Exited:
+		if p.killSignal != "" {
+			return fmt.Sprintf("%s, killed by SIG%s, not stopped in stopwaitsecs", p.formatStopTime(), p.killSignal)
+		}
+		if reason := p.getExitReason(); reason != "" {
+			return fmt.Sprintf("%s, %s", p.formatStopTime(), reason)
+		}
+		return p.formatStopTime()
+	case Fatal:
+		if p.spawnError != "" {
+			return p.spawnError
 		}
-		return fmt.Sprintf("pid %d, uptime %d:%02d:%02d", p.cmd.Process.Pid, hours%24, minutes%60, seconds%60)
-	} else if p.state != Stopped {
-		return p.stopTime.String()
+		return "Exited too quickly (process log may have details)"
+	case Backoff:
+		return "Exited too quickly (process log may have details)"
+	case Stopped:
+		if p.inStartDelay {
+			return "waiting for startdelay to start"
+		} else if p.autostartSkipped {
+			return "autostart skipped, autostart or autostart_if does not match"
+		} else if p.dependencyError != "" {
+			return "not started, " + p.dependencyError
+		}
+		return p.formatStopTime()
 	}
 	return ""
 }
 
+// format the stop time like "Oct 16 03:04 PM", it is "Not started" if the program never stopped
+func (p *Process) formatStopTime() string {
+	if p.stopTime.Unix() <= 0 {
+		return "Not started"
+	}
+	return p.stopTime.Format("Jan 02 03:04 PM")
+}
+
+// get why the program exited, like "exit status 1" or "terminated by signal 9 (killed)"
+func (p *Process) getExitReason() string {
+	if p.daemon != nil || p.cmd == nil || p.cmd.ProcessState == nil {
+		return ""
+	}
+	if status, ok := p.cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return fmt.Sprintf("terminated by signal %d (%v)", int(status.Signal()), status.Signal())
+	}
+	return fmt.Sprintf("exit status %d", p.cmd.ProcessState.ExitCode())
+}
+
 // GetExitstatus get the exit status of the process if the program exit
 func (p *Process) GetExitstatus() int {
 	p.lock.RLock()
@@ -242,15 +453,64 @@ func (p *Process) GetExitstatus() int {
 	return 0
 }
 
+// GetProcessNum get the process_num of the process, it is 1 if numprocs is not set
+func (p *Process) GetProcessNum() int {
+	return p.config.GetInt("process_num", 1)
+}
+
+// GetStdoutStats get the bytes and lines written by the program to stdout
+func (p *Process) GetStdoutStats() OutputStats {
+	return p.stdoutCounter.stats()
+}
+
+// GetStderrStats get the bytes and lines written by the program to stderr
+func (p *Process) GetStderrStats() OutputStats {
+	return p.stderrCounter.stats()
+}
+
+// GetLastError get the last stderr line of the program if it exited unexpectedly and
+// capture_fatal_reason is enabled
+func (p *Process) GetLastError() string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.lastError
+}
+
+// GetSpawnError get why the program failed to start, it is empty unless the program is in Fatal
+// state because it can't be started
+func (p *Process) GetSpawnError() string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.state != Fatal {
+		return ""
+	}
+	return p.spawnError
+}
+
+// GetArgv get the command line the program is started with last time, or the command line parsed
+// from the command setting with the environment expanded if the program is not started yet
+func (p *Process) GetArgv() []string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.cmd != nil && p.cmd.Process != nil {
+		return append([]string{}, p.cmd.Args...)
+	}
+	args, err := parseCommand(p.config.GetStringExpression("command", ""))
+	if err != nil {
+		return nil
+	}
+	return args
+}
+
 // GetPid get the pid of running process or 0 it is not in running status
 func (p *Process) GetPid() int {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
-	if p.state == Stopped || p.state == Fatal || p.state == Unknown || p.state == Exited || p.state == Backoff {
+	if p.state == Stopped || p.state == Fatal || p.state == Quarantined || p.state == Unknown || p.state == Exited || p.state == Backoff || p.state == Cooldown {
 		return 0
 	}
-	return p.cmd.Process.Pid
+	return p.getProcess().Pid
 }
 
 // GetState Get the process state
@@ -277,6 +537,74 @@ func (p *Process) GetStopTime() time.Time {
 	}
 }
 
+// GetNextRetryTime get the time of next start retry if the program is in Backoff or Cooldown state.
+// The zero time is returned if the program is not in Backoff or Cooldown state
+func (p *Process) GetNextRetryTime() time.Time {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.state != Backoff && p.state != Cooldown {
+		return time.Time{}
+	}
+	remaining := p.nextRetryMonotonic - processClock.monotonic()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return processClock.now().Add(remaining)
+}
+
+// GetUptime get how long the process has been running, 0 if it is not in Running state
+func (p *Process) GetUptime() time.Duration {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.state != Running {
+		return 0
+	}
+	return p.getUptime()
+}
+
+// GetRestarts get how many times the program is restarted since supervisord started
+func (p *Process) GetRestarts() int {
+	if spawnTimes := atomic.LoadInt32(&p.spawnTimes); spawnTimes > 1 {
+		return int(spawnTimes - 1)
+	}
+	return 0
+}
+
+// GetLastExitStatus get the exit status of the last run of the program, -1 if the program
+// is not exited yet or its exit status is unknown
+func (p *Process) GetLastExitStatus() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.lastExitStatus
+}
+
+// get how long the process has been started by the monotonic clock
+func (p *Process) getUptime() time.Duration {
+	return processClock.monotonic() - p.startMonotonic
+}
+
+// GetRetriesRemaining get how many start retries remain before the program enters Fatal state,
+// -1 if startretries is infinite
+func (p *Process) GetRetriesRemaining() int {
+	if p.getStartRetries() == unlimitedStartRetries {
+		return -1
+	}
+	remaining := int(p.getStartRetries() - atomic.LoadInt32(p.retryTimes))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// IsGroupKillRestartRequired check if the program needs to be restarted to stop/kill it as a group.
+// It is true if the stopasgroup or killasgroup is enabled but the running program was not started
+// in a new process group
+func (p *Process) IsGroupKillRestartRequired() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return (p.isStopAsGroup() || p.isKillAsGroup()) && p.isRunning() && !p.processGroupCreated
+}
+
 // GetStdoutLogfile get the program stdout log file
 func (p *Process) GetStdoutLogfile() string {
 	fileName := p.config.GetStringExpression("stdout_logfile", "/dev/null")
@@ -297,6 +625,26 @@ func (p *Process) GetStderrLogfile() string {
 	return expandFile
 }
 
+// GetStdoutLogInfo get the metadata of the program stdout log
+func (p *Process) GetStdoutLogInfo() logger.LogInfo {
+	return logger.GetLogInfo(p.GetStdoutLogfile(), p.config.GetInt("stdout_logfile_backups", 10))
+}
+
+// GetStderrLogInfo get the metadata of the program stderr log. If the stderr is
+// redirected to stdout, the metadata of stdout log is returned
+func (p *Process) GetStderrLogInfo() logger.LogInfo {
+	if p.IsStderrRedirected() {
+		return p.GetStdoutLogInfo()
+	}
+	return logger.GetLogInfo(p.GetStderrLogfile(), p.config.GetInt("stderr_logfile_backups", 10))
+}
+
+// IsStderrRedirected check if the stderr of the program is written to its stdout log by
+// redirect_stderr
+func (p *Process) IsStderrRedirected() bool {
+	return p.config.GetBool("redirect_stderr", false)
+}
+
 func (p *Process) getStartSeconds() int64 {
 	return int64(p.config.GetInt("startsecs", 1))
 }
@@ -305,12 +653,63 @@ func (p *Process) getRestartPause() int {
 	return p.config.GetInt("restartpause", 0)
 }
 
+// the startretries to retry starting the program forever
+const unlimitedStartRetries = -1
+
+// the minimum pause before a start retry if startretries is infinite, so the program which keeps
+// failing to start is not spawned in a busy loop
+const minUnlimitedRetryPause = time.Second
+
+// get the startretries, unlimitedStartRetries if it is infinite or negative
 func (p *Process) getStartRetries() int32 {
-	return int32(p.config.GetInt("startretries", 3))
+	if strings.EqualFold(strings.TrimSpace(p.config.GetString("startretries", "")), "infinite") {
+		return unlimitedStartRetries
+	}
+	if retries := p.config.GetInt("startretries", 3); retries >= 0 {
+		return int32(retries)
+	}
+	return unlimitedStartRetries
+}
+
+// check if the program fails to start too many times, it is never true if startretries is infinite
+func (p *Process) isStartRetriesExhausted() bool {
+	retries := p.getStartRetries()
+	return retries != unlimitedStartRetries && atomic.LoadInt32(p.retryTimes) >= retries
+}
+
+// IsAutoStart check if the program is autostarted on this host by autostart and autostart_if.
+// The program is marked as autostart skipped if the host does not match
+func (p *Process) IsAutoStart() bool {
+	autostart := p.config.GetAutostart()
+	if !strings.HasPrefix(strings.TrimSpace(autostart), autostartHostPrefix) && autostart != "true" {
+		return false
+	}
+	matched, err := EvalAutostart(autostart)
+	if err != nil {
+		zap.S().Errorw("fail to evaluate autostart", "program", p.GetName(), "autostart", autostart, "error", err)
+	} else if !matched {
+		zap.S().Infow("autostart does not match this host, the program is not started", "program", p.GetName(), "autostart", autostart)
+	}
+	if expr := p.config.GetString("autostart_if", ""); err == nil && matched && expr != "" {
+		matched, err = evalHostPredicates(expr)
+		if err != nil {
+			zap.S().Errorw("fail to evaluate autostart_if", "program", p.GetName(), "autostart_if", expr, "error", err)
+		} else if !matched {
+			zap.S().Infow("autostart_if does not match this host, the program is not started", "program", p.GetName(), "autostart_if", expr)
+		}
+	}
+	p.lock.Lock()
+	p.autostartSkipped = !matched
+	p.lock.Unlock()
+	return matched
 }
 
-func (p *Process) isAutoStart() bool {
-	return p.config.GetString("autostart", "true") == "true"
+// IsAutostartSkipped check if the program is not autostarted because its autostart or
+// autostart_if does not match this host
+func (p *Process) IsAutostartSkipped() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.autostartSkipped && p.state == Stopped
 }
 
 // GetPriority get the program priority
@@ -346,8 +745,10 @@ func (p *Process) isAutoRestart() bool {
 			exitCode, err := p.getExitCode()
 			//If unexpected, the process will be restarted when the program exits
 			//with an exit code that is not one of the exit codes associated with
-			//this process’ configuration (see exitcodes).
-			return err == nil && !p.inExitCodes(exitCode)
+			//this process’ configuration (see exitcodes). The exit code is unknown
+			//if the program is killed by a signal or it is a daemon tracked by
+			//pidfile, which is always an unexpected exit.
+			return err != nil || !p.inExitCodes(exitCode)
 		}
 	}
 	return false
@@ -364,6 +765,9 @@ func (p *Process) inExitCodes(exitCode int) bool {
 }
 
 func (p *Process) getExitCode() (int, error) {
+	if p.daemon != nil {
+		return -1, fmt.Errorf("the exit code of the daemon tracked by pidfile is unknown")
+	}
 	if p.cmd.ProcessState == nil {
 		return -1, fmt.Errorf("no exit code")
 	}
@@ -379,7 +783,7 @@ func (p *Process) getExitCodes() []int {
 	strExitCodes := strings.Split(p.config.GetString("exitcodes", "0,2"), ",")
 	result := make([]int, 0)
 	for _, val := range strExitCodes {
-		i, err := strconv.Atoi(val)
+		i, err := strconv.Atoi(strings.TrimSpace(val))
 		if err == nil {
 			result = append(result, i)
 		}
@@ -391,15 +795,21 @@ func (p *Process) getExitCodes() []int {
 //
 func (p *Process) isRunning() bool {
 	if p.cmd != nil && p.cmd.Process != nil {
-		if runtime.GOOS == "windows" {
-			proc, err := os.FindProcess(p.cmd.Process.Pid)
-			return proc != nil && err == nil
-		}
-		return p.cmd.Process.Signal(syscall.Signal(0)) == nil
+		return isProcessAlive(p.getProcess())
 	}
 	return false
 }
 
+// check if the process is alive. A zombie process is not alive although it can still be
+// signaled, for example the daemon tracked by pidfile which is not reaped by its parent yet
+func isProcessAlive(process *os.Process) bool {
+	if runtime.GOOS == "windows" {
+		proc, err := os.FindProcess(process.Pid)
+		return proc != nil && err == nil
+	}
+	return process.Signal(syscall.Signal(0)) == nil && !isZombie(process.Pid)
+}
+
 // create Command object for the program
 func (p *Process) createProgramCommand() error {
 	args, err := parseCommand(p.config.GetStringExpression("command", ""))
@@ -412,16 +822,39 @@ func (p *Process) createProgramCommand() error {
 		p.cmd.Args = args
 	}
 	p.cmd.SysProcAttr = &syscall.SysProcAttr{}
-	if p.setUser() != nil {
+	if p.setUser(p.cmd) != nil {
 		zap.S().Errorw("fail to run as user", "user", p.config.GetString("user", ""))
 		return fmt.Errorf("fail to set user")
 	}
 	p.setProgramRestartChangeMonitor(args[0])
 	setDeathsig(p.cmd.SysProcAttr)
-	p.setEnv()
-	p.setDir()
+	if err = p.setEnv(p.cmd); err != nil {
+		zap.S().Errorw("fail to set the environment", "program", p.GetName(), "error", err)
+		return err
+	}
+	if err = p.setDir(p.cmd); err != nil {
+		zap.S().Errorw("fail to set the directory", "program", p.GetName(), "error", err)
+		return err
+	}
 	p.setLog()
 
+	p.outputCopiers = nil
+	p.daemon = nil
+	if p.config.IsProgram() {
+		if p.config.GetBool("pty", false) {
+			stdout := p.cmd.Stdout
+			master, slave, err := attachPty(p.cmd)
+			if err == nil {
+				p.outputCopiers = []*outputCopier{{reader: master, childEnd: slave, writer: stdout}}
+				p.stdin = master
+				return nil
+			}
+			zap.S().Warnw("fail to allocate pty, start the program without it", "program", p.GetName(), "error", err)
+		}
+		// the output is read from the pipes until the program exits, so the final output is not lost
+		p.cmd.Stdout = p.pipeOutput(p.cmd.Stdout)
+		p.cmd.Stderr = p.pipeOutput(p.cmd.Stderr)
+	}
 	p.stdin, _ = p.cmd.StdinPipe()
 	return nil
 
@@ -460,39 +893,154 @@ func (p *Process) setProgramRestartChangeMonitor(programPath string) {
 
 // wait for the started program exit
 func (p *Process) waitForExit(startSecs int64) {
-	p.cmd.Wait()
-	if p.cmd.ProcessState != nil {
+	if p.daemon != nil {
+		p.waitDaemonExit()
+	} else {
+		p.cmd.Wait()
+	}
+	p.waitOutputCopiers()
+	if p.daemon != nil {
+		zap.S().Infow("the daemon tracked by pidfile exited", "program", p.GetName(), "pid", p.daemon.Pid)
+	} else if p.cmd.ProcessState != nil {
 		zap.S().Infow(fmt.Sprintf("program stopped with status:%v", p.cmd.ProcessState), "program", p.GetName())
 	} else {
 		zap.S().Infow("program stopped", "program", p.GetName())
 	}
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	p.stopTime = time.Now()
-	p.StdoutLog.Close()
+	p.stopTime = processClock.now()
+	p.lastExitStatus = -1
+	if exitCode, err := p.getExitCode(); err == nil {
+		p.lastExitStatus = exitCode
+	}
+	p.stopLivenessWatch()
+	p.stopLifetimeWatch()
+	// the stderr log may write its last line to the stdout log if redirect_stderr is set, so close it first
 	p.StderrLog.Close()
+	p.StdoutLog.Close()
+	if p.stderrLastLine != nil && !p.stopByUser {
+		if exitCode, err := p.getExitCode(); err != nil || !p.inExitCodes(exitCode) {
+			p.lastError = p.stderrLastLine.LastLine()
+		}
+	}
 }
 
 // fail to start the program
+//
+// The program is quarantined if it enters Fatal state quarantine_after times
 func (p *Process) failToStartProgram(reason string, finishCb func()) {
 	zap.S().Errorw(reason, "program", p.GetName())
+	p.spawnError = reason
 	p.changeStateTo(Fatal)
+	retries := int(atomic.LoadInt32(p.retryTimes))
+	events.EmitEvent(events.NewProcessFatalEvent(p.GetName(), p.GetGroup(), p.lastExitStatus, retries))
+	if p.fatalCallback != nil {
+		go p.fatalCallback(p, p.lastExitStatus, retries)
+	}
+	p.fatalTimes++
+	if quarantineAfter := p.config.GetInt("quarantine_after", 0); quarantineAfter > 0 && p.fatalTimes >= quarantineAfter {
+		zap.S().Errorw("the program is quarantined because it failed too many times", "program", p.GetName(), "fatalTimes", p.fatalTimes)
+		p.changeStateTo(Quarantined)
+	}
 	finishCb()
 }
 
-// monitor if the program is in running before endTime
+// ResetCounters clear the start retries, the backoff and the restart counters of the program
+// which is not running. The program in Backoff, Fatal, Quarantined or Cooldown state is moved to Stopped
+// state without starting it
+func (p *Process) ResetCounters() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.state == Starting || p.state == Running || p.state == Stopping {
+		return fmt.Errorf("program %s is in %s state", p.GetName(), p.state.String())
+	}
+	atomic.StoreInt32(p.retryTimes, 0)
+	atomic.StoreInt32(&p.runningTimes, 0)
+	p.fatalTimes = 0
+	p.backoffDelay = 0
+	p.nextRetryMonotonic = 0
+	p.restartMonotonics = nil
+	if p.state == Backoff || p.state == Fatal || p.state == Quarantined || p.state == Cooldown {
+		zap.S().Infow("reset the counters and stop retrying the program", "program", p.GetName(), "state", p.state.String())
+		p.stopByUser = true
+		if p.abortBackoff != nil {
+			close(p.abortBackoff)
+			p.abortBackoff = nil
+		}
+		p.changeStateTo(Stopped)
+	}
+	return nil
+}
+
+// abort the current pause between the start retries, the later pauses are not aborted
+func (p *Process) retryNow() {
+	if p.abortBackoff != nil {
+		close(p.abortBackoff)
+		p.abortBackoff = make(chan struct{})
+	}
+}
+
+// get the pause before the next start retry. If backoff_initial is set, the pause starts from
+// backoff_initial and is doubled after each failed attempt up to backoff_max, otherwise it is
+// restartPause. It is at least minUnlimitedRetryPause if startretries is infinite
+func (p *Process) nextRetryPause(restartPause time.Duration) time.Duration {
+	pause := p.nextBackoffDelay(restartPause)
+	if pause < minUnlimitedRetryPause && p.getStartRetries() == unlimitedStartRetries {
+		return minUnlimitedRetryPause
+	}
+	return pause
+}
+
+// get the pause doubled from backoff_initial up to backoff_max, or restartPause if the backoff is not set
+func (p *Process) nextBackoffDelay(restartPause time.Duration) time.Duration {
+	initial := p.config.GetDuration("backoff_initial", 0)
+	if initial <= 0 {
+		return restartPause
+	}
+	max := p.config.GetDuration("backoff_max", 60*time.Second)
+	if max < initial {
+		max = initial
+	}
+	if p.backoffDelay < initial {
+		p.backoffDelay = initial
+	}
+	pause := p.backoffDelay
+	p.backoffDelay *= 2
+	if p.backoffDelay > max {
+		p.backoffDelay = max
+	}
+	return pause
+}
+
+// sleep d or until the abort channel is closed
+func sleepUntilAborted(d time.Duration, abort chan struct{}) {
+	select {
+	case <-time.After(d):
+	case <-abort:
+	}
+}
+
+// IsQuarantined check if the program is quarantined because it entered Fatal state too many times
+func (p *Process) IsQuarantined() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.state == Quarantined
+}
+
+// monitor if the program is in running before the monotonic clock reaches endTime
 //
-func (p *Process) monitorProgramIsRunning(endTime time.Time, monitorExited *int32, programExited *int32) {
+func (p *Process) monitorProgramIsRunning(endTime time.Duration, monitorExited *int32, programExited *int32) {
 	// if time is not expired
-	for time.Now().Before(endTime) && atomic.LoadInt32(programExited) == 0 {
+	for processClock.monotonic() < endTime && atomic.LoadInt32(programExited) == 0 {
 		time.Sleep(time.Duration(100) * time.Millisecond)
 	}
+	healthy := p.waitHealthy(programExited)
 	atomic.StoreInt32(monitorExited, 1)
 
 	p.lock.Lock()
 	defer p.lock.Unlock()
 	// if the program does not exit
-	if atomic.LoadInt32(programExited) == 0 && p.state == Starting {
+	if healthy && atomic.LoadInt32(programExited) == 0 && p.state == Starting {
 		zap.S().Infow("success to start program", "program", p.GetName())
 		p.changeStateTo(Running)
 	}
@@ -509,10 +1057,13 @@ func (p *Process) run(finishCb func()) {
 		return
 
 	}
-	p.startTime = time.Now()
+	p.startTime = processClock.now()
+	p.startMonotonic = processClock.monotonic()
+	p.lastError = ""
 	atomic.StoreInt32(p.retryTimes, 0)
 	startSecs := p.getStartSeconds()
-	restartPause := p.getRestartPause()
+	restartPause := time.Duration(p.getRestartPause()) * time.Second
+	retryPause := time.Duration(0)
 	var once sync.Once
 
 	// finishCb can be only called one time
@@ -521,49 +1072,83 @@ func (p *Process) run(finishCb func()) {
 	}
 	//process is not expired and not stoped by user
 	for !p.stopByUser {
-		if restartPause > 0 && atomic.LoadInt32(p.retryTimes) != 0 {
+		if retryPause > 0 && atomic.LoadInt32(p.retryTimes) != 0 {
 			//pause
+			abortBackoff := p.abortBackoff
 			p.lock.Unlock()
-			zap.S().Infow(fmt.Sprintf("don't restart the program, start it after %d  seconds", restartPause), "program", p.GetName())
-			time.Sleep(time.Duration(restartPause) * time.Second)
+			zap.S().Infow(fmt.Sprintf("don't restart the program, start it after %v", retryPause), "program", p.GetName())
+			sleepUntilAborted(retryPause, abortBackoff)
 			p.lock.Lock()
+			if p.stopByUser {
+				break
+			}
 		}
-		endTime := time.Now().Add(time.Duration(startSecs) * time.Second)
+		if atomic.LoadInt32(p.retryTimes) != 0 && p.getStartRetries() == unlimitedStartRetries {
+			// the program retried forever may fail to start too often
+			p.lock.Unlock()
+			cooldownPassed := p.waitFlapCooldown()
+			p.lock.Lock()
+			if !cooldownPassed {
+				break
+			}
+		}
+		endTime := processClock.monotonic() + time.Duration(startSecs)*time.Second
+		p.healthStatus = ""
 		p.changeStateTo(Starting)
 		atomic.AddInt32(p.retryTimes, 1)
 
 		err := p.createProgramCommand()
 		if err != nil {
-			p.failToStartProgram("fail to create program", finishCbWrapper)
+			p.failToStartProgram(fmt.Sprintf("fail to create program with error:%v", err), finishCbWrapper)
 			break
 		}
 
-		err = p.cmd.Start()
+		err = p.startWithResourceLimits(p.cmd, p.config.GetOctal("umask", -1))
+		p.processGroupCreated = createsProcessGroup(p.cmd.SysProcAttr)
+		p.startOutputCopiers(err)
+		if pidfile := p.config.GetStringExpression("pidfile", ""); err == nil && pidfile != "" {
+			// the daemon is not in the process group of the started program
+			p.processGroupCreated = false
+			if err = p.trackPidfile(pidfile); err != nil {
+				p.waitOutputCopiers()
+				p.StderrLog.Close()
+				p.StdoutLog.Close()
+			} else {
+				// the daemon may be forked before the launcher is placed in the cgroup
+				p.placeInCgroup(p.getProcess().Pid)
+			}
+		}
 
 		if err != nil {
-			if atomic.LoadInt32(p.retryTimes) >= p.getStartRetries() {
+			if p.isStartRetriesExhausted() {
 				p.failToStartProgram(fmt.Sprintf("fail to start program with error:%v", err), finishCbWrapper)
 				break
 			} else {
 				zap.S().Infow("fail to start program with error", "error", err, "program", p.GetName())
-				p.changeStateTo(Backoff)
+				retryPause = p.nextRetryPause(restartPause)
+				p.backoff(retryPause)
 				continue
 			}
 		}
+		atomic.AddInt32(&p.spawnTimes, 1)
+		p.startLivenessWatch()
+		p.startLifetimeWatch()
 		if p.StdoutLog != nil {
-			p.StdoutLog.SetPid(p.cmd.Process.Pid)
+			p.StdoutLog.SetPid(p.getProcess().Pid)
 		}
 		if p.StderrLog != nil {
-			p.StderrLog.SetPid(p.cmd.Process.Pid)
+			p.StderrLog.SetPid(p.getProcess().Pid)
 		}
 
 		monitorExited := int32(0)
 		programExited := int32(0)
 		//Set startsec to 0 to indicate that the program needn't stay
 		//running for any particular amount of time.
-		if startSecs <= 0 {
+		if startSecs <= 0 && !p.hasHealthCheck() {
 			zap.S().Infow("success to start program", "program", p.GetName())
 			p.changeStateTo(Running)
+			// no monitor is started, so a quick exit is not a start failure
+			atomic.StoreInt32(&monitorExited, 1)
 			go finishCbWrapper()
 		} else {
 			go func() {
@@ -589,13 +1174,14 @@ func (p *Process) run(finishCb func()) {
 			zap.S().Infow("program exited", "program", p.GetName())
 			break
 		} else {
-			p.changeStateTo(Backoff)
+			retryPause = p.nextRetryPause(restartPause)
+			p.backoff(retryPause)
 		}
 
 		// The number of serial failure attempts that supervisord will allow when attempting to
 		// start the program before giving up and putting the process into an Fatal state
 		// first start time is not the retry time
-		if atomic.LoadInt32(p.retryTimes) >= p.getStartRetries() {
+		if p.isStartRetriesExhausted() {
 			p.failToStartProgram(fmt.Sprintf("fail to start program because retry times is greater than %d", p.getStartRetries()), finishCbWrapper)
 			break
 		}
@@ -603,6 +1189,19 @@ func (p *Process) run(finishCb func()) {
 
 }
 
+// set the callback which is called when the process enters Running state again after it is restarted
+func (p *Process) setRestartedCallback(callback func(p *Process)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.restartedCallback = callback
+}
+
+// change the state to Backoff and record when the next start retry happens
+func (p *Process) backoff(pause time.Duration) {
+	p.nextRetryMonotonic = processClock.monotonic() + pause
+	p.changeStateTo(Backoff)
+}
+
 func (p *Process) changeStateTo(procState State) {
 	if p.config.IsProgram() {
 		progName := p.config.GetProgramName()
@@ -610,26 +1209,39 @@ func (p *Process) changeStateTo(procState State) {
 		if procState == Starting {
 			events.EmitEvent(events.CreateProcessStartingEvent(progName, groupName, p.state.String(), int(atomic.LoadInt32(p.retryTimes))))
 		} else if procState == Running {
-			events.EmitEvent(events.CreateProcessRunningEvent(progName, groupName, p.state.String(), p.cmd.Process.Pid))
+			events.EmitEvent(events.CreateProcessRunningEvent(progName, groupName, p.state.String(), p.getProcess().Pid))
+			p.fatalTimes = 0
+			p.backoffDelay = 0
+			if atomic.AddInt32(&p.runningTimes, 1) > 1 && p.restartedCallback != nil {
+				go p.restartedCallback(p)
+			}
 		} else if procState == Backoff {
 			events.EmitEvent(events.CreateProcessBackoffEvent(progName, groupName, p.state.String(), int(atomic.LoadInt32(p.retryTimes))))
 		} else if procState == Stopping {
-			events.EmitEvent(events.CreateProcessStoppingEvent(progName, groupName, p.state.String(), p.cmd.Process.Pid))
+			events.EmitEvent(events.CreateProcessStoppingEvent(progName, groupName, p.state.String(), p.getProcess().Pid))
 		} else if procState == Exited {
 			exitCode, err := p.getExitCode()
 			expected := 0
 			if err == nil && p.inExitCodes(exitCode) {
 				expected = 1
 			}
-			events.EmitEvent(events.CreateProcessExitedEvent(progName, groupName, p.state.String(), expected, p.cmd.Process.Pid))
+			events.EmitEvent(events.CreateProcessExitedEvent(progName, groupName, p.state.String(), expected, p.getProcess().Pid))
 		} else if procState == Fatal {
 			events.EmitEvent(events.CreateProcessFatalEvent(progName, groupName, p.state.String()))
+		} else if procState == Quarantined {
+			events.EmitEvent(events.CreateProcessQuarantinedEvent(progName, groupName, p.state.String(), p.fatalTimes))
 		} else if procState == Stopped {
-			events.EmitEvent(events.CreateProcessStoppedEvent(progName, groupName, p.state.String(), p.cmd.Process.Pid))
+			// the program may be stopped while it is not started, like in Backoff or Fatal state
+			pid := 0
+			if p.daemon != nil || (p.cmd != nil && p.cmd.Process != nil) {
+				pid = p.getProcess().Pid
+			}
+			events.EmitEvent(events.CreateProcessStoppedEvent(progName, groupName, p.state.String(), pid))
 		} else if procState == Unknown {
 			events.EmitEvent(events.CreateProcessUnknownEvent(progName, groupName, p.state.String()))
 		}
 	}
+	p.recordStateTransition(procState)
 	p.state = procState
 }
 
@@ -646,6 +1258,23 @@ func (p *Process) Signal(sig os.Signal, sigChildren bool) error {
 	return p.sendSignal(sig, sigChildren)
 }
 
+// SignalProgram send the signal to the program, and also to its children in its process group
+// if killasgroup is set
+func (p *Process) SignalProgram(sig os.Signal) error {
+	return p.Signal(sig, p.isKillAsGroup())
+}
+
+// check if the stop signals are sent to the process group of the program by stopasgroup
+func (p *Process) isStopAsGroup() bool {
+	return p.config.GetBool("stopasgroup", false)
+}
+
+// check if the kill signal and the signals sent by user are sent to the process group of the
+// program by killasgroup, it defaults to stopasgroup
+func (p *Process) isKillAsGroup() bool {
+	return p.config.GetBool("killasgroup", p.isStopAsGroup())
+}
+
 // send signal to the process
 //
 // Args:
@@ -654,67 +1283,125 @@ func (p *Process) Signal(sig os.Signal, sigChildren bool) error {
 //
 func (p *Process) sendSignal(sig os.Signal, sigChildren bool) error {
 	if p.cmd != nil && p.cmd.Process != nil {
-		err := signals.Kill(p.cmd.Process, sig, sigChildren)
+		if sigChildren && !p.processGroupCreated {
+			zap.S().Warnw("the program is not started in a new process group, restart it to send signal to its children. Send the signal to the program only", "program", p.GetName(), "signal", sig)
+			sigChildren = false
+		}
+		err := signals.Kill(p.getProcess(), sig, sigChildren)
 		return err
 	}
 	return fmt.Errorf("process is not started")
 }
 
-func (p *Process) setEnv() {
-	env := p.config.GetEnv("environment")
-	if len(env) != 0 {
-		p.cmd.Env = append(os.Environ(), env...)
+// the variables of supervisord passed to the program even if inherit_env is false
+var minimalEnvKeys = []string{"PATH", "HOME"}
+
+// set the environment of the program. The variables in "environment" take precedence over
+// the ones read from "env_file", which is read every time the program is started like the files
+// of "%(file:path)s" in "environment". The program inherits the environment of supervisord
+// unless inherit_env is false, then only PATH and HOME are passed to it
+func (p *Process) setEnv(cmd *exec.Cmd) error {
+	if p.config.GetBool("inherit_env", true) {
+		cmd.Env = os.Environ()
 	} else {
-		p.cmd.Env = os.Environ()
+		cmd.Env = make([]string, 0)
+		for _, key := range minimalEnvKeys {
+			if value, ok := os.LookupEnv(key); ok {
+				cmd.Env = append(cmd.Env, key+"="+value)
+			}
+		}
+	}
+	if envFile := p.config.GetStringExpression("env_file", ""); envFile != "" {
+		if !filepath.IsAbs(envFile) {
+			envFile = filepath.Join(p.config.ConfigDir, envFile)
+		}
+		env, err := config.LoadEnvFile(envFile)
+		if err != nil {
+			return fmt.Errorf("fail to read env_file %s: %v", envFile, err)
+		}
+		cmd.Env = append(cmd.Env, env...)
 	}
+	env, err := p.config.GetEnvWithFiles("environment")
+	if err != nil {
+		return err
+	}
+	cmd.Env = append(cmd.Env, env...)
+	return nil
 }
 
-func (p *Process) setDir() {
-	dir := p.config.GetStringExpression("directory", "")
+func (p *Process) setDir(cmd *exec.Cmd) error {
+	dir, err := getDirectory(p.config)
+	if err != nil {
+		return err
+	}
 	if dir != "" {
-		p.cmd.Dir = dir
+		cmd.Dir = dir
 	}
+	return nil
 }
 
 func (p *Process) setLog() {
 	if p.config.IsProgram() {
-		p.StdoutLog = p.createLogger(p.GetStdoutLogfile(),
+		stdoutLog := p.createLogger(p.GetStdoutLogfile(), "stdout_logfile_format",
 			int64(p.config.GetBytes("stdout_logfile_maxbytes", 50*1024*1024)),
 			p.config.GetInt("stdout_logfile_backups", 10),
 			p.createStdoutLogEventEmitter())
+		p.StdoutLog = p.formatLogger(stdoutLog, "stdout_logfile_format", "stdout")
 		captureBytes := p.config.GetBytes("stdout_capture_maxbytes", 0)
 		if captureBytes > 0 {
 			zap.S().Infow("capture stdout process communication", "program", p.config.GetProgramName())
-			p.StdoutLog = logger.NewLogCaptureLogger(p.StdoutLog,
+			captureLogger := logger.NewLogCaptureLogger(p.StdoutLog,
 				captureBytes,
 				"PROCESS_COMMUNICATION_STDOUT",
 				p.GetName(),
 				p.GetGroup())
+			captureLogger.SetCaptureSwitch(p.IsStdoutEventsEnabled)
+			p.StdoutLog = captureLogger
 		}
 
-		p.cmd.Stdout = p.StdoutLog
+		p.stdoutCounter.reset()
+		p.stderrCounter.reset()
+		p.cmd.Stdout = p.stdoutCounter.wrap(p.StdoutLog)
 
 		if p.config.GetBool("redirect_stderr", false) {
 			p.StderrLog = p.StdoutLog
+			if p.isJSONLogFormat("stdout_logfile_format") {
+				// the redirected stderr lines are written to the stdout log with stream "stderr",
+				// the stdout log is closed by p.StdoutLog
+				stderrLog := logger.NewJSONLineLogger(stdoutLog, p.GetName(), p.GetGroup(), "stderr")
+				stderrLog.SetKeepUnderline(true)
+				p.StderrLog = stderrLog
+			}
 		} else {
-			p.StderrLog = p.createLogger(p.GetStderrLogfile(),
+			p.StderrLog = p.formatLogger(p.createLogger(p.GetStderrLogfile(), "stderr_logfile_format",
 				int64(p.config.GetBytes("stderr_logfile_maxbytes", 50*1024*1024)),
 				p.config.GetInt("stderr_logfile_backups", 10),
-				p.createStderrLogEventEmitter())
+				p.createStderrLogEventEmitter()), "stderr_logfile_format", "stderr")
 		}
 
 		captureBytes = p.config.GetBytes("stderr_capture_maxbytes", 0)
 
 		if captureBytes > 0 {
 			zap.S().Infow("capture stderr process communication", "program", p.config.GetProgramName())
-			p.StderrLog = logger.NewLogCaptureLogger(p.StdoutLog,
+			captureLogger := logger.NewLogCaptureLogger(p.StdoutLog,
 				captureBytes,
 				"PROCESS_COMMUNICATION_STDERR",
 				p.GetName(),
 				p.GetGroup())
+			captureLogger.SetCaptureSwitch(p.IsStderrEventsEnabled)
+			p.StderrLog = captureLogger
 		}
 
-		p.cmd.Stderr = p.StderrLog
+		p.cmd.Stderr = p.stderrCounter.wrap(p.StderrLog)
+		p.stderrLastLine = nil
+		if p.config.GetBool("capture_fatal_reason", false) {
+			p.stderrLastLine = newLastLineRecorder()
+			p.cmd.Stderr = io.MultiWriter(p.cmd.Stderr, p.stderrLastLine)
+		}
+		if scanners := p.createLogScanners(); len(scanners) > 0 {
+			p.cmd.Stdout = io.MultiWriter(p.cmd.Stdout, newLineScanWriter(scanners...))
+			p.cmd.Stderr = io.MultiWriter(p.cmd.Stderr, newLineScanWriter(scanners...))
+		}
 
 	} else if p.config.IsEventListener() {
 		in, err := p.cmd.StdoutPipe()
@@ -741,23 +1428,60 @@ func (p *Process) setLog() {
 }
 
 func (p *Process) createStdoutLogEventEmitter() logger.LogEventEmitter {
-	if p.config.GetBytes("stdout_capture_maxbytes", 0) <= 0 && p.config.GetBool("stdout_events_enabled", false) {
-		return logger.NewStdoutLogEventEmitter(p.config.GetProgramName(), p.config.GetGroupName(), func() int {
+	if p.config.GetBytes("stdout_capture_maxbytes", 0) <= 0 {
+		return logger.NewSwitchableLogEventEmitter(logger.NewStdoutLogEventEmitter(p.config.GetProgramName(), p.config.GetGroupName(), func() int {
 			return p.GetPid()
-		})
+		}), p.IsStdoutEventsEnabled)
 	}
 	return logger.NewNullLogEventEmitter()
 }
 
 func (p *Process) createStderrLogEventEmitter() logger.LogEventEmitter {
-	if p.config.GetBytes("stderr_capture_maxbytes", 0) <= 0 && p.config.GetBool("stderr_events_enabled", false) {
-		return logger.NewStdoutLogEventEmitter(p.config.GetProgramName(), p.config.GetGroupName(), func() int {
+	if p.config.GetBytes("stderr_capture_maxbytes", 0) <= 0 {
+		return logger.NewSwitchableLogEventEmitter(logger.NewStderrLogEventEmitter(p.config.GetProgramName(), p.config.GetGroupName(), func() int {
 			return p.GetPid()
-		})
+		}), p.IsStderrEventsEnabled)
 	}
 	return logger.NewNullLogEventEmitter()
 }
 
+// SetLogEventsEnabled enable or disable the stdout/stderr log events (or the process communication
+// capture if it is configured) at runtime. The log is still written to the log files
+func (p *Process) SetLogEventsEnabled(stdout bool, stderr bool) {
+	atomic.StoreInt32(&p.stdoutEventsEnabled, boolToInt32(stdout))
+	atomic.StoreInt32(&p.stderrEventsEnabled, boolToInt32(stderr))
+}
+
+// ResetLogEventsEnabled enable or disable the stdout/stderr log events as configured by
+// stdout_events_enabled/stderr_events_enabled, the override by SetLogEventsEnabled is discarded
+func (p *Process) ResetLogEventsEnabled() {
+	p.SetLogEventsEnabled(p.config.GetBool("stdout_events_enabled", false) || p.config.GetBytes("stdout_capture_maxbytes", 0) > 0,
+		p.config.GetBool("stderr_events_enabled", false) || p.config.GetBytes("stderr_capture_maxbytes", 0) > 0)
+}
+
+// SetAutostart override the autostart of the program in memory until the configuration is reloaded,
+// the later autostart of the programs, like starting a group or the configuration update, honors it
+func (p *Process) SetAutostart(enabled bool) {
+	p.config.SetAutostart(enabled)
+}
+
+// IsStdoutEventsEnabled check if the stdout log events are enabled
+func (p *Process) IsStdoutEventsEnabled() bool {
+	return atomic.LoadInt32(&p.stdoutEventsEnabled) == 1
+}
+
+// IsStderrEventsEnabled check if the stderr log events are enabled
+func (p *Process) IsStderrEventsEnabled() bool {
+	return atomic.LoadInt32(&p.stderrEventsEnabled) == 1
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func (p *Process) registerEventListener(eventListenerName string,
 	_events []string,
 	stdin io.Reader,
@@ -767,6 +1491,7 @@ func (p *Process) registerEventListener(eventListenerName string,
 		stdin,
 		stdout,
 		p.config.GetInt("buffer_size", 100))
+	eventListener.SetRedeliverFailedEvents(p.config.GetBool("redeliver_failed_events", true))
 	events.RegisterEventListener(eventListenerName, _events, eventListener)
 }
 
@@ -774,16 +1499,47 @@ func (p *Process) unregisterEventListener(eventListenerName string) {
 	events.UnregisterEventListener(eventListenerName)
 }
 
-func (p *Process) createLogger(logFile string, maxBytes int64, backups int, logEventEmitter logger.LogEventEmitter) logger.Logger {
-	return logger.NewLogger(p.GetName(), logFile, logger.NewNullLocker(), maxBytes, backups, logEventEmitter)
+// create the logger of the program stdout or stderr. The lines written to the stdout or stderr of
+// supervisord are prefixed with the program name unless the log format by formatKey is json, which
+// has the program name already
+func (p *Process) createLogger(logFile string, formatKey string, maxBytes int64, backups int, logEventEmitter logger.LogEventEmitter) logger.Logger {
+	syslogTag := p.config.GetString("syslog_tag", p.GetName())
+	stdLinePrefix := p.GetName() + " | "
+	if p.isJSONLogFormat(formatKey) {
+		stdLinePrefix = ""
+	}
+	return logger.NewLogger(syslogTag, p.config.GetString("syslog_facility", ""), stdLinePrefix, logFile, logger.NewNullLocker(), maxBytes, backups, p.config.GetString("logfile_rotate", ""), p.config.GetBool("logfile_compress", false), logEventEmitter)
+}
+
+// check if the log format configured by formatKey (stdout_logfile_format or stderr_logfile_format) is json
+func (p *Process) isJSONLogFormat(formatKey string) bool {
+	return strings.ToLower(p.config.GetString(formatKey, "")) == "json"
+}
+
+// wrap the log to the format configured by formatKey. The lines of the stream are
+// written as JSON objects if the format is json, otherwise they are written as is
+func (p *Process) formatLogger(log logger.Logger, formatKey string, stream string) logger.Logger {
+	if p.isJSONLogFormat(formatKey) {
+		return logger.NewJSONLineLogger(log, p.GetName(), p.GetGroup(), stream)
+	}
+	return log
 }
 
-func (p *Process) setUser() error {
+func (p *Process) setUser(cmd *exec.Cmd) error {
 	userName := p.config.GetString("user", "")
 	if len(userName) == 0 {
 		return nil
 	}
+	uid, gid, err := LookupUser(userName)
+	if err != nil {
+		return err
+	}
+	setUserID(cmd.SysProcAttr, uid, gid)
+	return nil
+}
 
+// LookupUser get the uid and gid of the user setting like USER or USER:GROUP
+func LookupUser(userName string) (uint32, uint32, error) {
 	//check if group is provided
 	pos := strings.Index(userName, ":")
 	groupName := ""
@@ -793,28 +1549,27 @@ func (p *Process) setUser() error {
 	}
 	u, err := user.Lookup(userName)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	uid, err := strconv.ParseUint(u.Uid, 10, 32)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	gid, err := strconv.ParseUint(u.Gid, 10, 32)
 	if err != nil && groupName == "" {
-		return err
+		return 0, 0, err
 	}
 	if groupName != "" {
 		g, err := user.LookupGroup(groupName)
 		if err != nil {
-			return err
+			return 0, 0, err
 		}
 		gid, err = strconv.ParseUint(g.Gid, 10, 32)
 		if err != nil {
-			return err
+			return 0, 0, err
 		}
 	}
-	setUserID(p.cmd.SysProcAttr, uint32(uid), uint32(gid))
-	return nil
+	return uint32(uid), uint32(gid), nil
 }
 
 //Stop send signal to process to stop it
@@ -822,27 +1577,44 @@ func (p *Process) Stop(wait bool) {
 	p.lock.Lock()
 	p.stopByUser = true
 	isRunning := p.isRunning()
+	// the start loop exits after the pause before the next start (like the cooldown or the
+	// start delay) is aborted
+	p.retryNow()
 	p.lock.Unlock()
 	if !isRunning {
 		zap.S().Infow("program is not running", "program", p.GetName())
 		return
 	}
 	zap.S().Infow("stop the program", "program", p.GetName())
+	stopped := p.sendStopSignals()
+	if wait {
+		for atomic.LoadInt32(stopped) == 0 {
+			time.Sleep(1 * time.Second)
+		}
+	}
+}
+
+// send the stopsignal to the program in background and escalate to the stopkillsignal if it does
+// not exit in stopwaitsecs, the signals are sent to the process group by stopasgroup and
+// killasgroup. Return the flag set to 1 after the program exits or is killed
+func (p *Process) sendStopSignals() *int32 {
 	sigs := strings.Fields(p.config.GetString("stopsignal", ""))
 	waitsecs := time.Duration(p.config.GetInt("stopwaitsecs", 10)) * time.Second
-	stopasgroup := p.config.GetBool("stopasgroup", false)
-	killasgroup := p.config.GetBool("killasgroup", stopasgroup)
+	killSignalName := p.config.GetString("stopkillsignal", "KILL")
+	stopasgroup := p.isStopAsGroup()
+	killasgroup := p.isKillAsGroup()
 	if stopasgroup && !killasgroup {
 		zap.S().Errorw("Cannot set stopasgroup=true and killasgroup=false", "program", p.GetName())
 	}
 
-	var stopped int32 = 0
+	stopped := new(int32)
 	go func() {
-		for i := 0; i < len(sigs) && atomic.LoadInt32(&stopped) == 0; i++ {
+		for i := 0; i < len(sigs) && atomic.LoadInt32(stopped) == 0; i++ {
 			// send signal to process
 			sig, err := signals.ToSignal(sigs[i])
 			if err != nil {
-				continue
+				zap.S().Warnw("invalid stop signal, send SIGTERM instead", "program", p.GetName(), "signal", sigs[i], "error", err)
+				sig = syscall.SIGTERM
 			}
 			zap.S().Infow("send stop signal to program", "program", p.GetName(), "signal", sigs[i])
 			p.Signal(sig, stopasgroup)
@@ -851,23 +1623,64 @@ func (p *Process) Stop(wait bool) {
 			for endTime.After(time.Now()) {
 				//if it already exits
 				if p.state != Starting && p.state != Running && p.state != Stopping {
-					atomic.StoreInt32(&stopped, 1)
+					atomic.StoreInt32(stopped, 1)
 					break
 				}
 				time.Sleep(10 * time.Millisecond)
 			}
 		}
-		if atomic.LoadInt32(&stopped) == 0 {
-			zap.S().Infow("force to kill the program", "program", p.GetName())
-			p.Signal(syscall.SIGKILL, killasgroup)
-			atomic.StoreInt32(&stopped, 1)
+		if atomic.LoadInt32(stopped) == 0 {
+			killSignal, err := signals.ToSignal(killSignalName)
+			if err != nil {
+				killSignal, killSignalName = syscall.SIGKILL, "KILL"
+			}
+			zap.S().Infow("force to kill the program", "program", p.GetName(), "signal", killSignalName)
+			if len(sigs) > 0 {
+				// the program does not exit after the stop signals are sent
+				p.lock.Lock()
+				p.killSignal = killSignalName
+				p.lock.Unlock()
+			}
+			p.Signal(killSignal, killasgroup)
+			atomic.StoreInt32(stopped, 1)
 		}
 	}()
-	if wait {
-		for atomic.LoadInt32(&stopped) == 0 {
-			time.Sleep(1 * time.Second)
-		}
+	return stopped
+}
+
+// SoftStop send the first configured stop signal (SIGTERM if not configured) to the program once
+// and return immediately. The program is not marked as stopped by user and the signal is not
+// escalated to SIGKILL, so the autorestart setting decides what happens after the program exits
+func (p *Process) SoftStop() error {
+	p.lock.RLock()
+	isRunning := p.isRunning()
+	p.lock.RUnlock()
+	if !isRunning {
+		return fmt.Errorf("program %s is not running", p.GetName())
+	}
+	sigName := "TERM"
+	if sigs := strings.Fields(p.config.GetString("stopsignal", "")); len(sigs) > 0 {
+		sigName = sigs[0]
+	}
+	sig, err := signals.ToSignal(sigName)
+	if err != nil {
+		return err
+	}
+	zap.S().Infow("send stop signal to program without waiting", "program", p.GetName(), "signal", sigName)
+	return p.Signal(sig, p.isStopAsGroup())
+}
+
+// Kill kill the program with SIGKILL immediately without waiting for graceful stop
+func (p *Process) Kill() {
+	p.lock.Lock()
+	p.stopByUser = true
+	isRunning := p.isRunning()
+	p.lock.Unlock()
+	if !isRunning {
+		return
 	}
+	zap.S().Infow("force to kill the program", "program", p.GetName())
+	p.Signal(syscall.SIGKILL, p.isKillAsGroup())
 }
 
 // GetStatus get the status of program in string
@@ -3,8 +3,10 @@ package process
 import (
 	"fmt"
 	"go.uber.org/zap"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ochinchina/supervisord/config"
 )
@@ -13,13 +15,38 @@ import (
 type Manager struct {
 	procs          map[string]*Process
 	eventListeners map[string]*Process
-	lock           sync.Mutex
+	// the start order of programs in group, key is the group name
+	groupStartOrders map[string][]string
+	// the last time the dependents of a program are restarted, key is the program name
+	dependentsRestartTimes map[string]time.Time
+	// the programs whose dependents are being restarted
+	restartingDependents map[string]bool
+	// the limit of the total memory of the programs and the action taken if it is exceeded
+	totalMemoryLimit  int64
+	totalMemoryAction string
+	memorySamplerOnce sync.Once
+	// the url to post to when a program enters Fatal state
+	fatalWebhookURL string
+	// how many recent state transitions are kept for each program
+	stateHistorySize int
+	lock             sync.Mutex
 }
 
+// the minimum interval between two restarts of dependents caused by the same program, it avoids
+// the restart storm if the program is flapping
+const minDependentsRestartInterval = 60 * time.Second
+
+// the max time an autostarted program waits for the RUNNING programs it depends on with ready_regex to be ready
+const maxDependencyReadyWait = 60 * time.Second
+
 // NewManager create a new Manager object
 func NewManager() *Manager {
 	return &Manager{procs: make(map[string]*Process),
-		eventListeners: make(map[string]*Process),
+		eventListeners:         make(map[string]*Process),
+		groupStartOrders:       make(map[string][]string),
+		dependentsRestartTimes: make(map[string]time.Time),
+		restartingDependents:   make(map[string]bool),
+		stateHistorySize:       DefaultStateHistorySize,
 	}
 }
 
@@ -37,12 +64,227 @@ func (pm *Manager) CreateProcess(supervisorID string, config *config.Entry) *Pro
 }
 
 // StartAutoStartPrograms start all the program if its autostart is true
+//
+// The programs in a group with start order are started one by one in that order. The
+// programs with depends_on are started after the programs they depend on are RUNNING. The
+// quarantined programs are not started
 func (pm *Manager) StartAutoStartPrograms() {
+	pm.startAutoStartPrograms(func(proc *Process) bool { return true })
+}
+
+// StartAutoStartGroup start the programs in a group if its autostart is true
+func (pm *Manager) StartAutoStartGroup(group string) {
+	pm.startAutoStartPrograms(func(proc *Process) bool { return proc.GetGroup() == group })
+}
+
+// start the programs selected by filter if its autostart is true
+func (pm *Manager) startAutoStartPrograms(filter func(proc *Process) bool) {
+	groupStartOrders := pm.getGroupStartOrders()
+	orderedGroups := make(map[string]bool)
 	pm.ForEachProcess(func(proc *Process) {
-		if proc.isAutoStart() {
-			proc.Start(false)
+		if !filter(proc) {
+			return
+		} else if proc.IsQuarantined() {
+			zap.S().Infow("Don't autostart the quarantined program", "program", proc.GetName())
+		} else if proc.IsAutoStart() {
+			if _, ok := groupStartOrders[proc.GetGroup()]; ok {
+				orderedGroups[proc.GetGroup()] = true
+			} else if deps := pm.getDependencies(proc); len(deps) > 0 {
+				proc.setWaitingDependencies(true)
+				go startAfterDependencies(proc, deps)
+			} else {
+				proc.Start(false)
+			}
+		}
+	})
+	for group := range orderedGroups {
+		go func(procs []*Process) {
+			for _, proc := range procs {
+				if !proc.IsQuarantined() && proc.IsAutoStart() {
+					proc.Start(true)
+				}
+			}
+		}(pm.GetGroupProcesses(group))
+	}
+}
+
+// get the programs the proc depends on by depends_on, a program with numprocs is expanded to
+// all its processes. The manager lock must be held
+func (pm *Manager) getDependencies(proc *Process) []*Process {
+	result := make([]*Process, 0)
+	for _, dep := range pm.procs {
+		if dep != proc && dependsOn(proc, dep) {
+			result = append(result, dep)
+		}
+	}
+	return result
+}
+
+// check if proc depends on dep by depends_on, either by the process name of dep or by the
+// name of its program
+func dependsOn(proc *Process, dep *Process) bool {
+	for _, name := range proc.config.GetDependsOn() {
+		if name == dep.GetName() || name == dep.config.GetSectionProgramName() {
+			return true
 		}
+	}
+	return false
+}
+
+// SetGroupStartOrder set the start order of programs in a group. The programs not in
+// the order list are started after the listed ones. An empty order removes the start
+// order of the group
+func (pm *Manager) SetGroupStartOrder(group string, order []string) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	if len(order) <= 0 {
+		delete(pm.groupStartOrders, group)
+	} else {
+		pm.groupStartOrders[group] = order
+	}
+}
+
+func (pm *Manager) getGroupStartOrders() map[string][]string {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	result := make(map[string][]string)
+	for group, order := range pm.groupStartOrders {
+		result[group] = order
+	}
+	return result
+}
+
+// GetGroupProcesses get all the processes in a group. If the group has a start order,
+// the processes are returned in the start order followed by the processes not in the
+// start order in configuration order, otherwise they are sorted by their priority
+func (pm *Manager) GetGroupProcesses(group string) []*Process {
+	procs := pm.FindMatch(group + ":*")
+	order, ok := pm.getGroupStartOrders()[group]
+	if !ok {
+		return procs
+	}
+	result := make([]*Process, 0)
+	added := make(map[*Process]bool)
+	for _, name := range order {
+		for _, proc := range procs {
+			if proc.GetName() == name && !added[proc] {
+				result = append(result, proc)
+				added[proc] = true
+			}
+		}
+	}
+	others := make([]*Process, 0)
+	for _, proc := range procs {
+		if !added[proc] {
+			others = append(others, proc)
+		}
+	}
+	sortByConfigOrder(others)
+	return append(result, others...)
+}
+
+// sort the processes by their position in the "programs" of the group section, the instances
+// of a program by their process_num
+func sortByConfigOrder(procs []*Process) {
+	sort.Slice(procs, func(i, j int) bool {
+		order1, order2 := procs[i].config.GetGroupOrder(), procs[j].config.GetGroupOrder()
+		if order1 != order2 {
+			return order1 < order2
+		}
+		num1, num2 := procs[i].config.GetInt("process_num", 0), procs[j].config.GetInt("process_num", 0)
+		if num1 != num2 {
+			return num1 < num2
+		}
+		return procs[i].GetName() < procs[j].GetName()
+	})
+}
+
+// StartGroup start all the processes in a group and return them.
+//
+// If the group has a start order, the processes are started one by one in that order
+// and each one waits for the previous one to be RUNNING. Otherwise the processes are
+// started concurrently
+func (pm *Manager) StartGroup(group string, wait bool) []*Process {
+	procs := pm.GetGroupProcesses(group)
+	if _, ok := pm.getGroupStartOrders()[group]; ok {
+		startInOrder := func() {
+			for _, proc := range procs {
+				proc.Start(true)
+			}
+		}
+		if wait {
+			startInOrder()
+		} else {
+			go startInOrder()
+		}
+		return procs
+	}
+	var wg sync.WaitGroup
+	for _, proc := range procs {
+		wg.Add(1)
+		go func(proc *Process) {
+			defer wg.Done()
+			proc.Start(wait)
+		}(proc)
+	}
+	wg.Wait()
+	return procs
+}
+
+// StopGroup stop all the processes in a group and return them.
+//
+// If the group has a start order, the processes are stopped one by one in the
+// reverse of the start order. Otherwise the processes are stopped concurrently
+func (pm *Manager) StopGroup(group string, wait bool) []*Process {
+	procs := pm.GetGroupProcesses(group)
+	if _, ok := pm.getGroupStartOrders()[group]; ok {
+		stopInReverseOrder := func() {
+			for i := len(procs) - 1; i >= 0; i-- {
+				procs[i].Stop(true)
+			}
+		}
+		if wait {
+			stopInReverseOrder()
+		} else {
+			go stopInReverseOrder()
+		}
+		return procs
+	}
+	var wg sync.WaitGroup
+	for _, proc := range procs {
+		wg.Add(1)
+		go func(proc *Process) {
+			defer wg.Done()
+			proc.Stop(wait)
+		}(proc)
+	}
+	wg.Wait()
+	return procs
+}
+
+// RestartGroup stop all the processes in a group like StopGroup and wait for them to be stopped,
+// then start them again like StartGroup and return them
+func (pm *Manager) RestartGroup(group string, wait bool) []*Process {
+	for _, proc := range pm.StopGroup(group, true) {
+		proc.waitStartLoopExit(10 * time.Second)
+	}
+	return pm.StartGroup(group, wait)
+}
+
+// RestartAllProcesses stop all the processes like StopAllProcesses and wait for them to be
+// stopped, then start them tier by tier in the ascending order of their priority and return them
+func (pm *Manager) RestartAllProcesses(wait bool) []*Process {
+	pm.StopAllProcesses()
+	procs := make([]*Process, 0)
+	var lock sync.Mutex
+	pm.ForEachProcessGroupedByPriority(func(proc *Process) {
+		proc.waitStartLoopExit(10 * time.Second)
+		proc.Start(wait)
+		lock.Lock()
+		defer lock.Unlock()
+		procs = append(procs, proc)
 	})
+	return procs
 }
 
 func (pm *Manager) createProgram(supervisorID string, config *config.Entry) *Process {
@@ -52,6 +294,9 @@ func (pm *Manager) createProgram(supervisorID string, config *config.Entry) *Pro
 
 	if !ok {
 		proc = NewProcess(supervisorID, config)
+		proc.stateHistorySize = pm.stateHistorySize
+		proc.setRestartedCallback(pm.restartDependents)
+		proc.setFatalCallback(pm.notifyFatal)
 		pm.procs[procName] = proc
 	}
 	zap.S().Info("create process:", procName)
@@ -65,6 +310,7 @@ func (pm *Manager) createEventListener(supervisorID string, config *config.Entry
 
 	if !ok {
 		evtListener = NewProcess(supervisorID, config)
+		evtListener.stateHistorySize = pm.stateHistorySize
 		pm.eventListeners[eventListenerName] = evtListener
 	}
 	zap.S().Info("create event listener:", eventListenerName)
@@ -170,11 +416,111 @@ func (pm *Manager) AsyncForEachProcess(procFunc func(p *Process), done chan *Pro
 	return len(procs)
 }
 
+// AsyncForEachProcessWithLimit handle each process in async mode like AsyncForEachProcess, but at
+// most limit processes are handled at the same time by a pool of workers, no limit if it is 0 or negative
+// Args:
+// - procFunc, the function to handle the process
+// - done, signal the process is completed
+// - limit, the max number of the processes handled concurrently
+// Returns: number of total processes
+func (pm *Manager) AsyncForEachProcessWithLimit(procFunc func(p *Process), done chan *Process, limit int) int {
+	if limit <= 0 {
+		return pm.AsyncForEachProcess(procFunc, done)
+	}
+	pm.lock.Lock()
+	procs := pm.getAllProcess()
+	pm.lock.Unlock()
+
+	pending := make(chan *Process, len(procs))
+	for _, proc := range procs {
+		pending <- proc
+	}
+	close(pending)
+	if limit > len(procs) {
+		limit = len(procs)
+	}
+	for i := 0; i < limit; i++ {
+		go func() {
+			for proc := range pending {
+				forOneProcess(proc, procFunc, done)
+			}
+		}()
+	}
+	return len(procs)
+}
+
 func forOneProcess(proc *Process, action func(p *Process), done chan *Process) {
 	action(proc)
 	done <- proc
 }
 
+// AsyncForEachProcessByReversePriority handle the processes in the descending order of their
+// priority in async mode. The processes with the same priority are handled concurrently, and
+// the processes with a lower priority are handled after all of them are completed
+// Args:
+// - procFunc, the function to handle the process
+// - done, signal the process is completed
+// Returns: number of total processes
+func (pm *Manager) AsyncForEachProcessByReversePriority(procFunc func(p *Process), done chan *Process) int {
+	tiers := pm.GetProcessesGroupedByPriority()
+	n := 0
+	for _, tier := range tiers {
+		n += len(tier)
+	}
+	go func() {
+		for i := len(tiers) - 1; i >= 0; i-- {
+			forEachProcessConcurrently(tiers[i], func(proc *Process) {
+				forOneProcess(proc, procFunc, done)
+			})
+		}
+	}()
+	return n
+}
+
+// ForEachProcessGroupedByPriority handle the processes tier by tier in the ascending order of
+// their priority. The processes with the same priority are handled concurrently, and the next
+// tier is handled after all of them are completed. It returns after all the processes are handled
+func (pm *Manager) ForEachProcessGroupedByPriority(procFunc func(p *Process)) {
+	for _, tier := range pm.GetProcessesGroupedByPriority() {
+		forEachProcessConcurrently(tier, procFunc)
+	}
+}
+
+// GetProcessesGroupedByPriority get all the processes bucketed by their priority, the buckets
+// are sorted in the ascending order of the priority
+func (pm *Manager) GetProcessesGroupedByPriority() [][]*Process {
+	pm.lock.Lock()
+	procs := pm.getAllProcess()
+	pm.lock.Unlock()
+
+	sort.SliceStable(procs, func(i, j int) bool {
+		return procs[i].GetPriority() < procs[j].GetPriority()
+	})
+	tiers := make([][]*Process, 0)
+	for start := 0; start < len(procs); {
+		end := start
+		for end < len(procs) && procs[end].GetPriority() == procs[start].GetPriority() {
+			end++
+		}
+		tiers = append(tiers, procs[start:end])
+		start = end
+	}
+	return tiers
+}
+
+// handle the processes concurrently and wait for all of them to be completed
+func forEachProcessConcurrently(procs []*Process, procFunc func(p *Process)) {
+	var wg sync.WaitGroup
+	for _, proc := range procs {
+		wg.Add(1)
+		go func(proc *Process) {
+			defer wg.Done()
+			procFunc(proc)
+		}(proc)
+	}
+	wg.Wait()
+}
+
 func (pm *Manager) getAllProcess() []*Process {
 	tmpProcs := make([]*Process, 0)
 	for _, proc := range pm.procs {
@@ -183,21 +529,134 @@ func (pm *Manager) getAllProcess() []*Process {
 	return sortProcess(tmpProcs)
 }
 
-// StopAllProcesses stop all the processes managed by this manager
+// StopAllProcesses stop all the processes managed by this manager in the descending order of
+// their priority. The processes with the same priority are stopped concurrently
 func (pm *Manager) StopAllProcesses() {
-	var wg sync.WaitGroup
+	done := make(chan *Process)
+	n := pm.AsyncForEachProcessByReversePriority(func(proc *Process) {
+		proc.Stop(true)
+	}, done)
+	for i := 0; i < n; i++ {
+		<-done
+	}
+}
 
-	pm.ForEachProcess(func(proc *Process) {
-		wg.Add(1)
+// restartDependents restart the running programs which depend on the restarted program in
+// dependency order if "restart_dependents" of the restarted program is true
+func (pm *Manager) restartDependents(proc *Process) {
+	if !proc.config.GetBool("restart_dependents", false) {
+		return
+	}
+	name := proc.GetName()
+	pm.lock.Lock()
+	if pm.restartingDependents[name] {
+		pm.lock.Unlock()
+		zap.S().Infow("the dependents are being restarted, don't restart them again", "program", name)
+		return
+	}
+	if lastTime, ok := pm.dependentsRestartTimes[name]; ok && time.Since(lastTime) < minDependentsRestartInterval {
+		pm.lock.Unlock()
+		zap.S().Warnw("the program restarts too frequently, don't restart its dependents", "program", name)
+		return
+	}
+	pm.dependentsRestartTimes[name] = time.Now()
+	pm.restartingDependents[name] = true
+	dependents := pm.getDependents(proc)
+	pm.lock.Unlock()
 
-		go func(wg *sync.WaitGroup) {
-			defer wg.Done()
+	defer func() {
+		pm.lock.Lock()
+		defer pm.lock.Unlock()
+		delete(pm.restartingDependents, name)
+	}()
 
-			proc.Stop(true)
-		}(&wg)
+	for _, dependent := range dependents {
+		if dependent.GetState() != Running {
+			continue
+		}
+		zap.S().Infow("restart the dependent program", "program", dependent.GetName(), "dependsOn", name)
+		dependent.Stop(true)
+		dependent.waitStartLoopExit(10 * time.Second)
+		dependent.Start(true)
+	}
+}
+
+// get the programs which depend on proc directly or through other programs, in dependency
+// order. The dependents of a program with restart_dependents are not walked, they are restarted
+// after that program is restarted. The manager lock must be held
+func (pm *Manager) getDependents(proc *Process) []*Process {
+	all := pm.getAllProcess()
+	dependents := make([]*Process, 0)
+	walked := map[*Process]bool{proc: true}
+	for queue := []*Process{proc}; len(queue) > 0; queue = queue[1:] {
+		if queue[0] != proc && queue[0].config.GetBool("restart_dependents", false) {
+			continue
+		}
+		for _, p := range all {
+			if !walked[p] && dependsOn(p, queue[0]) {
+				walked[p] = true
+				dependents = append(dependents, p)
+				queue = append(queue, p)
+			}
+		}
+	}
+	return dependents
+}
+
+// KillAllProcesses kill all the processes managed by this manager with SIGKILL immediately
+func (pm *Manager) KillAllProcesses() {
+	pm.ForEachProcess(func(proc *Process) {
+		proc.Kill()
 	})
+}
 
-	wg.Wait()
+// RollingRestartGroup restart the processes in a group with at most parallelism processes at a time.
+// The next batch is restarted only after all the processes in current batch come back to Running.
+// If any process fails to come back to Running, the rolling restart is aborted and the remaining
+// processes are not touched.
+//
+// Return the processes restarted successfully, the processes failed to come back to Running
+// and the processes skipped because of the abort
+func (pm *Manager) RollingRestartGroup(group string, parallelism int) (restarted []*Process, failed []*Process, skipped []*Process) {
+	restarted = make([]*Process, 0)
+	failed = make([]*Process, 0)
+	skipped = make([]*Process, 0)
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	procs := pm.FindMatch(group + ":*")
+	for start := 0; start < len(procs); start += parallelism {
+		if len(failed) > 0 {
+			skipped = append(skipped, procs[start:]...)
+			break
+		}
+		end := start + parallelism
+		if end > len(procs) {
+			end = len(procs)
+		}
+		var wg sync.WaitGroup
+		var lock sync.Mutex
+		for _, proc := range procs[start:end] {
+			wg.Add(1)
+			go func(proc *Process) {
+				defer wg.Done()
+				zap.S().Infow("rolling restart program", "program", proc.GetName(), "group", group)
+				proc.Stop(true)
+				proc.waitStartLoopExit(10 * time.Second)
+				proc.Start(true)
+				lock.Lock()
+				defer lock.Unlock()
+				if proc.GetState() == Running {
+					restarted = append(restarted, proc)
+				} else {
+					zap.S().Errorw("program fails to come back to running, abort the rolling restart", "program", proc.GetName(), "state", proc.GetState().String())
+					failed = append(failed, proc)
+				}
+			}(proc)
+		}
+		wg.Wait()
+	}
+	return
 }
 
 func sortProcess(procs []*Process) []*Process {
@@ -2,7 +2,10 @@ package process
 
 import (
 	"github.com/ochinchina/supervisord/config"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var procs *Manager = NewManager()
@@ -31,3 +34,266 @@ func TestProcMgrRemove(t *testing.T) {
 		t.Error("fail to remove process")
 	}
 }
+
+func TestGroupProcessesInStartOrder(t *testing.T) {
+	procs.Clear()
+	for _, name := range []string{"follower1", "leader", "other", "follower2"} {
+		entry := &config.Entry{ConfigDir: ".", Group: "raft", Name: "program:" + name}
+		procs.Add(name, NewProcess("supervisord", entry))
+	}
+	procs.SetGroupStartOrder("raft", []string{"leader", "follower1", "follower2"})
+	defer procs.SetGroupStartOrder("raft", nil)
+
+	groupProcs := procs.GetGroupProcesses("raft")
+	expected := []string{"leader", "follower1", "follower2", "other"}
+	if len(groupProcs) != len(expected) {
+		t.Fatalf("expect %d processes but got %d", len(expected), len(groupProcs))
+	}
+	for i, proc := range groupProcs {
+		if proc.GetName() != expected[i] {
+			t.Errorf("expect %s at position %d but got %s", expected[i], i, proc.GetName())
+		}
+	}
+}
+
+func TestGroupProcessesNotInStartOrder(t *testing.T) {
+	cfg := loadTestConfig(t, `
+[group:raft]
+programs=zeta,alpha,worker,leader
+group_start_order=leader
+[program:leader]
+command=/bin/true
+[program:alpha]
+command=/bin/true
+[program:zeta]
+command=/bin/true
+[program:worker]
+command=/bin/true
+numprocs=3
+process_name=%(program_name)s_%(process_num)d
+`)
+	procs.Clear()
+	for _, entry := range cfg.GetPrograms() {
+		procs.Add(entry.GetProgramName(), NewProcess("supervisord", entry))
+	}
+	procs.SetGroupStartOrder("raft", cfg.GetGroups()[0].GetGroupStartOrder())
+	defer procs.SetGroupStartOrder("raft", nil)
+
+	expected := []string{"leader", "zeta", "alpha", "worker_1", "worker_2", "worker_3"}
+	for n := 0; n < 10; n++ {
+		groupProcs := procs.GetGroupProcesses("raft")
+		if len(groupProcs) != len(expected) {
+			t.Fatalf("expect %d processes but got %d", len(expected), len(groupProcs))
+		}
+		for i, proc := range groupProcs {
+			if proc.GetName() != expected[i] {
+				t.Fatalf("expect %s at position %d but got %s", expected[i], i, proc.GetName())
+			}
+		}
+	}
+}
+
+func TestAsyncForEachProcessByReversePriority(t *testing.T) {
+	cfg := loadTestConfig(t, `
+[program:db]
+command=/bin/true
+priority=1
+[program:app1]
+command=/bin/true
+priority=10
+[program:app2]
+command=/bin/true
+priority=10
+[program:proxy]
+command=/bin/true
+priority=20
+`)
+	procs.Clear()
+	for _, entry := range cfg.GetPrograms() {
+		procs.Add(entry.GetProgramName(), NewProcess("supervisord", entry))
+	}
+
+	var lock sync.Mutex
+	order := make([]string, 0)
+	// app1 and app2 wait for each other, they must be handled concurrently
+	appsStarted := sync.WaitGroup{}
+	appsStarted.Add(2)
+	done := make(chan *Process)
+	n := procs.AsyncForEachProcessByReversePriority(func(proc *Process) {
+		if proc.GetPriority() == 10 {
+			appsStarted.Done()
+			appsStarted.Wait()
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, proc.GetName())
+	}, done)
+	for i := 0; i < n; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the processes with the same priority are not handled concurrently")
+		}
+	}
+
+	if n != 4 || len(order) != 4 {
+		t.Fatalf("expect 4 processes handled but got %v", order)
+	}
+	if order[0] != "proxy" || order[3] != "db" {
+		t.Errorf("expect the processes handled in the descending order of priority but got %v", order)
+	}
+}
+
+func TestAsyncForEachProcessWithLimit(t *testing.T) {
+	procs.Clear()
+	for _, name := range []string{"p1", "p2", "p3", "p4", "p5"} {
+		entry := &config.Entry{ConfigDir: ".", Group: name, Name: "program:" + name}
+		procs.Add(name, NewProcess("supervisord", entry))
+	}
+
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	done := make(chan *Process)
+	n := procs.AsyncForEachProcessWithLimit(func(proc *Process) {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+	}, done, 2)
+	handled := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		select {
+		case proc := <-done:
+			handled[proc.GetName()] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("not all the processes are handled")
+		}
+	}
+	if n != 5 || len(handled) != 5 {
+		t.Errorf("expect 5 processes handled but got %v", handled)
+	}
+	if maxRunning != 2 {
+		t.Errorf("expect at most 2 processes handled at the same time but got %d", maxRunning)
+	}
+}
+
+func TestForEachProcessGroupedByPriority(t *testing.T) {
+	cfg := loadTestConfig(t, `
+[program:db]
+command=/bin/true
+priority=1
+[program:app1]
+command=/bin/true
+priority=10
+[program:app2]
+command=/bin/true
+priority=10
+[program:proxy]
+command=/bin/true
+priority=20
+`)
+	procs.Clear()
+	for _, entry := range cfg.GetPrograms() {
+		procs.Add(entry.GetProgramName(), NewProcess("supervisord", entry))
+	}
+
+	tiers := procs.GetProcessesGroupedByPriority()
+	if len(tiers) != 3 || len(tiers[0]) != 1 || len(tiers[1]) != 2 || len(tiers[2]) != 1 {
+		t.Fatalf("expect the processes bucketed by 3 priorities but got %v", tiers)
+	}
+
+	var lock sync.Mutex
+	order := make([]string, 0)
+	// app1 and app2 wait for each other, they must be handled concurrently
+	appsStarted := sync.WaitGroup{}
+	appsStarted.Add(2)
+	finished := make(chan struct{})
+	go func() {
+		procs.ForEachProcessGroupedByPriority(func(proc *Process) {
+			if proc.GetPriority() == 10 {
+				appsStarted.Done()
+				appsStarted.Wait()
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			order = append(order, proc.GetName())
+		})
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the processes with the same priority are not handled concurrently")
+	}
+
+	if len(order) != 4 || order[0] != "db" || order[3] != "proxy" {
+		t.Errorf("expect the processes handled in the ascending order of priority but got %v", order)
+	}
+}
+
+func TestFindMatchNumprocs(t *testing.T) {
+	cfg := loadTestConfig(t, "[program:worker]\ncommand=/bin/true\nnumprocs=2\nprocess_name=%(program_name)s_%(process_num)02d\n")
+	procs.Clear()
+	for _, entry := range cfg.GetPrograms() {
+		procs.CreateProcess("supervisord", entry)
+	}
+
+	if found := procs.FindMatch("worker_02"); len(found) != 1 || found[0].GetName() != "worker_02" {
+		t.Errorf("expect to find worker_02 by its name but got %v", found)
+	}
+	if found := procs.FindMatch("worker:worker_01"); len(found) != 1 || found[0].GetName() != "worker_01" {
+		t.Errorf("expect to find worker_01 in the group but got %v", found)
+	}
+	if found := procs.FindMatch("worker:*"); len(found) != 2 {
+		t.Errorf("expect to find the 2 processes of the group but got %v", found)
+	}
+}
+
+func TestDependenciesOfNumprocsProgram(t *testing.T) {
+	cfg := loadTestConfig(t, `
+[program:web]
+command=/bin/true
+numprocs=2
+process_name=%(program_name)s_%(process_num)02d
+[program:proxy]
+command=/bin/true
+depends_on=web
+restart_dependents=true
+[program:monitor]
+command=/bin/true
+depends_on=proxy
+[program:alert]
+command=/bin/true
+depends_on=monitor
+`)
+	procs.Clear()
+	for _, entry := range cfg.GetPrograms() {
+		procs.CreateProcess("supervisord", entry)
+	}
+
+	deps := procs.getDependencies(procs.Find("proxy"))
+	if len(deps) != 2 || deps[0].config.GetSectionProgramName() != "web" || deps[1].config.GetSectionProgramName() != "web" {
+		t.Errorf("expect proxy depends on the 2 processes of web but got %v", deps)
+	}
+
+	names := make([]string, 0)
+	for _, dependent := range procs.getDependents(procs.Find("web_01")) {
+		names = append(names, dependent.GetName())
+	}
+	if strings.Join(names, ",") != "proxy" {
+		t.Errorf("expect only proxy is walked because it restarts its own dependents but got %v", names)
+	}
+	names = names[:0]
+	for _, dependent := range procs.getDependents(procs.Find("proxy")) {
+		names = append(names, dependent.GetName())
+	}
+	if strings.Join(names, ",") != "monitor,alert" {
+		t.Errorf("expect the dependents of proxy are walked transitively in dependency order but got %v", names)
+	}
+}
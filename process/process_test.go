@@ -0,0 +1,462 @@
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ochinchina/supervisord/config"
+	"github.com/ochinchina/supervisord/events"
+)
+
+// load the program test from the configuration
+func loadTestProgram(t *testing.T, content string) *config.Entry {
+	return loadTestConfig(t, content).GetProgram("test")
+}
+
+// load the configuration from content
+func loadTestConfig(t *testing.T, content string) *config.Config {
+	f, err := ioutil.TempFile("", "supervisord-*.conf")
+	if err != nil {
+		t.Fatalf("fail to create the configuration file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(content)
+	f.Close()
+
+	cfg := config.NewConfig(f.Name())
+	if _, err = cfg.Load(); err != nil {
+		t.Fatalf("fail to load the configuration: %v", err)
+	}
+	return cfg
+}
+
+func TestNextRetryPauseBackoff(t *testing.T) {
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/true\nbackoff_initial=1s\nbackoff_max=5s\n")
+	proc := NewProcess("supervisord", entry)
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, pause := range expected {
+		if got := proc.nextRetryPause(0); got != pause {
+			t.Errorf("expect the pause %v before retry %d but got %v", pause, i+1, got)
+		}
+	}
+}
+
+func TestNextRetryPauseWithoutBackoff(t *testing.T) {
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/true\n")
+	proc := NewProcess("supervisord", entry)
+
+	for i := 0; i < 3; i++ {
+		if got := proc.nextRetryPause(3 * time.Second); got != 3*time.Second {
+			t.Errorf("expect the restartpause 3s but got %v", got)
+		}
+	}
+}
+
+func TestSetEnvFromEnvFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "env-*")
+	if err != nil {
+		t.Fatalf("fail to create the env file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("export SECRET=old\nTOKEN=abc\n")
+	f.Close()
+
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/true\nenv_file="+f.Name()+"\nenvironment=TOKEN=override\n")
+	proc := NewProcess("supervisord", entry)
+	getEnv := func(cmd *exec.Cmd, key string) string {
+		// the last one takes effect if a variable is set more than one time
+		value := ""
+		for _, kv := range cmd.Env {
+			if strings.HasPrefix(kv, key+"=") {
+				value = kv[len(key)+1:]
+			}
+		}
+		return value
+	}
+
+	cmd := exec.Command("/bin/true")
+	if err := proc.setEnv(cmd); err != nil {
+		t.Fatalf("fail to set the environment: %v", err)
+	}
+	if getEnv(cmd, "SECRET") != "old" || getEnv(cmd, "TOKEN") != "override" {
+		t.Errorf("unexpected environment %v", cmd.Env)
+	}
+
+	// the env file is read again when the program is restarted
+	ioutil.WriteFile(f.Name(), []byte("SECRET=new\n"), 0600)
+	if err := proc.setEnv(cmd); err != nil || getEnv(cmd, "SECRET") != "new" {
+		t.Errorf("the rotated env file is not read, error: %v", err)
+	}
+
+	os.Remove(f.Name())
+	if err := proc.setEnv(cmd); err == nil {
+		t.Error("expect an error if the env file is missing")
+	}
+}
+
+func TestStopEscalatesToKillSignal(t *testing.T) {
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/sh -c \"trap '' TERM; exec sleep 30\"\nstartsecs=1\nstopsignal=TERM\nstopwaitsecs=1\n")
+	proc := NewProcess("supervisord", entry)
+	proc.Start(true)
+	if proc.GetState() != Running {
+		t.Fatalf("fail to start the program, it is %v", proc.GetState())
+	}
+
+	proc.Stop(true)
+	for i := 0; i < 100 && proc.GetState() != Exited; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if desc := proc.GetDescription(); proc.GetState() != Exited || !strings.HasSuffix(desc, ", killed by SIGKILL, not stopped in stopwaitsecs") {
+		t.Errorf("expect the program is killed after stopwaitsecs, state %v, description %q", proc.GetState(), desc)
+	}
+}
+
+func TestAutoRestartUnexpected(t *testing.T) {
+	tests := []struct {
+		config   string
+		exitCode string
+		restart  bool
+	}{
+		{"autorestart=unexpected\nexitcodes=0, 3\n", "3", false},
+		{"autorestart=unexpected\nexitcodes=0, 3\n", "0", false},
+		{"autorestart=unexpected\nexitcodes=0, 3\n", "1", true},
+		{"exitcodes=0,3\n", "2", true},
+		{"autorestart=true\nexitcodes=0,3\n", "3", true},
+		{"autorestart=false\n", "1", false},
+	}
+	for _, test := range tests {
+		proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\n"+test.config))
+		proc.cmd = exec.Command("/bin/sh", "-c", "exit "+test.exitCode)
+		proc.cmd.Run()
+		if proc.isAutoRestart() != test.restart {
+			t.Errorf("expect restart %v after exit code %s with %q", test.restart, test.exitCode, test.config)
+		}
+	}
+}
+
+func TestStartWithTimeout(t *testing.T) {
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/sleep 30\nstartsecs=5\n")
+	proc := NewProcess("supervisord", entry)
+	defer proc.Stop(true)
+
+	begin := time.Now()
+	err := proc.StartWithTimeout(200 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "Starting") {
+		t.Errorf("expect an error with the Starting state but got %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Errorf("expect to give up waiting after the timeout but waited %v", elapsed)
+	}
+	if proc.GetState() != Starting {
+		t.Errorf("expect the program is still starting but it is %v", proc.GetState())
+	}
+}
+
+func TestGetDescription(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\n"))
+	if desc := proc.GetDescription(); desc != "Not started" {
+		t.Errorf("expect the description of the program never started but got %q", desc)
+	}
+
+	proc.cmd = exec.Command("/bin/sh", "-c", "exit 3")
+	proc.cmd.Run()
+	proc.stopTime = time.Date(2020, 10, 16, 15, 4, 0, 0, time.Local)
+	proc.state = Exited
+	if desc := proc.GetDescription(); desc != "Oct 16 03:04 PM, exit status 3" {
+		t.Errorf("unexpected description of the exited program %q", desc)
+	}
+
+	proc.cmd = exec.Command("/bin/sh", "-c", "kill -9 $$")
+	proc.cmd.Run()
+	if desc := proc.GetDescription(); desc != "Oct 16 03:04 PM, terminated by signal 9 (killed)" {
+		t.Errorf("unexpected description of the killed program %q", desc)
+	}
+
+	proc.state = Fatal
+	if desc := proc.GetDescription(); desc != "Exited too quickly (process log may have details)" {
+		t.Errorf("unexpected description of the fatal program %q", desc)
+	}
+	proc.spawnError = "fail to start program with error:no such file"
+	if desc := proc.GetDescription(); desc != proc.spawnError {
+		t.Errorf("expect the spawn error in the description of the fatal program but got %q", desc)
+	}
+}
+
+func TestGetArgv(t *testing.T) {
+	os.Setenv("SUPERVISORD_TEST_ARG", "substituted")
+	defer os.Unsetenv("SUPERVISORD_TEST_ARG")
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sh -c \"exit 0\" %(ENV_SUPERVISORD_TEST_ARG)s\nautorestart=false\nstartsecs=0\n"))
+	expected := []string{"/bin/sh", "-c", "exit 0", "substituted"}
+	if argv := proc.GetArgv(); strings.Join(argv, "|") != strings.Join(expected, "|") {
+		t.Errorf("expect the argv %q before the program is started but got %q", expected, argv)
+	}
+
+	proc.Start(true)
+	if argv := proc.GetArgv(); strings.Join(argv, "|") != strings.Join(expected, "|") {
+		t.Errorf("expect the argv %q after the program is started but got %q", expected, argv)
+	}
+}
+
+func TestDirectoryExpansion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisord-dir-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("SUPERVISORD_TEST_DIR", dir)
+	defer os.Unsetenv("SUPERVISORD_TEST_DIR")
+
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\ndirectory=%(ENV_SUPERVISORD_TEST_DIR)s\n"))
+	cmd := exec.Command("/bin/true")
+	if err := proc.setDir(cmd); err != nil || cmd.Dir != dir {
+		t.Errorf("expect the directory %s but got %s, error: %v", dir, cmd.Dir, err)
+	}
+
+	home, err := PathExpand("~")
+	if err != nil {
+		t.Skipf("the home directory is not available: %v", err)
+	}
+	proc = NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\ndirectory=~\n"))
+	if err := proc.setDir(cmd); err != nil || cmd.Dir != home {
+		t.Errorf("expect the home directory %s but got %s, error: %v", home, cmd.Dir, err)
+	}
+
+	proc = NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\ndirectory="+dir+"/missing\nstartretries=0\n"))
+	proc.Start(true)
+	if proc.GetState() != Fatal || !strings.Contains(proc.GetDescription(), "does not exist") {
+		t.Errorf("expect the program fails to start in the missing directory, state %v, description %q", proc.GetState(), proc.GetDescription())
+	}
+}
+
+func TestMaxLifetimeRestart(t *testing.T) {
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/sleep 30\nstartsecs=1\nmax_lifetime_secs=2\nflap_max=1\n")
+	proc := NewProcess("supervisord", entry)
+	defer proc.Stop(true)
+	proc.Start(true)
+	if proc.GetState() != Running {
+		t.Fatalf("fail to start the program, it is %v", proc.GetState())
+	}
+	pid := proc.GetPid()
+	if restartTime := proc.GetScheduledRestartTime(); !restartTime.Equal(proc.GetStartTime().Add(2 * time.Second)) {
+		t.Errorf("expect the restart is scheduled 2s after the start but it is at %v", restartTime)
+	}
+
+	for i := 0; i < 100 && (proc.GetPid() == pid || proc.GetState() != Running); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if proc.GetState() != Running || proc.GetPid() == pid {
+		t.Fatalf("expect the program is restarted after max_lifetime_secs, state %v", proc.GetState())
+	}
+	if proc.GetFlapCount() != 0 {
+		t.Errorf("expect the scheduled restart is not counted as flapping but got %d", proc.GetFlapCount())
+	}
+}
+
+func TestStateHistory(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sh -c \"sleep 1.5; exit 3\"\nstartsecs=1\nautorestart=false\n"))
+	proc.Start(true)
+	for i := 0; i < 100 && proc.GetState() != Exited; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	expected := []StateTransition{{From: Stopped, To: Starting, ExitStatus: -1},
+		{From: Starting, To: Running, ExitStatus: -1},
+		{From: Running, To: Exited, ExitStatus: 3}}
+	history := proc.GetStateHistory()
+	if len(history) != len(expected) {
+		t.Fatalf("expect %d state transitions but got %v", len(expected), history)
+	}
+	for i, transition := range history {
+		if transition.From != expected[i].From || transition.To != expected[i].To || transition.ExitStatus != expected[i].ExitStatus {
+			t.Errorf("expect the state transition %v but got %v", expected[i], transition)
+		}
+		if transition.Time.IsZero() {
+			t.Errorf("the time of the state transition %v is not set", transition)
+		}
+	}
+
+	proc.setStateHistorySize(2)
+	if history = proc.GetStateHistory(); len(history) != 2 || history[1].To != Exited {
+		t.Errorf("expect the last 2 state transitions are kept but got %v", history)
+	}
+}
+
+func TestSignalProgramAsGroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisord-group-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	childPidFile := dir + "/child.pid"
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/sh -c \"sleep 30 & echo $! > "+childPidFile+"; wait\"\nstartsecs=1\nautorestart=false\nkillasgroup=true\n")
+	proc := NewProcess("supervisord", entry)
+	defer proc.Stop(true)
+	proc.Start(true)
+	content, err := ioutil.ReadFile(childPidFile)
+	if err != nil {
+		t.Fatalf("the child of the program is not started: %v", err)
+	}
+	childPid, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+
+	if err := proc.SignalProgram(syscall.SIGTERM); err != nil {
+		t.Fatalf("fail to signal the program: %v", err)
+	}
+	childExited := false
+	for i := 0; i < 100 && !childExited; i++ {
+		time.Sleep(20 * time.Millisecond)
+		// the exited child may be a zombie until it is reaped
+		stat, err := ioutil.ReadFile("/proc/" + strconv.Itoa(childPid) + "/stat")
+		childExited = syscall.Kill(childPid, 0) != nil || (err == nil && strings.Contains(string(stat), ") Z "))
+	}
+	if !childExited {
+		syscall.Kill(childPid, syscall.SIGKILL)
+		t.Error("expect the signal is sent to the child of the program with killasgroup")
+	}
+}
+
+func TestUnlimitedStartRetries(t *testing.T) {
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/false\nstartsecs=1\nstartretries=infinite\nflap_max=2\nflap_cooldown_secs=60\n")
+	proc := NewProcess("supervisord", entry)
+	defer proc.Stop(true)
+	if proc.GetRetriesRemaining() != -1 {
+		t.Errorf("expect -1 retries remaining if startretries is infinite but got %d", proc.GetRetriesRemaining())
+	}
+	proc.Start(false)
+	for i := 0; i < 100 && proc.GetState() != Cooldown && proc.GetState() != Fatal; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if proc.GetState() != Cooldown {
+		t.Errorf("expect the program failing to start forever enters Cooldown but it is %v", proc.GetState())
+	}
+	if retries := atomic.LoadInt32(proc.retryTimes); retries != 3 {
+		t.Errorf("expect 3 start attempts before the cooldown but got %d", retries)
+	}
+}
+
+func TestFlushPartialLineOnExit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisord-log-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	entry := loadTestProgram(t, "[program:test]\ncommand=/bin/sh -c \"sleep 1.2; printf 'last words'; printf 'on stderr' >&2\"\nstartsecs=1\nautorestart=false\nstdout_logfile="+dir+"/stdout.log\nstderr_logfile="+dir+"/stderr.log\nready_regex=never\n")
+	proc := NewProcess("supervisord", entry)
+	proc.Start(true)
+	for i := 0; i < 100 && proc.GetState() != Exited; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if proc.GetState() != Exited {
+		t.Fatalf("expect the program exited but it is %v", proc.GetState())
+	}
+	for file, expected := range map[string]string{"stdout.log": "last words", "stderr.log": "on stderr"} {
+		if content, err := ioutil.ReadFile(dir + "/" + file); err != nil || string(content) != expected {
+			t.Errorf("expect the partial line %q in %s but got %q, error: %v", expected, file, content, err)
+		}
+	}
+}
+
+func TestStartSecsZeroQuickExit(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\nstartsecs=0\nautorestart=unexpected\n"))
+	proc.Start(true)
+	for i := 0; i < 100 && proc.GetState() != Exited; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if proc.GetState() != Exited || proc.GetExitstatus() != 0 {
+		t.Fatalf("expect the program exited with 0 but it is %v with %d", proc.GetState(), proc.GetExitstatus())
+	}
+
+	expected := []State{Starting, Running, Exited}
+	history := proc.GetStateHistory()
+	if len(history) != len(expected) {
+		t.Fatalf("expect the quick exit is not a start failure but got %v", history)
+	}
+	for i, transition := range history {
+		if transition.To != expected[i] {
+			t.Errorf("expect the state %v but got %v", expected[i], transition.To)
+		}
+	}
+}
+
+func TestStartSecsZeroLongLived(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sleep 10\nstartsecs=0\n"))
+	start := time.Now()
+	proc.Start(true)
+	if proc.GetState() != Running {
+		t.Fatalf("expect the program is running once it is started but it is %v", proc.GetState())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expect the program is running without waiting but it takes %v", elapsed)
+	}
+	proc.Stop(true)
+	if proc.GetState() != Exited {
+		t.Errorf("expect the program exits after it is stopped but it is %v", proc.GetState())
+	}
+}
+
+func TestSetEnvWithoutInheritance(t *testing.T) {
+	os.Setenv("SUPERVISORD_TEST_LEAKED", "leaked")
+	defer os.Unsetenv("SUPERVISORD_TEST_LEAKED")
+
+	for _, inherit := range []bool{true, false} {
+		proc := NewProcess("supervisord", loadTestProgram(t, fmt.Sprintf("[program:test]\ncommand=/bin/true\ninherit_env=%v\nenvironment=FOO=\"bar\"\n", inherit)))
+		cmd := exec.Command("/bin/true")
+		if err := proc.setEnv(cmd); err != nil {
+			t.Fatalf("fail to set the environment: %v", err)
+		}
+		env := strings.Join(cmd.Env, "\n") + "\n"
+		if !strings.Contains(env, "FOO=bar\n") || !strings.Contains(env, "PATH="+os.Getenv("PATH")+"\n") {
+			t.Errorf("expect the declared variables and PATH if inherit_env=%v but got %v", inherit, cmd.Env)
+		}
+		if leaked := strings.Contains(env, "SUPERVISORD_TEST_LEAKED=leaked\n"); leaked != inherit {
+			t.Errorf("expect the supervisord variable is passed %v if inherit_env=%v but got %v", inherit, inherit, cmd.Env)
+		}
+	}
+}
+
+func TestStartWithMissingSecretFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisord-secret-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sleep 10\nenvironment=DB_PASSWORD=%(file:"+dir+"/db)s\n"))
+	proc.Start(true)
+	if proc.GetState() != Fatal || !strings.Contains(proc.GetDescription(), dir+"/db") {
+		t.Errorf("expect the program fails to start with the missing file but it is %v: %s", proc.GetState(), proc.GetDescription())
+	}
+}
+
+func TestResetCountersOfFatalProgram(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/nonexistent/program\nstartretries=0\n"))
+	proc.Start(true)
+	if proc.GetState() != Fatal {
+		t.Fatalf("expect the program fails to start but it is %v", proc.GetState())
+	}
+	if !strings.Contains(proc.GetSpawnError(), "fail to start program") {
+		t.Errorf("expect the spawn error of the fatal program but got %q", proc.GetSpawnError())
+	}
+	stoppedEvents := make([]string, 0)
+	unsubscribe := events.Subscribe([]string{"PROCESS_STATE_STOPPED"}, func(event events.Event) {
+		stoppedEvents = append(stoppedEvents, event.GetBody())
+	})
+	defer unsubscribe()
+
+	if err := proc.ResetCounters(); err != nil || proc.GetState() != Stopped {
+		t.Fatalf("expect the program is stopped by resetting its counters, state %v, error %v", proc.GetState(), err)
+	}
+	if len(stoppedEvents) != 1 || !strings.Contains(stoppedEvents[0], "from_state:Fatal") {
+		t.Errorf("expect the PROCESS_STATE_STOPPED event from FATAL but got %v", stoppedEvents)
+	}
+	if proc.GetSpawnError() != "" {
+		t.Errorf("expect no spawn error after the program is stopped but got %q", proc.GetSpawnError())
+	}
+}
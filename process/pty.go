@@ -0,0 +1,47 @@
+// +build linux darwin
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// attach the command to a new pseudo-terminal as its controlling terminal. The stdin, stdout
+// and stderr of the command are the pty slave, which should be closed after the command starts
+func attachPty(cmd *exec.Cmd) (master *os.File, slave *os.File, err error) {
+	master, slave, err = openPty()
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	// a new session is created for the controlling terminal, it is also a new process group
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+	return master, slave, nil
+}
+
+// call ioctl on the file without changing it to blocking mode, so a pending read can be
+// interrupted by closing the file
+func ptyIoctl(f *os.File, request uintptr, arg uintptr) error {
+	rawConn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = rawConn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
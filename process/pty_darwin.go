@@ -0,0 +1,37 @@
+// +build darwin
+
+package process
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// open a pseudo-terminal and return its master and slave
+func openPty() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := make([]byte, 128)
+	if err = ptyIoctl(master, syscall.TIOCPTYGRANT, 0); err == nil {
+		if err = ptyIoctl(master, syscall.TIOCPTYUNLK, 0); err == nil {
+			err = ptyIoctl(master, syscall.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0])))
+		}
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	if i := bytes.IndexByte(name, 0); i != -1 {
+		name = name[0:i]
+	}
+	slave, err := os.OpenFile(string(name), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
@@ -0,0 +1,33 @@
+// +build linux
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// open a pseudo-terminal and return its master and slave
+func openPty() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ptyNum uint32
+	unlock := int32(0)
+	if err = ptyIoctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&ptyNum))); err == nil {
+		err = ptyIoctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock)))
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", ptyNum), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
@@ -0,0 +1,14 @@
+// +build !linux,!darwin
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// the pseudo-terminal is not supported on this platform
+func attachPty(cmd *exec.Cmd) (*os.File, *os.File, error) {
+	return nil, nil, fmt.Errorf("pty is not supported on this platform")
+}
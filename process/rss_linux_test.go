@@ -0,0 +1,18 @@
+// +build linux
+
+package process
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGetProcessRSS(t *testing.T) {
+	rss, err := getProcessRSS(os.Getpid())
+	if err != nil || rss <= 0 {
+		t.Errorf("fail to get the memory of current process: %d, %v", rss, err)
+	}
+	if _, err := getProcessRSS(-1); err == nil {
+		t.Error("expect error for the process not existing")
+	}
+}
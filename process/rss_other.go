@@ -0,0 +1,13 @@
+// +build !linux
+
+package process
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// get the resident set size of the process, only supported on linux
+func getProcessRSS(pid int) (int64, error) {
+	return 0, fmt.Errorf("reading the memory usage of process is not supported on %s", runtime.GOOS)
+}
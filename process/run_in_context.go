@@ -0,0 +1,89 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the max bytes of the stdout and stderr each kept by RunInContext, the rest is discarded
+const maxRunInContextOutputBytes = 1024 * 1024
+
+// limitedBuffer an io.Writer keeps the first max bytes written to it and discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+// Write keep the data until max bytes are kept, the data is always accepted so the command is not
+// failed by a broken pipe
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.max - b.buf.Len(); len(p) > remain {
+		b.buf.Write(p[:remain])
+		b.truncated = true
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// String get the kept data, with a note if the rest is discarded
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + fmt.Sprintf("\n[output truncated to %d bytes]", b.max)
+	}
+	return b.buf.String()
+}
+
+// RunInContext run a transient command with the same environment, user, working directory, umask
+// and cgroup as the program, the running program is not touched. The command is killed if it does
+// not exit in timeout, and at most 1MB of its stdout and stderr each is kept.
+//
+// Return the stdout, stderr and exit code of the command
+func (p *Process) RunInContext(command []string, timeout time.Duration) (stdout string, stderr string, exitCode int, err error) {
+	if len(command) <= 0 {
+		return "", "", -1, fmt.Errorf("no command to run")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if err = p.setUser(cmd); err != nil {
+		return "", "", -1, fmt.Errorf("fail to set user: %v", err)
+	}
+	setDeathsig(cmd.SysProcAttr)
+	if err = p.setEnv(cmd); err != nil {
+		return "", "", -1, fmt.Errorf("fail to set the environment: %v", err)
+	}
+	if err = p.setDir(cmd); err != nil {
+		return "", "", -1, fmt.Errorf("fail to set the directory: %v", err)
+	}
+	stdoutBuf := &limitedBuffer{max: maxRunInContextOutputBytes}
+	stderrBuf := &limitedBuffer{max: maxRunInContextOutputBytes}
+	cmd.Stdout = stdoutBuf
+	cmd.Stderr = stderrBuf
+
+	zap.S().Infow("run command in the program context", "program", p.GetName(), "command", command)
+	p.lock.Lock()
+	err = p.startWithResourceLimits(cmd, p.config.GetOctal("umask", -1))
+	p.lock.Unlock()
+	if err == nil {
+		err = cmd.Wait()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return stdoutBuf.String(), stderrBuf.String(), -1, fmt.Errorf("command does not exit in %v", timeout)
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return stdoutBuf.String(), stderrBuf.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return stdoutBuf.String(), stderrBuf.String(), -1, err
+	}
+	return stdoutBuf.String(), stderrBuf.String(), 0, nil
+}
@@ -0,0 +1,65 @@
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunInContextWithMissingEnvFile(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sleep 10\nenv_file=/nonexistent/test.env\n"))
+	_, _, exitCode, err := proc.RunInContext([]string{"/bin/true"}, 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "env_file") || exitCode != -1 {
+		t.Errorf("expect the command is not run without the env_file but got %d, %v", exitCode, err)
+	}
+}
+
+func TestRunInContextWithMissingDirectory(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sleep 10\ndirectory=/nonexistent/test\n"))
+	_, _, exitCode, err := proc.RunInContext([]string{"/bin/pwd"}, 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "directory") || exitCode != -1 {
+		t.Errorf("expect the command is not run without its directory but got %d, %v", exitCode, err)
+	}
+}
+
+func TestRunInContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "run-in-context")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sleep 10\n"+
+		"environment=GREETING=hello\ndirectory="+dir+"\numask=077\n"))
+
+	stdout, stderr, exitCode, err := proc.RunInContext([]string{"/bin/sh", "-c", "echo $GREETING; pwd; umask; echo oops >&2; exit 3"}, 5*time.Second)
+	if err != nil || exitCode != 3 {
+		t.Fatalf("expect the exit code 3 but got %d, %v", exitCode, err)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 3 || lines[0] != "hello" || lines[1] != dir || lines[2] != "0077" {
+		t.Errorf("expect the environment, directory and umask of the program but got %q", stdout)
+	}
+	if stderr != "oops\n" {
+		t.Errorf("expect the stderr of the command but got %q", stderr)
+	}
+
+	start := time.Now()
+	if _, _, exitCode, err = proc.RunInContext([]string{"/bin/sleep", "10"}, 100*time.Millisecond); err == nil || exitCode != -1 {
+		t.Errorf("expect the command is killed after the timeout but got %d, %v", exitCode, err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("expect the command is killed soon after the timeout")
+	}
+}
+
+func TestRunInContextOutputLimit(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sleep 10\n"))
+	stdout, _, exitCode, err := proc.RunInContext([]string{"/bin/sh", "-c", "head -c 3000000 /dev/zero"}, 10*time.Second)
+	if err != nil || exitCode != 0 {
+		t.Fatalf("expect the command exits normally but got %d, %v", exitCode, err)
+	}
+	if !strings.HasPrefix(stdout[maxRunInContextOutputBytes:], "\n[output truncated") || len(stdout) > maxRunInContextOutputBytes+100 {
+		t.Errorf("expect the stdout is truncated to %d bytes but got %d bytes", maxRunInContextOutputBytes, len(stdout))
+	}
+}
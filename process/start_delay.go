@@ -0,0 +1,34 @@
+package process
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the monotonic clock reading when supervisord is started, the startdelay is counted from it
+var bootMonotonic = processClock.monotonic()
+
+// wait until startdelay seconds elapse since supervisord is started, so the program started
+// earlier (autostarted or started explicitly) is delayed. Return false if the program is
+// stopped in the delay
+func (p *Process) waitStartDelay() bool {
+	delay := time.Duration(p.config.GetInt("startdelay", 0)) * time.Second
+	remaining := bootMonotonic + delay - processClock.monotonic()
+	if remaining <= 0 {
+		return true
+	}
+	zap.S().Infow(fmt.Sprintf("delay the start of the program by startdelay, start it after %v", remaining.Round(time.Second)), "program", p.GetName())
+	p.lock.Lock()
+	p.inStartDelay = true
+	abortDelay := p.abortBackoff
+	p.lock.Unlock()
+
+	sleepUntilAborted(remaining, abortDelay)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.inStartDelay = false
+	return !p.stopByUser
+}
@@ -0,0 +1,44 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitStartDelay(t *testing.T) {
+	fake := &fakeClock{wall: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), mono: bootMonotonic + 2900*time.Millisecond}
+	useFakeClock(t, fake)
+
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\nstartdelay=3\n"))
+	proc.abortBackoff = make(chan struct{})
+	begin := time.Now()
+	if !proc.waitStartDelay() || time.Since(begin) < 50*time.Millisecond {
+		t.Errorf("expect to wait the rest of startdelay but waited %v", time.Since(begin))
+	}
+
+	// the program started after startdelay elapses is not delayed
+	fake.advance(time.Second)
+	begin = time.Now()
+	if !proc.waitStartDelay() || time.Since(begin) > 50*time.Millisecond {
+		t.Errorf("expect no delay after startdelay elapses but waited %v", time.Since(begin))
+	}
+}
+
+func TestStopInStartDelay(t *testing.T) {
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/sleep 30\nstartdelay=600\n"))
+	proc.Start(false)
+	for i := 0; i < 100 && proc.GetDescription() != "waiting for startdelay to start"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if proc.GetState() != Stopped || proc.GetDescription() != "waiting for startdelay to start" {
+		t.Fatalf("expect the program waits for startdelay, state %v, description %q", proc.GetState(), proc.GetDescription())
+	}
+
+	proc.Stop(true)
+	proc.waitStartLoopExit(time.Second)
+	proc.lock.RLock()
+	defer proc.lock.RUnlock()
+	if proc.inStart || proc.cmd != nil {
+		t.Errorf("expect the program is not started after it is stopped in startdelay")
+	}
+}
@@ -0,0 +1,85 @@
+package process
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultStateHistorySize the default number of the recent state transitions kept for each program
+const DefaultStateHistorySize = 20
+
+// StateTransition a state transition of the program
+type StateTransition struct {
+	Time time.Time
+	From State
+	To   State
+	// the exit status of the program if the transition is caused by the program exit, otherwise -1
+	ExitStatus int
+}
+
+// record the transition to the state in the history, the lock must be held. The oldest
+// transitions are dropped if there are more than stateHistorySize transitions
+func (p *Process) recordStateTransition(procState State) {
+	if p.stateHistorySize <= 0 {
+		return
+	}
+	exitStatus := -1
+	if p.state == Starting || p.state == Running || p.state == Stopping {
+		if exitCode, err := p.getExitCodeIfExited(); err == nil {
+			exitStatus = exitCode
+		}
+	}
+	p.stateHistory = append(p.stateHistory, StateTransition{Time: processClock.now(),
+		From:       p.state,
+		To:         procState,
+		ExitStatus: exitStatus})
+	if len(p.stateHistory) > p.stateHistorySize {
+		p.stateHistory = p.stateHistory[len(p.stateHistory)-p.stateHistorySize:]
+	}
+}
+
+// get the exit code of the started program if it exited
+func (p *Process) getExitCodeIfExited() (int, error) {
+	if p.cmd == nil {
+		return -1, fmt.Errorf("not started")
+	}
+	return p.getExitCode()
+}
+
+// set how many recent state transitions are kept, 0 to not keep any
+func (p *Process) setStateHistorySize(size int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stateHistorySize = size
+	if size <= 0 {
+		p.stateHistory = nil
+	} else if len(p.stateHistory) > size {
+		p.stateHistory = p.stateHistory[len(p.stateHistory)-size:]
+	}
+}
+
+// GetStateHistory get the recent state transitions of the program, the oldest first
+func (p *Process) GetStateHistory() []StateTransition {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return append([]StateTransition(nil), p.stateHistory...)
+}
+
+// SetStateHistorySize set how many recent state transitions are kept for each program, 0 to not
+// keep any. It applies to the programs created later too
+func (pm *Manager) SetStateHistorySize(size int) {
+	pm.lock.Lock()
+	pm.stateHistorySize = size
+	procs := make([]*Process, 0, len(pm.procs)+len(pm.eventListeners))
+	for _, proc := range pm.procs {
+		procs = append(procs, proc)
+	}
+	for _, proc := range pm.eventListeners {
+		procs = append(procs, proc)
+	}
+	pm.lock.Unlock()
+
+	for _, proc := range procs {
+		proc.setStateHistorySize(size)
+	}
+}
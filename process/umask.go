@@ -0,0 +1,26 @@
+// +build !windows
+
+package process
+
+import (
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// serialize the commands started by startCommand, so a command is never started with the umask
+// of another program
+var umaskLock sync.Mutex
+
+// start the command with the umask if it is not negative. The umask is process wide, so it is set
+// only while the command is started and the previous umask of supervisord is restored right after
+func startCommand(cmd *exec.Cmd, umask int) error {
+	umaskLock.Lock()
+	defer umaskLock.Unlock()
+	if umask < 0 {
+		return cmd.Start()
+	}
+	prevUmask := syscall.Umask(umask)
+	defer syscall.Umask(prevUmask)
+	return cmd.Start()
+}
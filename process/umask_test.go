@@ -0,0 +1,30 @@
+// +build !windows
+
+package process
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestStartCommandWithUmask(t *testing.T) {
+	prevUmask := syscall.Umask(022)
+	defer syscall.Umask(prevUmask)
+
+	var out bytes.Buffer
+	cmd := exec.Command("/bin/sh", "-c", "umask")
+	cmd.Stdout = &out
+	if err := startCommand(cmd, 0027); err != nil {
+		t.Fatalf("fail to start the command: %v", err)
+	}
+	cmd.Wait()
+	if umask := strings.TrimSpace(out.String()); umask != "0027" && umask != "027" {
+		t.Errorf("expect the command runs with umask 027 but got %s", umask)
+	}
+	if umask := syscall.Umask(022); umask != 022 {
+		t.Errorf("expect the umask of supervisord is restored to 022 but got %o", umask)
+	}
+}
@@ -0,0 +1,17 @@
+// +build windows
+
+package process
+
+import (
+	"os/exec"
+
+	"go.uber.org/zap"
+)
+
+// start the command, the umask is ignored on windows
+func startCommand(cmd *exec.Cmd, umask int) error {
+	if umask >= 0 {
+		zap.S().Warnw("umask is not supported on windows, ignore it", "command", cmd.Path)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,22 @@
+// +build linux
+
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// check if the process has exited but is not reaped by its parent yet from the state in
+// /proc/<pid>/stat. The command name in the stat may have spaces and parentheses, so the
+// state is read after the last ')'
+func isZombie(pid int) bool {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	stat := string(b)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	return len(fields) > 0 && fields[0] == "Z"
+}
@@ -0,0 +1,39 @@
+// +build linux
+
+package process
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestWaitDaemonExitOfZombie(t *testing.T) {
+	// the killed daemon is not reaped until Wait is called, so it stays a zombie
+	daemon := exec.Command("/bin/sleep", "30")
+	if err := daemon.Start(); err != nil {
+		t.Fatalf("fail to start the daemon: %v", err)
+	}
+	defer daemon.Wait()
+	daemon.Process.Kill()
+	for i := 0; i < 100 && !isZombie(daemon.Process.Pid); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !isZombie(daemon.Process.Pid) {
+		t.Fatal("expect the killed daemon is a zombie before it is reaped")
+	}
+
+	proc := NewProcess("supervisord", loadTestProgram(t, "[program:test]\ncommand=/bin/true\n"))
+	proc.cmd = daemon
+	proc.daemon = daemon.Process
+	exited := make(chan struct{})
+	go func() {
+		proc.waitDaemonExit()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(3 * time.Second):
+		t.Error("expect the zombie daemon is not waited as an alive process")
+	}
+}
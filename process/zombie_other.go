@@ -0,0 +1,8 @@
+// +build !linux
+
+package process
+
+// check if the process has exited but is not reaped by its parent yet, only supported on linux
+func isZombie(pid int) bool {
+	return false
+}
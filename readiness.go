@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/ochinchina/supervisord/process"
+)
+
+// Readiness report the readiness of the programs with ready_regex through http interface
+type Readiness struct {
+	router     *mux.Router
+	supervisor *Supervisor
+}
+
+// NewReadiness create a Readiness object
+func NewReadiness(supervisor *Supervisor) *Readiness {
+	return &Readiness{router: mux.NewRouter(), supervisor: supervisor}
+}
+
+// CreateHandler create the /readyz http handler. It responds 200 if all the programs with
+// ready_regex are ready, otherwise 503 with the programs not ready
+func (rd *Readiness) CreateHandler() http.Handler {
+	rd.router.HandleFunc("/readyz", rd.getReadiness).Methods("GET")
+	return rd.router
+}
+
+func (rd *Readiness) getReadiness(w http.ResponseWriter, req *http.Request) {
+	notReady := make([]string, 0)
+	rd.supervisor.GetManager().ForEachProcess(func(proc *process.Process) {
+		if proc.HasReadinessProbe() && !proc.IsReady() {
+			notReady = append(notReady, proc.GetName())
+		}
+	})
+	w.Header().Set("Content-Type", "text/plain")
+	if len(notReady) > 0 {
+		sort.Strings(notReady)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, name := range notReady {
+			fmt.Fprintf(w, "%s not ready\n", name)
+		}
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
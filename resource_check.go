@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ochinchina/supervisord/config"
+	"github.com/ochinchina/supervisord/logger"
+	"github.com/ochinchina/supervisord/process"
+)
+
+// checkRequiredResources check the resources required by supervisord before any program is started
+func (s *Supervisor) checkRequiredResources() error {
+	if err := s.checkResourceLimits(); err != nil {
+		return err
+	}
+	if err := s.checkHTTPServerAddress(); err != nil {
+		return err
+	}
+	if err := s.checkHTTPServerTLS(); err != nil {
+		return err
+	}
+	if supervisordConf, ok := s.config.GetSupervisord(); ok && supervisordConf.GetBool("check_writable_paths", false) {
+		if problems := s.checkWritablePaths(); len(problems) > 0 {
+			return fmt.Errorf("following paths are not writable:\n%s", strings.Join(problems, "\n"))
+		}
+	}
+	return nil
+}
+
+// checkHTTPServer check the http server configuration before the http server is restarted on
+// reload, the http server keeps running with the previous configuration if it is invalid
+func (s *Supervisor) checkHTTPServer() error {
+	if httpServerConfig, ok := s.config.GetInetHTTPServer(); ok {
+		// the hostname is resolved too, it may fail temporarily at runtime
+		if addr := httpServerConfig.GetString("port", ""); addr != "" {
+			if _, err := resolveInetListenAddrs(addr); err != nil {
+				return err
+			}
+		}
+	}
+	return s.checkHTTPServerTLS()
+}
+
+// checkHTTPServerAddress check if the port of [inet_http_server] is a valid address to listen on
+func (s *Supervisor) checkHTTPServerAddress() error {
+	httpServerConfig, ok := s.config.GetInetHTTPServer()
+	if !ok {
+		return nil
+	}
+	if addr := httpServerConfig.GetString("port", ""); addr != "" {
+		_, _, err := parseInetListenAddr(addr)
+		return err
+	}
+	return nil
+}
+
+// checkHTTPServerTLS check if both tls_cert_file and tls_key_file of [inet_http_server] are set
+// and can be loaded, or neither is set. The http server never falls back to plaintext silently
+func (s *Supervisor) checkHTTPServerTLS() error {
+	httpServerConfig, ok := s.config.GetInetHTTPServer()
+	if !ok {
+		return nil
+	}
+	tlsFiles := getHTTPTLSFiles(httpServerConfig)
+	if tlsFiles.CertFile == "" && tlsFiles.KeyFile == "" {
+		return nil
+	}
+	if tlsFiles.CertFile == "" || tlsFiles.KeyFile == "" {
+		return fmt.Errorf("both tls_cert_file and tls_key_file of inet_http_server must be set to serve https")
+	}
+	if _, err := tls.LoadX509KeyPair(tlsFiles.CertFile, tlsFiles.KeyFile); err != nil {
+		return fmt.Errorf("fail to load tls_cert_file %s and tls_key_file %s: %v", tlsFiles.CertFile, tlsFiles.KeyFile, err)
+	}
+	return nil
+}
+
+// checkWritablePaths check if the log files of all programs and the logfile/pidfile of
+// supervisord can be written. All the problems are collected and returned together
+func (s *Supervisor) checkWritablePaths() []string {
+	problems := make([]string, 0)
+	checked := make(map[string]bool)
+	checkFile := func(owner string, file string) {
+		if checked[file] {
+			return
+		}
+		checked[file] = true
+		if err := checkFileWritable(file); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", owner, err))
+		}
+	}
+
+	if supervisordConf, ok := s.config.GetSupervisord(); ok {
+		env := config.NewStringExpression("here", s.config.GetConfigFileDir())
+		if logFile, err := env.Eval(supervisordConf.GetString("logfile", "supervisord.log")); err == nil {
+			for _, f := range logger.GetLogFiles(logFile) {
+				checkFile("supervisord logfile", f)
+			}
+		}
+	}
+	if pidfile, err := s.getPidfile(); err == nil && pidfile != "" {
+		checkFile("supervisord pidfile", pidfile)
+	}
+
+	for _, entry := range s.config.GetPrograms() {
+		for _, key := range []string{"stdout_logfile", "stderr_logfile"} {
+			logFile, err := process.PathExpand(entry.GetStringExpression(key, "/dev/null"))
+			if err != nil {
+				continue
+			}
+			for _, f := range logger.GetLogFiles(logFile) {
+				checkFile(fmt.Sprintf("%s of program %s", key, entry.GetProgramName()), f)
+			}
+		}
+	}
+	return problems
+}
+
+// checkFileWritable check if the file can be written. If the file exists, it is opened for
+// writing without changing its content. Otherwise a temporary file is created in its directory
+func checkFileWritable(file string) error {
+	if _, err := os.Stat(file); err == nil {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return fmt.Errorf("%s is not writable", file)
+		}
+		return f.Close()
+	}
+	dir := filepath.Dir(file)
+	if !isDirectory(dir) {
+		return fmt.Errorf("directory %s of %s does not exist", dir, file)
+	}
+	f, err := ioutil.TempFile(dir, ".supervisord-check")
+	if err != nil {
+		return fmt.Errorf("directory %s of %s is not writable", dir, file)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
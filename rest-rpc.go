@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/gorilla/mux"
 	"github.com/ochinchina/supervisord/types"
+	"io"
 	"io/ioutil"
 	"net/http"
 )
@@ -27,6 +28,7 @@ func (sr *SupervisorRestful) CreateProgramHandler() http.Handler {
 	sr.router.HandleFunc("/program/log/{name}/stdout", sr.ReadStdoutLog).Methods("GET")
 	sr.router.HandleFunc("/program/startPrograms", sr.StartPrograms).Methods("POST", "PUT")
 	sr.router.HandleFunc("/program/stopPrograms", sr.StopPrograms).Methods("POST", "PUT")
+	sr.router.HandleFunc("/program/exportLogs", sr.ExportLogs).Methods("POST", "PUT")
 	return sr.router
 }
 
@@ -132,6 +134,50 @@ func (sr *SupervisorRestful) StopPrograms(w http.ResponseWriter, req *http.Reque
 
 }
 
+// ExportLogs stream the tar.gz of program log files through the restful interface.
+//
+// The request body is a json object like {"Names": ["prog1", "group1:*"], "IncludeBackups": true}
+func (sr *SupervisorRestful) ExportLogs(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	var args ProcessLogExportArgs
+	var b []byte
+	var err error
+	if b, err = ioutil.ReadAll(req.Body); err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("not a valid request"))
+		return
+	}
+
+	if err = json.Unmarshal(b, &args); err != nil || len(args.Names) <= 0 {
+		w.WriteHeader(400)
+		w.Write([]byte("not a valid request"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"logs.tar.gz\"")
+	cw := &countingWriter{writer: w}
+	// the error can be sent to client only if nothing is written
+	if err = sr.supervisor.ExportProcessLogs(&args, cw); err != nil && cw.count == 0 {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Del("Content-Disposition")
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+	}
+}
+
+// countingWriter count the bytes written to the underlying writer
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.writer.Write(p)
+	cw.count += int64(n)
+	return n, err
+}
+
 // ReadStdoutLog read the stdout of given program
 func (sr *SupervisorRestful) ReadStdoutLog(w http.ResponseWriter, req *http.Request) {
 }
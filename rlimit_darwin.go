@@ -0,0 +1,4 @@
+package main
+
+// the RLIMIT_NPROC resource on darwin, it is not defined in syscall package
+const rlimitNproc = 7
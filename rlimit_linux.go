@@ -0,0 +1,4 @@
+package main
+
+// the RLIMIT_NPROC resource on linux, it is not defined in syscall package
+const rlimitNproc = 6
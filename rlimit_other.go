@@ -0,0 +1,17 @@
+// +build !linux,!darwin
+
+package main
+
+import (
+	"go.uber.org/zap"
+)
+
+// checkResourceLimits the "minfds" and "minprocs" are not supported on this platform
+func (s *Supervisor) checkResourceLimits() error {
+	if entry, ok := s.config.GetSupervisord(); ok {
+		if entry.GetInt("minfds", 0) > 0 || entry.GetInt("minprocs", 0) > 0 {
+			zap.S().Warn("minfds and minprocs are not supported on this platform, skip the check")
+		}
+	}
+	return nil
+}
@@ -1,7 +0,0 @@
-// +build windows
-
-package main
-
-func (s *Supervisor) checkRequiredResources() error {
-	return nil
-}
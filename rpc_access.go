@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/ochinchina/gorilla-xmlrpc/xml"
+	"github.com/ochinchina/supervisord/faults"
+	"go.uber.org/zap"
+)
+
+// the RPC methods which don't change supervisord or the programs, they can be called by
+// the read-only user. All the other methods are regarded as mutating
+var readOnlyMethods = map[string]bool{"Supervisor.GetVersion": true,
+	"Supervisor.GetSupervisorVersion":   true,
+	"Supervisor.GetIdentification":      true,
+	"Supervisor.GetState":               true,
+	"Supervisor.GetSupervisorInfo":      true,
+	"Supervisor.GetPID":                 true,
+	"Supervisor.ReadLog":                true,
+	"Supervisor.GetAllProcessInfo":      true,
+	"Supervisor.GetProcessInfo":         true,
+	"Supervisor.GetProcessInfoByState":  true,
+	"Supervisor.GetProcessInfoByGroup":  true,
+	"Supervisor.GetAllConfigInfo":       true,
+	"Supervisor.ExplainProcessConfig":   true,
+	"Supervisor.ValidateProgramConfig":  true,
+	"Supervisor.GetProcessLogInfo":      true,
+	"Supervisor.GetLogCursor":           true,
+	"Supervisor.ReadProcessStdoutLog":   true,
+	"Supervisor.ReadProcessStderrLog":   true,
+	"Supervisor.TailProcessStdoutLog":   true,
+	"Supervisor.TailProcessStderrLog":   true,
+	"Supervisor.TailProcessLog":         true,
+	"Supervisor.GetProcessStateHistory": true}
+
+// the REST paths accept POST but only read data, they can be called by the read-only user.
+// Besides them, the read-only user can only send GET and HEAD requests
+var readOnlyPostPaths = map[string]bool{"/program/exportLogs": true}
+
+type contextKey string
+
+// the request context key marks the request is from the read-only user
+const readOnlyUserKey = contextKey("readOnlyUser")
+
+// mark the request is authenticated as the read-only user
+func withReadOnlyUser(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), readOnlyUserKey, true))
+}
+
+// check if the request is authenticated as the read-only user
+func isReadOnlyUser(r *http.Request) bool {
+	readOnly, ok := r.Context().Value(readOnlyUserKey).(bool)
+	return ok && readOnly
+}
+
+// rpcCallInspector resolve the method of the RPC call served by the XML-RPC or JSON-RPC server
+type rpcCallInspector interface {
+	// get the method name like "Supervisor.GetState" of the call, and the function to reject it
+	inspect(r *http.Request) (method string, reject func(w http.ResponseWriter), err error)
+}
+
+// xmlRPCInspector inspect the XML-RPC call
+type xmlRPCInspector struct {
+	codec *xml.Codec
+}
+
+func (i xmlRPCInspector) inspect(r *http.Request) (string, func(w http.ResponseWriter), error) {
+	codecReq := i.codec.NewRequest(r)
+	method, err := codecReq.Method()
+	return method, func(w http.ResponseWriter) {
+		codecReq.WriteResponse(w, nil, xml.Fault{Code: faults.Failed, String: "READ_ONLY_USER"})
+	}, err
+}
+
+// jsonRPCInspector inspect the JSON-RPC call
+type jsonRPCInspector struct {
+	codec *json2.Codec
+}
+
+func (i jsonRPCInspector) inspect(r *http.Request) (string, func(w http.ResponseWriter), error) {
+	codecReq := i.codec.NewRequest(r)
+	method, err := codecReq.Method()
+	return method, func(w http.ResponseWriter) {
+		codecReq.WriteError(w, http.StatusForbidden, &json2.Error{Code: json2.E_SERVER, Message: "READ_ONLY_USER"})
+	}, err
+}
+
+// readOnlyFilter reject the mutating requests from the read-only user
+type readOnlyFilter struct {
+	rpcInspector rpcCallInspector // resolve the RPC method name if the handler is the RPC server
+	handler      http.Handler
+}
+
+func newReadOnlyFilter(rpcInspector rpcCallInspector, handler http.Handler) *readOnlyFilter {
+	return &readOnlyFilter{rpcInspector: rpcInspector, handler: handler}
+}
+
+func (f *readOnlyFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isReadOnlyUser(r) {
+		f.handler.ServeHTTP(w, r)
+		return
+	}
+	if f.rpcInspector == nil {
+		if r.Method == "GET" || r.Method == "HEAD" || readOnlyPostPaths[r.URL.Path] {
+			f.handler.ServeHTTP(w, r)
+		} else {
+			zap.S().Warnw("reject the request from read-only user", "method", r.Method, "path", r.URL.Path)
+			w.WriteHeader(http.StatusForbidden)
+		}
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	method, reject, err := f.rpcInspector.inspect(r)
+	if err != nil {
+		// the method of the call is unknown, so it may be a mutating one
+		zap.S().Warnw("reject the RPC call from read-only user", "error", err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !readOnlyMethods[method] {
+		zap.S().Warnw("reject the RPC call from read-only user", "method", method)
+		reject(w)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	f.handler.ServeHTTP(w, r)
+}
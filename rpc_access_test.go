@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/ochinchina/gorilla-xmlrpc/xml"
+)
+
+func TestReadOnlyFilter(t *testing.T) {
+	xmlrpcCodec := xml.NewCodec()
+	xmlrpcCodec.RegisterAlias("supervisor.getState", "Supervisor.GetState")
+	xmlrpcCodec.RegisterAlias("supervisor.startProcess", "Supervisor.StartProcess")
+	tests := []struct {
+		inspector   rpcCallInspector
+		contentType string
+		body        string
+		served      bool
+	}{
+		{xmlRPCInspector{codec: xmlrpcCodec}, "text/xml", "<methodCall><methodName>supervisor.getState</methodName></methodCall>", true},
+		{xmlRPCInspector{codec: xmlrpcCodec}, "text/xml", "<methodCall><methodName>supervisor.startProcess</methodName></methodCall>", false},
+		{xmlRPCInspector{codec: xmlrpcCodec}, "text/xml", "<methodCall><methodName>supervisor.unknown</methodName></methodCall>", false},
+		{xmlRPCInspector{codec: xmlrpcCodec}, "text/xml", "<methodCall><methodName>", false},
+		{jsonRPCInspector{codec: json2.NewCodec()}, "application/json", `{"jsonrpc": "2.0", "method": "Supervisor.GetState", "id": 1}`, true},
+		{jsonRPCInspector{codec: json2.NewCodec()}, "application/json", `{"jsonrpc": "2.0", "method": "Supervisor.StartProcess", "id": 1}`, false},
+		{jsonRPCInspector{codec: json2.NewCodec()}, "application/json", `{"jsonrpc":`, false},
+	}
+	for _, test := range tests {
+		served := false
+		filter := newReadOnlyFilter(test.inspector, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served = true
+		}))
+		r := httptest.NewRequest("POST", "/RPC2", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.contentType)
+		filter.ServeHTTP(httptest.NewRecorder(), withReadOnlyUser(r))
+		if served != test.served {
+			t.Errorf("expect the call %q from the read-only user served: %v", test.body, test.served)
+		}
+	}
+}
+
+func TestReadOnlyFilterRejectUnparsableCall(t *testing.T) {
+	tests := []struct {
+		inspector   rpcCallInspector
+		contentType string
+		body        string
+	}{
+		{xmlRPCInspector{codec: xml.NewCodec()}, "text/xml", "<methodCall><methodName>"},
+		{jsonRPCInspector{codec: json2.NewCodec()}, "application/json", `{"jsonrpc":`},
+	}
+	for _, test := range tests {
+		filter := newReadOnlyFilter(test.inspector, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("expect the unparsable call %q from the read-only user is not served", test.body)
+		}))
+		r := httptest.NewRequest("POST", "/RPC2", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.contentType)
+		w := httptest.NewRecorder()
+		filter.ServeHTTP(w, withReadOnlyUser(r))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expect 403 for the unparsable call %q but got %d", test.body, w.Code)
+		}
+	}
+}
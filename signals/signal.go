@@ -3,29 +3,41 @@
 package signals
 
 import (
+	"fmt"
 	"os"
 	"syscall"
 )
 
-// ToSignal convert a signal name to signal
-func ToSignal(signalName string) (os.Signal, error) {
-	if signalName == "HUP" {
-		return syscall.SIGHUP, nil
-	} else if signalName == "INT" {
-		return syscall.SIGINT, nil
-	} else if signalName == "QUIT" {
-		return syscall.SIGQUIT, nil
-	} else if signalName == "KILL" {
-		return syscall.SIGKILL, nil
-	} else if signalName == "USR1" {
-		return syscall.SIGUSR1, nil
-	} else if signalName == "USR2" {
-		return syscall.SIGUSR2, nil
-	} else {
-		return syscall.SIGTERM, nil
+// the signals by the name without the SIG prefix
+var signalsByName = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"TERM":  syscall.SIGTERM,
+	"ALRM":  syscall.SIGALRM,
+	"CONT":  syscall.SIGCONT,
+	"STOP":  syscall.SIGSTOP,
+	"TSTP":  syscall.SIGTSTP,
+	"WINCH": syscall.SIGWINCH,
+}
 
+// ToSignal convert a signal name like "TERM" or "SIGTERM", or a signal number like "37" or
+// "SIG37", to signal. An error is returned if the name is unknown or the number is out of range
+func ToSignal(signalName string) (os.Signal, error) {
+	name := normalizeSignalName(signalName)
+	if num, ok := signalNumber(name); ok {
+		if num < 1 || num > maxSignalNumber {
+			return nil, fmt.Errorf("signal number %s is out of range 1-%d", signalName, maxSignalNumber)
+		}
+		return syscall.Signal(num), nil
 	}
-
+	if sig, ok := signalsByName[name]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unknown signal %s", signalName)
 }
 
 // Kill send signal to the process
@@ -0,0 +1,21 @@
+package signals
+
+import (
+	"strconv"
+	"strings"
+)
+
+// the max signal number accepted by ToSignal, which covers the real-time signals on linux
+const maxSignalNumber = 64
+
+// normalize the signal name like "sigterm" or "SIGTERM" to "TERM"
+func normalizeSignalName(signalName string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(signalName)), "SIG")
+}
+
+// get the signal number from the normalized signal name like "37". ok is false if the name is
+// not a decimal number
+func signalNumber(name string) (num int, ok bool) {
+	num, err := strconv.Atoi(name)
+	return num, err == nil
+}
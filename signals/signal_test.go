@@ -0,0 +1,30 @@
+// +build !windows
+
+package signals
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestToSignal(t *testing.T) {
+	tests := map[string]syscall.Signal{
+		"TERM":    syscall.SIGTERM,
+		"SIGHUP":  syscall.SIGHUP,
+		"usr1":    syscall.SIGUSR1,
+		"37":      syscall.Signal(37),
+		"SIG37":   syscall.Signal(37),
+		" WINCH ": syscall.SIGWINCH,
+	}
+	for name, expected := range tests {
+		if sig, err := ToSignal(name); err != nil || sig != expected {
+			t.Errorf("expect %s is converted to %v but got %v, error %v", name, expected, sig, err)
+		}
+	}
+
+	for _, name := range []string{"", "FOO", "0", "65", "SIG-1"} {
+		if _, err := ToSignal(name); err == nil {
+			t.Errorf("expect an error for the signal %q", name)
+		}
+	}
+}
@@ -3,7 +3,6 @@
 package signals
 
 import (
-	"errors"
 	"fmt"
 	"go.uber.org/zap"
 	"os"
@@ -11,27 +10,35 @@ import (
 	"syscall"
 )
 
-//convert a signal name to signal
-func ToSignal(signalName string) (os.Signal, error) {
-	if signalName == "HUP" {
-		return syscall.SIGHUP, nil
-	} else if signalName == "INT" {
-		return syscall.SIGINT, nil
-	} else if signalName == "QUIT" {
-		return syscall.SIGQUIT, nil
-	} else if signalName == "KILL" {
-		return syscall.SIGKILL, nil
-	} else if signalName == "USR1" {
-		zap.S().Warn("signal USR1 is not supported in windows")
-		return nil, errors.New("signal USR1 is not supported in windows")
-	} else if signalName == "USR2" {
-		zap.S().Warn("signal USR2 is not supported in windows")
-		return nil, errors.New("signal USR2 is not supported in windows")
-	} else {
-		return syscall.SIGTERM, nil
+// the signals supported on windows by the name without the SIG prefix
+var signalsByName = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
 
+// ToSignal convert a signal name like "TERM" or "SIGTERM", or a signal number like "15" or
+// "SIG15", to signal. Only HUP, INT, QUIT, KILL and TERM are supported on windows
+func ToSignal(signalName string) (os.Signal, error) {
+	name := normalizeSignalName(signalName)
+	if num, ok := signalNumber(name); ok {
+		for _, sig := range signalsByName {
+			if int(sig) == num {
+				return sig, nil
+			}
+		}
+		return nil, fmt.Errorf("signal number %s is not supported in windows", signalName)
 	}
-
+	if sig, ok := signalsByName[name]; ok {
+		return sig, nil
+	}
+	if name == "USR1" || name == "USR2" {
+		zap.S().Warnf("signal %s is not supported in windows", name)
+		return nil, fmt.Errorf("signal %s is not supported in windows", name)
+	}
+	return nil, fmt.Errorf("unknown signal %s", signalName)
 }
 
 //
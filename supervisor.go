@@ -6,6 +6,9 @@ import (
 	"go.uber.org/zap/zapcore"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,17 +31,25 @@ const (
 // Supervisor manage all the processes defined in the supervisor configuration file.
 // All the supervisor public interface is defined in this class
 type Supervisor struct {
-	config     *config.Config   // supervisor configuration
-	procMgr    *process.Manager // process manager
-	xmlRPC     *XMLRPC          // XMLRPC interface
-	logger     logger.Logger    // logger manager
-	restarting bool             // if supervisor is in restarting state
+	config      *config.Config   // supervisor configuration
+	procMgr     *process.Manager // process manager
+	xmlRPC      *XMLRPC          // XMLRPC interface
+	logger      logger.Logger    // logger manager
+	restarting  chan struct{}    // closed when the supervisor is restarted
+	restartOnce sync.Once        // close restarting only once
+	logCursors  *logCursors      // the log offsets of the log consumers
+	reloadLock  sync.Mutex       // serialize the configuration reloads
+	pidfile     string           // the pidfile overriding the pidfile in [supervisord] section
+	startTime   time.Time        // when the supervisor is created
+	loaded      bool             // if the configuration is loaded once, guarded by reloadLock
+	controlFifo *controlFifo     // the reader of the control_fifo, guarded by reloadLock
 }
 
 // StartProcessArgs arguments for starting a process
 type StartProcessArgs struct {
-	Name string // program name
-	Wait bool   `default:"true"` // Wait the program starting finished
+	Name    string // program name
+	Wait    bool   `default:"true"` // Wait the program starting finished
+	Timeout int    // the seconds to wait the program starting finished if Wait is true, 0 to wait until it finishes
 }
 
 //ProcessStdin  process stdin from client
@@ -47,6 +58,13 @@ type ProcessStdin struct {
 	Chars string // inputs from client
 }
 
+// RunInProcessContextArgs the command to run in the context of a program
+type RunInProcessContextArgs struct {
+	Name        string   // program name
+	Command     []string // the command and its arguments
+	TimeoutSecs int      // kill the command if it does not exit in this seconds, 30 if not set
+}
+
 // RemoteCommEvent remove communication event from client side
 type RemoteCommEvent struct {
 	Type string // the event type
@@ -59,6 +77,16 @@ type StateInfo struct {
 	Statename string `xml:"statename"`
 }
 
+// SupervisorInfo the information of the running supervisor
+type SupervisorInfo struct {
+	Pid        int    `xml:"pid" json:"pid"`
+	StartTime  int    `xml:"start_time" json:"start_time"` // the unix time the supervisor is started
+	Uptime     int    `xml:"uptime" json:"uptime"`         // the seconds since the supervisor is started
+	ConfigFile string `xml:"config_file" json:"config_file"`
+	Version    string `xml:"version" json:"version"`
+	Programs   int    `xml:"programs" json:"programs"` // the number of the managed programs
+}
+
 // RPCTaskResult result of some remote commands
 type RPCTaskResult struct {
 	Name        string `xml:"name"`        // the program name
@@ -80,11 +108,24 @@ type ProcessLogReadInfo struct {
 	Length int    // the length of log to read
 }
 
+// ProcessLogData the output of reading the program log
+type ProcessLogData struct {
+	LogData string
+	Binary  bool // true if the LogData is encoded with base64 because it is not valid UTF-8 text
+}
+
 // ProcessTailLog the output of tail the program log
 type ProcessTailLog struct {
-	LogData  string
-	Offset   int64
+	LogData string
+	// int instead of int64, which can't be encoded in the XML RPC reply
+	Offset   int
 	Overflow bool
+	Binary   bool // true if the LogData is encoded with base64 because it is not valid UTF-8 text
+}
+
+// ProcessStateHistory the recent state transitions of a program
+type ProcessStateHistory struct {
+	StateHistory []types.ProcessStateTransition
 }
 
 // NewSupervisor create a Supervisor object with supervisor configuration file
@@ -92,7 +133,9 @@ func NewSupervisor(configFile string) *Supervisor {
 	return &Supervisor{config: config.NewConfig(configFile),
 		procMgr:    process.NewManager(),
 		xmlRPC:     NewXMLRPC(),
-		restarting: false}
+		logCursors: newLogCursors(),
+		startTime:  time.Now(),
+		restarting: make(chan struct{})}
 }
 
 // GetConfig get the loaded superisor configuration
@@ -154,6 +197,30 @@ func (s *Supervisor) GetPID(r *http.Request, args *struct{}, reply *struct{ Pid
 	return nil
 }
 
+// GetSupervisorInfo get the pid, start time, configuration file, version and the number of
+// programs of the supervisor
+func (s *Supervisor) GetSupervisorInfo(r *http.Request, args *struct{}, reply *struct{ Info SupervisorInfo }) error {
+	pidReply := struct{ Pid int }{}
+	s.GetPID(r, args, &pidReply)
+	versionReply := struct{ Version string }{}
+	s.GetSupervisorVersion(r, args, &versionReply)
+	configFile := s.config.GetConfigFile()
+	if absFile, err := filepath.Abs(configFile); err == nil && !config.IsRemoteConfigFile(configFile) {
+		configFile = absFile
+	}
+	programs := 0
+	s.procMgr.ForEachProcess(func(proc *process.Process) {
+		programs++
+	})
+	reply.Info = SupervisorInfo{Pid: pidReply.Pid,
+		StartTime:  int(s.startTime.Unix()),
+		Uptime:     int(time.Since(s.startTime).Seconds()),
+		ConfigFile: configFile,
+		Version:    versionReply.Version,
+		Programs:   programs}
+	return nil
+}
+
 // ReadLog read the log of supervisor
 func (s *Supervisor) ReadLog(r *http.Request, args *LogReadInfo, reply *struct{ Log string }) error {
 	data, err := s.logger.ReadLog(int64(args.Offset), int64(args.Length))
@@ -183,31 +250,75 @@ func (s *Supervisor) Shutdown(r *http.Request, args *struct{}, reply *struct{ Re
 // Restart restart the supervisor
 func (s *Supervisor) Restart(r *http.Request, args *struct{}, reply *struct{ Ret bool }) error {
 	zap.S().Info("Receive instruction to restart")
-	s.restarting = true
+	// don't stop the running programs if the new supervisor can't load the configuration
+	newConfig := config.NewConfig(s.config.GetConfigFile())
+	newConfig.SetPublicKey(s.config.GetPublicKey())
+	if _, err := newConfig.Load(); err != nil {
+		zap.S().Errorw("fail to load configuration, the restart is cancelled", "file", s.config.GetConfigFile(), "error", err)
+		reply.Ret = false
+		return err
+	}
+	s.restartOnce.Do(func() { close(s.restarting) })
 	reply.Ret = true
 	return nil
 }
 
 // IsRestarting check if supervisor is in restarting state
 func (s *Supervisor) IsRestarting() bool {
-	return s.restarting
+	select {
+	case <-s.restarting:
+		return true
+	default:
+		return false
+	}
 }
 
 func getProcessInfo(proc *process.Process) *types.ProcessInfo {
+	nextRetryAt := 0
+	if nextRetryTime := proc.GetNextRetryTime(); !nextRetryTime.IsZero() {
+		nextRetryAt = int(nextRetryTime.Unix())
+	}
+	scheduledRestartAt := 0
+	if restartTime := proc.GetScheduledRestartTime(); !restartTime.IsZero() {
+		scheduledRestartAt = int(restartTime.Unix())
+	}
+	stdoutStats := proc.GetStdoutStats()
+	stderrStats := proc.GetStderrStats()
+	aliveMatches, deadMatches := proc.GetLogProbeMatches()
 	return &types.ProcessInfo{Name: proc.GetName(),
-		Group:         proc.GetGroup(),
-		Description:   proc.GetDescription(),
-		Start:         int(proc.GetStartTime().Unix()),
-		Stop:          int(proc.GetStopTime().Unix()),
-		Now:           int(time.Now().Unix()),
-		State:         int(proc.GetState()),
-		Statename:     proc.GetState().String(),
-		Spawnerr:      "",
-		Exitstatus:    proc.GetExitstatus(),
-		Logfile:       proc.GetStdoutLogfile(),
-		StdoutLogfile: proc.GetStdoutLogfile(),
-		StderrLogfile: proc.GetStderrLogfile(),
-		Pid:           proc.GetPid()}
+		Group:                    proc.GetGroup(),
+		Description:              proc.GetDescription(),
+		Start:                    int(proc.GetStartTime().Unix()),
+		Stop:                     int(proc.GetStopTime().Unix()),
+		Now:                      int(time.Now().Unix()),
+		State:                    int(proc.GetState()),
+		Statename:                proc.GetState().String(),
+		Spawnerr:                 proc.GetSpawnError(),
+		Exitstatus:               proc.GetExitstatus(),
+		Logfile:                  proc.GetStdoutLogfile(),
+		StdoutLogfile:            proc.GetStdoutLogfile(),
+		StderrLogfile:            proc.GetStderrLogfile(),
+		Pid:                      proc.GetPid(),
+		NextRetryAt:              nextRetryAt,
+		RetriesRemaining:         proc.GetRetriesRemaining(),
+		GroupKillRestartRequired: proc.IsGroupKillRestartRequired(),
+		LastError:                proc.GetLastError(),
+		AutostartSkipped:         proc.IsAutostartSkipped(),
+		StdoutBytes:              int(stdoutStats.Bytes),
+		StdoutLines:              int(stdoutStats.Lines),
+		StderrBytes:              int(stderrStats.Bytes),
+		StderrLines:              int(stderrStats.Lines),
+		TotalStdoutBytes:         int(stdoutStats.TotalBytes),
+		TotalStdoutLines:         int(stdoutStats.TotalLines),
+		TotalStderrBytes:         int(stderrStats.TotalBytes),
+		TotalStderrLines:         int(stderrStats.TotalLines),
+		Ready:                    proc.IsReady(),
+		AliveMatches:             aliveMatches,
+		DeadMatches:              deadMatches,
+		HealthCheck:              proc.GetHealthStatus(),
+		FlapCount:                proc.GetFlapCount(),
+		Argv:                     proc.GetArgv(),
+		ScheduledRestartAt:       scheduledRestartAt}
 
 }
 
@@ -222,6 +333,39 @@ func (s *Supervisor) GetAllProcessInfo(r *http.Request, args *struct{}, reply *s
 	return nil
 }
 
+// GetProcessInfoByState get the process information of the programs in any of the states, like
+// [200] for the programs in FATAL state
+func (s *Supervisor) GetProcessInfoByState(r *http.Request, args *struct{ States []int }, reply *struct{ AllProcessInfo []types.ProcessInfo }) error {
+	states := make(map[int]bool)
+	for _, state := range args.States {
+		states[state] = true
+	}
+	reply.AllProcessInfo = s.filterProcessInfo(func(procInfo *types.ProcessInfo) bool {
+		return states[procInfo.State]
+	})
+	return nil
+}
+
+// GetProcessInfoByGroup get the process information of the programs in the group
+func (s *Supervisor) GetProcessInfoByGroup(r *http.Request, args *struct{ Group string }, reply *struct{ AllProcessInfo []types.ProcessInfo }) error {
+	reply.AllProcessInfo = s.filterProcessInfo(func(procInfo *types.ProcessInfo) bool {
+		return procInfo.Group == args.Group
+	})
+	return nil
+}
+
+// get the sorted process information of the programs accepted by the filter
+func (s *Supervisor) filterProcessInfo(filter func(procInfo *types.ProcessInfo) bool) []types.ProcessInfo {
+	result := make([]types.ProcessInfo, 0)
+	s.procMgr.ForEachProcess(func(proc *process.Process) {
+		if procInfo := getProcessInfo(proc); filter(procInfo) {
+			result = append(result, *procInfo)
+		}
+	})
+	types.SortProcessInfos(result)
+	return result
+}
+
 // GetProcessInfo get the process information of one program
 func (s *Supervisor) GetProcessInfo(r *http.Request, args *struct{ Name string }, reply *struct{ ProcInfo types.ProcessInfo }) error {
 	zap.S().Info("Get process info of: ", args.Name)
@@ -234,6 +378,24 @@ func (s *Supervisor) GetProcessInfo(r *http.Request, args *struct{ Name string }
 	return nil
 }
 
+// GetProcessStateHistory get the recent state transitions of the program, the oldest first
+func (s *Supervisor) GetProcessStateHistory(r *http.Request, args *struct{ Name string }, reply *ProcessStateHistory) error {
+	proc := s.procMgr.Find(args.Name)
+	if proc == nil {
+		return fmt.Errorf("no process named %s", args.Name)
+	}
+	reply.StateHistory = make([]types.ProcessStateTransition, 0)
+	for _, transition := range proc.GetStateHistory() {
+		reply.StateHistory = append(reply.StateHistory, types.ProcessStateTransition{Time: int(transition.Time.Unix()),
+			FromState:     int(transition.From),
+			FromStatename: transition.From.String(),
+			ToState:       int(transition.To),
+			ToStatename:   transition.To.String(),
+			ExitStatus:    transition.ExitStatus})
+	}
+	return nil
+}
+
 // StartProcess start the given program
 func (s *Supervisor) StartProcess(r *http.Request, args *StartProcessArgs, reply *struct{ Success bool }) error {
 	procs := s.procMgr.FindMatch(args.Name)
@@ -242,7 +404,13 @@ func (s *Supervisor) StartProcess(r *http.Request, args *StartProcessArgs, reply
 		return fmt.Errorf("fail to find process %s", args.Name)
 	}
 	for _, proc := range procs {
-		proc.Start(args.Wait)
+		if args.Wait && args.Timeout > 0 {
+			if err := proc.StartWithTimeout(time.Duration(args.Timeout) * time.Second); err != nil {
+				return err
+			}
+		} else {
+			proc.Start(args.Wait)
+		}
 	}
 	reply.Success = true
 	return nil
@@ -255,9 +423,9 @@ func (s *Supervisor) StartAllProcesses(r *http.Request, args *struct {
 
 	finishedProcCh := make(chan *process.Process)
 
-	n := s.procMgr.AsyncForEachProcess(func(proc *process.Process) {
+	n := s.procMgr.AsyncForEachProcessWithLimit(func(proc *process.Process) {
 		proc.Start(args.Wait)
-	}, finishedProcCh)
+	}, finishedProcCh, s.getStartConcurrency())
 
 	for i := 0; i < n; i++ {
 		proc, ok := <-finishedProcCh
@@ -274,22 +442,44 @@ func (s *Supervisor) StartAllProcesses(r *http.Request, args *struct {
 	return nil
 }
 
-// StartProcessGroup start all the processes in one group
-func (s *Supervisor) StartProcessGroup(r *http.Request, args *StartProcessArgs, reply *struct{ AllProcessInfo []types.ProcessInfo }) error {
-	zap.S().Infow("start process group", "group", args.Name)
-	finishedProcCh := make(chan *process.Process)
-
-	n := s.procMgr.AsyncForEachProcess(func(proc *process.Process) {
-		if proc.GetGroup() == args.Name {
+// StartProcessesBySelector start all the processes whose labels match the selector, for
+// example "tier=critical,team=payments"
+func (s *Supervisor) StartProcessesBySelector(r *http.Request, args *struct {
+	Selector string
+	Wait     bool `default:"true"`
+}, reply *struct{ RPCTaskResults []RPCTaskResult }) error {
+	selector, err := process.ParseLabelSelector(args.Selector)
+	if err != nil {
+		return err
+	}
+	procs := s.procMgr.FindBySelector(selector)
+	zap.S().Infow("start processes by selector", "selector", args.Selector, "processes", len(procs))
+	var wg sync.WaitGroup
+	for _, proc := range procs {
+		wg.Add(1)
+		go func(proc *process.Process) {
+			defer wg.Done()
 			proc.Start(args.Wait)
+		}(proc)
+	}
+	wg.Wait()
+	reply.RPCTaskResults = make([]RPCTaskResult, 0)
+	for _, proc := range procs {
+		result := RPCTaskResult{Name: proc.GetName(), Group: proc.GetGroup(), Status: faults.Success, Description: "OK"}
+		if state := proc.GetState(); args.Wait && state != process.Running {
+			result.Status = faults.SpawnError
+			result.Description = fmt.Sprintf("fail to start, current state is %s", state.String())
 		}
-	}, finishedProcCh)
+		reply.RPCTaskResults = append(reply.RPCTaskResults, result)
+	}
+	return nil
+}
 
-	for i := 0; i < n; i++ {
-		proc, ok := <-finishedProcCh
-		if ok && proc.GetGroup() == args.Name {
-			reply.AllProcessInfo = append(reply.AllProcessInfo, *getProcessInfo(proc))
-		}
+// StartProcessGroup start all the processes in one group
+func (s *Supervisor) StartProcessGroup(r *http.Request, args *StartProcessArgs, reply *struct{ AllProcessInfo []types.ProcessInfo }) error {
+	zap.S().Infow("start process group", "group", args.Name)
+	for _, proc := range s.procMgr.StartGroup(args.Name, args.Wait) {
+		reply.AllProcessInfo = append(reply.AllProcessInfo, *getProcessInfo(proc))
 	}
 
 	return nil
@@ -309,32 +499,134 @@ func (s *Supervisor) StopProcess(r *http.Request, args *StartProcessArgs, reply
 	return nil
 }
 
-// StopProcessGroup stop all processes in one group
-func (s *Supervisor) StopProcessGroup(r *http.Request, args *StartProcessArgs, reply *struct{ AllProcessInfo []types.ProcessInfo }) error {
-	zap.S().Infow("stop process group", "group", args.Name)
-	finishedProcCh := make(chan *process.Process)
-	n := s.procMgr.AsyncForEachProcess(func(proc *process.Process) {
-		if proc.GetGroup() == args.Name {
-			proc.Stop(args.Wait)
+// SetProcessLogEvents enable or disable the stdout/stderr log events of a program at runtime. The
+// configured stdout_events_enabled/stderr_events_enabled is overridden until the configuration is
+// reloaded. The program keeps running and writing its log files
+func (s *Supervisor) SetProcessLogEvents(r *http.Request, args *struct {
+	Name   string
+	Stdout bool
+	Stderr bool
+}, reply *struct{ Success bool }) error {
+	procs := s.procMgr.FindMatch(args.Name)
+	if len(procs) <= 0 {
+		return fmt.Errorf("fail to find process %s", args.Name)
+	}
+	for _, proc := range procs {
+		zap.S().Infow("set log events of program", "program", proc.GetName(), "stdout", args.Stdout, "stderr", args.Stderr)
+		proc.SetLogEventsEnabled(args.Stdout, args.Stderr)
+	}
+	reply.Success = true
+	return nil
+}
+
+// SetProcessAutostart enable or disable the autostart of a program at runtime, so the program is not
+// started again by starting its group or updating the configuration during the maintenance. The
+// configuration file is not changed. The override is saved to the overlay file if overlay_file is
+// set, otherwise it is lost when the configuration is reloaded
+func (s *Supervisor) SetProcessAutostart(r *http.Request, args *struct {
+	Name    string
+	Enabled bool
+}, reply *struct{ Success bool }) error {
+	procs := s.procMgr.FindMatch(args.Name)
+	if len(procs) <= 0 {
+		return fmt.Errorf("fail to find process %s", args.Name)
+	}
+	persisted := s.config.GetOverlayFile() != ""
+	for _, proc := range procs {
+		zap.S().Infow("set autostart of program", "program", proc.GetName(), "autostart", args.Enabled)
+		proc.SetAutostart(args.Enabled)
+		if persisted {
+			if err := s.config.SetOverlayValue(proc.GetName(), "autostart", strconv.FormatBool(args.Enabled)); err != nil {
+				zap.S().Errorw("fail to save the autostart to the overlay file", "program", proc.GetName(), "error", err)
+				return err
+			}
 		}
-	}, finishedProcCh)
+	}
+	reply.Success = true
+	return nil
+}
 
-	for i := 0; i < n; i++ {
-		proc, ok := <-finishedProcCh
-		if ok && proc.GetGroup() == args.Name {
-			reply.AllProcessInfo = append(reply.AllProcessInfo, *getProcessInfo(proc))
+// check if the program is autostarted on this host by its autostart setting
+func isAutostartEnabled(entry *config.Entry) bool {
+	autostart, _ := process.EvalAutostart(entry.GetAutostart())
+	return autostart
+}
+
+// GetAllConfigInfo get the configuration of all the programs
+func (s *Supervisor) GetAllConfigInfo(r *http.Request, args *struct{}, reply *struct{ ConfigInfo []types.ConfigInfo }) error {
+	reply.ConfigInfo = make([]types.ConfigInfo, 0)
+	for _, entry := range s.config.GetPrograms() {
+		configInfo := types.ConfigInfo{Name: entry.GetProgramName(),
+			Group:               entry.Group,
+			Command:             entry.GetString("command", ""),
+			Autostart:           isAutostartEnabled(entry),
+			Priority:            entry.GetInt("priority", 999),
+			StdoutLogfile:       entry.GetString("stdout_logfile", ""),
+			StderrLogfile:       entry.GetString("stderr_logfile", ""),
+			StdoutEventsEnabled: entry.GetBool("stdout_events_enabled", false),
+			StderrEventsEnabled: entry.GetBool("stderr_events_enabled", false)}
+		if proc := s.procMgr.Find(entry.GetProgramName()); proc != nil {
+			configInfo.StdoutLogfile = proc.GetStdoutLogfile()
+			configInfo.StderrLogfile = proc.GetStderrLogfile()
+			configInfo.StdoutEventsEnabled = proc.IsStdoutEventsEnabled()
+			configInfo.StderrEventsEnabled = proc.IsStderrEventsEnabled()
 		}
+		reply.ConfigInfo = append(reply.ConfigInfo, configInfo)
+	}
+	return nil
+}
+
+// ExplainProcessConfig get the effective value of a setting of the program and where it comes from:
+// "program", "program-default", "built-in" or "unset"
+func (s *Supervisor) ExplainProcessConfig(r *http.Request, args *struct {
+	Name string
+	Key  string
+}, reply *struct {
+	Value  string
+	Source string
+}) error {
+	value, source, err := s.config.ExplainProgramSetting(args.Name, args.Key)
+	if err != nil {
+		return err
+	}
+	reply.Value = value
+	reply.Source = source
+	return nil
+}
+
+// SoftStopProcess send the stop signal to the program once and return immediately without waiting
+// for the program to exit. Whether the program is restarted is decided by its autorestart setting
+func (s *Supervisor) SoftStopProcess(r *http.Request, args *struct{ Name string }, reply *struct{ Success bool }) error {
+	zap.S().Infow("soft stop process", "program", args.Name)
+	procs := s.procMgr.FindMatch(args.Name)
+	if len(procs) <= 0 {
+		return fmt.Errorf("fail to find process %s", args.Name)
+	}
+	for _, proc := range procs {
+		if err := proc.SoftStop(); err != nil {
+			return err
+		}
+	}
+	reply.Success = true
+	return nil
+}
+
+// StopProcessGroup stop all processes in one group
+func (s *Supervisor) StopProcessGroup(r *http.Request, args *StartProcessArgs, reply *struct{ AllProcessInfo []types.ProcessInfo }) error {
+	zap.S().Infow("stop process group", "group", args.Name)
+	for _, proc := range s.procMgr.StopGroup(args.Name, args.Wait) {
+		reply.AllProcessInfo = append(reply.AllProcessInfo, *getProcessInfo(proc))
 	}
 	return nil
 }
 
-// StopAllProcesses stop all programs managed by supervisor
+// StopAllProcesses stop all programs managed by supervisor in the descending order of their priority
 func (s *Supervisor) StopAllProcesses(r *http.Request, args *struct {
 	Wait bool `default:"true"`
 }, reply *struct{ RPCTaskResults []RPCTaskResult }) error {
 	finishedProcCh := make(chan *process.Process)
 
-	n := s.procMgr.AsyncForEachProcess(func(proc *process.Process) {
+	n := s.procMgr.AsyncForEachProcessByReversePriority(func(proc *process.Process) {
 		proc.Stop(args.Wait)
 	}, finishedProcCh)
 
@@ -353,6 +645,108 @@ func (s *Supervisor) StopAllProcesses(r *http.Request, args *struct {
 	return nil
 }
 
+// RestartProcess stop the matching programs and wait for them to be stopped, then start them
+// again. The programs are restarted in one call, so a client failing between the stop and the
+// start does not leave them stopped
+func (s *Supervisor) RestartProcess(r *http.Request, args *StartProcessArgs, reply *struct{ RPCTaskResults []RPCTaskResult }) error {
+	zap.S().Infow("restart process", "program", args.Name)
+	procs := s.procMgr.FindMatch(args.Name)
+	if len(procs) <= 0 {
+		return fmt.Errorf("fail to find process %s", args.Name)
+	}
+	var wg sync.WaitGroup
+	for _, proc := range procs {
+		wg.Add(1)
+		go func(proc *process.Process) {
+			defer wg.Done()
+			proc.Restart(args.Wait)
+		}(proc)
+	}
+	wg.Wait()
+	reply.RPCTaskResults = getRestartResults(procs, args.Wait)
+	return nil
+}
+
+// RestartProcessGroup stop all the processes in a group and wait for them to be stopped, then
+// start them again. The start order of the group is kept like StopProcessGroup and StartProcessGroup
+func (s *Supervisor) RestartProcessGroup(r *http.Request, args *StartProcessArgs, reply *struct{ RPCTaskResults []RPCTaskResult }) error {
+	zap.S().Infow("restart process group", "group", args.Name)
+	if len(s.procMgr.FindMatch(args.Name+":*")) <= 0 {
+		return fmt.Errorf("fail to find process group %s", args.Name)
+	}
+	procs := s.procMgr.RestartGroup(args.Name, args.Wait)
+	reply.RPCTaskResults = getRestartResults(procs, args.Wait)
+	return nil
+}
+
+// RestartAllProcesses stop all the programs in the descending order of their priority and wait for
+// them to be stopped, then start them in the ascending order of their priority. The programs with
+// the same priority are stopped and started concurrently
+func (s *Supervisor) RestartAllProcesses(r *http.Request, args *struct {
+	Wait bool `default:"true"`
+}, reply *struct{ RPCTaskResults []RPCTaskResult }) error {
+	zap.S().Info("restart all processes")
+	procs := s.procMgr.RestartAllProcesses(args.Wait)
+	reply.RPCTaskResults = getRestartResults(procs, args.Wait)
+	return nil
+}
+
+// get the results of the restarted processes. If wait is true, the process not RUNNING after it
+// is started is reported with SPAWN_ERROR
+func getRestartResults(procs []*process.Process, wait bool) []RPCTaskResult {
+	results := make([]RPCTaskResult, 0)
+	for _, proc := range procs {
+		result := RPCTaskResult{Name: proc.GetName(), Group: proc.GetGroup(), Status: faults.Success, Description: "OK"}
+		if state := proc.GetState(); wait && state != process.Running {
+			result.Status = faults.SpawnError
+			result.Description = fmt.Sprintf("fail to restart, current state is %s", state.String())
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// RollingRestartGroup restart the processes in a group with at most Parallelism processes at a time.
+// The rolling restart is aborted if any process fails to come back to RUNNING
+func (s *Supervisor) RollingRestartGroup(r *http.Request, args *struct {
+	Group       string
+	Parallelism int
+}, reply *struct{ RPCTaskResults []RPCTaskResult }) error {
+	zap.S().Infow("rolling restart process group", "group", args.Group, "parallelism", args.Parallelism)
+	if len(s.procMgr.FindMatch(args.Group+":*")) <= 0 {
+		return fmt.Errorf("fail to find process group %s", args.Group)
+	}
+	restarted, failed, skipped := s.procMgr.RollingRestartGroup(args.Group, args.Parallelism)
+	for _, proc := range restarted {
+		reply.RPCTaskResults = append(reply.RPCTaskResults, RPCTaskResult{
+			Name:        proc.GetName(),
+			Group:       proc.GetGroup(),
+			Status:      faults.Success,
+			Description: "OK",
+		})
+	}
+	for _, proc := range failed {
+		reply.RPCTaskResults = append(reply.RPCTaskResults, RPCTaskResult{
+			Name:        proc.GetName(),
+			Group:       proc.GetGroup(),
+			Status:      faults.AbnormalTermination,
+			Description: fmt.Sprintf("fail to come back to RUNNING, current state is %s", proc.GetState().String()),
+		})
+	}
+	for _, proc := range skipped {
+		reply.RPCTaskResults = append(reply.RPCTaskResults, RPCTaskResult{
+			Name:        proc.GetName(),
+			Group:       proc.GetGroup(),
+			Status:      faults.Failed,
+			Description: "not restarted because the rolling restart is aborted",
+		})
+	}
+	if len(failed) > 0 {
+		zap.S().Errorw("rolling restart is aborted", "group", args.Group, "failed", len(failed), "skipped", len(skipped))
+	}
+	return nil
+}
+
 // SignalProcess send a signal to running program
 func (s *Supervisor) SignalProcess(r *http.Request, args *types.ProcessSignal, reply *struct{ Success bool }) error {
 	procs := s.procMgr.FindMatch(args.Name)
@@ -361,10 +755,12 @@ func (s *Supervisor) SignalProcess(r *http.Request, args *types.ProcessSignal, r
 		return fmt.Errorf("No process named %s", args.Name)
 	}
 	sig, err := signals.ToSignal(args.Signal)
-	if err == nil {
-		for _, proc := range procs {
-			proc.Signal(sig, false)
-		}
+	if err != nil {
+		reply.Success = false
+		return err
+	}
+	for _, proc := range procs {
+		proc.SignalProgram(sig)
 	}
 	reply.Success = true
 	return nil
@@ -372,12 +768,13 @@ func (s *Supervisor) SignalProcess(r *http.Request, args *types.ProcessSignal, r
 
 // SignalProcessGroup send signal to all processes in one group
 func (s *Supervisor) SignalProcessGroup(r *http.Request, args *types.ProcessSignal, reply *struct{ AllProcessInfo []types.ProcessInfo }) error {
+	sig, err := signals.ToSignal(args.Signal)
+	if err != nil {
+		return err
+	}
 	s.procMgr.ForEachProcess(func(proc *process.Process) {
 		if proc.GetGroup() == args.Name {
-			sig, err := signals.ToSignal(args.Signal)
-			if err == nil {
-				proc.Signal(sig, false)
-			}
+			proc.SignalProgram(sig)
 		}
 	})
 
@@ -391,11 +788,12 @@ func (s *Supervisor) SignalProcessGroup(r *http.Request, args *types.ProcessSign
 
 // SignalAllProcesses send signal to all the processes in the supervisor
 func (s *Supervisor) SignalAllProcesses(r *http.Request, args *types.ProcessSignal, reply *struct{ AllProcessInfo []types.ProcessInfo }) error {
+	sig, err := signals.ToSignal(args.Signal)
+	if err != nil {
+		return err
+	}
 	s.procMgr.ForEachProcess(func(proc *process.Process) {
-		sig, err := signals.ToSignal(args.Signal)
-		if err == nil {
-			proc.Signal(sig, false)
-		}
+		proc.SignalProgram(sig)
 	})
 	s.procMgr.ForEachProcess(func(proc *process.Process) {
 		reply.AllProcessInfo = append(reply.AllProcessInfo, *getProcessInfo(proc))
@@ -423,6 +821,76 @@ func (s *Supervisor) SendProcessStdin(r *http.Request, args *ProcessStdin, reply
 	return err
 }
 
+// SendProcessStdinGroup send the chars to the stdin of all the running programs in the group. The
+// result of each program is returned, the programs not running are skipped
+func (s *Supervisor) SendProcessStdinGroup(r *http.Request, args *struct {
+	Group string // the group name
+	Chars string // inputs from client
+}, reply *struct{ RPCTaskResults []RPCTaskResult }) error {
+	procs := make([]*process.Process, 0)
+	s.procMgr.ForEachProcess(func(proc *process.Process) {
+		if proc.GetGroup() == args.Group {
+			procs = append(procs, proc)
+		}
+	})
+	if len(procs) == 0 {
+		return fmt.Errorf("BAD_NAME: no program in group %s", args.Group)
+	}
+	// write outside the ForEachProcess because the write may block if a program doesn't read its stdin
+	reply.RPCTaskResults = make([]RPCTaskResult, 0)
+	for _, proc := range procs {
+		result := RPCTaskResult{Name: proc.GetName(), Group: proc.GetGroup(), Status: faults.Success, Description: "OK"}
+		if proc.GetState() != process.Running {
+			result.Status = faults.NotRunning
+			result.Description = "skipped, the program is not running"
+		} else if err := proc.SendProcessStdin(args.Chars); err != nil {
+			zap.S().Errorw("fail to send the stdin to the program", "program", proc.GetName(), "error", err)
+			result.Status = faults.Failed
+			result.Description = err.Error()
+		}
+		reply.RPCTaskResults = append(reply.RPCTaskResults, result)
+	}
+	return nil
+}
+
+// ResetProcessCounters clear the start retries, the backoff and the restart counters of the program.
+// The program in BACKOFF, FATAL, QUARANTINED or COOLDOWN state is moved to STOPPED state without starting it
+func (s *Supervisor) ResetProcessCounters(r *http.Request, args *struct{ Name string }, reply *struct{ Success bool }) error {
+	proc := s.procMgr.Find(args.Name)
+	if proc == nil {
+		return fmt.Errorf("fail to find process %s", args.Name)
+	}
+	if err := proc.ResetCounters(); err != nil {
+		zap.S().Errorw("fail to reset the process counters", "program", args.Name, "error", err)
+		return err
+	}
+	reply.Success = true
+	return nil
+}
+
+// RunInProcessContext run a transient command with the same environment, user, working directory,
+// umask and cgroup as the program and return its output. The running program is not touched
+func (s *Supervisor) RunInProcessContext(r *http.Request, args *RunInProcessContextArgs, reply *struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}) error {
+	proc := s.procMgr.Find(args.Name)
+	if proc == nil {
+		return fmt.Errorf("fail to find process %s", args.Name)
+	}
+	timeout := 30 * time.Second
+	if args.TimeoutSecs > 0 {
+		timeout = time.Duration(args.TimeoutSecs) * time.Second
+	}
+	stdout, stderr, exitCode, err := proc.RunInContext(args.Command, timeout)
+	reply.Stdout, reply.Stderr, reply.ExitCode = stdout, stderr, exitCode
+	if err != nil {
+		zap.S().Errorw("fail to run command in the program context", "program", args.Name, "command", args.Command, "error", err)
+	}
+	return err
+}
+
 // SendRemoteCommEvent emit a remote communication event
 func (s *Supervisor) SendRemoteCommEvent(r *http.Request, args *RemoteCommEvent, reply *struct{ Success bool }) error {
 	events.EmitEvent(events.NewRemoteCommunicationEvent(args.Type, args.Data))
@@ -431,62 +899,152 @@ func (s *Supervisor) SendRemoteCommEvent(r *http.Request, args *RemoteCommEvent,
 }
 
 // Reload reload the supervisor configuration
-//return err, addedGroup, changedGroup, removedGroup
 //
-func (s *Supervisor) Reload() (addedGroup []string, changedGroup []string, removedGroup []string, err error) {
+// The running processes whose program configuration is changed are restarted, the
+// processes whose program configuration is not changed are kept running untouched
+func (s *Supervisor) Reload() (result types.ReloadConfigResult, err error) {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
 	//get the previous loaded programs
 	prevPrograms := s.config.GetProgramNames()
 	prevProgGroup := s.config.ProgramGroup.Clone()
+	prevHashes := getProgramHashes(s.config)
 
 	loadedPrograms, err := s.config.Load()
+	if err != nil {
+		zap.S().Errorw("fail to load configuration, keep running with the previous loaded configuration", "file", s.config.GetConfigFile(), "error", err)
+		return result, err
+	}
 
-	if checkErr := s.checkRequiredResources(); checkErr != nil {
-		zap.S().Error(checkErr)
-		os.Exit(1)
-
+	// the required resources are checked only before any program is started, a reload never exits
+	// the supervisord and its programs even if a log directory becomes unwritable at runtime
+	if !s.loaded {
+		if checkErr := s.checkRequiredResources(); checkErr != nil {
+			zap.S().Error(checkErr)
+			os.Exit(1)
+		}
 	}
-	if err == nil {
-		s.setSupervisordInfo()
-		s.startEventListeners()
-		s.createPrograms(prevPrograms)
+	s.loaded = true
+	s.setSupervisordInfo()
+	s.writePidfile()
+	s.setLogCursorFile()
+	s.setTotalMemoryLimit()
+	s.setTotalLogBytesLimit()
+	s.setFatalWebhook()
+	s.setStateHistorySize()
+	s.startEventListeners()
+	restartedPrograms, preservedPrograms := s.createPrograms(prevHashes)
+	// the programs are already applied, so an invalid http server configuration is returned
+	// as a warning with the applied changes instead of failing the reload
+	if httpErr := s.checkHTTPServer(); httpErr == nil {
 		s.startHTTPServer()
-		s.startAutoStartPrograms()
+	} else {
+		zap.S().Errorw("keep the http server running with the previous configuration", "error", httpErr)
+		result.Warnings = append(result.Warnings, fmt.Sprintf("keep the http server running with the previous configuration: %v", httpErr))
 	}
+	s.startControlFifo()
+	s.startAutoStartPrograms()
 	removedPrograms := util.Sub(prevPrograms, loadedPrograms)
+	removedProcs := make([]*process.Process, 0)
 	for _, removedProg := range removedPrograms {
 		zap.S().Infow("the program is removed and will be stopped", "program", removedProg)
 		s.config.RemoveProgram(removedProg)
 		proc := s.procMgr.Remove(removedProg)
 		if proc != nil {
-			proc.Stop(false)
+			removedProcs = append(removedProcs, proc)
 		}
 
 	}
-	addedGroup, changedGroup, removedGroup = s.config.ProgramGroup.Sub(prevProgGroup)
-	return addedGroup, changedGroup, removedGroup, err
+	stopRemovedProcesses(removedProcs)
+	result.AddedGroup, result.ChangedGroup, result.RemovedGroup = s.config.ProgramGroup.Sub(prevProgGroup)
+	result.AddedPrograms = util.Sub(loadedPrograms, prevPrograms)
+	result.RemovedPrograms = removedPrograms
+	result.RestartedPrograms, result.PreservedPrograms = restartedPrograms, preservedPrograms
+	return result, err
 
 }
 
-// WaitForExit wait the superisor to exit
-func (s *Supervisor) WaitForExit() {
-	for {
-		if s.IsRestarting() {
-			s.procMgr.StopAllProcesses()
-			break
-		}
-		time.Sleep(10 * time.Second)
+// the program items which take effect only after the program is restarted
+var restartRequiredKeys = []string{"command", "environment", "directory", "user"}
+
+// get the hash of the program items which require restart for all the programs
+func getProgramHashes(cfg *config.Config) map[string]string {
+	hashes := make(map[string]string)
+	for _, entry := range cfg.GetPrograms() {
+		hashes[entry.GetProgramName()] = entry.Hash(restartRequiredKeys...)
 	}
+	return hashes
 }
 
-func (s *Supervisor) createPrograms(prevPrograms []string) {
+// check if the process in state has a running program or is going to run it
+func isActiveState(state process.State) bool {
+	return state == process.Starting || state == process.Running || state == process.Backoff
+}
+
+// WaitForExit wait the superisor to be restarted and stop all the programs. The shutdown by
+// signal or the shutdown RPC exits the supervisord without returning from the wait
+func (s *Supervisor) WaitForExit() {
+	<-s.restarting
+	s.reloadLock.Lock()
+	fifo := s.controlFifo
+	s.controlFifo = nil
+	s.reloadLock.Unlock()
+	// the supervisor created by the restart reads the control fifo
+	fifo.close()
+	s.procMgr.StopAllProcesses()
+}
 
-	programs := s.config.GetProgramNames()
+// create the processes of the programs just added and restart the running processes whose
+// command, environment, directory or user is different from prevHashes. The other processes
+// keep running with the reloaded configuration.
+//
+// return the restarted programs and the preserved programs which are not restarted
+func (s *Supervisor) createPrograms(prevHashes map[string]string) (restarted []string, preserved []string) {
+	restarted = make([]string, 0)
+	preserved = make([]string, 0)
 	for _, entry := range s.config.GetPrograms() {
-		s.procMgr.CreateProcess(s.GetSupervisorID(), entry)
+		name := entry.GetProgramName()
+		proc := s.procMgr.Find(name)
+		if proc == nil {
+			s.procMgr.CreateProcess(s.GetSupervisorID(), entry)
+			continue
+		}
+		// the program kept across reload takes the configured log events, like a re-created one
+		proc.ResetLogEventsEnabled()
+		prevHash, ok := prevHashes[name]
+		if !ok || prevHash == entry.Hash(restartRequiredKeys...) || !isActiveState(proc.GetState()) {
+			preserved = append(preserved, name)
+			continue
+		}
+		zap.S().Infow("the program command, environment, directory or user is changed and it will be restarted", "program", name)
+		restarted = append(restarted, name)
+		// the new start waits for the start loop of the stopped program to exit
+		go proc.Restart(false)
+	}
+	for _, entry := range s.config.GetGroups() {
+		s.procMgr.SetGroupStartOrder(entry.GetGroupName(), entry.GetGroupStartOrder())
+	}
+	return restarted, preserved
+}
+
+// stop the processes removed from the configuration gracefully. The instances of a program
+// (for example, after numprocs is decreased) are stopped one by one from the highest
+// process_num, the instances kept in configuration are not touched
+func stopRemovedProcesses(procs []*process.Process) {
+	groupProcs := make(map[string][]*process.Process)
+	for _, proc := range procs {
+		groupProcs[proc.GetGroup()] = append(groupProcs[proc.GetGroup()], proc)
 	}
-	removedPrograms := util.Sub(prevPrograms, programs)
-	for _, p := range removedPrograms {
-		s.procMgr.Remove(p)
+	for _, procs := range groupProcs {
+		sort.SliceStable(procs, func(i, j int) bool {
+			return procs[i].GetProcessNum() > procs[j].GetProcessNum()
+		})
+		go func(procs []*process.Process) {
+			for _, proc := range procs {
+				proc.Stop(true)
+				proc.RemoveCgroup()
+			}
+		}(procs)
 	}
 }
 
@@ -515,8 +1073,8 @@ func (s *Supervisor) startHTTPServer() {
 			cond := sync.NewCond(&sync.Mutex{})
 			cond.L.Lock()
 			defer cond.L.Unlock()
-			go s.xmlRPC.StartInetHTTPServer(httpServerConfig.GetString("username", ""),
-				httpServerConfig.GetString("password", ""),
+			go s.xmlRPC.StartInetHTTPServer(getHTTPCredentials(httpServerConfig),
+				getHTTPTLSFiles(httpServerConfig),
 				addr,
 				s,
 				func() {
@@ -536,11 +1094,13 @@ func (s *Supervisor) startHTTPServer() {
 			cond := sync.NewCond(&sync.Mutex{})
 			cond.L.Lock()
 			defer cond.L.Unlock()
-			go s.xmlRPC.StartUnixHTTPServer(httpServerConfig.GetString("username", ""),
-				httpServerConfig.GetString("password", ""),
+			go s.xmlRPC.StartUnixHTTPServer(getHTTPCredentials(httpServerConfig),
 				sockFile,
 				s,
 				func() {
+					if s.xmlRPC.isHTTPServerStartedOnProtocol("unix") {
+						setUnixSocketPermission(sockFile, httpServerConfig)
+					}
 					cond.L.Lock()
 					cond.Signal()
 					cond.L.Unlock()
@@ -551,6 +1111,20 @@ func (s *Supervisor) startHTTPServer() {
 
 }
 
+// get the certificate and key files of the https server
+func getHTTPTLSFiles(httpServerConfig *config.Entry) HTTPTLSFiles {
+	return HTTPTLSFiles{CertFile: httpServerConfig.GetStringExpression("tls_cert_file", ""),
+		KeyFile: httpServerConfig.GetStringExpression("tls_key_file", "")}
+}
+
+// get the basic authentication credentials of the http server
+func getHTTPCredentials(httpServerConfig *config.Entry) HTTPCredentials {
+	return HTTPCredentials{User: httpServerConfig.GetString("username", ""),
+		Password:         httpServerConfig.GetString("password", ""),
+		ReadOnlyUser:     httpServerConfig.GetString("readonly_username", ""),
+		ReadOnlyPassword: httpServerConfig.GetString("readonly_password", "")}
+}
+
 func (s *Supervisor) setSupervisordInfo() {
 	supervisordConf, ok := s.config.GetSupervisord()
 	if ok {
@@ -570,20 +1144,120 @@ func (s *Supervisor) setSupervisordInfo() {
 			logfileMaxbytes := int64(supervisordConf.GetBytes("logfileMaxbytes", 50*1024*1024))
 			logfileBackups := supervisordConf.GetInt("logfileBackups", 10)
 			loglevel := supervisordConf.GetString("loglevel", "info")
-			s.logger = logger.NewLogger("supervisord", logFile, &sync.Mutex{}, logfileMaxbytes, logfileBackups, logEventEmitter)
+			logfileRotate := supervisordConf.GetString("logfile_rotate", "")
+			logfileCompress := supervisordConf.GetBool("logfile_compress", false)
+			s.logger = logger.NewLogger("supervisord", "", "", logFile, &sync.Mutex{}, logfileMaxbytes, logfileBackups, logfileRotate, logfileCompress, logEventEmitter)
 			core := zapcore.NewCore(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()), zapcore.AddSync(s.logger), toLogLevel(loglevel))
 			zap.ReplaceGlobals(zap.New(core))
 		}
-		//set the pid
-		pidfile, err := env.Eval(supervisordConf.GetString("pidfile", "supervisord.pid"))
-		if err == nil {
-			f, err := os.Create(pidfile)
-			if err == nil {
-				fmt.Fprintf(f, "%d", os.Getpid())
-				f.Close()
-			}
+	}
+}
+
+// SetPidfile set the pidfile overriding the pidfile in [supervisord] section
+func (s *Supervisor) SetPidfile(pidfile string) {
+	s.pidfile = pidfile
+}
+
+// get the pidfile set by SetPidfile or the pidfile in [supervisord] section, which is expanded
+// like the other paths in the configuration. It is empty if no pidfile is written
+func (s *Supervisor) getPidfile() (string, error) {
+	pidfile := s.pidfile
+	if pidfile == "" {
+		supervisordConf, ok := s.config.GetSupervisord()
+		if !ok {
+			return "", nil
 		}
+		pidfile = supervisordConf.GetString("pidfile", "supervisord.pid")
+	}
+	env := config.NewStringExpression("here", s.config.GetConfigFileDir())
+	expanded, err := env.Eval(pidfile)
+	if err != nil {
+		return "", err
 	}
+	return process.PathExpand(expanded)
+}
+
+// write the pid of supervisord to the pidfile
+func (s *Supervisor) writePidfile() {
+	pidfile, err := s.getPidfile()
+	if err != nil {
+		zap.S().Errorw("fail to expand the pidfile", "error", err)
+		return
+	}
+	if pidfile == "" {
+		return
+	}
+	if dir := filepath.Dir(pidfile); !isDirectory(dir) {
+		zap.S().Errorw("fail to create the pidfile because its directory does not exist", "pidfile", pidfile, "directory", dir)
+		return
+	}
+	f, err := os.Create(pidfile)
+	if err != nil {
+		zap.S().Errorw("fail to create the pidfile", "pidfile", pidfile, "error", err)
+		return
+	}
+	fmt.Fprintf(f, "%d", os.Getpid())
+	f.Close()
+}
+
+// check if the path is an existing directory
+func isDirectory(path string) bool {
+	fileInfo, err := os.Stat(path)
+	return err == nil && fileInfo.IsDir()
+}
+
+// set the limit of the total memory of all the programs from total_memory_limit and
+// total_memory_action in [supervisord] section
+func (s *Supervisor) setTotalMemoryLimit() {
+	limit := 0
+	action := process.MemoryActionWarn
+	if supervisordConf, ok := s.config.GetSupervisord(); ok {
+		limit = supervisordConf.GetBytes("total_memory_limit", 0)
+		action = supervisordConf.GetString("total_memory_action", process.MemoryActionWarn)
+		if action != process.MemoryActionWarn && action != process.MemoryActionShed {
+			zap.S().Errorw("invalid total_memory_action, warn is used", "total_memory_action", action)
+			action = process.MemoryActionWarn
+		}
+	}
+	s.procMgr.SetTotalMemoryLimit(int64(limit), action)
+}
+
+// set the limit of the total size of all the log files and their backup files from
+// total_log_bytes_limit in [supervisord] section
+func (s *Supervisor) setTotalLogBytesLimit() {
+	limit := 0
+	if supervisordConf, ok := s.config.GetSupervisord(); ok {
+		limit = supervisordConf.GetBytes("total_log_bytes_limit", 0)
+	}
+	logger.SetTotalLogBytesLimit(int64(limit))
+}
+
+// set the url to post to when a program enters Fatal state from fatal_webhook_url in [supervisord] section
+func (s *Supervisor) setFatalWebhook() {
+	url := ""
+	if supervisordConf, ok := s.config.GetSupervisord(); ok {
+		url = supervisordConf.GetString("fatal_webhook_url", "")
+	}
+	s.procMgr.SetFatalWebhook(url)
+}
+
+// set how many recent state transitions are kept for each program from state_history_size in
+// [supervisord] section
+func (s *Supervisor) setStateHistorySize() {
+	size := process.DefaultStateHistorySize
+	if supervisordConf, ok := s.config.GetSupervisord(); ok {
+		size = supervisordConf.GetInt("state_history_size", process.DefaultStateHistorySize)
+	}
+	s.procMgr.SetStateHistorySize(size)
+}
+
+// get how many programs are started at the same time by startAllProcesses from start_concurrency in
+// [supervisord] section, 0 if they are all started at once
+func (s *Supervisor) getStartConcurrency() int {
+	if supervisordConf, ok := s.config.GetSupervisord(); ok {
+		return supervisordConf.GetInt("start_concurrency", 0)
+	}
+	return 0
 }
 
 func toLogLevel(level string) zapcore.Level {
@@ -603,56 +1277,181 @@ func toLogLevel(level string) zapcore.Level {
 
 // ReloadConfig reload the supervisor configuration file
 func (s *Supervisor) ReloadConfig(r *http.Request, args *struct{}, reply *types.ReloadConfigResult) error {
+	result, err := s.reloadConfig()
+	*reply = result
+	return err
+}
+
+// reload the configuration and log the changed groups and the restarted programs
+func (s *Supervisor) reloadConfig() (types.ReloadConfigResult, error) {
 	zap.S().Info("start to reload config")
-	addedGroup, changedGroup, removedGroup, err := s.Reload()
-	if len(addedGroup) > 0 {
-		zap.S().Infow("added groups", "groups", strings.Join(addedGroup, ","))
+	result, err := s.Reload()
+	if len(result.AddedGroup) > 0 {
+		zap.S().Infow("added groups", "groups", strings.Join(result.AddedGroup, ","))
 	}
 
-	if len(changedGroup) > 0 {
-		zap.S().Infow("changed groups", "groups", strings.Join(changedGroup, ","))
+	if len(result.ChangedGroup) > 0 {
+		zap.S().Infow("changed groups", "groups", strings.Join(result.ChangedGroup, ","))
 	}
 
-	if len(removedGroup) > 0 {
-		zap.S().Infow("removed groups", "groups", strings.Join(removedGroup, ","))
+	if len(result.RemovedGroup) > 0 {
+		zap.S().Infow("removed groups", "groups", strings.Join(result.RemovedGroup, ","))
 	}
-	reply.AddedGroup = addedGroup
-	reply.ChangedGroup = changedGroup
-	reply.RemovedGroup = removedGroup
-	return err
+	if len(result.RestartedPrograms) > 0 {
+		zap.S().Infow("restarted programs", "programs", strings.Join(result.RestartedPrograms, ","))
+	}
+	for _, warning := range result.Warnings {
+		zap.S().Warn(warning)
+	}
+	return result, err
+}
+
+// ReloadProgram read the configuration file again and apply it only to the named program, the
+// other programs are not touched. The program added to the configuration is created and started
+// if it is autostarted, the program removed from the configuration is stopped and removed, and the
+// changed program is restarted if it is running. The program items before and after the reload
+// are returned
+func (s *Supervisor) ReloadProgram(r *http.Request, args *struct{ Name string }, reply *types.ReloadProgramResult) error {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	newConfig := config.NewConfig(s.config.GetConfigFile())
+	newConfig.SetPublicKey(s.config.GetPublicKey())
+	if _, err := newConfig.Load(); err != nil {
+		zap.S().Errorw("fail to load configuration, the program is not reloaded", "file", s.config.GetConfigFile(), "program", args.Name, "error", err)
+		return err
+	}
+	prevEntry := s.config.GetProgram(args.Name)
+	entry := newConfig.GetProgram(args.Name)
+	if prevEntry == nil && entry == nil {
+		return fmt.Errorf("fail to find program %s in the configuration", args.Name)
+	}
+	reply.Name = args.Name
+	if prevEntry != nil {
+		reply.Before = prevEntry.String()
+	}
+	if entry != nil {
+		reply.After = entry.String()
+	}
+
+	switch {
+	case entry == nil:
+		zap.S().Infow("the program is removed and will be stopped", "program", args.Name)
+		reply.Action = "removed"
+		s.config.RemoveProgram(args.Name)
+		if proc := s.procMgr.Remove(args.Name); proc != nil {
+			proc.Stop(true)
+			proc.RemoveCgroup()
+		}
+	case prevEntry == nil:
+		zap.S().Infow("the program is added", "program", args.Name)
+		reply.Action = "added"
+		proc := s.procMgr.CreateProcess(s.GetSupervisorID(), s.config.UpdateProgram(entry))
+		if proc.IsAutoStart() {
+			proc.Start(false)
+		}
+	case prevEntry.Hash() == entry.Hash():
+		reply.Action = "unchanged"
+	default:
+		reply.Action = "changed"
+		s.config.UpdateProgram(entry)
+		if proc := s.procMgr.Find(args.Name); proc != nil && isActiveState(proc.GetState()) {
+			zap.S().Infow("the program is changed and it will be restarted", "program", args.Name)
+			proc.Restart(false)
+		}
+	}
+	return nil
+}
+
+// ValidateProgramConfig validate the program sections submitted by client without touching the loaded configuration
+func (s *Supervisor) ValidateProgramConfig(r *http.Request, args *struct{ Ini string }, reply *struct {
+	Valid  bool
+	Errors []string
+}) error {
+	reply.Errors = config.ValidateProgramConfig(args.Ini, s.config.GetConfigFileDir())
+	reply.Valid = len(reply.Errors) == 0
+	return nil
 }
 
-// AddProcessGroup add a process group to the supervisor
+// AddProcessGroup create the programs of a group from the loaded configuration and start the
+// autostart ones. It fails if the group is not in the configuration or it is already added
 func (s *Supervisor) AddProcessGroup(r *http.Request, args *struct{ Name string }, reply *struct{ Success bool }) error {
-	reply.Success = false
+	entries := s.config.GetEntries(func(entry *config.Entry) bool {
+		return entry.IsProgram() && entry.Group == args.Name
+	})
+	if len(entries) <= 0 {
+		return fmt.Errorf("fail to find process group %s in the configuration", args.Name)
+	}
+	if len(s.procMgr.GetGroupProcesses(args.Name)) > 0 {
+		return fmt.Errorf("process group %s is already added", args.Name)
+	}
+	zap.S().Infow("add the process group", "group", args.Name)
+	for _, entry := range entries {
+		s.procMgr.CreateProcess(s.GetSupervisorID(), entry)
+	}
+	for _, entry := range s.config.GetGroups() {
+		if entry.GetGroupName() == args.Name {
+			s.procMgr.SetGroupStartOrder(args.Name, entry.GetGroupStartOrder())
+		}
+	}
+	s.procMgr.StartAutoStartGroup(args.Name)
+	reply.Success = true
 	return nil
 }
 
-// RemoveProcessGroup remove a process group from the supervisor
+// RemoveProcessGroup stop all the programs of a group and remove them from the supervisor. The
+// configuration is not changed, so the group can be added again by AddProcessGroup
 func (s *Supervisor) RemoveProcessGroup(r *http.Request, args *struct{ Name string }, reply *struct{ Success bool }) error {
-	reply.Success = false
+	procs := s.procMgr.GetGroupProcesses(args.Name)
+	if len(procs) <= 0 {
+		return fmt.Errorf("fail to find process group %s", args.Name)
+	}
+	zap.S().Infow("remove the process group", "group", args.Name)
+	s.procMgr.StopGroup(args.Name, true)
+	for _, proc := range procs {
+		s.procMgr.Remove(proc.GetName())
+		proc.RemoveCgroup()
+	}
+	s.procMgr.SetGroupStartOrder(args.Name, nil)
+	reply.Success = true
+	return nil
+}
+
+// GetProcessLogInfo get the metadata of stdout and stderr log of a given program without reading the log content
+func (s *Supervisor) GetProcessLogInfo(r *http.Request, args *struct{ Name string }, reply *struct {
+	StdoutLogInfo logger.LogInfo
+	StderrLogInfo logger.LogInfo
+}) error {
+	proc := s.procMgr.Find(args.Name)
+	if proc == nil {
+		return fmt.Errorf("No such process %s", args.Name)
+	}
+	reply.StdoutLogInfo = proc.GetStdoutLogInfo()
+	reply.StderrLogInfo = proc.GetStderrLogInfo()
 	return nil
 }
 
 // ReadProcessStdoutLog read the stdout log of a given program
-func (s *Supervisor) ReadProcessStdoutLog(r *http.Request, args *ProcessLogReadInfo, reply *struct{ LogData string }) error {
+func (s *Supervisor) ReadProcessStdoutLog(r *http.Request, args *ProcessLogReadInfo, reply *ProcessLogData) error {
 	proc := s.procMgr.Find(args.Name)
 	if proc == nil {
 		return fmt.Errorf("No such process %s", args.Name)
 	}
 	var err error
 	reply.LogData, err = proc.StdoutLog.ReadLog(int64(args.Offset), int64(args.Length))
+	reply.LogData, reply.Binary = logger.EncodeLogData(reply.LogData)
 	return err
 }
 
 // ReadProcessStderrLog read the stderr log of a given program
-func (s *Supervisor) ReadProcessStderrLog(r *http.Request, args *ProcessLogReadInfo, reply *struct{ LogData string }) error {
+func (s *Supervisor) ReadProcessStderrLog(r *http.Request, args *ProcessLogReadInfo, reply *ProcessLogData) error {
 	proc := s.procMgr.Find(args.Name)
 	if proc == nil {
 		return fmt.Errorf("No such process %s", args.Name)
 	}
 	var err error
 	reply.LogData, err = proc.StderrLog.ReadLog(int64(args.Offset), int64(args.Length))
+	reply.LogData, reply.Binary = logger.EncodeLogData(reply.LogData)
 	return err
 }
 
@@ -662,8 +1461,9 @@ func (s *Supervisor) TailProcessStdoutLog(r *http.Request, args *ProcessLogReadI
 	if proc == nil {
 		return fmt.Errorf("No such process %s", args.Name)
 	}
-	var err error
-	reply.LogData, reply.Offset, reply.Overflow, err = proc.StdoutLog.ReadTailLog(int64(args.Offset), int64(args.Length))
+	logData, offset, overflow, err := proc.StdoutLog.ReadTailLog(int64(args.Offset), int64(args.Length))
+	reply.Offset, reply.Overflow = int(offset), overflow
+	reply.LogData, reply.Binary = logger.EncodeLogData(logData)
 	return err
 }
 
@@ -673,11 +1473,45 @@ func (s *Supervisor) TailProcessStderrLog(r *http.Request, args *ProcessLogReadI
 	if proc == nil {
 		return fmt.Errorf("No such process %s", args.Name)
 	}
-	var err error
-	reply.LogData, reply.Offset, reply.Overflow, err = proc.StderrLog.ReadTailLog(int64(args.Offset), int64(args.Length))
+	logData, offset, overflow, err := proc.StderrLog.ReadTailLog(int64(args.Offset), int64(args.Length))
+	reply.Offset, reply.Overflow = int(offset), overflow
+	reply.LogData, reply.Binary = logger.EncodeLogData(logData)
 	return err
 }
 
+// TailProcessLog tail the stdout and stderr of a program together. The stdout lines are followed
+// by the stderr lines, and each line is prefixed with its stream. If the stderr is redirected to
+// stdout, only the stdout log is read because the lines are already interleaved in it. A log
+// which can't be read (like a program without stderr log) is skipped
+func (s *Supervisor) TailProcessLog(r *http.Request, args *ProcessCombinedLogReadInfo, reply *ProcessCombinedTailLog) error {
+	proc := s.procMgr.Find(args.Name)
+	if proc == nil {
+		return fmt.Errorf("No such process %s", args.Name)
+	}
+	var logData strings.Builder
+	tail := func(stream string, log logger.Logger, offset int) (int, error) {
+		data, nextOffset, overflow, err := log.ReadTailLog(int64(offset), int64(args.Length))
+		if err != nil {
+			return offset, err
+		}
+		reply.Overflow = reply.Overflow || overflow
+		lines, consumed := markLogLines(stream, data, args.Length)
+		logData.WriteString(lines)
+		return int(nextOffset) - (len(data) - consumed), nil
+	}
+	var stdoutErr, stderrErr error
+	reply.StdoutOffset, stdoutErr = tail("stdout", proc.StdoutLog, args.StdoutOffset)
+	reply.StderrOffset = args.StderrOffset
+	if !proc.IsStderrRedirected() {
+		reply.StderrOffset, stderrErr = tail("stderr", proc.StderrLog, args.StderrOffset)
+	}
+	reply.LogData, reply.Binary = logger.EncodeLogData(logData.String())
+	if stdoutErr != nil && (stderrErr != nil || proc.IsStderrRedirected()) {
+		return stdoutErr
+	}
+	return nil
+}
+
 // ClearProcessLogs clear the log of a given program
 func (s *Supervisor) ClearProcessLogs(r *http.Request, args *struct{ Name string }, reply *struct{ Success bool }) error {
 	proc := s.procMgr.Find(args.Name)
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ochinchina/supervisord/config"
+	"github.com/ochinchina/supervisord/faults"
+	"github.com/ochinchina/supervisord/process"
+	"github.com/ochinchina/supervisord/types"
+)
+
+func TestGetProcessInfoByStateAndGroup(t *testing.T) {
+	s := NewSupervisor("supervisord.conf")
+	for _, name := range []string{"web2", "web1", "worker"} {
+		group := "web"
+		if name == "worker" {
+			group = "worker"
+		}
+		entry := &config.Entry{ConfigDir: ".", Group: group, Name: "program:" + name}
+		s.GetManager().Add(name, process.NewProcess("supervisord", entry))
+	}
+	names := func(infos []types.ProcessInfo) []string {
+		result := make([]string, 0)
+		for _, info := range infos {
+			result = append(result, info.Name)
+		}
+		return result
+	}
+
+	reply := struct{ AllProcessInfo []types.ProcessInfo }{}
+	s.GetProcessInfoByGroup(nil, &struct{ Group string }{"web"}, &reply)
+	if got := names(reply.AllProcessInfo); len(got) != 2 || got[0] != "web1" || got[1] != "web2" {
+		t.Errorf("expect the sorted programs of group web but got %v", got)
+	}
+
+	s.GetProcessInfoByState(nil, &struct{ States []int }{[]int{int(process.Stopped)}}, &reply)
+	if len(reply.AllProcessInfo) != 3 {
+		t.Errorf("expect all the stopped programs but got %v", names(reply.AllProcessInfo))
+	}
+	s.GetProcessInfoByState(nil, &struct{ States []int }{[]int{int(process.Fatal), int(process.Running)}}, &reply)
+	if len(reply.AllProcessInfo) != 0 {
+		t.Errorf("expect no fatal or running program but got %v", names(reply.AllProcessInfo))
+	}
+}
+
+// create a supervisor with the configuration content loaded, the programs are not created
+func loadTestSupervisor(t *testing.T, content string) *Supervisor {
+	f, err := ioutil.TempFile("", "supervisord-*.conf")
+	if err != nil {
+		t.Fatalf("fail to create the configuration file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(content)
+	f.Close()
+
+	s := NewSupervisor(f.Name())
+	if _, err := s.GetConfig().Load(); err != nil {
+		t.Fatalf("fail to load the configuration: %v", err)
+	}
+	return s
+}
+
+// create a supervisor with the configuration file in a new directory and load it by Reload. The
+// processes are stopped and the directory is removed when the test finishes
+func reloadTestSupervisor(t *testing.T, content string) *Supervisor {
+	dir, err := ioutil.TempDir("", "supervisord-reload-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	configFile := filepath.Join(dir, "supervisord.conf")
+	if err = ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("fail to create the configuration file: %v", err)
+	}
+	s := NewSupervisor(configFile)
+	if _, err = s.Reload(); err != nil {
+		t.Fatalf("fail to load the configuration: %v", err)
+	}
+	t.Cleanup(s.procMgr.StopAllProcesses)
+	return s
+}
+
+func TestCheckHTTPServerTLS(t *testing.T) {
+	s := loadTestSupervisor(t, "[inet_http_server]\nport=127.0.0.1:9001\ntls_cert_file=/etc/supervisor/cert.pem\n")
+	if err := s.checkHTTPServerTLS(); err == nil || !strings.Contains(err.Error(), "both tls_cert_file and tls_key_file") {
+		t.Errorf("expect an error if tls_key_file is not set but got %v", err)
+	}
+}
+
+func TestSendProcessStdinGroup(t *testing.T) {
+	logFile, err := ioutil.TempFile("", "stdin-*.log")
+	if err != nil {
+		t.Fatalf("fail to create the log file: %v", err)
+	}
+	logFile.Close()
+	defer os.Remove(logFile.Name())
+
+	s := loadTestSupervisor(t, "[program:w1]\ncommand=/bin/cat\nstartsecs=0\nstdout_logfile="+logFile.Name()+
+		"\n[program:w2]\ncommand=/bin/cat\n[group:pool]\nprograms=w1,w2\n")
+	for _, entry := range s.GetConfig().GetPrograms() {
+		s.GetManager().Add(entry.GetProgramName(), process.NewProcess("supervisord", entry))
+	}
+	w1 := s.GetManager().Find("w1")
+	w1.Start(true)
+	defer w1.Stop(true)
+
+	reply := struct{ RPCTaskResults []RPCTaskResult }{}
+	if err := s.SendProcessStdinGroup(nil, &struct {
+		Group string
+		Chars string
+	}{"pool", "hello\n"}, &reply); err != nil {
+		t.Fatalf("fail to send the stdin to the group: %v", err)
+	}
+	statuses := make(map[string]int)
+	for _, result := range reply.RPCTaskResults {
+		statuses[result.Name] = result.Status
+	}
+	if len(statuses) != 2 || statuses["w1"] != faults.Success || statuses["w2"] != faults.NotRunning {
+		t.Errorf("expect w1 succeeds and w2 is skipped but got %v", reply.RPCTaskResults)
+	}
+	for i := 0; i < 100; i++ {
+		if data, _ := ioutil.ReadFile(logFile.Name()); string(data) == "hello\n" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("the running program does not receive the stdin")
+}
+
+func TestWaitForExitReturnsOnRestart(t *testing.T) {
+	f, err := ioutil.TempFile("", "supervisord-*.conf")
+	if err != nil {
+		t.Fatalf("fail to create the configuration file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("[program:test]\ncommand=/bin/true\n")
+	f.Close()
+
+	s := NewSupervisor(f.Name())
+	exited := make(chan struct{})
+	go func() {
+		s.WaitForExit()
+		close(exited)
+	}()
+	if s.IsRestarting() {
+		t.Fatal("expect the supervisor is not restarting before Restart")
+	}
+	reply := struct{ Ret bool }{}
+	if err := s.Restart(nil, &struct{}{}, &reply); err != nil || !reply.Ret {
+		t.Fatalf("fail to restart the supervisor: %v", err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("WaitForExit does not return after Restart")
+	}
+	if !s.IsRestarting() {
+		t.Error("expect the supervisor is restarting after Restart")
+	}
+	// restart again does not close the channel twice
+	s.Restart(nil, &struct{}{}, &reply)
+}
+
+func TestWritePidfileOverride(t *testing.T) {
+	s := loadTestSupervisor(t, "[supervisord]\npidfile=%(here)s/supervisord.pid\n[program:test]\ncommand=/bin/true\n")
+	if pidfile, err := s.getPidfile(); err != nil || pidfile != filepath.Join(os.TempDir(), "supervisord.pid") {
+		t.Errorf("expect the pidfile in the configuration directory but got %s, error %v", pidfile, err)
+	}
+
+	dir, err := ioutil.TempDir("", "pidfile")
+	if err != nil {
+		t.Fatalf("fail to create the pidfile directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	s.SetPidfile(filepath.Join(dir, "override.pid"))
+	s.writePidfile()
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "override.pid")); err != nil || string(b) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("expect the pid is written to the pidfile set by --pidfile, got %q, error %v", string(b), err)
+	}
+
+	s = loadTestSupervisor(t, "[program:test]\ncommand=/bin/true\n")
+	if pidfile, err := s.getPidfile(); err != nil || pidfile != "" {
+		t.Errorf("expect no pidfile without [supervisord] section but got %s", pidfile)
+	}
+}
+
+func TestReloadProgram(t *testing.T) {
+	f, err := ioutil.TempFile("", "supervisord-*.conf")
+	if err != nil {
+		t.Fatalf("fail to create the configuration file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	writeConfig := func(content string) {
+		ioutil.WriteFile(f.Name(), []byte(content), 0644)
+	}
+	writeConfig("[program:a]\ncommand=/bin/sleep 1\nautostart=false\n[program:b]\ncommand=/bin/sleep 1\nautostart=false\n")
+	s := NewSupervisor(f.Name())
+	if _, err := s.GetConfig().Load(); err != nil {
+		t.Fatalf("fail to load the configuration: %v", err)
+	}
+	s.createPrograms(nil)
+
+	writeConfig("[program:a]\ncommand=/bin/sleep 2\nautostart=false\n[program:b]\ncommand=/bin/sleep 2\nautostart=false\n[program:c]\ncommand=/bin/sleep 2\nautostart=false\n")
+	reply := types.ReloadProgramResult{}
+	if err := s.ReloadProgram(nil, &struct{ Name string }{"a"}, &reply); err != nil || reply.Action != "changed" {
+		t.Fatalf("expect the program a is changed but got %v, error %v", reply.Action, err)
+	}
+	if !strings.Contains(reply.Before, "command=/bin/sleep 1\n") || !strings.Contains(reply.After, "command=/bin/sleep 2\n") {
+		t.Errorf("unexpected before %q and after %q", reply.Before, reply.After)
+	}
+	if s.GetConfig().GetProgram("a").GetString("command", "") != "/bin/sleep 2" || s.GetConfig().GetProgram("b").GetString("command", "") != "/bin/sleep 1" {
+		t.Error("expect only the program a uses the new configuration")
+	}
+
+	reply = types.ReloadProgramResult{}
+	if err := s.ReloadProgram(nil, &struct{ Name string }{"c"}, &reply); err != nil || reply.Action != "added" || s.procMgr.Find("c") == nil {
+		t.Errorf("expect the program c is added but got %v, error %v", reply.Action, err)
+	}
+
+	writeConfig("[program:b]\ncommand=/bin/sleep 2\nautostart=false\n")
+	reply = types.ReloadProgramResult{}
+	if err := s.ReloadProgram(nil, &struct{ Name string }{"a"}, &reply); err != nil || reply.Action != "removed" || s.procMgr.Find("a") != nil || reply.After != "" {
+		t.Errorf("expect the program a is removed but got %v, error %v", reply.Action, err)
+	}
+	if err := s.ReloadProgram(nil, &struct{ Name string }{"x"}, &reply); err == nil {
+		t.Error("expect an error for the program not in the configuration")
+	}
+}
+
+func TestTailProcessLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail-")
+	if err != nil {
+		t.Fatalf("fail to create the log directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := loadTestSupervisor(t, "[program:test]\ncommand=/bin/sh -c \"echo out1; echo err1 1>&2; printf part 1>&2\"\n"+
+		"startsecs=0\nautorestart=false\nstdout_logfile="+dir+"/stdout.log\nstderr_logfile="+dir+"/stderr.log\n")
+	proc := process.NewProcess("supervisord", s.GetConfig().GetProgram("test"))
+	s.GetManager().Add("test", proc)
+	proc.Start(true)
+	for i := 0; i < 100 && proc.GetState() != process.Exited; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	reply := ProcessCombinedTailLog{}
+	if err := s.TailProcessLog(nil, &ProcessCombinedLogReadInfo{Name: "test", Length: 100}, &reply); err != nil {
+		t.Fatalf("fail to tail the log: %v", err)
+	}
+	if reply.LogData != "stdout: out1\nstderr: err1\n" || reply.StdoutOffset != 5 || reply.StderrOffset != 5 {
+		t.Errorf("unexpected combined log %q with offsets %d and %d", reply.LogData, reply.StdoutOffset, reply.StderrOffset)
+	}
+
+	// the incomplete line is returned if it is longer than the length
+	if err := s.TailProcessLog(nil, &ProcessCombinedLogReadInfo{Name: "test", StdoutOffset: 5, StderrOffset: 5, Length: 3}, &reply); err != nil {
+		t.Fatalf("fail to tail the log: %v", err)
+	}
+	if reply.LogData != "stderr: par\n" || reply.StdoutOffset != 5 || reply.StderrOffset != 8 {
+		t.Errorf("unexpected combined log %q with offsets %d and %d", reply.LogData, reply.StdoutOffset, reply.StderrOffset)
+	}
+}
+
+func TestSetUnixSocketPermission(t *testing.T) {
+	sockFile, err := ioutil.TempFile("", "supervisord-*.sock")
+	if err != nil {
+		t.Fatalf("fail to create the socket file: %v", err)
+	}
+	sockFile.Close()
+	defer os.Remove(sockFile.Name())
+
+	s := loadTestSupervisor(t, "[unix_http_server]\nfile="+sockFile.Name()+"\nchmod=0660\nchown=no-such-user\n")
+	httpServerConfig, _ := s.GetConfig().GetUnixHTTPServer()
+	setUnixSocketPermission(sockFile.Name(), httpServerConfig)
+	if fileInfo, err := os.Stat(sockFile.Name()); err != nil || fileInfo.Mode().Perm() != 0660 {
+		t.Errorf("expect the socket mode 0660 but got %v, error: %v", fileInfo.Mode().Perm(), err)
+	}
+}
+
+func TestRestartProcess(t *testing.T) {
+	s := loadTestSupervisor(t, "[program:w1]\ncommand=/bin/sleep 30\n[program:w2]\ncommand=/bin/sleep 30\n[group:pool]\nprograms=w1,w2\n")
+	for _, entry := range s.GetConfig().GetPrograms() {
+		s.GetManager().Add(entry.GetProgramName(), process.NewProcess("supervisord", entry))
+	}
+	defer s.GetManager().StopAllProcesses()
+	w1 := s.GetManager().Find("w1")
+	w1.Start(true)
+	pid := w1.GetPid()
+
+	reply := struct{ RPCTaskResults []RPCTaskResult }{}
+	if err := s.RestartProcess(nil, &StartProcessArgs{Name: "w1", Wait: true}, &reply); err != nil {
+		t.Fatalf("fail to restart the process: %v", err)
+	}
+	if len(reply.RPCTaskResults) != 1 || reply.RPCTaskResults[0].Status != faults.Success {
+		t.Errorf("expect w1 is restarted but got %v", reply.RPCTaskResults)
+	}
+	if w1.GetState() != process.Running || w1.GetPid() == pid {
+		t.Errorf("expect w1 is running with a new pid, state %v, pid %d", w1.GetState(), w1.GetPid())
+	}
+
+	if err := s.RestartProcessGroup(nil, &StartProcessArgs{Name: "pool", Wait: true}, &reply); err != nil {
+		t.Fatalf("fail to restart the process group: %v", err)
+	}
+	if len(reply.RPCTaskResults) != 2 || s.GetManager().Find("w2").GetState() != process.Running {
+		t.Errorf("expect both processes in the group are restarted but got %v", reply.RPCTaskResults)
+	}
+	if err := s.RestartProcessGroup(nil, &StartProcessArgs{Name: "no-such-group", Wait: true}, &reply); err == nil {
+		t.Error("expect an error if the group does not exist")
+	}
+}
+
+func TestGetSupervisorInfo(t *testing.T) {
+	s := loadTestSupervisor(t, "[program:test1]\ncommand=/bin/true\n[program:test2]\ncommand=/bin/true\n")
+	s.createPrograms(nil)
+	reply := struct{ Info SupervisorInfo }{}
+	if err := s.GetSupervisorInfo(nil, &struct{}{}, &reply); err != nil {
+		t.Fatalf("fail to get the supervisor info: %v", err)
+	}
+	info := reply.Info
+	if info.Pid != os.Getpid() || info.Version != SupervisorVersion || info.Programs != 2 {
+		t.Errorf("unexpected supervisor info %+v", info)
+	}
+	if !filepath.IsAbs(info.ConfigFile) || info.ConfigFile != s.GetConfig().GetConfigFile() {
+		t.Errorf("expect the absolute path of the configuration file but got %s", info.ConfigFile)
+	}
+	if info.StartTime <= 0 || info.StartTime > int(time.Now().Unix()) || info.Uptime < 0 {
+		t.Errorf("unexpected start time %d and uptime %d", info.StartTime, info.Uptime)
+	}
+}
+
+func TestSetProcessAutostart(t *testing.T) {
+	s := loadTestSupervisor(t, "[program:test]\ncommand=/bin/true\n")
+	s.createPrograms(nil)
+	reply := struct{ Success bool }{}
+	if err := s.SetProcessAutostart(nil, &struct {
+		Name    string
+		Enabled bool
+	}{Name: "test", Enabled: false}, &reply); err != nil || !reply.Success {
+		t.Fatalf("fail to set the autostart: %v", err)
+	}
+	if s.procMgr.Find("test").IsAutoStart() {
+		t.Error("expect the program is not autostarted")
+	}
+	configReply := struct{ ConfigInfo []types.ConfigInfo }{}
+	s.GetAllConfigInfo(nil, &struct{}{}, &configReply)
+	if len(configReply.ConfigInfo) != 1 || configReply.ConfigInfo[0].Autostart {
+		t.Errorf("expect the autostart is disabled in the config info but got %+v", configReply.ConfigInfo)
+	}
+
+	if err := s.SetProcessAutostart(nil, &struct {
+		Name    string
+		Enabled bool
+	}{Name: "unknown", Enabled: true}, &reply); err == nil {
+		t.Error("expect an error for the unknown program")
+	}
+}
+
+func TestSetProcessAutostartPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisord-overlay-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	overlayFile := filepath.Join(dir, "overlay.json")
+	s := loadTestSupervisor(t, "[supervisord]\noverlay_file="+overlayFile+"\n[program:test]\ncommand=/bin/true\n")
+	s.createPrograms(nil)
+	reply := struct{ Success bool }{}
+	if err := s.SetProcessAutostart(nil, &struct {
+		Name    string
+		Enabled bool
+	}{Name: "test", Enabled: false}, &reply); err != nil || !reply.Success {
+		t.Fatalf("fail to set the autostart: %v", err)
+	}
+	content, err := ioutil.ReadFile(overlayFile)
+	if err != nil || !strings.Contains(string(content), `"autostart": "false"`) {
+		t.Errorf("expect the autostart is saved to the overlay file but got %s, error: %v", content, err)
+	}
+}
+
+func TestStartAllProcessesWithConcurrency(t *testing.T) {
+	s := loadTestSupervisor(t, "[supervisord]\nstart_concurrency=2\n[program:test1]\ncommand=/bin/true\nstartsecs=0\n[program:test2]\ncommand=/bin/true\nstartsecs=0\n[program:test3]\ncommand=/bin/true\nstartsecs=0\n")
+	s.createPrograms(nil)
+	if s.getStartConcurrency() != 2 {
+		t.Errorf("expect the start concurrency 2 but got %d", s.getStartConcurrency())
+	}
+	reply := struct{ RPCTaskResults []RPCTaskResult }{}
+	if err := s.StartAllProcesses(nil, &struct {
+		Wait bool `default:"true"`
+	}{Wait: true}, &reply); err != nil {
+		t.Fatalf("fail to start all the processes: %v", err)
+	}
+	if len(reply.RPCTaskResults) != 3 {
+		t.Errorf("expect the results of all the 3 processes but got %v", reply.RPCTaskResults)
+	}
+}
+
+func TestReloadWithUnwritablePath(t *testing.T) {
+	content := "[supervisord]\nlogfile=%(here)s/supervisord.log\npidfile=%(here)s/supervisord.pid\ncheck_writable_paths=true\n" +
+		"[program:test]\ncommand=/bin/sleep 10\nautostart=false\nstdout_logfile=%(here)s/logs/test.log\n"
+	logDir, err := ioutil.TempDir("", "supervisord-logs-")
+	if err != nil {
+		t.Fatalf("fail to create the directory: %v", err)
+	}
+	defer os.RemoveAll(logDir)
+	s := reloadTestSupervisor(t, strings.Replace(content, "%(here)s/logs", logDir, 1))
+
+	// the pre-flight check exits the supervisord only when it starts
+	os.RemoveAll(logDir)
+	if _, err = s.Reload(); err != nil {
+		t.Errorf("expect the reload is not failed by the unwritable path but got %v", err)
+	}
+	if s.procMgr.Find("test") == nil {
+		t.Error("expect the program is kept after reload")
+	}
+}
+
+func TestReloadWithMissingTLSCert(t *testing.T) {
+	content := "[supervisord]\nlogfile=%(here)s/supervisord.log\npidfile=%(here)s/supervisord.pid\n[program:test]\ncommand=/bin/sleep 10\nautostart=false\n"
+	s := reloadTestSupervisor(t, content)
+
+	content += "[inet_http_server]\nport=127.0.0.1:0\ntls_cert_file=%(here)s/cert.pem\ntls_key_file=%(here)s/key.pem\n"
+	content = strings.Replace(content, "/bin/sleep 10", "/bin/sleep 20", 1)
+	ioutil.WriteFile(s.GetConfig().GetConfigFile(), []byte(content), 0644)
+	result, err := s.Reload()
+	if err != nil || len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "tls_cert_file") {
+		t.Errorf("expect the reload returns the warning of the missing certificate but got %v, %v", result.Warnings, err)
+	}
+	if s.xmlRPC.isHTTPServerStartedOnProtocol("tcp") {
+		t.Error("expect the http server is not restarted with the invalid configuration")
+	}
+	if entry := s.GetConfig().GetProgram("test"); entry == nil || entry.GetString("command", "") != "/bin/sleep 20" {
+		t.Error("expect the changed program is applied although the http server configuration is invalid")
+	}
+}
+
+func TestReloadWithUnresolvableHost(t *testing.T) {
+	content := "[supervisord]\nlogfile=%(here)s/supervisord.log\npidfile=%(here)s/supervisord.pid\n"
+	s := reloadTestSupervisor(t, content)
+
+	prevLookupHost := lookupHost
+	defer func() { lookupHost = prevLookupHost }()
+	lookupHost = func(host string) ([]string, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	content += "[inet_http_server]\nport=supervisord.test:9001\n"
+	ioutil.WriteFile(s.GetConfig().GetConfigFile(), []byte(content), 0644)
+	result, err := s.Reload()
+	if err != nil || len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "fail to resolve") {
+		t.Errorf("expect the reload returns the warning of the unresolvable host but got %v, %v", result.Warnings, err)
+	}
+}
+
+func TestRunInProcessContext(t *testing.T) {
+	s := loadTestSupervisor(t, "[program:test]\ncommand=/bin/sleep 10\nautostart=false\nenvironment=GREETING=hello\ndirectory=/tmp\n")
+	s.createPrograms(nil)
+
+	reply := struct {
+		Stdout   string
+		Stderr   string
+		ExitCode int
+	}{}
+	args := RunInProcessContextArgs{Name: "test", Command: []string{"/bin/sh", "-c", "echo $GREETING $PWD; exit 2"}}
+	if err := s.RunInProcessContext(nil, &args, &reply); err != nil || reply.ExitCode != 2 || reply.Stdout != "hello /tmp\n" {
+		t.Errorf("expect the command runs in the program context but got %+v, %v", reply, err)
+	}
+	args = RunInProcessContextArgs{Name: "test", Command: []string{"/bin/sleep", "10"}, TimeoutSecs: 1}
+	if err := s.RunInProcessContext(nil, &args, &reply); err == nil || reply.ExitCode != -1 {
+		t.Errorf("expect the command is killed after the timeout but got %+v, %v", reply, err)
+	}
+	args = RunInProcessContextArgs{Name: "unknown", Command: []string{"/bin/true"}}
+	if err := s.RunInProcessContext(nil, &args, &reply); err == nil {
+		t.Error("expect an error for the unknown program")
+	}
+}
+
+func TestLogEventsAfterReload(t *testing.T) {
+	content := "[supervisord]\nlogfile=%(here)s/supervisord.log\npidfile=%(here)s/supervisord.pid\n[program:test]\ncommand=/bin/sleep 10\nautostart=false\nstdout_logfile=/dev/null\n"
+	s := reloadTestSupervisor(t, content)
+	reload := func() {
+		if _, err := s.Reload(); err != nil {
+			t.Fatalf("fail to load the configuration: %v", err)
+		}
+	}
+	proc := s.procMgr.Find("test")
+
+	reply := struct{ Success bool }{}
+	s.SetProcessLogEvents(nil, &struct {
+		Name   string
+		Stdout bool
+		Stderr bool
+	}{"test", true, false}, &reply)
+	if !proc.IsStdoutEventsEnabled() {
+		t.Fatal("expect the stdout log events are enabled at runtime")
+	}
+	reload()
+	if proc.IsStdoutEventsEnabled() {
+		t.Error("expect the runtime override is discarded by reload")
+	}
+
+	ioutil.WriteFile(s.config.GetConfigFile(), []byte(content+"stdout_events_enabled=true\n"), 0644)
+	reload()
+	if s.procMgr.Find("test") != proc || !proc.IsStdoutEventsEnabled() {
+		t.Error("expect the changed stdout_events_enabled takes effect on the kept program")
+	}
+}
+
+func TestAddRemoveProcessGroup(t *testing.T) {
+	s := loadTestSupervisor(t, "[group:g]\nprograms=a,b\n[program:a]\ncommand=/bin/sleep 30\nstartsecs=0\n[program:b]\ncommand=/bin/sleep 30\nautostart=false\n")
+	defer s.procMgr.StopAllProcesses()
+	reply := struct{ Success bool }{}
+	if err := s.AddProcessGroup(nil, &struct{ Name string }{"unknown"}, &reply); err == nil {
+		t.Error("expect an error to add a group not in the configuration")
+	}
+	if err := s.AddProcessGroup(nil, &struct{ Name string }{"g"}, &reply); err != nil || !reply.Success {
+		t.Fatalf("fail to add the group: %v", err)
+	}
+	a, b := s.procMgr.Find("a"), s.procMgr.Find("b")
+	if a == nil || b == nil {
+		t.Fatal("expect the programs of the group are added")
+	}
+	for i := 0; i < 100 && a.GetState() != process.Running; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if a.GetState() != process.Running || b.GetState() != process.Stopped {
+		t.Errorf("expect only the autostart program is started, a: %v, b: %v", a.GetState(), b.GetState())
+	}
+	if err := s.AddProcessGroup(nil, &struct{ Name string }{"g"}, &reply); err == nil {
+		t.Error("expect an error to add the group already added")
+	}
+
+	if err := s.RemoveProcessGroup(nil, &struct{ Name string }{"g"}, &reply); err != nil || !reply.Success {
+		t.Fatalf("fail to remove the group: %v", err)
+	}
+	if a.GetState() == process.Running {
+		t.Error("expect the programs of the group are stopped")
+	}
+	if s.procMgr.Find("a") != nil || s.procMgr.Find("b") != nil {
+		t.Error("expect the programs of the group are removed")
+	}
+	if err := s.RemoveProcessGroup(nil, &struct{ Name string }{"g"}, &reply); err == nil {
+		t.Error("expect an error to remove the group already removed")
+	}
+}
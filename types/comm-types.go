@@ -20,6 +20,51 @@ type ProcessInfo struct {
 	StdoutLogfile string `xml:"stdout_logfile" json:"stdout_logfile"`
 	StderrLogfile string `xml:"stderr_logfile" json:"stderr_logfile"`
 	Pid           int    `xml:"pid" json:"pid"`
+	// the unix time of next start retry if the program is in BACKOFF state, otherwise 0
+	NextRetryAt      int `xml:"next_retry_at" json:"next_retry_at"`
+	RetriesRemaining int `xml:"retries_remaining" json:"retries_remaining"`
+	// true if the program needs to be restarted to stop/kill it with its children as a group
+	GroupKillRestartRequired bool `xml:"group_kill_restart_required" json:"group_kill_restart_required"`
+	// the last stderr line when the program exited unexpectedly if capture_fatal_reason is enabled
+	LastError string `xml:"last_error" json:"last_error"`
+	// true if the program is not autostarted because its autostart or autostart_if does not match this host
+	AutostartSkipped bool `xml:"autostart_skipped" json:"autostart_skipped"`
+	// the bytes and lines written to stdout/stderr since the program is started
+	StdoutBytes int `xml:"stdout_bytes" json:"stdout_bytes"`
+	StdoutLines int `xml:"stdout_lines" json:"stdout_lines"`
+	StderrBytes int `xml:"stderr_bytes" json:"stderr_bytes"`
+	StderrLines int `xml:"stderr_lines" json:"stderr_lines"`
+	// the bytes and lines written to stdout/stderr by all the runs of the program
+	TotalStdoutBytes int `xml:"total_stdout_bytes" json:"total_stdout_bytes"`
+	TotalStdoutLines int `xml:"total_stdout_lines" json:"total_stdout_lines"`
+	TotalStderrBytes int `xml:"total_stderr_bytes" json:"total_stderr_bytes"`
+	TotalStderrLines int `xml:"total_stderr_lines" json:"total_stderr_lines"`
+	// true if the program is running and, if ready_regex is set, it writes a line matching ready_regex
+	Ready bool `xml:"ready" json:"ready"`
+	// the output lines matching alive_regex and dead_regex since the program is started
+	AliveMatches int `xml:"alive_matches" json:"alive_matches"`
+	DeadMatches  int `xml:"dead_matches" json:"dead_matches"`
+	// the result of the last health check by healthcheck_url, "passing" or "failing: <reason>"
+	HealthCheck string `xml:"health_check" json:"health_check"`
+	// how many times the program is restarted in flap_window_secs if flap_max is set
+	FlapCount int `xml:"flap_count" json:"flap_count"`
+	// the command line the program is started with, or will be started with if it is not started yet
+	Argv []string `xml:"argv" json:"argv"`
+	// the unix time the program is restarted by max_lifetime_secs, 0 if no restart is scheduled
+	ScheduledRestartAt int `xml:"scheduled_restart_at" json:"scheduled_restart_at"`
+}
+
+// ConfigInfo the configuration of a program
+type ConfigInfo struct {
+	Name                string `xml:"name" json:"name"`
+	Group               string `xml:"group" json:"group"`
+	Command             string `xml:"command" json:"command"`
+	Autostart           bool   `xml:"autostart" json:"autostart"`
+	Priority            int    `xml:"priority" json:"priority"`
+	StdoutLogfile       string `xml:"stdout_logfile" json:"stdout_logfile"`
+	StderrLogfile       string `xml:"stderr_logfile" json:"stderr_logfile"`
+	StdoutEventsEnabled bool   `xml:"stdout_events_enabled" json:"stdout_events_enabled"`
+	StderrEventsEnabled bool   `xml:"stderr_events_enabled" json:"stderr_events_enabled"`
 }
 
 // ReloadConfigResult the result of supervisor configuration reloading
@@ -27,6 +72,32 @@ type ReloadConfigResult struct {
 	AddedGroup   []string
 	ChangedGroup []string
 	RemovedGroup []string
+	// the programs at process granularity
+	AddedPrograms     []string
+	RemovedPrograms   []string
+	RestartedPrograms []string // the running programs restarted because their configuration is changed
+	PreservedPrograms []string // the programs not restarted by the reload
+	Warnings          []string // the problems which don't fail the reload, like the http server kept with the previous configuration
+}
+
+// ReloadProgramResult the result of reloading the configuration of a single program
+type ReloadProgramResult struct {
+	Name   string
+	Action string // one of added, changed, unchanged and removed
+	Before string // the program items before the reload, empty if the program is added
+	After  string // the program items after the reload, empty if the program is removed
+}
+
+// ProcessStateTransition a state transition of the program
+type ProcessStateTransition struct {
+	// the unix time of the transition
+	Time          int    `xml:"time" json:"time"`
+	FromState     int    `xml:"from_state" json:"from_state"`
+	FromStatename string `xml:"from_statename" json:"from_statename"`
+	ToState       int    `xml:"to_state" json:"to_state"`
+	ToStatename   string `xml:"to_statename" json:"to_statename"`
+	// the exit status if the program exited in the transition, otherwise -1
+	ExitStatus int `xml:"exitstatus" json:"exitstatus"`
 }
 
 // ProcessSignal process signal includes program name and signal sent to it
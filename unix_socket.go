@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+
+	"github.com/ochinchina/supervisord/config"
+	"github.com/ochinchina/supervisord/process"
+	"go.uber.org/zap"
+)
+
+// set the mode and the owner of the unix domain socket file by the chmod (an octal mode like 0770)
+// and chown (USER or USER:GROUP) settings in the unix_http_server section. The socket file keeps
+// its default mode or owner if the setting is invalid or can't be applied
+func setUnixSocketPermission(sockFile string, httpServerConfig *config.Entry) {
+	if httpServerConfig.GetString("chmod", "") != "" {
+		if mode := httpServerConfig.GetOctal("chmod", -1); mode < 0 || mode > 0777 {
+			zap.S().Errorw("the chmod of the unix socket is not a valid octal mode", "file", sockFile, "chmod", httpServerConfig.GetString("chmod", ""))
+		} else if err := os.Chmod(sockFile, os.FileMode(mode)); err != nil {
+			zap.S().Errorw("fail to change the mode of the unix socket", "file", sockFile, "error", err)
+		}
+	}
+	if owner := httpServerConfig.GetString("chown", ""); owner != "" {
+		if uid, gid, err := process.LookupUser(owner); err != nil {
+			zap.S().Errorw("fail to find the owner of the unix socket, keep the default owner", "file", sockFile, "chown", owner, "error", err)
+		} else if err = os.Chown(sockFile, int(uid), int(gid)); err != nil {
+			zap.S().Errorw("fail to change the owner of the unix socket", "file", sockFile, "chown", owner, "error", err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ochinchina/supervisord/process"
+)
+
+// validate the configuration without starting the http server, daemonizing or spawning any
+// program. The configuration is loaded, the resources required by supervisord are checked, and
+// the command binary and user of each program are checked. All the problems found are printed
+//
+// Return the exit code: 0 if the configuration is valid, otherwise 1
+func validateConfiguration() int {
+	loadEnvFile()
+	s, err := createSupervisor()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fail to load the public key: %v\n", err)
+		return 1
+	}
+	if _, err = s.GetConfig().Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "fail to load the configuration %s: %v\n", s.GetConfig().GetConfigFile(), err)
+		return 1
+	}
+	problems := make([]string, 0)
+	if err = s.checkRequiredResources(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, entry := range s.GetConfig().GetPrograms() {
+		problems = append(problems, process.CheckProgram(entry)...)
+	}
+	for _, entry := range s.GetConfig().GetEventListeners() {
+		problems = append(problems, process.CheckProgram(entry)...)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "the configuration %s is invalid:\n", s.GetConfig().GetConfigFile())
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		return 1
+	}
+	fmt.Printf("the configuration %s is valid\n", s.GetConfig().GetConfigFile())
+	return 0
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto/sha1"
+	"crypto/tls"
 	"encoding/hex"
 	"go.uber.org/zap"
 	"io"
@@ -11,81 +12,114 @@ import (
 	"strings"
 
 	"github.com/gorilla/rpc"
+	rpc2 "github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
 	"github.com/ochinchina/gorilla-xmlrpc/xml"
 )
 
 // XMLRPC mange the XML RPC servers
 // start XML RPC servers to accept the XML RPC request from client side
 type XMLRPC struct {
-	// all the listeners to accept the XML RPC request
-	listeners map[string]net.Listener
+	// all the listeners to accept the XML RPC request, the tcp server may listen on more than one address
+	listeners map[string][]net.Listener
+}
+
+// HTTPTLSFiles the certificate and private key files to serve https, both are empty to serve
+// plaintext http
+type HTTPTLSFiles struct {
+	CertFile string
+	KeyFile  string
+}
+
+// HTTPCredentials the basic authentication credentials of the http server
+type HTTPCredentials struct {
+	User     string
+	Password string
+	// the read-only user can only call the RPC methods which don't change anything
+	ReadOnlyUser     string
+	ReadOnlyPassword string
 }
 
 type httpBasicAuth struct {
-	user     string
-	password string
-	handler  http.Handler
+	credentials HTTPCredentials
+	handler     http.Handler
 }
 
-// create a new HttpBasicAuth oject with user name, password and the http request handler
-func newHTTPBasicAuth(user string, password string, handler http.Handler) *httpBasicAuth {
-	if user != "" && password != "" {
+// create a new HttpBasicAuth oject with the credentials and the http request handler
+func newHTTPBasicAuth(credentials HTTPCredentials, handler http.Handler) *httpBasicAuth {
+	if credentials.User != "" && credentials.Password != "" {
 		zap.S().Debug("require authentication")
 	}
-	return &httpBasicAuth{user: user, password: password, handler: handler}
+	return &httpBasicAuth{credentials: credentials, handler: handler}
 }
 
 func (h *httpBasicAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if h.user == "" || h.password == "" {
+	if h.credentials.User == "" || h.credentials.Password == "" {
 		zap.S().Debug("no auth required")
 		h.handler.ServeHTTP(w, r)
 		return
 	}
 	username, password, ok := r.BasicAuth()
-	if ok && username == h.user {
-		if strings.HasPrefix(h.password, "{SHA}") {
-			zap.S().Debug("auth with SHA")
-			hash := sha1.New()
-			io.WriteString(hash, password)
-			if hex.EncodeToString(hash.Sum(nil)) == h.password[5:] {
-				h.handler.ServeHTTP(w, r)
-				return
-			}
-		} else if password == h.password {
-			zap.S().Debug("Auth with normal password")
-			h.handler.ServeHTTP(w, r)
-			return
-		}
+	if ok && username == h.credentials.User && checkPassword(password, h.credentials.Password) {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	if ok && h.credentials.ReadOnlyUser != "" && h.credentials.ReadOnlyPassword != "" &&
+		username == h.credentials.ReadOnlyUser && checkPassword(password, h.credentials.ReadOnlyPassword) {
+		h.handler.ServeHTTP(w, withReadOnlyUser(r))
+		return
 	}
 	w.Header().Set("WWW-Authenticate", "Basic realm=\"supervisor\"")
 	w.WriteHeader(401)
 }
 
+// check if the password matches the expected one, which can be a SHA1 hash with "{SHA}" prefix
+func checkPassword(password string, expected string) bool {
+	if strings.HasPrefix(expected, "{SHA}") {
+		zap.S().Debug("auth with SHA")
+		hash := sha1.New()
+		io.WriteString(hash, password)
+		return hex.EncodeToString(hash.Sum(nil)) == expected[5:]
+	}
+	zap.S().Debug("Auth with normal password")
+	return password == expected
+}
+
 // NewXMLRPC create a new XML RPC object
 func NewXMLRPC() *XMLRPC {
-	return &XMLRPC{listeners: make(map[string]net.Listener)}
+	return &XMLRPC{listeners: make(map[string][]net.Listener)}
 }
 
 // Stop stop network listening
 func (p *XMLRPC) Stop() {
 	zap.S().Info("stop listening")
-	for _, listener := range p.listeners {
-		listener.Close()
+	for _, listeners := range p.listeners {
+		for _, listener := range listeners {
+			listener.Close()
+		}
 	}
-	p.listeners = make(map[string]net.Listener)
+	p.listeners = make(map[string][]net.Listener)
 }
 
 // StartUnixHTTPServer start http server on unix domain socket with path listenAddr. If both user and password are not empty, the user
 // must provide user and password for basic authentication when making a XML RPC request.
-func (p *XMLRPC) StartUnixHTTPServer(user string, password string, listenAddr string, s *Supervisor, startedCb func()) {
+func (p *XMLRPC) StartUnixHTTPServer(credentials HTTPCredentials, listenAddr string, s *Supervisor, startedCb func()) {
 	os.Remove(listenAddr)
-	p.startHTTPServer(user, password, "unix", listenAddr, s, startedCb)
+	p.startHTTPServer(credentials, HTTPTLSFiles{}, "unix", []string{listenAddr}, s, startedCb)
 }
 
 // StartInetHTTPServer start http server on tcp with path listenAddr. If both user and password are not empty, the user
-// must provide user and password for basic authentication when making a XML RPC request.
-func (p *XMLRPC) StartInetHTTPServer(user string, password string, listenAddr string, s *Supervisor, startedCb func()) {
-	p.startHTTPServer(user, password, "tcp", listenAddr, s, startedCb)
+// must provide user and password for basic authentication when making a XML RPC request. If the certificate and key
+// files are set, https is served instead of plaintext http. The listenAddr may be an IPv6 address in brackets like
+// [::1]:9001 or a hostname, which is served on all the addresses it resolves to.
+func (p *XMLRPC) StartInetHTTPServer(credentials HTTPCredentials, tlsFiles HTTPTLSFiles, listenAddr string, s *Supervisor, startedCb func()) {
+	addrs, err := resolveInetListenAddrs(listenAddr)
+	if err != nil {
+		startedCb()
+		zap.S().Fatalw("fail to listen on address", "addr", listenAddr, "protocol", "tcp", "error", err)
+		return
+	}
+	p.startHTTPServer(credentials, tlsFiles, "tcp", addrs, s, startedCb)
 }
 
 func (p *XMLRPC) isHTTPServerStartedOnProtocol(protocol string) bool {
@@ -93,34 +127,71 @@ func (p *XMLRPC) isHTTPServerStartedOnProtocol(protocol string) bool {
 	return ok
 }
 
-func (p *XMLRPC) startHTTPServer(user string, password string, protocol string, listenAddr string, s *Supervisor, startedCb func()) {
+func (p *XMLRPC) startHTTPServer(credentials HTTPCredentials, tlsFiles HTTPTLSFiles, protocol string, listenAddrs []string, s *Supervisor, startedCb func()) {
 	if p.isHTTPServerStartedOnProtocol(protocol) {
 		startedCb()
 		return
 	}
 	mux := http.NewServeMux()
-	mux.Handle("/RPC2", newHTTPBasicAuth(user, password, p.createRPCServer(s)))
+	rpcServer, rpcCodec := p.createRPCServer(s)
+	mux.Handle("/RPC2", newHTTPBasicAuth(credentials, newReadOnlyFilter(xmlRPCInspector{rpcCodec}, rpcServer)))
+	jsonRPCServer, jsonRPCCodec := p.createJSONRPCServer(s)
+	mux.Handle("/jsonrpc", newHTTPBasicAuth(credentials, newReadOnlyFilter(jsonRPCInspector{jsonRPCCodec}, jsonRPCServer)))
 	progRestHandler := NewSupervisorRestful(s).CreateProgramHandler()
-	mux.Handle("/program/", newHTTPBasicAuth(user, password, progRestHandler))
+	mux.Handle("/program/", newHTTPBasicAuth(credentials, newReadOnlyFilter(nil, progRestHandler)))
 	supervisorRestHandler := NewSupervisorRestful(s).CreateSupervisorHandler()
-	mux.Handle("/supervisor/", newHTTPBasicAuth(user, password, supervisorRestHandler))
+	mux.Handle("/supervisor/", newHTTPBasicAuth(credentials, newReadOnlyFilter(nil, supervisorRestHandler)))
 	logtailHandler := NewLogtail(s).CreateHandler()
-	mux.Handle("/logtail/", newHTTPBasicAuth(user, password, logtailHandler))
+	mux.Handle("/logtail/", newHTTPBasicAuth(credentials, newReadOnlyFilter(nil, logtailHandler)))
+	apiHandler := NewProcessAPI(s).CreateHandler()
+	mux.Handle("/api/", newHTTPBasicAuth(credentials, newReadOnlyFilter(nil, apiHandler)))
+	eventStreamHandler := NewEventStream(s).CreateHandler()
+	mux.Handle("/ws/events", newHTTPBasicAuth(credentials, newReadOnlyFilter(nil, eventStreamHandler)))
+	// the readiness probes of the orchestrators can't send the credentials, so /readyz is not authenticated
+	mux.Handle("/readyz", NewReadiness(s).CreateHandler())
+	if protocol == "tcp" && s.isMetricsEnabled() {
+		metricsHandler := NewMetrics(s).CreateHandler()
+		mux.Handle("/metrics", newHTTPBasicAuth(credentials, newReadOnlyFilter(nil, metricsHandler)))
+	}
 	webguiHandler := NewSupervisorWebgui(s).CreateHandler()
-	mux.Handle("/", newHTTPBasicAuth(user, password, webguiHandler))
-	listener, err := net.Listen(protocol, listenAddr)
-	if err == nil {
-		zap.S().Infow("success to listen on address", "addr", listenAddr, "protocol", protocol)
-		p.listeners[protocol] = listener
-		startedCb()
-		http.Serve(listener, mux)
-	} else {
-		startedCb()
-		zap.S().Fatalw("fail to listen on address", "addr", listenAddr, "protocol", protocol)
+	mux.Handle("/", newHTTPBasicAuth(credentials, newReadOnlyFilter(nil, webguiHandler)))
+	listeners := make([]net.Listener, 0)
+	for _, listenAddr := range listenAddrs {
+		listener, err := p.listen(protocol, listenAddr, tlsFiles)
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			startedCb()
+			zap.S().Fatalw("fail to listen on address", "addr", listenAddr, "protocol", protocol, "error", err)
+			return
+		}
+		zap.S().Infow("success to listen on address", "addr", listenAddr, "protocol", protocol, "tls", tlsFiles.CertFile != "")
+		listeners = append(listeners, listener)
+	}
+	p.listeners[protocol] = listeners
+	startedCb()
+	for _, listener := range listeners[1:] {
+		go http.Serve(listener, mux)
 	}
+	http.Serve(listeners[0], mux)
+}
 
+// listen on the address, the connections are served over tls if the certificate file is set
+func (p *XMLRPC) listen(protocol string, listenAddr string, tlsFiles HTTPTLSFiles) (net.Listener, error) {
+	listener, err := net.Listen(protocol, listenAddr)
+	if err != nil || tlsFiles.CertFile == "" {
+		return listener, err
+	}
+	cert, err := tls.LoadX509KeyPair(tlsFiles.CertFile, tlsFiles.KeyFile)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{cert}}), nil
 }
-func (p *XMLRPC) createRPCServer(s *Supervisor) *rpc.Server {
+
+func (p *XMLRPC) createRPCServer(s *Supervisor) (*rpc.Server, *xml.Codec) {
 	RPC := rpc.NewServer()
 	xmlrpcCodec := xml.NewCodec()
 	RPC.RegisterCodec(xmlrpcCodec, "text/xml")
@@ -130,6 +201,7 @@ func (p *XMLRPC) createRPCServer(s *Supervisor) *rpc.Server {
 	xmlrpcCodec.RegisterAlias("supervisor.getAPIVersion", "Supervisor.GetVersion")
 	xmlrpcCodec.RegisterAlias("supervisor.getIdentification", "Supervisor.GetIdentification")
 	xmlrpcCodec.RegisterAlias("supervisor.getState", "Supervisor.GetState")
+	xmlrpcCodec.RegisterAlias("supervisor.getSupervisorInfo", "Supervisor.GetSupervisorInfo")
 	xmlrpcCodec.RegisterAlias("supervisor.getPID", "Supervisor.GetPID")
 	xmlrpcCodec.RegisterAlias("supervisor.readLog", "Supervisor.ReadLog")
 	xmlrpcCodec.RegisterAlias("supervisor.clearLog", "Supervisor.ClearLog")
@@ -138,25 +210,57 @@ func (p *XMLRPC) createRPCServer(s *Supervisor) *rpc.Server {
 	xmlrpcCodec.RegisterAlias("supervisor.getProcessInfo", "Supervisor.GetProcessInfo")
 	xmlrpcCodec.RegisterAlias("supervisor.getSupervisorVersion", "Supervisor.GetVersion")
 	xmlrpcCodec.RegisterAlias("supervisor.getAllProcessInfo", "Supervisor.GetAllProcessInfo")
+	xmlrpcCodec.RegisterAlias("supervisor.getProcessInfoByState", "Supervisor.GetProcessInfoByState")
+	xmlrpcCodec.RegisterAlias("supervisor.getProcessInfoByGroup", "Supervisor.GetProcessInfoByGroup")
+	xmlrpcCodec.RegisterAlias("supervisor.getProcessStateHistory", "Supervisor.GetProcessStateHistory")
 	xmlrpcCodec.RegisterAlias("supervisor.startProcess", "Supervisor.StartProcess")
 	xmlrpcCodec.RegisterAlias("supervisor.startAllProcesses", "Supervisor.StartAllProcesses")
 	xmlrpcCodec.RegisterAlias("supervisor.startProcessGroup", "Supervisor.StartProcessGroup")
 	xmlrpcCodec.RegisterAlias("supervisor.stopProcess", "Supervisor.StopProcess")
+	xmlrpcCodec.RegisterAlias("supervisor.softStopProcess", "Supervisor.SoftStopProcess")
+	xmlrpcCodec.RegisterAlias("supervisor.setProcessLogEvents", "Supervisor.SetProcessLogEvents")
+	xmlrpcCodec.RegisterAlias("supervisor.setProcessAutostart", "Supervisor.SetProcessAutostart")
+	xmlrpcCodec.RegisterAlias("supervisor.getAllConfigInfo", "Supervisor.GetAllConfigInfo")
+	xmlrpcCodec.RegisterAlias("supervisor.explainProcessConfig", "Supervisor.ExplainProcessConfig")
 	xmlrpcCodec.RegisterAlias("supervisor.stopProcessGroup", "Supervisor.StopProcessGroup")
 	xmlrpcCodec.RegisterAlias("supervisor.stopAllProcesses", "Supervisor.StopAllProcesses")
 	xmlrpcCodec.RegisterAlias("supervisor.signalProcess", "Supervisor.SignalProcess")
 	xmlrpcCodec.RegisterAlias("supervisor.signalProcessGroup", "Supervisor.SignalProcessGroup")
 	xmlrpcCodec.RegisterAlias("supervisor.signalAllProcesses", "Supervisor.SignalAllProcesses")
 	xmlrpcCodec.RegisterAlias("supervisor.sendProcessStdin", "Supervisor.SendProcessStdin")
+	xmlrpcCodec.RegisterAlias("supervisor.sendProcessStdinGroup", "Supervisor.SendProcessStdinGroup")
 	xmlrpcCodec.RegisterAlias("supervisor.sendRemoteCommEvent", "Supervisor.SendRemoteCommEvent")
+	xmlrpcCodec.RegisterAlias("supervisor.runInProcessContext", "Supervisor.RunInProcessContext")
+	xmlrpcCodec.RegisterAlias("supervisor.resetProcessCounters", "Supervisor.ResetProcessCounters")
+	xmlrpcCodec.RegisterAlias("supervisor.startProcessesBySelector", "Supervisor.StartProcessesBySelector")
 	xmlrpcCodec.RegisterAlias("supervisor.reloadConfig", "Supervisor.ReloadConfig")
+	xmlrpcCodec.RegisterAlias("supervisor.reloadProgram", "Supervisor.ReloadProgram")
 	xmlrpcCodec.RegisterAlias("supervisor.addProcessGroup", "Supervisor.AddProcessGroup")
 	xmlrpcCodec.RegisterAlias("supervisor.removeProcessGroup", "Supervisor.RemoveProcessGroup")
 	xmlrpcCodec.RegisterAlias("supervisor.readProcessStdoutLog", "Supervisor.ReadProcessStdoutLog")
 	xmlrpcCodec.RegisterAlias("supervisor.readProcessStderrLog", "Supervisor.ReadProcessStderrLog")
 	xmlrpcCodec.RegisterAlias("supervisor.tailProcessStdoutLog", "Supervisor.TailProcessStdoutLog")
 	xmlrpcCodec.RegisterAlias("supervisor.tailProcessStderrLog", "Supervisor.TailProcessStderrLog")
+	xmlrpcCodec.RegisterAlias("supervisor.tailProcessLog", "Supervisor.TailProcessLog")
+	xmlrpcCodec.RegisterAlias("supervisor.getProcessLogInfo", "Supervisor.GetProcessLogInfo")
+	xmlrpcCodec.RegisterAlias("supervisor.getLogCursor", "Supervisor.GetLogCursor")
+	xmlrpcCodec.RegisterAlias("supervisor.advanceLogCursor", "Supervisor.AdvanceLogCursor")
 	xmlrpcCodec.RegisterAlias("supervisor.clearProcessLogs", "Supervisor.ClearProcessLogs")
 	xmlrpcCodec.RegisterAlias("supervisor.clearAllProcessLogs", "Supervisor.ClearAllProcessLogs")
-	return RPC
+	xmlrpcCodec.RegisterAlias("supervisor.validateProgramConfig", "Supervisor.ValidateProgramConfig")
+	xmlrpcCodec.RegisterAlias("supervisor.restartProcess", "Supervisor.RestartProcess")
+	xmlrpcCodec.RegisterAlias("supervisor.restartProcessGroup", "Supervisor.RestartProcessGroup")
+	xmlrpcCodec.RegisterAlias("supervisor.restartAllProcesses", "Supervisor.RestartAllProcesses")
+	xmlrpcCodec.RegisterAlias("supervisor.rollingRestartGroup", "Supervisor.RollingRestartGroup")
+	return RPC, xmlrpcCodec
+}
+
+// create the JSON-RPC 2.0 server which serves the same methods as the XML-RPC server,
+// the method names are like "Supervisor.GetAllProcessInfo"
+func (p *XMLRPC) createJSONRPCServer(s *Supervisor) (*rpc2.Server, *json2.Codec) {
+	RPC := rpc2.NewServer()
+	jsonrpcCodec := json2.NewCodec()
+	RPC.RegisterCodec(jsonrpcCodec, "application/json")
+	RPC.RegisterService(s, "")
+	return RPC, jsonrpcCodec
 }
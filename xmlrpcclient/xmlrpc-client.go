@@ -44,6 +44,14 @@ type AllProcessInfoReply struct {
 	Value []types.ProcessInfo
 }
 
+// TailLogReply the log of a program read from an offset
+type TailLogReply struct {
+	LogData  string
+	Offset   int
+	Overflow bool
+	Binary   bool // true if the LogData is encoded with base64 because it is not valid UTF-8 text
+}
+
 var emptyReader io.ReadCloser
 
 func init() {
@@ -196,7 +204,7 @@ func (r *XMLRPCClient) GetVersion() (reply VersionReply, err error) {
 	r.post("supervisor.getVersion", &ins, func(body io.ReadCloser, procError error) {
 		err = procError
 		if err == nil {
-			err = xml.DecodeClientResponse(body, reply)
+			err = decodeClientResponse(body, &reply)
 		}
 	})
 	return
@@ -208,7 +216,7 @@ func (r *XMLRPCClient) GetAllProcessInfo() (reply AllProcessInfoReply, err error
 	r.post("supervisor.getAllProcessInfo", &ins, func(body io.ReadCloser, procError error) {
 		err = procError
 		if err == nil {
-			err = xml.DecodeClientResponse(body, &reply)
+			err = decodeClientResponse(body, &reply)
 		}
 	})
 
@@ -226,7 +234,7 @@ func (r *XMLRPCClient) ChangeProcessState(change string, processName string) (re
 	r.post(fmt.Sprintf("supervisor.%sProcess", change), &ins, func(body io.ReadCloser, procError error) {
 		err = procError
 		if err == nil {
-			err = xml.DecodeClientResponse(body, &reply)
+			err = decodeClientResponse(body, &reply)
 		}
 	})
 
@@ -243,7 +251,7 @@ func (r *XMLRPCClient) ChangeAllProcessState(change string) (reply AllProcessInf
 	r.post(fmt.Sprintf("supervisor.%sAllProcesses", change), &ins, func(body io.ReadCloser, procError error) {
 		err = procError
 		if err == nil {
-			err = xml.DecodeClientResponse(body, &reply)
+			err = decodeClientResponse(body, &reply)
 		}
 	})
 	return
@@ -255,7 +263,7 @@ func (r *XMLRPCClient) Shutdown() (reply ShutdownReply, err error) {
 	r.post("supervisor.shutdown", &ins, func(body io.ReadCloser, procError error) {
 		err = procError
 		if err == nil {
-			err = xml.DecodeClientResponse(body, &reply)
+			err = decodeClientResponse(body, &reply)
 		}
 
 	})
@@ -271,18 +279,22 @@ func (r *XMLRPCClient) ReloadConfig() (reply types.ReloadConfigResult, err error
 	reply.AddedGroup = make([]string, 0)
 	reply.ChangedGroup = make([]string, 0)
 	reply.RemovedGroup = make([]string, 0)
-	i := -1
-	hasValue := false
-	xmlProcMgr.AddNonLeafProcessor("methodResponse/params/param/value/array/data", func() {
-		if hasValue {
-			hasValue = false
-		} else {
-			i++
-		}
+	reply.AddedPrograms = make([]string, 0)
+	reply.RemovedPrograms = make([]string, 0)
+	reply.RestartedPrograms = make([]string, 0)
+	reply.PreservedPrograms = make([]string, 0)
+	reply.Warnings = make([]string, 0)
+	// the index of the array param, the end of an array with values is processed as a leaf
+	// because the value of its last element is kept
+	i := 0
+	nextParam := func() {
+		i++
+	}
+	xmlProcMgr.AddNonLeafProcessor("methodResponse/params/param/value/array/data", nextParam)
+	xmlProcMgr.AddLeafProcessor("methodResponse/params/param/value/array/data", func(value string) {
+		nextParam()
 	})
 	xmlProcMgr.AddLeafProcessor("methodResponse/params/param/value/array/data/value", func(value string) {
-		hasValue = true
-		i++
 		switch i {
 		case 0:
 			reply.AddedGroup = append(reply.AddedGroup, value)
@@ -290,6 +302,16 @@ func (r *XMLRPCClient) ReloadConfig() (reply types.ReloadConfigResult, err error
 			reply.ChangedGroup = append(reply.ChangedGroup, value)
 		case 2:
 			reply.RemovedGroup = append(reply.RemovedGroup, value)
+		case 3:
+			reply.AddedPrograms = append(reply.AddedPrograms, value)
+		case 4:
+			reply.RemovedPrograms = append(reply.RemovedPrograms, value)
+		case 5:
+			reply.RestartedPrograms = append(reply.RestartedPrograms, value)
+		case 6:
+			reply.PreservedPrograms = append(reply.PreservedPrograms, value)
+		case 7:
+			reply.Warnings = append(reply.Warnings, value)
 		}
 	})
 	r.post("supervisor.reloadConfig", &ins, func(body io.ReadCloser, procError error) {
@@ -307,7 +329,7 @@ func (r *XMLRPCClient) SignalProcess(signal string, name string) (reply types.Bo
 	r.post("supervisor.signalProcess", &ins, func(body io.ReadCloser, procError error) {
 		err = procError
 		if err == nil {
-			err = xml.DecodeClientResponse(body, &reply)
+			err = decodeClientResponse(body, &reply)
 		}
 	})
 	return
@@ -319,7 +341,7 @@ func (r *XMLRPCClient) SignalAll(signal string) (reply AllProcessInfoReply, err
 	r.post("supervisor.signalProcess", &ins, func(body io.ReadCloser, procError error) {
 		err = procError
 		if err == nil {
-			err = xml.DecodeClientResponse(body, &reply)
+			err = decodeClientResponse(body, &reply)
 		}
 	})
 
@@ -333,7 +355,7 @@ func (r *XMLRPCClient) GetProcessInfo(process string) (reply types.ProcessInfo,
 	r.post("supervisor.getProcessInfo", &ins, func(body io.ReadCloser, procError error) {
 		err = procError
 		if err == nil {
-			err = xml.DecodeClientResponse(body, &result)
+			err = decodeClientResponse(body, &result)
 			if err == nil {
 				reply = result.Reply
 			} else if r.verbose {
@@ -344,3 +366,24 @@ func (r *XMLRPCClient) GetProcessInfo(process string) (reply types.ProcessInfo,
 
 	return
 }
+
+// TailProcessLog read at most length bytes of the stdout or stderr log of the program from the
+// offset. The offset in the reply is where the next read starts
+func (r *XMLRPCClient) TailProcessLog(name string, stream string, offset int, length int) (reply TailLogReply, err error) {
+	method := "supervisor.tailProcessStdoutLog"
+	if stream == "stderr" {
+		method = "supervisor.tailProcessStderrLog"
+	}
+	ins := struct {
+		Name   string
+		Offset int
+		Length int
+	}{name, offset, length}
+	r.post(method, &ins, func(body io.ReadCloser, procError error) {
+		err = procError
+		if err == nil {
+			err = decodeClientResponse(body, &reply)
+		}
+	})
+	return
+}
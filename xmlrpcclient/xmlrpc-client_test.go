@@ -0,0 +1,53 @@
+package xmlrpcclient
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ochinchina/gorilla-xmlrpc/xml"
+	"github.com/ochinchina/supervisord/types"
+)
+
+func TestDecodeSnakeCaseMembers(t *testing.T) {
+	response := `<methodResponse><params><param><value><struct>` +
+		`<member><name>name</name><value><string>web</string></value></member>` +
+		`<member><name>next_retry_at</name><value><int>100</int></value></member>` +
+		`<member><name>last_error</name><value><string></string></value></member>` +
+		`<member><name>health_check</name><value><string>passing</string></value></member>` +
+		`</struct></value></param></params></methodResponse>`
+	var result struct{ Value types.ProcessInfo }
+	if err := decodeClientResponse(strings.NewReader(response), &result); err != nil {
+		t.Fatalf("fail to decode the response: %v", err)
+	}
+	if result.Value.Name != "web" || result.Value.NextRetryAt != 100 || result.Value.LastError != "" || result.Value.HealthCheck != "passing" {
+		t.Errorf("fail to decode the process info: %+v", result.Value)
+	}
+}
+
+func TestDecodeEmptyStringsAndArrays(t *testing.T) {
+	response := `<methodResponse><params><param><value><array><data>` +
+		`<value><struct><member><name>name</name><value>web</value></member>` +
+		`<member><name>description</name><value><string></string></value></member>` +
+		`<member><name>stdout_logfile</name><value><string>&lt;string&gt;&lt;/string&gt;</string></value></member>` +
+		`<member><name>added_by_newer_server</name><value><int>1</int></value></member>` +
+		`</struct></value></data></array></value></param></params></methodResponse>`
+	var reply AllProcessInfoReply
+	if err := decodeClientResponse(strings.NewReader(response), &reply); err != nil {
+		t.Fatalf("fail to decode the response: %v", err)
+	}
+	if len(reply.Value) != 1 || reply.Value[0].Name != "web" || reply.Value[0].Description != "" || reply.Value[0].StdoutLogfile != "<string></string>" {
+		t.Errorf("fail to decode the process infos: %+v", reply.Value)
+	}
+}
+
+func TestDecodeFault(t *testing.T) {
+	response := `<methodResponse><fault><value><struct>` +
+		`<member><name>faultCode</name><value><int>10</int></value></member>` +
+		`<member><name>faultString</name><value><string>BAD_NAME</string></value></member>` +
+		`</struct></value></fault></methodResponse>`
+	var reply StartStopReply
+	err := decodeClientResponse(strings.NewReader(response), &reply)
+	if fault, ok := err.(xml.Fault); !ok || fault.Code != 10 || fault.String != "BAD_NAME" {
+		t.Errorf("expect the fault in the response but got %v", err)
+	}
+}
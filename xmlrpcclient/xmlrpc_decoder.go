@@ -0,0 +1,216 @@
+package xmlrpcclient
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	rpcxml "github.com/ochinchina/gorilla-xmlrpc/xml"
+)
+
+// the xml-rpc response decoded by decodeClientResponse
+type rpcResponse struct {
+	Params []rpcParam `xml:"params>param"`
+	Fault  *rpcValue  `xml:"fault>value"`
+}
+
+type rpcParam struct {
+	Value rpcValue `xml:"value"`
+}
+
+// the typed values are pointers, so an empty <string></string> is not taken as an absent value
+type rpcValue struct {
+	Array    *rpcArray   `xml:"array"`
+	Struct   []rpcMember `xml:"struct>member"`
+	String   *string     `xml:"string"`
+	Int      *string     `xml:"int"`
+	Int4     *string     `xml:"i4"`
+	Double   *string     `xml:"double"`
+	Boolean  *string     `xml:"boolean"`
+	DateTime *string     `xml:"dateTime.iso8601"`
+	Base64   *string     `xml:"base64"`
+	Nil      *struct{}   `xml:"nil"`
+	Raw      string      `xml:",chardata"`
+}
+
+type rpcArray struct {
+	Values []rpcValue `xml:"data>value"`
+}
+
+type rpcMember struct {
+	Name  string   `xml:"name"`
+	Value rpcValue `xml:"value"`
+}
+
+// decode the response like xml.DecodeClientResponse(), the params are set to the fields of the
+// reply in order. Unlike xml.DecodeClientResponse(), the struct members are mapped to the fields
+// by the xml tags of the fields, like the server encodes them, and the empty strings are decoded
+// as empty strings
+func decodeClientResponse(body io.Reader, reply interface{}) error {
+	replyValue := reflect.ValueOf(reply)
+	if replyValue.Kind() != reflect.Ptr || replyValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("the reply must be a pointer to struct but it is %T", reply)
+	}
+	var response rpcResponse
+	if err := xml.NewDecoder(body).Decode(&response); err != nil {
+		return rpcxml.FaultDecode
+	}
+	if response.Fault != nil {
+		return decodeFault(response.Fault)
+	}
+	replyValue = replyValue.Elem()
+	for i := 0; i < replyValue.NumField() && i < len(response.Params); i++ {
+		if err := decodeValue(&response.Params[i].Value, replyValue.Field(i)); err != nil {
+			return fmt.Errorf("fail to decode %s: %v", replyValue.Type().Field(i).Name, err)
+		}
+	}
+	return nil
+}
+
+func decodeFault(value *rpcValue) error {
+	fault := rpcxml.Fault{}
+	for i := range value.Struct {
+		member := &value.Struct[i]
+		switch member.Name {
+		case "faultCode":
+			if code, err := decodeInt(&member.Value); err == nil {
+				fault.Code = int(code)
+			}
+		case "faultString":
+			fault.String = decodeString(&member.Value)
+		}
+	}
+	return fault
+}
+
+// decode the value to the field, the type of the value must match the field
+func decodeValue(value *rpcValue, field reflect.Value) error {
+	if value.Nil != nil {
+		return nil
+	}
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		if value.DateTime == nil {
+			return fmt.Errorf("expect dateTime.iso8601 for %s", field.Type())
+		}
+		t, err := time.ParseInLocation("20060102T15:04:05", strings.TrimSpace(*value.DateTime), time.Local)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := decodeInt(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		if value.Double == nil {
+			return fmt.Errorf("expect double for %s", field.Type())
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(*value.Double), 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		if value.Boolean == nil {
+			return fmt.Errorf("expect boolean for %s", field.Type())
+		}
+		b := strings.TrimSpace(*value.Boolean)
+		field.SetBool(b == "1" || strings.EqualFold(b, "true"))
+	case reflect.String:
+		if !isStringValue(value) {
+			return fmt.Errorf("expect string for %s", field.Type())
+		}
+		field.SetString(decodeString(value))
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 && value.Base64 != nil {
+			b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(*value.Base64))
+			if err != nil {
+				return err
+			}
+			field.SetBytes(b)
+			return nil
+		}
+		if value.Array == nil {
+			return fmt.Errorf("expect array for %s", field.Type())
+		}
+		slice := reflect.MakeSlice(field.Type(), len(value.Array.Values), len(value.Array.Values))
+		for i := range value.Array.Values {
+			if err := decodeValue(&value.Array.Values[i], slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+	case reflect.Struct:
+		for i := range value.Struct {
+			member := &value.Struct[i]
+			// the members unknown to the client, like the ones added by a newer server, are ignored
+			if f, ok := findMemberField(field, member.Name); ok {
+				if err := decodeValue(&member.Value, f); err != nil {
+					return fmt.Errorf("member %s: %v", member.Name, err)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// find the field of the struct member by the xml tag of the field, or by the field name with the
+// first letter in upper case if the field has no xml tag
+func findMemberField(structValue reflect.Value, name string) (reflect.Value, bool) {
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		fieldType := structType.Field(i)
+		if fieldType.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(fieldType.Tag.Get("xml"), ",")[0]
+		if tag == name || (tag == "" && fieldType.Name == uppercaseFirst(name)) {
+			return structValue.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func uppercaseFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+func decodeInt(value *rpcValue) (int64, error) {
+	s := value.Int
+	if s == nil {
+		s = value.Int4
+	}
+	if s == nil {
+		return 0, fmt.Errorf("expect int")
+	}
+	return strconv.ParseInt(strings.TrimSpace(*s), 10, 64)
+}
+
+// check if the value is a string, the value without type is a string too
+func isStringValue(value *rpcValue) bool {
+	return value.String != nil || (value.Int == nil && value.Int4 == nil && value.Double == nil &&
+		value.Boolean == nil && value.DateTime == nil && value.Base64 == nil &&
+		value.Array == nil && len(value.Struct) == 0)
+}
+
+func decodeString(value *rpcValue) string {
+	if value.String != nil {
+		return *value.String
+	}
+	return value.Raw
+}